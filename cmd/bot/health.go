@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/metrics"
+)
+
+// healthCheckTimeout bounds each readiness check
+const healthCheckTimeout = 5 * time.Second
+
+// healthCheck reports whether a dependency of the bot is usable
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// serveHealth serves the probes used by Docker and Kubernetes on addr until
+// ctx is done:
+//   - /healthz answers as long as the process is up
+//   - /readyz runs every check and fails if any of them does
+func serveHealth(ctx context.Context, addr string, checks []healthCheck) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		results, ready := runHealthChecks(r.Context(), checks)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(results)
+	})
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("Health server listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Error serving health checks: %v", err)
+	}
+}
+
+// runHealthChecks runs the checks concurrently and returns "ok" or the error
+// of each, and whether all passed
+func runHealthChecks(ctx context.Context, checks []healthCheck) (map[string]string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]string, len(checks))
+		ready   = true
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("Health check %s failed: %v", c.name, err)
+				results[c.name] = err.Error()
+				ready = false
+				return
+			}
+			results[c.name] = "ok"
+		}()
+	}
+	wg.Wait()
+
+	return results, ready
+}
@@ -1,18 +1,33 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
 
+	"github.com/escalopa/quran-read-bot/internal/adapter/analytics"
+	"github.com/escalopa/quran-read-bot/internal/adapter/disk"
+	"github.com/escalopa/quran-read-bot/internal/adapter/ffmpeg"
 	"github.com/escalopa/quran-read-bot/internal/adapter/i18n"
 	"github.com/escalopa/quran-read-bot/internal/adapter/quranapi"
 	"github.com/escalopa/quran-read-bot/internal/adapter/redis"
+	"github.com/escalopa/quran-read-bot/internal/adapter/s3"
+	"github.com/escalopa/quran-read-bot/internal/adapter/sqlstore"
 	"github.com/escalopa/quran-read-bot/internal/adapter/telegram"
+	"github.com/escalopa/quran-read-bot/internal/adapter/web"
 	"github.com/escalopa/quran-read-bot/internal/application"
 	"github.com/escalopa/quran-read-bot/internal/config"
+	"github.com/escalopa/quran-read-bot/internal/domain"
 )
 
 func main() {
@@ -22,10 +37,15 @@ func main() {
 }
 
 func run() error {
-	// Load configuration
+	// Load configuration. Without CONFIG_PATH, config.yaml is optional and
+	// the environment may provide everything.
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
 		configPath = "config.yaml"
+		if _, err := os.Stat(configPath); errors.Is(err, fs.ErrNotExist) {
+			log.Println("No config.yaml found, reading configuration from the environment only")
+			configPath = ""
+		}
 	}
 
 	cfg, err := config.Load(configPath)
@@ -35,6 +55,12 @@ func run() error {
 
 	log.Println("Configuration loaded successfully")
 
+	// Verify the dependencies up front and report every problem at once,
+	// rather than the first one when a user gets to it
+	if err := selfTest(context.Background(), startupChecks(cfg)); err != nil {
+		return err
+	}
+
 	// Initialize i18n
 	i18nService, err := i18n.NewI18n(cfg.App.LocalesDir)
 	if err != nil {
@@ -42,54 +68,315 @@ func run() error {
 	}
 	log.Println("i18n initialized")
 
-	// Initialize Redis FSM
-	fsm, err := redis.NewFSM(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
-	if err != nil {
-		return err
+	// Connect the session storage shared by the bots
+	var checks []healthCheck
+	limits := make(map[domain.RateAction]domain.RateLimit)
+	for action, l := range cfg.RateLimits {
+		limits[domain.RateAction(action)] = domain.RateLimit{Requests: l.Requests, Per: l.Per, Burst: l.Burst}
+	}
+	var redisClient *redis.Client
+	switch cfg.FSM.Driver {
+	case config.FSMDriverMemory:
+		log.Println("In-memory FSM initialized")
+	default:
+		redisClient, err = redis.NewClient(redisOptions(cfg))
+		if err != nil {
+			return err
+		}
+		defer redisClient.Close()
+		checks = append(checks, healthCheck{name: "redis", check: func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}})
+		log.Println("Redis FSM connected")
 	}
-	defer fsm.Close()
-	log.Println("Redis FSM connected")
 
 	// Initialize Quran API client
-	quranAPIClient := quranapi.NewClient(cfg.QuranAPI.BaseURL, cfg.QuranAPI.APIKey)
+	rawAPIClient := quranapi.NewClient(cfg.QuranAPI.BaseURL, cfg.QuranAPI.APIKey)
+	quranAPIClient := quranapi.NewCachedClient(rawAPIClient, cfg.QuranAPI.CacheSize)
+	checks = append(checks, healthCheck{name: "quran_api", check: rawAPIClient.Ping})
 	log.Println("Quran API client initialized")
 
-	// Initialize application service
-	botService := application.NewBotService(quranAPIClient, fsm, i18nService)
-	log.Println("Bot service initialized")
+	// Options shared by the services of all bots, which tell the time on
+	// the same clock as the stores
+	clock := domain.SystemClock{}
+	sharedOpts := []application.Option{application.WithClock(clock)}
+	adminIDs := make([]string, 0, len(cfg.App.AdminIDs))
+	for _, id := range cfg.App.AdminIDs {
+		adminIDs = append(adminIDs, strconv.FormatInt(id, 10))
+	}
+	sharedOpts = append(sharedOpts, application.WithAdmins(adminIDs...))
+
+	gradingScale, err := newGradingScale(cfg.Grading)
+	if err != nil {
+		return fmt.Errorf("grading scale: %w", err)
+	}
+	sharedOpts = append(sharedOpts, application.WithGradingScale(gradingScale))
+
+	curatedWird, err := parseAyahs(cfg.Wird.Curated)
+	if err != nil {
+		return fmt.Errorf("curated wird: %w", err)
+	}
+	sharedOpts = append(sharedOpts, application.WithCuratedWird(curatedWird))
+
+	// Load the text typed ayahs are checked against
+	if len(cfg.QuranText.Files) > 0 {
+		texts, err := disk.NewAyahTexts(cfg.QuranText.Files)
+		if err != nil {
+			return fmt.Errorf("quran text: %w", err)
+		}
+		sharedOpts = append(sharedOpts, application.WithAyahTexts(texts))
+		log.Printf("Typing ayahs enabled for %d riwayat", len(cfg.QuranText.Files))
+	}
+
+	if cfg.QuranAPI.AutoDetect {
+		sharedOpts = append(sharedOpts, application.WithSpokenNavigation(), application.WithWordDrills())
+		log.Println("Spoken navigation and word drills enabled")
+	}
+
+	// Initialize persistent user store
+	var store *sqlstore.Store
+	if cfg.Database.DSN != "" {
+		switch cfg.Database.Driver {
+		case config.DatabaseDriverSQLite:
+			store, err = sqlstore.NewSQLite(cfg.Database.DSN, clock)
+		default:
+			store, err = sqlstore.NewPostgres(cfg.Database.DSN, clock)
+		}
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		sharedOpts = append(sharedOpts, application.WithUserRepository(store))
+		log.Printf("%s user store connected", cfg.Database.Driver)
+	}
+
+	// Initialize the analytics sink
+	switch cfg.Analytics.Sink {
+	case config.AnalyticsSinkFile:
+		fileSink, err := analytics.NewFileSink(cfg.Analytics.File)
+		if err != nil {
+			return err
+		}
+		defer fileSink.Close()
+		sharedOpts = append(sharedOpts, application.WithAnalytics(fileSink))
+	case config.AnalyticsSinkDatabase:
+		sharedOpts = append(sharedOpts, application.WithAnalytics(store))
+	case config.AnalyticsSinkHTTP:
+		sharedOpts = append(sharedOpts, application.WithAnalytics(analytics.NewHTTPSink(cfg.Analytics.URL, cfg.Analytics.Token)))
+	}
+	if cfg.Analytics.Sink != "" {
+		log.Printf("Analytics events written to the %s sink", cfg.Analytics.Sink)
+	}
+
+	// Initialize the cache of the latest submission of each user
+	if cfg.Audio.Cache.Dir != "" {
+		audioCache, err := disk.NewAudioCache(cfg.Audio.Cache.Dir, cfg.Audio.Cache.TTL, clock)
+		if err != nil {
+			return err
+		}
+		defer audioCache.Close()
+		sharedOpts = append(sharedOpts, application.WithAudioCache(audioCache))
+		log.Printf("Audio cache enabled in %s", cfg.Audio.Cache.Dir)
+	}
+
+	// Initialize the archive of submitted audio
+	if cfg.Archive.Bucket != "" {
+		archive, err := s3.NewArchive(s3.Options{
+			Endpoint:  cfg.Archive.Endpoint,
+			Region:    cfg.Archive.Region,
+			Bucket:    cfg.Archive.Bucket,
+			AccessKey: cfg.Archive.AccessKey,
+			SecretKey: cfg.Archive.SecretKey,
+		}, cfg.Archive.Prefix)
+		if err != nil {
+			return err
+		}
+		sharedOpts = append(sharedOpts, application.WithAudioArchive(archive))
+		log.Printf("Audio archive enabled in bucket %s", cfg.Archive.Bucket)
+	}
 
-	// Initialize Telegram bot
-	bot, err := telegram.NewBot(cfg.Telegram.Token, botService, i18nService)
+	// Initialize the audio processor
+	var audioFilters []string
+	if cfg.Audio.Loudnorm.Enabled {
+		audioFilters = append(audioFilters, ffmpeg.LoudnormFilter(cfg.Audio.Loudnorm.TargetLUFS))
+	}
+	audioProcessor, err := ffmpeg.NewProcessor(ffmpeg.Options{
+		Filters:         audioFilters,
+		Denoise:         cfg.Audio.DenoiseFilter,
+		Output:          ffmpeg.Output{Format: cfg.Audio.Output.Format, SampleRate: cfg.Audio.Output.SampleRate, Channels: cfg.Audio.Output.Channels},
+		MaxFileSize:     cfg.Audio.MaxFileSize,
+		Passthrough:     cfg.Audio.PassthroughOGG,
+		NativeOpus:      cfg.Audio.Decoder == config.AudioDecoderNative,
+		RejectNonSpeech: cfg.Audio.RejectNonSpeech,
+	})
 	if err != nil {
 		return err
 	}
-	log.Println("Telegram bot initialized")
+	sharedOpts = append(sharedOpts, application.WithAudioProcessor(audioProcessor))
+	checks = append(checks, healthCheck{name: "ffmpeg", check: audioProcessor.Ping})
+
+	if cfg.App.LogTransitions {
+		sharedOpts = append(sharedOpts, application.WithTransitionHook(application.LogTransitions))
+	}
+
+	// Initialize a service and a Telegram bot per configured bot, each with
+	// its own stores
+	var (
+		services []*application.BotService
+		bots     []*telegram.Bot
+		webhooks = make(map[string]http.Handler)
+	)
+	for _, botCfg := range cfg.Telegram.Bots {
+		var stores botStores
+		if redisClient != nil {
+			stores = newRedisStores(redisClient.WithNamespace(botCfg.Namespace), limits, clock)
+		} else {
+			stores = newMemoryStores(limits, clock)
+		}
+		defer stores.close()
+
+		// The user store, when configured, replaces the per-bot preferences,
+		// history, pins and tags, so users keep them across bots
+		prefs, stats, pins, tags := stores.prefs, stores.stats, stores.pins, stores.tags
+		if store != nil {
+			prefs, stats, pins, tags = store, store, store, store
+		}
+
+		serviceOpts := append([]application.Option{
+			application.WithEventBus(stores.bus),
+			application.WithRecordingCache(stores.cache),
+			application.WithLeaderboard(stores.board),
+			application.WithRateLimiter(stores.limiter),
+			application.WithAuditLog(stores.audit),
+			application.WithStatsStore(stats),
+			application.WithActivity(stores.activity),
+			application.WithRecordingLifecycle(stores.lifecycles),
+			application.WithGoals(stores.goals),
+			application.WithPositions(stores.positions),
+			application.WithRecentSurahs(stores.recentSurahs),
+			application.WithChallenges(stores.challenges),
+			application.WithWirds(stores.wirds),
+			application.WithKhatmahs(stores.khatmahs),
+			application.WithPins(pins),
+			application.WithTags(tags),
+			application.WithChunkedSubmission(cfg.Audio.ChunkDuration, stores.chunks),
+		}, sharedOpts...)
+		botService := application.NewBotService(quranAPIClient, stores.fsm, prefs, i18nService, serviceOpts...)
+
+		botOpts := []telegram.BotOption{
+			telegram.WithTranscodeLimit(cfg.Audio.MaxConcurrentJobs, cfg.Audio.MaxQueuedJobs),
+			telegram.WithAudioLimits(cfg.Audio.MaxDuration, cfg.Audio.MaxFileSize),
+			telegram.WithReferenceAudio(cfg.Audio.ReferenceURL),
+			telegram.WithUpdateLog(stores.updates),
+			telegram.WithRateLimiter(stores.limiter),
+		}
+		if botCfg.WebhookURL != "" {
+			botOpts = append(botOpts, telegram.WithWebhook(botCfg.WebhookURL))
+		}
+		bot, err := telegram.NewBot(botCfg.Token, botService, i18nService, stores.locks, botOpts...)
+		if err != nil {
+			return fmt.Errorf("bot %s: %w", botCfg.Name, err)
+		}
+		if botCfg.WebhookURL != "" {
+			webhookURL, _ := url.Parse(botCfg.WebhookURL)
+			webhooks[cmp.Or(webhookURL.Path, "/")] = bot
+		}
+		log.Printf("Telegram bot %s initialized", botCfg.Name)
+
+		checkName := "telegram"
+		if len(cfg.Telegram.Bots) > 1 {
+			checkName += "_" + botCfg.Name
+		}
+		checks = append(checks, healthCheck{name: checkName, check: bot.Ping})
+
+		services = append(services, botService)
+		bots = append(bots, bot)
+	}
+
+	// Alerts and the dashboard go through the first bot
+	botService, bot := services[0], bots[0]
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Receive updates pushed by Telegram
+	if cfg.Telegram.Webhook.URL != "" {
+		go serveWebhook(ctx, cfg.Telegram.Webhook.Addr, webhooks)
+	}
+
+	// Serve health and readiness probes
+	if cfg.Health.Addr != "" {
+		go serveHealth(ctx, cfg.Health.Addr, checks)
+	}
+
+	// Alert operators of outages
+	if cfg.Alerts.ChatID != 0 {
+		alerts := &watchdog{
+			checks:    checks,
+			rates:     botService.ErrorRates,
+			panics:    botService.Panics,
+			notify:    func(text string) error { return bot.Notify(cfg.Alerts.ChatID, text) },
+			interval:  cfg.Alerts.Interval,
+			window:    cfg.Alerts.Window,
+			threshold: cfg.Alerts.ErrorRate,
+			minOps:    cfg.Alerts.MinOperations,
+		}
+		go alerts.run(ctx)
+	}
+
+	// Serve the admin dashboard
+	if cfg.Dashboard.Addr != "" {
+		dashboard := web.NewDashboard(web.Options{
+			Addr:     cfg.Dashboard.Addr,
+			Username: cfg.Dashboard.Username,
+			Password: cfg.Dashboard.Password,
+		}, botService, bot)
+		go dashboard.Run(ctx)
+	}
+
+	// Serve profiles for troubleshooting
+	if cfg.Debug.Pprof {
+		go servePprof(ctx, cfg.Debug.PprofPort)
+	}
+
+	// Write analytics events in the background
+	var analyticsDone sync.WaitGroup
+	for _, service := range services {
+		analyticsDone.Add(1)
+		go func() {
+			defer analyticsDone.Done()
+			service.RunAnalytics(ctx)
+		}()
+	}
+
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	// Start bot in a goroutine
-	errChan := make(chan error, 1)
-	go func() {
-		log.Println("Starting bot...")
-		if err := bot.Start(ctx); err != nil {
-			errChan <- err
-		}
-	}()
+	// Start the bots in goroutines
+	errChan := make(chan error, len(bots))
+	for i, bot := range bots {
+		name := cfg.Telegram.Bots[i].Name
+		go func() {
+			log.Printf("Starting bot %s...", name)
+			if err := bot.Start(ctx); err != nil {
+				errChan <- fmt.Errorf("bot %s: %w", name, err)
+			}
+		}()
+	}
 
 	// Wait for shutdown signal or error
 	select {
 	case <-sigChan:
-		log.Println("Received shutdown signal, stopping bot...")
+		log.Println("Received shutdown signal, stopping bots...")
 		cancel()
-		if err := bot.Stop(); err != nil {
-			log.Printf("Error stopping bot: %v", err)
+		for _, bot := range bots {
+			if err := bot.Stop(); err != nil {
+				log.Printf("Error stopping bot: %v", err)
+			}
 		}
+		analyticsDone.Wait()
 	case err := <-errChan:
 		log.Printf("Bot error: %v", err)
 		return err
@@ -98,3 +385,64 @@ func run() error {
 	log.Println("Bot stopped successfully")
 	return nil
 }
+
+// newGradingScale returns the grades of cfg, or its predefined scale when
+// it defines none
+func newGradingScale(cfg config.GradingConfig) (domain.GradingScale, error) {
+	if len(cfg.Grades) == 0 {
+		scale, ok := domain.GradingScalePreset(cfg.Scale)
+		if !ok {
+			return nil, fmt.Errorf("unknown scale %s", cfg.Scale)
+		}
+		return scale, nil
+	}
+	grades := make([]domain.Grade, 0, len(cfg.Grades))
+	for _, grade := range cfg.Grades {
+		grades = append(grades, domain.Grade{Name: grade.Name, MinAccuracy: grade.MinAccuracy})
+	}
+	return domain.NewGradingScale(grades)
+}
+
+// parseAyahs parses ayahs formatted as "surah:ayah", e.g. "2:255"
+func parseAyahs(ids []string) ([]domain.Ayah, error) {
+	ayahs := make([]domain.Ayah, 0, len(ids))
+	for _, id := range ids {
+		ayah, err := domain.ParseAyahID(id)
+		if err != nil {
+			return nil, err
+		}
+		ayahs = append(ayahs, ayah)
+	}
+	return ayahs, nil
+}
+
+// redisOptions returns the options of the Redis client of cfg
+func redisOptions(cfg *config.Config) redis.Options {
+	return redis.Options{
+		URL:      cfg.Redis.URL,
+		Addr:     cfg.Redis.Addr,
+		Username: cfg.Redis.Username,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		TLS: redis.TLSOptions{
+			Enabled:            cfg.Redis.TLS.Enabled,
+			CAFile:             cfg.Redis.TLS.CAFile,
+			CertFile:           cfg.Redis.TLS.CertFile,
+			KeyFile:            cfg.Redis.TLS.KeyFile,
+			ServerName:         cfg.Redis.TLS.ServerName,
+			InsecureSkipVerify: cfg.Redis.TLS.InsecureSkipVerify,
+		},
+		Pool: redis.PoolOptions{
+			PoolSize:        cfg.Redis.Pool.Size,
+			MinIdleConns:    cfg.Redis.Pool.MinIdleConns,
+			PoolTimeout:     cfg.Redis.Pool.PoolTimeout,
+			DialTimeout:     cfg.Redis.Pool.DialTimeout,
+			ReadTimeout:     cfg.Redis.Pool.ReadTimeout,
+			WriteTimeout:    cfg.Redis.Pool.WriteTimeout,
+			MaxRetries:      cfg.Redis.Pool.MaxRetries,
+			MinRetryBackoff: cfg.Redis.Pool.MinRetryBackoff,
+			MaxRetryBackoff: cfg.Redis.Pool.MaxRetryBackoff,
+		},
+		Namespace: cfg.Redis.Namespace,
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+)
+
+// servePprof serves the net/http/pprof profiles on localhost only, until ctx
+// is done. Profiles are captured from the host, or through a port forward,
+// e.g. go tool pprof http://localhost:6060/debug/pprof/heap
+func servePprof(ctx context.Context, port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("pprof server listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Error serving pprof: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/adapter/ffmpeg"
+	"github.com/escalopa/quran-read-bot/internal/adapter/i18n"
+	"github.com/escalopa/quran-read-bot/internal/adapter/quranapi"
+	"github.com/escalopa/quran-read-bot/internal/adapter/redis"
+	"github.com/escalopa/quran-read-bot/internal/adapter/telegram"
+	"github.com/escalopa/quran-read-bot/internal/config"
+)
+
+// selfTestTimeout bounds the startup self-test
+const selfTestTimeout = 15 * time.Second
+
+// startupCheck is a dependency verified before the bot starts, with a hint
+// on how to fix it when it fails
+type startupCheck struct {
+	healthCheck
+	hint string
+}
+
+// startupChecks returns the dependencies of the bot configured by cfg
+func startupChecks(cfg *config.Config) []startupCheck {
+	checks := []startupCheck{
+		{
+			healthCheck: healthCheck{name: "locales", check: func(ctx context.Context) error {
+				_, err := i18n.NewI18n(cfg.App.LocalesDir)
+				return err
+			}},
+			hint: fmt.Sprintf("check that app.locales_dir (%q) holds en.yaml, ar.yaml and ru.yaml", cfg.App.LocalesDir),
+		},
+		{
+			healthCheck: healthCheck{name: "quran_api", check: quranapi.NewClient(cfg.QuranAPI.BaseURL, cfg.QuranAPI.APIKey).Ping},
+			hint:        "check quran_api.base_url and quran_api.api_key (QURAN_API_URL, QURAN_API_KEY)",
+		},
+	}
+	// Voice messages decoded natively need no ffmpeg
+	if cfg.Audio.Decoder != config.AudioDecoderNative {
+		checks = append(checks, startupCheck{
+			healthCheck: healthCheck{name: "ffmpeg", check: func(ctx context.Context) error {
+				return ffmpeg.CheckInstalled()
+			}},
+			hint: "install ffmpeg and make sure it is on the PATH, or set audio.decoder to \"native\"",
+		})
+	}
+	if cfg.FSM.Driver == config.FSMDriverRedis {
+		checks = append(checks, startupCheck{
+			healthCheck: healthCheck{name: "redis", check: func(ctx context.Context) error {
+				client, err := redis.NewClient(redisOptions(cfg))
+				if err != nil {
+					return err
+				}
+				return client.Close()
+			}},
+			hint: "check the redis address or url and credentials, or set fsm.driver to \"memory\" to run without Redis",
+		})
+	}
+	for _, bot := range cfg.Telegram.Bots {
+		name := "telegram"
+		if len(cfg.Telegram.Bots) > 1 {
+			name += "_" + bot.Name
+		}
+		checks = append(checks, startupCheck{
+			healthCheck: healthCheck{name: name, check: func(ctx context.Context) error {
+				return telegram.CheckToken(bot.Token)
+			}},
+			hint: "check the bot token from @BotFather (TELEGRAM_TOKEN) and that api.telegram.org is reachable",
+		})
+	}
+	return checks
+}
+
+// selfTest runs the checks concurrently and fails with all the problems
+// found, each with its hint
+func selfTest(ctx context.Context, checks []startupCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.check(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w; %s", c.name, err, c.hint)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("self-test found %d problem(s):\n%w", len(failed), errors.Join(failed...))
+	}
+	log.Printf("Self-test passed: %d dependencies checked", len(checks))
+	return nil
+}
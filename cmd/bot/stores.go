@@ -0,0 +1,92 @@
+package main
+
+import (
+	"github.com/escalopa/quran-read-bot/internal/adapter/memory"
+	"github.com/escalopa/quran-read-bot/internal/adapter/redis"
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// botStores are the session and state stores of one bot
+type botStores struct {
+	fsm          domain.FSMPort
+	prefs        domain.PreferencesPort
+	locks        domain.LockPort
+	updates      domain.UpdateLogPort
+	bus          domain.EventBusPort
+	cache        domain.RecordingCachePort
+	stats        domain.StatsPort
+	board        domain.LeaderboardPort
+	limiter      domain.RateLimiterPort
+	audit        domain.AuditLogPort
+	activity     domain.ActivityPort
+	lifecycles   domain.RecordingLifecyclePort
+	goals        domain.GoalPort
+	positions    domain.PositionPort
+	recentSurahs domain.RecentSurahPort
+	challenges   domain.ChallengePort
+	wirds        domain.WirdPort
+	khatmahs     domain.KhatmahPort
+	pins         domain.PinPort
+	tags         domain.TagPort
+	chunks       domain.RecordingChunkPort
+
+	close func()
+}
+
+// newMemoryStores keeps the state of a bot in process
+func newMemoryStores(limits map[domain.RateAction]domain.RateLimit, clock domain.Clock) botStores {
+	fsm := memory.NewFSM(clock)
+	return botStores{
+		fsm:          fsm,
+		prefs:        memory.NewPreferences(),
+		locks:        memory.NewLocker(clock),
+		updates:      memory.NewUpdateLog(clock),
+		bus:          memory.NewEventBus(),
+		cache:        memory.NewRecordingCache(),
+		stats:        memory.NewStats(),
+		board:        memory.NewLeaderboard(),
+		limiter:      memory.NewRateLimiter(limits, clock),
+		audit:        memory.NewAuditLog(),
+		activity:     memory.NewActivity(),
+		lifecycles:   memory.NewRecordingLifecycles(),
+		goals:        memory.NewGoals(),
+		positions:    memory.NewPositions(),
+		recentSurahs: memory.NewRecentSurahs(),
+		challenges:   memory.NewChallenges(clock),
+		wirds:        memory.NewWirds(),
+		khatmahs:     memory.NewKhatmahs(),
+		pins:         memory.NewPins(),
+		tags:         memory.NewTags(),
+		chunks:       memory.NewRecordingChunks(),
+		close:        func() { fsm.Close() },
+	}
+}
+
+// newRedisStores keeps the state of a bot in Redis, under the namespace of
+// client
+func newRedisStores(client *redis.Client, limits map[domain.RateAction]domain.RateLimit, clock domain.Clock) botStores {
+	return botStores{
+		fsm:          redis.NewFSM(client, clock),
+		prefs:        redis.NewPreferences(client),
+		locks:        redis.NewLocker(client),
+		updates:      redis.NewUpdateLog(client),
+		bus:          redis.NewEventBus(client),
+		cache:        redis.NewRecordingCache(client),
+		stats:        redis.NewStats(client),
+		board:        redis.NewLeaderboard(client),
+		limiter:      redis.NewRateLimiter(client, limits),
+		audit:        redis.NewAuditLog(client),
+		activity:     redis.NewActivity(client),
+		lifecycles:   redis.NewRecordingLifecycles(client),
+		goals:        redis.NewGoals(client),
+		positions:    redis.NewPositions(client),
+		recentSurahs: redis.NewRecentSurahs(client),
+		challenges:   redis.NewChallenges(client),
+		wirds:        redis.NewWirds(client),
+		khatmahs:     redis.NewKhatmahs(client),
+		pins:         redis.NewPins(client),
+		tags:         redis.NewTags(client),
+		chunks:       redis.NewRecordingChunks(client),
+		close:        func() {},
+	}
+}
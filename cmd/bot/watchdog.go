@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/application"
+)
+
+// watchdog periodically runs the readiness checks and looks at the error
+// rates of conversions and submissions and at recovered panics, and notifies operators when one of
+// them starts failing and once it recovers. Each replica watches and alerts
+// on its own.
+type watchdog struct {
+	checks    []healthCheck
+	rates     func(window time.Duration) []application.OperationStats
+	panics    func(window time.Duration) int
+	notify    func(text string) error
+	interval  time.Duration
+	window    time.Duration
+	threshold float64 // error rate above which an operation alerts
+	minOps    int     // operations needed in the window before rates count
+
+	// firing holds the problems currently alerted on, by name
+	firing map[string]bool
+}
+
+// run checks every interval until ctx is done
+func (w *watchdog) run(ctx context.Context) {
+	w.firing = make(map[string]bool)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w.check(ctx)
+	}
+}
+
+// check finds the current problems and alerts on the ones that changed
+func (w *watchdog) check(ctx context.Context) {
+	problems := make(map[string]string)
+
+	results, _ := runHealthChecks(ctx, w.checks)
+	for name, result := range results {
+		if result != "ok" {
+			problems[name] = fmt.Sprintf("%s check failing: %s", name, result)
+		}
+	}
+
+	for _, op := range w.rates(w.window) {
+		if op.Total >= w.minOps && op.ErrorRate() > w.threshold {
+			problems[op.Operation] = fmt.Sprintf("%.0f%% of %ss failed over the last %s (%d of %d)",
+				op.ErrorRate()*100, op.Operation, shortDuration(w.window), op.Failed, op.Total)
+		}
+	}
+
+	if panics := w.panics(w.window); panics > 0 {
+		problems["panic"] = fmt.Sprintf("%d panics recovered from over the last %s, see the logs for their stacks",
+			panics, shortDuration(w.window))
+	}
+
+	var lines []string
+	for name, problem := range problems {
+		if !w.firing[name] {
+			lines = append(lines, "🚨 "+problem)
+		}
+	}
+	for name := range w.firing {
+		if _, ok := problems[name]; !ok {
+			lines = append(lines, "✅ "+name+" back to normal")
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	sort.Strings(lines)
+
+	if err := w.notify(strings.Join(lines, "\n")); err != nil {
+		// Keep the previous state so the alert is sent again next time
+		log.Printf("Error sending alert: %v", err)
+		return
+	}
+	w.firing = make(map[string]bool, len(problems))
+	for name := range problems {
+		w.firing[name] = true
+	}
+}
+
+// shortDuration formats d without trailing zero units, e.g. 5m rather than
+// 5m0s
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// serveWebhook serves the webhooks of the bots on addr until ctx is done,
+// routing each path to the bot it was registered for
+func serveWebhook(ctx context.Context, addr string, bots map[string]http.Handler) {
+	mux := http.NewServeMux()
+	for path, bot := range bots {
+		mux.Handle("POST "+path, bot)
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("Webhook server listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Error serving webhook: %v", err)
+	}
+}
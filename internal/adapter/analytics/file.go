@@ -0,0 +1,55 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// FileSink appends analytics events to a file as JSON lines
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens path for appending, creating it and its directory if
+// needed
+func NewFileSink(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create analytics directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open analytics file: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+// WriteEvents appends the events, one JSON object per line
+func (f *FileSink) WriteEvents(ctx context.Context, events []domain.AnalyticsEvent) error {
+	var buf []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Write(buf); err != nil {
+		return fmt.Errorf("write events: %w", err)
+	}
+	return nil
+}
+
+func (f *FileSink) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// HTTPSink posts analytics events to a collector as a JSON array
+type HTTPSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTPSink posts to url, with token as a bearer token if not empty
+func NewHTTPSink(url, token string) *HTTPSink {
+	return &HTTPSink{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// WriteEvents posts the events in a single request
+func (h *HTTPSink) WriteEvents(ctx context.Context, events []domain.AnalyticsEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("encode events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post events: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post events: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package disk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const janitorInterval = time.Minute
+
+// AudioCache keeps the audio of each user's latest submission as a file in a
+// directory, so it can be resubmitted without recording again. Files older
+// than the TTL are ignored and removed by a janitor. The cache is local to
+// the instance: other replicas do not see it.
+type AudioCache struct {
+	dir   string
+	ttl   time.Duration
+	clock domain.Clock
+	done  chan struct{}
+}
+
+// NewAudioCache creates the directory if needed and starts the janitor
+func NewAudioCache(dir string, ttl time.Duration, clock domain.Clock) (*AudioCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create audio cache dir: %w", err)
+	}
+
+	c := &AudioCache{
+		dir:   dir,
+		ttl:   ttl,
+		clock: clock,
+		done:  make(chan struct{}),
+	}
+
+	go c.janitor()
+
+	return c, nil
+}
+
+func (c *AudioCache) Close() error {
+	close(c.done)
+	return nil
+}
+
+// PutAudio stores the audio of the user's latest submission, replacing the
+// previous one
+func (c *AudioCache) PutAudio(ctx context.Context, userID string, audio domain.CachedAudio) error {
+	path, err := c.path(userID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(audio)
+	if err != nil {
+		return fmt.Errorf("encode audio: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp, err := os.CreateTemp(c.dir, "audio-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write audio: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close audio file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("store audio: %w", err)
+	}
+	return nil
+}
+
+// GetAudio returns the audio of the user's latest submission, or nil if
+// there is none or it expired
+func (c *AudioCache) GetAudio(ctx context.Context, userID string) (*domain.CachedAudio, error) {
+	path, err := c.path(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stat audio: %w", err)
+	}
+	if c.clock.Now().Sub(info.ModTime()) > c.ttl {
+		os.Remove(path)
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read audio: %w", err)
+	}
+
+	var audio domain.CachedAudio
+	if err := json.Unmarshal(data, &audio); err != nil {
+		return nil, fmt.Errorf("decode audio: %w", err)
+	}
+	return &audio, nil
+}
+
+// DeleteAudio deletes the user's cached audio
+func (c *AudioCache) DeleteAudio(ctx context.Context, userID string) error {
+	path, err := c.path(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete audio: %w", err)
+	}
+	return nil
+}
+
+// path returns the file of a user's audio, refusing IDs that would escape
+// the cache directory
+func (c *AudioCache) path(userID string) (string, error) {
+	if userID == "" || strings.ContainsAny(userID, `/\.`) {
+		return "", fmt.Errorf("invalid user id: %q", userID)
+	}
+	return filepath.Join(c.dir, userID+".json"), nil
+}
+
+// janitor periodically removes expired files
+func (c *AudioCache) janitor() {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.evict()
+		}
+	}
+}
+
+// evict removes the files older than the TTL
+func (c *AudioCache) evict() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		log.Printf("Error reading audio cache dir: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || c.clock.Now().Sub(info.ModTime()) <= c.ttl {
+			continue
+		}
+		os.Remove(filepath.Join(c.dir, entry.Name()))
+	}
+}
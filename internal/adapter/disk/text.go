@@ -0,0 +1,79 @@
+package disk
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// AyahTexts holds the text of the Quran in each riwayah, read once from
+// files in the Tanzil format: one "surah|ayah|text" line per ayah, with
+// empty lines and "#" comments ignored
+type AyahTexts struct {
+	texts map[domain.Riwayah]map[domain.Ayah]string
+}
+
+// NewAyahTexts reads the file of each riwayah, e.g. "hafs"
+func NewAyahTexts(files map[string]string) (*AyahTexts, error) {
+	t := &AyahTexts{texts: make(map[domain.Riwayah]map[domain.Ayah]string, len(files))}
+	for name, path := range files {
+		riwayah := domain.Riwayah(name)
+		if !slices.Contains(domain.Riwayat, riwayah) {
+			return nil, fmt.Errorf("unknown riwayah: %s", name)
+		}
+		texts, err := readAyahTexts(path, riwayah)
+		if err != nil {
+			return nil, fmt.Errorf("read %s text: %w", name, err)
+		}
+		t.texts[riwayah] = texts
+	}
+	return t, nil
+}
+
+// AyahText returns the text of an ayah, or domain.ErrNoAyahText if its
+// riwayah or the ayah is missing from the files
+func (t *AyahTexts) AyahText(ctx context.Context, riwayah domain.Riwayah, ayah domain.Ayah) (string, error) {
+	text, ok := t.texts[riwayah][ayah]
+	if !ok {
+		return "", domain.ErrNoAyahText
+	}
+	return text, nil
+}
+
+func readAyahTexts(path string, riwayah domain.Riwayah) (map[domain.Ayah]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	texts := make(map[domain.Ayah]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, "|", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want surah|ayah|text", line)
+		}
+		surah, errSurah := strconv.Atoi(fields[0])
+		number, errAyah := strconv.Atoi(fields[1])
+		ayah := domain.Ayah{SurahNumber: surah, AyahNumber: number}
+		if errSurah != nil || errAyah != nil || ayah.Validate(riwayah) != nil {
+			return nil, fmt.Errorf("line %d: invalid ayah %s:%s", line, fields[0], fields[1])
+		}
+		texts[ayah] = fields[2]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return texts, nil
+}
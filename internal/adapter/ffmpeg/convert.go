@@ -0,0 +1,165 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported input audio formats, named after their usual file extension
+const (
+	FormatOGG = "ogg"
+	FormatMP3 = "mp3"
+	FormatM4A = "m4a"
+	FormatAMR = "amr"
+	FormatWAV = "wav"
+
+	FormatFLAC = "flac" // output only
+)
+
+// mimeFormats maps the MIME types reported by chat platforms to input formats
+var mimeFormats = map[string]string{
+	"audio/ogg":     FormatOGG,
+	"audio/opus":    FormatOGG,
+	"audio/mpeg":    FormatMP3,
+	"audio/mp3":     FormatMP3,
+	"audio/mp4":     FormatM4A,
+	"audio/m4a":     FormatM4A,
+	"audio/x-m4a":   FormatM4A,
+	"audio/aac":     FormatM4A,
+	"audio/amr":     FormatAMR,
+	"audio/wav":     FormatWAV,
+	"audio/wave":    FormatWAV,
+	"audio/x-wav":   FormatWAV,
+	"audio/vnd.wav": FormatWAV,
+}
+
+// detectAudioFormat identifies the container from its magic bytes, falling
+// back to the MIME type reported by the sender. It returns "" if the format
+// is not supported.
+func detectAudioFormat(data []byte, mimeType string) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return FormatOGG
+	case bytes.HasPrefix(data, []byte("RIFF")) && len(data) >= 12 && string(data[8:12]) == "WAVE":
+		return FormatWAV
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return FormatAMR
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return FormatM4A
+	case bytes.HasPrefix(data, []byte("ID3")), len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return FormatMP3
+	}
+	return mimeFormats[strings.ToLower(mimeType)]
+}
+
+// outputCodecs maps the supported output formats to their ffmpeg encoder
+var outputCodecs = map[string]string{
+	FormatWAV:  "pcm_s16le",
+	FormatFLAC: "flac",
+	FormatOGG:  "libopus",
+}
+
+// ffmpegDuration matches the input duration ffmpeg logs, e.g.
+// "Duration: 00:01:05.32"
+var ffmpegDuration = regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+
+// parseFFmpegDuration extracts the input duration from ffmpeg's log, 0 if it
+// is not found
+func parseFFmpegDuration(log string) time.Duration {
+	m := ffmpegDuration.FindStringSubmatch(log)
+	if m == nil {
+		return 0
+	}
+	hours, _ := strconv.Atoi(m[1])
+	minutes, _ := strconv.Atoi(m[2])
+	seconds, _ := strconv.ParseFloat(m[3], 64)
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second))
+}
+
+// convertAudio converts audio in the given input format to the output
+// format using FFmpeg, applying the audio filters in order. It also returns
+// the input duration, 0 if ffmpeg did not report it.
+func convertAudio(ctx context.Context, data []byte, format string, filters []string, out Output) ([]byte, time.Duration, error) {
+	// Check if FFmpeg is available
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, 0, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+
+	// Create unique temporary input file, with the extension of its format
+	// so ffmpeg picks the right demuxer
+	inFile, err := os.CreateTemp("", "quran-audio-*."+format)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create temp %s file: %w", format, err)
+	}
+	inPath := inFile.Name()
+
+	// Create unique temporary output file
+	outFile, err := os.CreateTemp("", "quran-audio-*."+out.Format)
+	if err != nil {
+		inFile.Close()
+		os.Remove(inPath)
+		return nil, 0, fmt.Errorf("create temp %s file: %w", out.Format, err)
+	}
+	outPath := outFile.Name()
+	outFile.Close() // Close immediately since ffmpeg will write to it
+
+	// Cleanup temporary files
+	defer func() {
+		os.Remove(inPath)
+		os.Remove(outPath)
+	}()
+
+	// Write input data to temporary file
+	if _, err := inFile.Write(data); err != nil {
+		inFile.Close()
+		return nil, 0, fmt.Errorf("write %s data: %w", format, err)
+	}
+
+	if err := inFile.Close(); err != nil {
+		return nil, 0, fmt.Errorf("close %s file: %w", format, err)
+	}
+
+	// Convert using FFmpeg
+	// -i input file
+	// -af audio filters, if any
+	// -c:a output codec
+	// -ar sample rate (16kHz is good for speech)
+	// -ac number of channels
+	// -y overwrite output file
+	args := []string{"-i", inPath}
+	if len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+	args = append(args,
+		"-c:a", outputCodecs[out.Format],
+		"-ar", strconv.Itoa(out.SampleRate),
+		"-ac", strconv.Itoa(out.Channels),
+		"-y", outPath,
+	)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		log.Printf("FFmpeg error: %s", stderr.String())
+		return nil, 0, fmt.Errorf("ffmpeg conversion failed: %w", err)
+	}
+
+	// Read converted file
+	converted, err := os.ReadFile(outPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read %s file: %w", out.Format, err)
+	}
+
+	return converted, parseFFmpegDuration(stderr.String()), nil
+}
@@ -0,0 +1,110 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const (
+	// downloadAttempts is how many times a download is tried, and
+	// downloadBackoff the wait before the first retry, doubled afterwards
+	downloadAttempts = 4
+	downloadBackoff  = 500 * time.Millisecond
+
+	// downloadTimeout bounds each attempt
+	downloadTimeout = time.Minute
+)
+
+// errDownloadTransient marks failures that are worth retrying
+var errDownloadTransient = errors.New("transient download failure")
+
+// downloadFile downloads a recording. Transient failures, such as dropped
+// connections and 5xx responses, are retried with backoff, resuming from the
+// bytes already received when the server supports ranges. Files larger than
+// maxSize are rejected with domain.ErrAudioTooLarge.
+func downloadFile(ctx context.Context, fileURL string, maxSize int) ([]byte, error) {
+	var data []byte
+	backoff := downloadBackoff
+
+	for attempt := 1; ; attempt++ {
+		var err error
+		data, err = downloadAttempt(ctx, fileURL, data, maxSize)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, errDownloadTransient) || attempt == downloadAttempts {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// downloadAttempt downloads the rest of a file of which data was already
+// received, and returns the whole file. On a transient failure it returns
+// the bytes received so far along with the error, so that the next attempt
+// can resume.
+func downloadAttempt(ctx context.Context, fileURL string, data []byte, maxSize int) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if len(data) > 0 {
+		req.Header.Set("Range", "bytes="+strconv.Itoa(len(data))+"-")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return data, fmt.Errorf("%w: %v", errDownloadTransient, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && len(data) > 0:
+		// Resuming
+	case resp.StatusCode == http.StatusOK:
+		data = data[:0] // Ranges not supported, start over
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return data, fmt.Errorf("%w: status %d", errDownloadTransient, resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	}
+
+	if resp.ContentLength > 0 && len(data)+int(resp.ContentLength) > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", domain.ErrAudioTooLarge, len(data)+int(resp.ContentLength))
+	}
+
+	// Read one byte past the cap to detect oversized files without a length
+	body := io.LimitReader(resp.Body, int64(maxSize-len(data)+1))
+	chunk, err := io.ReadAll(body)
+	data = append(data, chunk...)
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", domain.ErrAudioTooLarge, maxSize)
+	}
+	if err != nil {
+		return data, fmt.Errorf("%w: read file: %v", errDownloadTransient, err)
+	}
+	if resp.ContentLength > 0 && len(chunk) < int(resp.ContentLength) {
+		return data, fmt.Errorf("%w: got %d of %d bytes", errDownloadTransient, len(chunk), resp.ContentLength)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,99 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+// opusSampleRates are the output sample rates the Opus decoder supports
+var opusSampleRates = map[int]bool{8000: true, 12000: true, 16000: true, 24000: true, 48000: true}
+
+// opusMaxFrameSamples is the largest Opus packet, 120ms, in samples per
+// channel at 48kHz
+const opusMaxFrameSamples = 5760
+
+// decodeOpusToWAV decodes an OGG/Opus file to 16-bit PCM WAV in pure Go,
+// without ffmpeg. The decoder resamples to the output sample rate, which
+// must be 8, 12, 16, 24 or 48kHz, and mixes to the output channels. It also
+// returns the duration of the audio.
+func decodeOpusToWAV(data []byte, out Output) ([]byte, time.Duration, error) {
+	reader, header, err := oggreader.NewWith(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("read ogg header: %w", err)
+	}
+	if header.ChannelMap != 0 {
+		return nil, 0, fmt.Errorf("unsupported opus channel mapping %d", header.ChannelMap)
+	}
+
+	decoder, err := opus.NewDecoderWithOutput(out.SampleRate, out.Channels)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create opus decoder: %w", err)
+	}
+
+	var samples []int16
+	frame := make([]int16, opusMaxFrameSamples*out.Channels)
+	for {
+		packet, _, err := reader.ParseNextPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("read ogg packet: %w", err)
+		}
+		if bytes.HasPrefix(packet, []byte("OpusTags")) {
+			continue
+		}
+
+		n, err := decoder.DecodeToInt16(packet, frame)
+		if err != nil {
+			return nil, 0, fmt.Errorf("decode opus packet: %w", err)
+		}
+		samples = append(samples, frame[:n*out.Channels]...)
+	}
+
+	// Drop the encoder delay, given in samples at 48kHz
+	skip := int(header.PreSkip) * out.SampleRate / 48000 * out.Channels
+	if skip > len(samples) {
+		skip = len(samples)
+	}
+	samples = samples[skip:]
+
+	frames := len(samples) / out.Channels
+	duration := time.Duration(frames) * time.Second / time.Duration(out.SampleRate)
+	return encodeWAV(samples, out.SampleRate, out.Channels), duration, nil
+}
+
+// encodeWAV wraps interleaved 16-bit PCM samples in a WAV container
+func encodeWAV(samples []int16, sampleRate, channels int) []byte {
+	const bitsPerSample = 16
+	dataSize := len(samples) * 2
+	blockAlign := channels * bitsPerSample / 8
+
+	var buf bytes.Buffer
+	buf.Grow(44 + dataSize)
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16)) // chunk size
+	binary.Write(&buf, binary.LittleEndian, uint16(1))  // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	binary.Write(&buf, binary.LittleEndian, samples)
+
+	return buf.Bytes()
+}
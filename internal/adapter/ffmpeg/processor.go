@@ -0,0 +1,204 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/metrics"
+)
+
+// DefaultDenoiseFilter is ffmpeg's FFT denoiser with its default settings
+const DefaultDenoiseFilter = "afftdn"
+
+// defaultMaxFileSize bounds downloads when Options.MaxFileSize is not set
+const defaultMaxFileSize = 20 << 20
+
+// Output is the format recordings are converted to before submission
+type Output struct {
+	Format     string // FormatWAV, FormatFLAC or FormatOGG
+	SampleRate int    // in Hz
+	Channels   int
+}
+
+// DefaultOutput is 16kHz mono WAV, which the analysis API expects
+var DefaultOutput = Output{Format: FormatWAV, SampleRate: 16000, Channels: 1}
+
+// voiceOutput is the format chat apps play as a voice message
+var voiceOutput = Output{Format: FormatOGG, SampleRate: 48000, Channels: 1}
+
+// Options configures how recordings are prepared
+type Options struct {
+	// Filters are ffmpeg audio filters applied to every recording, e.g.
+	// LoudnormFilter(-16)
+	Filters []string
+
+	// Denoise is the ffmpeg filter reducing background noise for users who
+	// enabled "Enhance audio quality", e.g. afftdn or arnndn with an RNNoise
+	// model. Empty disables noise reduction.
+	Denoise string
+
+	// Output defaults to DefaultOutput
+	Output Output
+
+	// MaxFileSize rejects larger downloads, defaults to 20 MiB
+	MaxFileSize int
+
+	// Passthrough uploads OGG/Opus recordings, such as voice messages,
+	// without transcoding them, for analysis APIs that accept them.
+	// Recordings that go through audio filters are still transcoded.
+	Passthrough bool
+
+	// NativeOpus decodes OGG/Opus recordings in pure Go instead of running
+	// ffmpeg, so the bot can run in containers without it. The output must
+	// be WAV at 8, 12, 16, 24 or 48kHz. Other formats and recordings that go
+	// through audio filters still need ffmpeg.
+	NativeOpus bool
+
+	// RejectNonSpeech rejects silent recordings and ones that sound like
+	// music rather than a recitation before they are submitted. Only WAV
+	// output is checked.
+	RejectNonSpeech bool
+}
+
+// Processor downloads recordings and converts them with ffmpeg (or the
+// native Opus decoder) to the format submitted for analysis
+type Processor struct {
+	opts Options
+}
+
+// NewProcessor validates the options and creates a processor
+func NewProcessor(opts Options) (*Processor, error) {
+	if opts.Output == (Output{}) {
+		opts.Output = DefaultOutput
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = defaultMaxFileSize
+	}
+	if _, ok := outputCodecs[opts.Output.Format]; !ok {
+		return nil, fmt.Errorf("unsupported audio output format: %s", opts.Output.Format)
+	}
+	if opts.NativeOpus && (opts.Output.Format != FormatWAV || !opusSampleRates[opts.Output.SampleRate]) {
+		return nil, fmt.Errorf("native opus decoder needs wav output at 8, 12, 16, 24 or 48kHz")
+	}
+	return &Processor{opts: opts}, nil
+}
+
+// Ping checks that ffmpeg is installed
+func (p *Processor) Ping(ctx context.Context) error {
+	return CheckInstalled()
+}
+
+// CheckInstalled checks that the ffmpeg binary is on the PATH
+func CheckInstalled() error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	return nil
+}
+
+// LoudnormFilter returns the ffmpeg filter normalizing loudness to
+// targetLUFS, with the EBU R128 defaults for true peak and loudness range
+func LoudnormFilter(targetLUFS float64) string {
+	return fmt.Sprintf("loudnorm=I=%g:TP=-1.5:LRA=11", targetLUFS)
+}
+
+// PrepareAudio downloads a recording and converts it to the output format,
+// applying the ffmpeg filters, and describes the result for the API
+func (p *Processor) PrepareAudio(ctx context.Context, src domain.AudioSource, opts domain.AudioProcessOptions) ([]byte, domain.AudioMetadata, error) {
+	started := time.Now()
+	data, err := downloadFile(ctx, src.URL, p.opts.MaxFileSize)
+	metrics.VoiceStageDuration.Since("download", started)
+	if err != nil {
+		return nil, domain.AudioMetadata{}, fmt.Errorf("download file: %w", err)
+	}
+
+	format := detectAudioFormat(data, src.MimeType)
+	if format == "" {
+		return nil, domain.AudioMetadata{}, fmt.Errorf("%w: %s", domain.ErrUnsupportedAudio, src.MimeType)
+	}
+
+	filters := p.filters(opts)
+
+	// Upload OGG/Opus files as they are when the API accepts them, unless
+	// they have to be filtered
+	if p.opts.Passthrough && format == FormatOGG && len(filters) == 0 {
+		meta := domain.AudioMetadata{
+			Duration:       src.Duration,
+			Format:         FormatOGG,
+			OriginalFormat: FormatOGG,
+		}
+		return data, meta, nil
+	}
+
+	// Convert to the output format
+	out := p.opts.Output
+	var converted []byte
+	var duration time.Duration
+	started = time.Now()
+	if p.opts.NativeOpus && format == FormatOGG && len(filters) == 0 {
+		converted, duration, err = decodeOpusToWAV(data, out)
+	} else {
+		converted, duration, err = convertAudio(ctx, data, format, filters, out)
+	}
+	metrics.VoiceStageDuration.Since("convert", started)
+	if err != nil {
+		return nil, domain.AudioMetadata{}, fmt.Errorf("convert audio: %w", err)
+	}
+
+	if p.opts.RejectNonSpeech && out.Format == FormatWAV {
+		if err := checkWAVSpeech(converted); err != nil {
+			return nil, domain.AudioMetadata{}, err
+		}
+	}
+
+	// Prefer the measured duration over the one set by the sender
+	if duration == 0 {
+		duration = src.Duration
+	}
+	meta := domain.AudioMetadata{
+		Duration:       duration,
+		SampleRate:     out.SampleRate,
+		Format:         out.Format,
+		OriginalFormat: format,
+	}
+
+	return converted, meta, nil
+}
+
+// EncodeVoice converts audio in the given format to OGG/Opus, which chat
+// apps play as a voice message
+func (p *Processor) EncodeVoice(ctx context.Context, data []byte, format string) ([]byte, error) {
+	if format == FormatOGG {
+		return data, nil
+	}
+
+	converted, _, err := convertAudio(ctx, data, format, nil, voiceOutput)
+	if err != nil {
+		return nil, fmt.Errorf("convert audio: %w", err)
+	}
+	return converted, nil
+}
+
+// checkWAVSpeech runs the speech check on a WAV recording. Recordings that
+// cannot be decoded are let through.
+func checkWAVSpeech(data []byte) error {
+	audio, err := decodeWAV(data)
+	if err != nil {
+		log.Printf("Error decoding wav for the speech check: %v", err)
+		return nil
+	}
+	return checkSpeech(audio.mono(), audio.sampleRate)
+}
+
+// filters returns the ffmpeg filters for a recording: noise reduction first
+// if the user enabled it, then the filters applied to every recording
+func (p *Processor) filters(opts domain.AudioProcessOptions) []string {
+	if !opts.Enhance || p.opts.Denoise == "" {
+		return p.opts.Filters
+	}
+	return append([]string{p.opts.Denoise}, p.opts.Filters...)
+}
@@ -0,0 +1,184 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Thresholds of the speech check, tuned to let quiet or melodic recitations
+// through and only reject recordings that cannot contain one
+const (
+	speechFrame = 30 * time.Millisecond
+
+	// minDuration is the shortest recording that can hold an ayah
+	minDuration = time.Second
+
+	// silenceDB is the loudness, in dBFS, under which a recording is silent
+	silenceDB = -50.0
+	// activeAboveFloorDB is how far above the noise floor a frame must be to
+	// count as voiced, or activeBelowPeakDB below the peak at most, and
+	// activeMinDB the least loudness it needs anyway
+	activeAboveFloorDB = 12.0
+	activeBelowPeakDB  = 20.0
+	activeMinDB        = -45.0
+	// minVoiced is the least voiced audio a recitation has
+	minVoiced = 500 * time.Millisecond
+
+	// Speech alternates syllables and short gaps, so in every second some
+	// frames are much quieter than the average. Music rarely drops like that.
+	// Recordings of at least musicMinDuration with fewer than musicMaxLSTER
+	// of such frames are taken for music.
+	musicMinDuration = 5 * time.Second
+	musicMaxLSTER    = 0.03
+)
+
+// checkSpeech rejects recordings that are too short, silent or do not sound
+// like speech, with domain.ErrAudioTooShort, domain.ErrSilentAudio or
+// domain.ErrNonSpeechAudio. It is a
+// cheap energy heuristic, not a speech detector: it errs on the side of
+// letting recordings through.
+func checkSpeech(samples []int16, sampleRate int) error {
+	frameLen := sampleRate * int(speechFrame/time.Millisecond) / 1000
+	if frameLen == 0 || len(samples) < sampleRate*int(minDuration/time.Millisecond)/1000 {
+		return fmt.Errorf("%w: %d samples at %d Hz", domain.ErrAudioTooShort, len(samples), sampleRate)
+	}
+
+	energies, levels, peak := frameEnergies(samples, frameLen)
+	if peak < silenceDB {
+		return fmt.Errorf("%w: peak %.1f dBFS", domain.ErrSilentAudio, peak)
+	}
+
+	threshold := voicedThreshold(levels, peak)
+	voiced := 0
+	for _, level := range levels {
+		if level > threshold {
+			voiced++
+		}
+	}
+	if time.Duration(voiced)*speechFrame < minVoiced {
+		return fmt.Errorf("%w: %d voiced frames", domain.ErrSilentAudio, voiced)
+	}
+
+	if time.Duration(len(levels))*speechFrame >= musicMinDuration {
+		if lster := lowEnergyRatio(energies); lster < musicMaxLSTER {
+			return fmt.Errorf("%w: low energy ratio %.3f", domain.ErrNonSpeechAudio, lster)
+		}
+	}
+	return nil
+}
+
+// frameEnergies returns the loudness of every frame of frameLen samples, as
+// linear energy and in dBFS, and the loudest level
+func frameEnergies(samples []int16, frameLen int) (energies, levels []float64, peak float64) {
+	frames := len(samples) / frameLen
+	energies = make([]float64, frames)
+	levels = make([]float64, frames)
+	peak = math.Inf(-1)
+	for i := range frames {
+		var sum float64
+		for _, s := range samples[i*frameLen : (i+1)*frameLen] {
+			v := float64(s) / 32768
+			sum += v * v
+		}
+		energies[i] = sum / float64(frameLen)
+		levels[i] = 10 * math.Log10(energies[i]+1e-12)
+		peak = math.Max(peak, levels[i])
+	}
+	return energies, levels, peak
+}
+
+// voicedThreshold returns the level above which frames are voiced: well
+// above the noise floor. Recordings without quiet frames to estimate the
+// floor from are judged relative to their peak.
+func voicedThreshold(levels []float64, peak float64) float64 {
+	sorted := append([]float64(nil), levels...)
+	sort.Float64s(sorted)
+	floor := sorted[len(sorted)/10]
+	return math.Max(math.Min(floor+activeAboveFloorDB, peak-activeBelowPeakDB), activeMinDB)
+}
+
+// lowEnergyRatio returns the share of frames with less than half the
+// average energy of the second around them (the low short-time energy
+// ratio, or LSTER)
+func lowEnergyRatio(energies []float64) float64 {
+	window := int(time.Second / speechFrame)
+	low := 0
+	for start := 0; start < len(energies); start += window {
+		end := min(start+window, len(energies))
+		var sum float64
+		for _, e := range energies[start:end] {
+			sum += e
+		}
+		avg := sum / float64(end-start)
+		for _, e := range energies[start:end] {
+			if e < avg/2 {
+				low++
+			}
+		}
+	}
+	return float64(low) / float64(len(energies))
+}
+
+// pcm is 16-bit audio with interleaved channels
+type pcm struct {
+	samples    []int16
+	sampleRate int
+	channels   int
+}
+
+// mono mixes the channels down to one
+func (p pcm) mono() []int16 {
+	if p.channels == 1 {
+		return p.samples
+	}
+	samples := make([]int16, len(p.samples)/p.channels)
+	for i := range samples {
+		var sum int
+		for c := range p.channels {
+			sum += int(p.samples[i*p.channels+c])
+		}
+		samples[i] = int16(sum / p.channels)
+	}
+	return samples
+}
+
+// decodeWAV returns the samples of a 16-bit PCM WAV file
+func decodeWAV(data []byte) (pcm, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return pcm{}, errors.New("not a wav file")
+	}
+
+	var channels, sampleRate, bits int
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8 : min(pos+8+size, len(data))]
+
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return pcm{}, errors.New("short wav fmt chunk")
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bits = int(binary.LittleEndian.Uint16(body[14:16]))
+		case "data":
+			if bits != 16 || channels == 0 {
+				return pcm{}, fmt.Errorf("unsupported wav encoding: %d bits, %d channels", bits, channels)
+			}
+			samples := make([]int16, len(body)/2/channels*channels)
+			binary.Read(bytes.NewReader(body[:len(samples)*2]), binary.LittleEndian, samples)
+			return pcm{samples: samples, sampleRate: sampleRate, channels: channels}, nil
+		}
+
+		pos += 8 + size + size%2 // chunks are padded to an even size
+	}
+	return pcm{}, errors.New("no wav data chunk")
+}
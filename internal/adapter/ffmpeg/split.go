@@ -0,0 +1,132 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const (
+	// minPause is the shortest gap between voiced frames taken for a pause
+	minPause = 250 * time.Millisecond
+
+	// minChunk is the shortest chunk a recording is cut into, so that a
+	// hesitation right after a cut does not make a chunk of a single word
+	minChunk = 5 * time.Second
+)
+
+// pause is a run of unvoiced frames, [start, end)
+type pause struct {
+	start, end int
+}
+
+// SplitAudio cuts a long recording into chunks of at most maxDuration, at
+// the longest pause of each stretch, where reciters usually stop between
+// ayahs. Stretches without a pause are cut at their quietest frame.
+// Recordings no longer than maxDuration are returned as a single chunk. Only
+// WAV recordings can be split.
+func (p *Processor) SplitAudio(ctx context.Context, data []byte, meta domain.AudioMetadata, maxDuration time.Duration) ([]domain.AudioChunk, error) {
+	if meta.Format != FormatWAV {
+		return nil, fmt.Errorf("%w: cannot split %s", domain.ErrUnsupportedAudio, meta.Format)
+	}
+	if maxDuration < 2*minChunk {
+		return nil, fmt.Errorf("chunks of %s are too short to split at pauses", maxDuration)
+	}
+
+	audio, err := decodeWAV(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode wav: %w", err)
+	}
+
+	frameLen := audio.sampleRate * int(speechFrame/time.Millisecond) / 1000
+	if frameLen == 0 {
+		return nil, fmt.Errorf("invalid sample rate: %d", audio.sampleRate)
+	}
+	_, levels, peak := frameEnergies(audio.mono(), frameLen)
+
+	cuts := splitPoints(levels, voicedThreshold(levels, peak), int(maxDuration/speechFrame))
+
+	// Cut the interleaved samples at the frame boundaries
+	chunks := make([]domain.AudioChunk, 0, len(cuts)+1)
+	bounds := append(append([]int{0}, cuts...), -1)
+	for i := 0; i < len(bounds)-1; i++ {
+		start := bounds[i] * frameLen * audio.channels
+		end := len(audio.samples)
+		if bounds[i+1] >= 0 {
+			end = bounds[i+1] * frameLen * audio.channels
+		}
+
+		samples := audio.samples[start:end]
+		chunks = append(chunks, domain.AudioChunk{
+			Data:     encodeWAV(samples, audio.sampleRate, audio.channels),
+			Offset:   pcmDuration(start/audio.channels, audio.sampleRate),
+			Duration: pcmDuration(len(samples)/audio.channels, audio.sampleRate),
+		})
+	}
+	return chunks, nil
+}
+
+// splitPoints returns the frames to cut a recording at, so that no chunk is
+// longer than maxFrames. Each cut is in the middle of the longest pause
+// between minChunk and maxFrames after the previous one, or at the quietest
+// frame there if it has no pause.
+func splitPoints(levels []float64, threshold float64, maxFrames int) []int {
+	pauses := findPauses(levels, threshold, int(minPause/speechFrame))
+	minFrames := int(minChunk / speechFrame)
+
+	var cuts []int
+	for start := 0; len(levels)-start > maxFrames; {
+		lo, hi := start+minFrames, start+maxFrames
+
+		cut, longest := -1, 0
+		for _, p := range pauses {
+			mid := (p.start + p.end) / 2
+			if mid <= lo || mid > hi {
+				continue
+			}
+			if length := p.end - p.start; length >= longest {
+				cut, longest = mid, length
+			}
+		}
+		if cut < 0 {
+			cut = lo + 1
+			for i := lo + 1; i <= hi; i++ {
+				if levels[i] < levels[cut] {
+					cut = i
+				}
+			}
+		}
+
+		cuts = append(cuts, cut)
+		start = cut
+	}
+	return cuts
+}
+
+// findPauses returns the runs of at least minFrames frames below threshold
+func findPauses(levels []float64, threshold float64, minFrames int) []pause {
+	var pauses []pause
+	start := -1
+	for i, level := range levels {
+		switch {
+		case level <= threshold && start < 0:
+			start = i
+		case level > threshold && start >= 0:
+			if i-start >= minFrames {
+				pauses = append(pauses, pause{start: start, end: i})
+			}
+			start = -1
+		}
+	}
+	if start >= 0 && len(levels)-start >= minFrames {
+		pauses = append(pauses, pause{start: start, end: len(levels)})
+	}
+	return pauses
+}
+
+// pcmDuration returns the duration of n samples per channel
+func pcmDuration(n, sampleRate int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(sampleRate)
+}
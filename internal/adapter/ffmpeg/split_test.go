@@ -0,0 +1,100 @@
+package ffmpeg
+
+import (
+	"slices"
+	"testing"
+)
+
+// levelsOf returns n frames at loud, with the frames in quiet at -60 dBFS
+func levelsOf(n int, quiet ...[2]int) []float64 {
+	levels := make([]float64, n)
+	for i := range levels {
+		levels[i] = -10
+	}
+	for _, run := range quiet {
+		for i := run[0]; i < run[1]; i++ {
+			levels[i] = -60
+		}
+	}
+	return levels
+}
+
+func TestFindPauses(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels []float64
+		want   []pause
+	}{
+		{name: "no pause", levels: levelsOf(100)},
+		{name: "too short", levels: levelsOf(100, [2]int{40, 47})},
+		{name: "just long enough", levels: levelsOf(100, [2]int{40, 48}), want: []pause{{40, 48}}},
+		{name: "at the start", levels: levelsOf(100, [2]int{0, 10}), want: []pause{{0, 10}}},
+		{name: "at the end", levels: levelsOf(100, [2]int{90, 100}), want: []pause{{90, 100}}},
+		{name: "whole recording", levels: levelsOf(20, [2]int{0, 20}), want: []pause{{0, 20}}},
+		{
+			name:   "several",
+			levels: levelsOf(100, [2]int{10, 20}, [2]int{30, 33}, [2]int{50, 70}),
+			want:   []pause{{10, 20}, {50, 70}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findPauses(tt.levels, -30, 8); !slices.Equal(got, tt.want) {
+				t.Errorf("findPauses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPoints(t *testing.T) {
+	// Chunks are at least minChunk (166 frames) long, and at most maxFrames
+	const maxFrames = 400
+
+	quietestAt := func(levels []float64, frame int) []float64 {
+		levels[frame] = -20 // quieter than the rest, yet voiced
+		return levels
+	}
+
+	tests := []struct {
+		name   string
+		levels []float64
+		want   []int
+	}{
+		{name: "short enough", levels: levelsOf(maxFrames)},
+		{name: "no pause, cut at the quietest frame", levels: quietestAt(levelsOf(600), 300), want: []int{300}},
+		{name: "no pause, quietest frame at maxFrames", levels: quietestAt(levelsOf(600), maxFrames), want: []int{maxFrames}},
+		{name: "no pause, flat", levels: levelsOf(600), want: []int{167, 334}},
+		{name: "cut in the middle of a pause", levels: levelsOf(600, [2]int{250, 270}), want: []int{260}},
+		{
+			name:   "longest pause wins",
+			levels: levelsOf(600, [2]int{200, 210}, [2]int{300, 330}, [2]int{380, 390}),
+			want:   []int{315},
+		},
+		{name: "pause ending a chunk at maxFrames", levels: levelsOf(600, [2]int{390, 410}), want: []int{maxFrames}},
+		{
+			name:   "pause past maxFrames is left to the next chunk",
+			levels: quietestAt(levelsOf(600, [2]int{401, 421}), 250),
+			want:   []int{250},
+		},
+		{
+			name:   "pause at the start is too early",
+			levels: quietestAt(levelsOf(600, [2]int{0, 20}), 350),
+			want:   []int{350},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitPoints(tt.levels, -30, maxFrames)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("splitPoints() = %v, want %v", got, tt.want)
+			}
+
+			bounds := append(append([]int{0}, got...), len(tt.levels))
+			for i := 1; i < len(bounds); i++ {
+				if length := bounds[i] - bounds[i-1]; length > maxFrames {
+					t.Errorf("chunk %d is %d frames long, more than %d", i, length, maxFrames)
+				}
+			}
+		})
+	}
+}
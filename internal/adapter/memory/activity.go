@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Activity is an in-process ActivityPort implementation
+type Activity struct {
+	mu       sync.RWMutex
+	lastSeen map[string]time.Time
+}
+
+func NewActivity() *Activity {
+	return &Activity{lastSeen: make(map[string]time.Time)}
+}
+
+// TouchUser records that the user interacted with the bot at the given time
+func (a *Activity) TouchUser(ctx context.Context, userID string, at time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if at.After(a.lastSeen[userID]) {
+		a.lastSeen[userID] = at
+	}
+	return nil
+}
+
+// ActiveUsers returns the users who interacted at or after since, most
+// recent first
+func (a *Activity) ActiveUsers(ctx context.Context, since time.Time) ([]domain.ActiveUser, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var users []domain.ActiveUser
+	for userID, lastSeen := range a.lastSeen {
+		if !lastSeen.Before(since) {
+			users = append(users, domain.ActiveUser{UserID: userID, LastSeen: lastSeen})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].LastSeen.After(users[j].LastSeen) })
+	return users, nil
+}
+
+// RemoveUser forgets the user
+func (a *Activity) RemoveUser(ctx context.Context, userID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.lastSeen, userID)
+	return nil
+}
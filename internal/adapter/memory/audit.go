@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// auditCap is the number of entries kept per user
+const auditCap = 200
+
+// AuditLog is an in-process AuditLogPort implementation
+type AuditLog struct {
+	mu      sync.RWMutex
+	entries map[string][]domain.AuditEntry // oldest first
+}
+
+func NewAuditLog() *AuditLog {
+	return &AuditLog{entries: make(map[string][]domain.AuditEntry)}
+}
+
+// AppendAudit adds an entry, dropping the oldest ones beyond the cap
+func (a *AuditLog) AppendAudit(ctx context.Context, userID string, entry domain.AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries := append(a.entries[userID], entry)
+	if len(entries) > auditCap {
+		entries = append([]domain.AuditEntry(nil), entries[len(entries)-auditCap:]...)
+	}
+	a.entries[userID] = entries
+	return nil
+}
+
+// ListAudit returns up to limit entries, newest first
+func (a *AuditLog) ListAudit(ctx context.Context, userID string, limit int) ([]domain.AuditEntry, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	stored := a.entries[userID]
+	n := len(stored)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+
+	entries := make([]domain.AuditEntry, 0, n)
+	for i := len(stored) - 1; i >= len(stored)-n; i-- {
+		entries = append(entries, stored[i])
+	}
+	return entries, nil
+}
+
+// DeleteAudit deletes the user's whole log
+func (a *AuditLog) DeleteAudit(ctx context.Context, userID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.entries, userID)
+	return nil
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Challenges is an in-process ChallengePort implementation
+type Challenges struct {
+	mu         sync.Mutex
+	challenges map[string]expiring[domain.Challenge]       // by ID
+	answers    map[string]expiring[domain.ChallengeAnswer] // by recording ID
+	clock      domain.Clock
+}
+
+// expiring is a value kept until it expires
+type expiring[T any] struct {
+	value     T
+	expiresAt time.Time
+}
+
+// NewChallenges creates a Challenges store whose entries expire on clock
+func NewChallenges(clock domain.Clock) *Challenges {
+	return &Challenges{
+		challenges: make(map[string]expiring[domain.Challenge]),
+		answers:    make(map[string]expiring[domain.ChallengeAnswer]),
+		clock:      clock,
+	}
+}
+
+// SaveChallenge stores a challenge
+func (c *Challenges) SaveChallenge(ctx context.Context, challenge domain.Challenge, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropExpired()
+	c.challenges[challenge.ID] = expiring[domain.Challenge]{value: challenge, expiresAt: c.clock.Now().Add(ttl)}
+	return nil
+}
+
+// GetChallenge returns a challenge
+func (c *Challenges) GetChallenge(ctx context.Context, id string) (*domain.Challenge, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.challenges[id]
+	if !ok || !c.clock.Now().Before(entry.expiresAt) {
+		return nil, domain.ErrChallengeNotFound
+	}
+	return &entry.value, nil
+}
+
+// SaveAnswer stores a friend's attempt at a challenge
+func (c *Challenges) SaveAnswer(ctx context.Context, answer domain.ChallengeAnswer, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropExpired()
+	c.answers[answer.RecordingID] = expiring[domain.ChallengeAnswer]{value: answer, expiresAt: c.clock.Now().Add(ttl)}
+	return nil
+}
+
+// GetAnswer returns the challenge attempt a recording is, or nil if it is
+// none
+func (c *Challenges) GetAnswer(ctx context.Context, recordingID string) (*domain.ChallengeAnswer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.answers[recordingID]
+	if !ok || !c.clock.Now().Before(entry.expiresAt) {
+		return nil, nil
+	}
+	return &entry.value, nil
+}
+
+// dropExpired forgets expired challenges and answers. The caller holds the
+// mutex.
+func (c *Challenges) dropExpired() {
+	now := c.clock.Now()
+	for id, entry := range c.challenges {
+		if !now.Before(entry.expiresAt) {
+			delete(c.challenges, id)
+		}
+	}
+	for id, entry := range c.answers {
+		if !now.Before(entry.expiresAt) {
+			delete(c.answers, id)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// RecordingChunks is an in-process RecordingChunkPort implementation
+type RecordingChunks struct {
+	mu     sync.Mutex
+	chunks map[string]map[string][]domain.RecordingChunk
+}
+
+func NewRecordingChunks() *RecordingChunks {
+	return &RecordingChunks{chunks: make(map[string]map[string][]domain.RecordingChunk)}
+}
+
+// SetChunks stores the chunks of a recording
+func (c *RecordingChunks) SetChunks(ctx context.Context, userID, recordingID string, chunks []domain.RecordingChunk) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.chunks[userID] == nil {
+		c.chunks[userID] = make(map[string][]domain.RecordingChunk)
+	}
+	c.chunks[userID][recordingID] = slices.Clone(chunks)
+	return nil
+}
+
+// ListChunks returns the chunks of the user's split recordings
+func (c *RecordingChunks) ListChunks(ctx context.Context, userID string) (map[string][]domain.RecordingChunk, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	list := maps.Clone(c.chunks[userID])
+	for id, chunks := range list {
+		list[id] = slices.Clone(chunks)
+	}
+	return list, nil
+}
+
+// DeleteChunks forgets the chunks of one of the user's recordings, or of
+// all of them when recordingID is empty
+func (c *RecordingChunks) DeleteChunks(ctx context.Context, userID, recordingID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if recordingID == "" {
+		delete(c.chunks, userID)
+		return nil
+	}
+	delete(c.chunks[userID], recordingID)
+	return nil
+}
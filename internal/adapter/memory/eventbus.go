@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// EventBus is an in-process EventBusPort implementation
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]chan domain.RecordingEvent
+	nextID      int
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[int]chan domain.RecordingEvent)}
+}
+
+// Publish delivers a recording event to all current subscribers
+func (e *EventBus) Publish(ctx context.Context, event domain.RecordingEvent) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, ch := range e.subscribers {
+		select {
+		case ch <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe calls handler for every published event until ctx is done
+func (e *EventBus) Subscribe(ctx context.Context, handler func(ctx context.Context, event domain.RecordingEvent)) error {
+	ch := make(chan domain.RecordingEvent, 16)
+
+	e.mu.Lock()
+	id := e.nextID
+	e.nextID++
+	e.subscribers[id] = ch
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		delete(e.subscribers, id)
+		e.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-ch:
+			handler(ctx, event)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const (
+	defaultTTL      = 24 * time.Hour
+	janitorInterval = time.Minute
+)
+
+type session struct {
+	session   domain.Session
+	expiresAt time.Time
+}
+
+// FSM is an in-process FSMPort implementation intended for local development
+// and CI, where running Redis is not desirable. Sessions are lost on restart.
+type FSM struct {
+	mu       sync.Mutex
+	sessions map[domain.SessionScope]*session
+	expired  []domain.SessionScope // sessions that expired mid-flow, not yet popped
+	clock    domain.Clock
+	done     chan struct{}
+}
+
+// NewFSM creates an FSM whose sessions expire on clock
+func NewFSM(clock domain.Clock) *FSM {
+	f := &FSM{
+		sessions: make(map[domain.SessionScope]*session),
+		clock:    clock,
+		done:     make(chan struct{}),
+	}
+
+	go f.janitor()
+
+	return f
+}
+
+func (f *FSM) Close() error {
+	close(f.done)
+	return nil
+}
+
+// GetSession reads a session
+func (f *FSM) GetSession(ctx context.Context, scope domain.SessionScope) (*domain.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.sessions[scope]
+	if !ok || f.clock.Now().After(s.expiresAt) {
+		return domain.NewSession(), nil
+	}
+	return clone(&s.session), nil
+}
+
+// SaveSession stores a whole session and refreshes its expiry
+func (f *FSM) SaveSession(ctx context.Context, scope domain.SessionScope, sess *domain.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sessions[scope] = &session{
+		session:   *clone(sess),
+		expiresAt: f.clock.Now().Add(defaultTTL),
+	}
+	return nil
+}
+
+// UpdateSession applies fn to the session and stores the result, all while
+// holding the lock
+func (f *FSM) UpdateSession(ctx context.Context, scope domain.SessionScope, fn func(session *domain.Session) error) (*domain.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sess := domain.NewSession()
+	if s, ok := f.sessions[scope]; ok && f.clock.Now().Before(s.expiresAt) {
+		sess = clone(&s.session)
+	}
+
+	if err := fn(sess); err != nil {
+		return nil, err
+	}
+
+	f.sessions[scope] = &session{
+		session:   *clone(sess),
+		expiresAt: f.clock.Now().Add(defaultTTL),
+	}
+	return sess, nil
+}
+
+// clone copies a session deeply, so that sessions handed out and the one
+// stored never share the context of their flow, as with a store outside
+// the process
+func clone(sess *domain.Session) *domain.Session {
+	copied := *sess
+	copied.Context = bytes.Clone(sess.Context)
+	return &copied
+}
+
+// PopExpiredSessions returns up to limit sessions that expired mid-flow
+func (f *FSM) PopExpiredSessions(ctx context.Context, limit int) ([]domain.SessionScope, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.evict(f.clock.Now())
+
+	n := len(f.expired)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	popped := append([]domain.SessionScope(nil), f.expired[:n]...)
+	f.expired = f.expired[n:]
+	return popped, nil
+}
+
+// ClearSession deletes a session
+func (f *FSM) ClearSession(ctx context.Context, scope domain.SessionScope) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.sessions, scope)
+	return nil
+}
+
+// ClearUserSessions deletes all of a user's sessions, in every chat
+func (f *FSM) ClearUserSessions(ctx context.Context, userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for scope := range f.sessions {
+		if scope.UserID == userID {
+			delete(f.sessions, scope)
+		}
+	}
+	return nil
+}
+
+// janitor periodically evicts expired sessions
+func (f *FSM) janitor() {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+			f.mu.Lock()
+			f.evict(f.clock.Now())
+			f.mu.Unlock()
+		}
+	}
+}
+
+// evict removes sessions expired at now, remembering the ones that were
+// mid-flow. The caller must hold the lock.
+func (f *FSM) evict(now time.Time) {
+	for scope, s := range f.sessions {
+		if now.After(s.expiresAt) {
+			if s.session.State.InFlow() {
+				f.expired = append(f.expired, scope)
+			}
+			delete(f.sessions, scope)
+		}
+	}
+}
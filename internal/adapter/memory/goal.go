@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Goals is an in-process GoalPort implementation
+type Goals struct {
+	mu    sync.Mutex
+	goals map[string]map[int]domain.Goal // by user, then surah
+}
+
+func NewGoals() *Goals {
+	return &Goals{goals: make(map[string]map[int]domain.Goal)}
+}
+
+// SaveGoal adds a goal, or replaces the user's goal for the same surah
+func (g *Goals) SaveGoal(ctx context.Context, goal domain.Goal) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	bySurah, ok := g.goals[goal.UserID]
+	if !ok {
+		bySurah = make(map[int]domain.Goal)
+		g.goals[goal.UserID] = bySurah
+	}
+	bySurah[goal.SurahNumber] = goal
+	return nil
+}
+
+// ListGoals returns the user's goals, soonest deadline first
+func (g *Goals) ListGoals(ctx context.Context, userID string) ([]domain.Goal, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	list := make([]domain.Goal, 0, len(g.goals[userID]))
+	for _, goal := range g.goals[userID] {
+		list = append(list, goal)
+	}
+	sortGoals(list)
+	return list, nil
+}
+
+// AllGoals returns the goals of every user
+func (g *Goals) AllGoals(ctx context.Context) ([]domain.Goal, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var list []domain.Goal
+	for _, bySurah := range g.goals {
+		for _, goal := range bySurah {
+			list = append(list, goal)
+		}
+	}
+	sortGoals(list)
+	return list, nil
+}
+
+// DeleteGoal removes the user's goal for a surah
+func (g *Goals) DeleteGoal(ctx context.Context, userID string, surahNumber int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.goals[userID][surahNumber]; !ok {
+		return domain.ErrGoalNotFound
+	}
+	delete(g.goals[userID], surahNumber)
+	if len(g.goals[userID]) == 0 {
+		delete(g.goals, userID)
+	}
+	return nil
+}
+
+// DeleteGoals removes all of a user's goals
+func (g *Goals) DeleteGoals(ctx context.Context, userID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.goals, userID)
+	return nil
+}
+
+// sortGoals orders goals by deadline, then surah
+func sortGoals(list []domain.Goal) {
+	slices.SortFunc(list, func(a, b domain.Goal) int {
+		if c := a.Deadline.Compare(b.Deadline); c != 0 {
+			return c
+		}
+		return a.SurahNumber - b.SurahNumber
+	})
+}
@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Khatmahs is an in-process KhatmahPort implementation
+type Khatmahs struct {
+	mu       sync.Mutex
+	khatmahs map[string]domain.Khatmah
+}
+
+func NewKhatmahs() *Khatmahs {
+	return &Khatmahs{khatmahs: make(map[string]domain.Khatmah)}
+}
+
+// SaveKhatmah adds or replaces the user's khatmah
+func (k *Khatmahs) SaveKhatmah(ctx context.Context, khatmah domain.Khatmah) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	khatmah.Completed = append([]time.Time(nil), khatmah.Completed...)
+	k.khatmahs[khatmah.UserID] = khatmah
+	return nil
+}
+
+// GetKhatmah returns the user's khatmah, or nil if they have none
+func (k *Khatmahs) GetKhatmah(ctx context.Context, userID string) (*domain.Khatmah, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	khatmah, ok := k.khatmahs[userID]
+	if !ok {
+		return nil, nil
+	}
+	khatmah.Completed = append([]time.Time(nil), khatmah.Completed...)
+	return &khatmah, nil
+}
+
+// DeleteKhatmah removes the user's khatmah
+func (k *Khatmahs) DeleteKhatmah(ctx context.Context, userID string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.khatmahs, userID)
+	return nil
+}
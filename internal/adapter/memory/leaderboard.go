@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Leaderboard is an in-process LeaderboardPort implementation. Boards of past
+// periods are kept until restart.
+type Leaderboard struct {
+	mu     sync.RWMutex
+	boards map[string]map[string]float64
+	seen   map[string]struct{}
+}
+
+func NewLeaderboard() *Leaderboard {
+	return &Leaderboard{
+		boards: make(map[string]map[string]float64),
+		seen:   make(map[string]struct{}),
+	}
+}
+
+// AddScore adds the points of a completed recording to the current boards
+func (l *Leaderboard) AddScore(ctx context.Context, userID, recordingID string, points float64, at time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.seen[recordingID]; ok {
+		return nil
+	}
+	l.seen[recordingID] = struct{}{}
+
+	for _, period := range []domain.LeaderboardPeriod{domain.LeaderboardWeekly, domain.LeaderboardMonthly} {
+		key := boardKey(period, at)
+		if l.boards[key] == nil {
+			l.boards[key] = make(map[string]float64)
+		}
+		l.boards[key][userID] += points
+	}
+	return nil
+}
+
+// Top returns the highest ranked users of the period containing at
+func (l *Leaderboard) Top(ctx context.Context, period domain.LeaderboardPeriod, at time.Time, limit int) ([]domain.LeaderboardEntry, error) {
+	entries := l.ranked(period, at)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// Rank returns the user's position in the period containing at
+func (l *Leaderboard) Rank(ctx context.Context, period domain.LeaderboardPeriod, at time.Time, userID string) (*domain.LeaderboardEntry, error) {
+	for _, entry := range l.ranked(period, at) {
+		if entry.UserID == userID {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// RemoveUser removes the user from the current boards
+func (l *Leaderboard) RemoveUser(ctx context.Context, userID string, at time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.boards[boardKey(domain.LeaderboardWeekly, at)], userID)
+	delete(l.boards[boardKey(domain.LeaderboardMonthly, at)], userID)
+	return nil
+}
+
+// ranked returns the whole board sorted by descending score, ties broken by
+// user ID like Redis sorted sets
+func (l *Leaderboard) ranked(period domain.LeaderboardPeriod, at time.Time) []domain.LeaderboardEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	board := l.boards[boardKey(period, at)]
+	entries := make([]domain.LeaderboardEntry, 0, len(board))
+	for userID, score := range board {
+		entries = append(entries, domain.LeaderboardEntry{UserID: userID, Score: score})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].UserID > entries[j].UserID
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries
+}
+
+func boardKey(period domain.LeaderboardPeriod, at time.Time) string {
+	return string(period) + ":" + period.PeriodID(at)
+}
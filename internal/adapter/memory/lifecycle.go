@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// lifecycleCap is the number of recordings tracked per user
+const lifecycleCap = 100
+
+// RecordingLifecycles is an in-process RecordingLifecyclePort implementation
+type RecordingLifecycles struct {
+	mu         sync.Mutex
+	lifecycles map[string]map[string]domain.RecordingLifecycle // by user, then recording
+}
+
+func NewRecordingLifecycles() *RecordingLifecycles {
+	return &RecordingLifecycles{lifecycles: make(map[string]map[string]domain.RecordingLifecycle)}
+}
+
+// TrackRecording starts tracking a recording, dropping the user's oldest
+// beyond the cap
+func (r *RecordingLifecycles) TrackRecording(ctx context.Context, lifecycle domain.RecordingLifecycle) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byID, ok := r.lifecycles[lifecycle.UserID]
+	if !ok {
+		byID = make(map[string]domain.RecordingLifecycle)
+		r.lifecycles[lifecycle.UserID] = byID
+	}
+	byID[lifecycle.RecordingID] = lifecycle
+
+	for len(byID) > lifecycleCap {
+		oldest := lifecycle
+		for _, l := range byID {
+			if l.SubmittedAt.Before(oldest.SubmittedAt) {
+				oldest = l
+			}
+		}
+		delete(byID, oldest.RecordingID)
+	}
+	return nil
+}
+
+// AdvanceRecording moves a recording to a stage and reports whether it moved
+func (r *RecordingLifecycles) AdvanceRecording(ctx context.Context, userID, recordingID string, to domain.RecordingStage, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lifecycle, ok := r.lifecycles[userID][recordingID]
+	if !ok {
+		return false, domain.ErrRecordingNotTracked
+	}
+	if !lifecycle.Advance(to, at) {
+		return false, nil
+	}
+	r.lifecycles[userID][recordingID] = lifecycle
+	return true, nil
+}
+
+// ListLifecycles returns the user's tracked recordings, newest first
+func (r *RecordingLifecycles) ListLifecycles(ctx context.Context, userID string) ([]domain.RecordingLifecycle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]domain.RecordingLifecycle, 0, len(r.lifecycles[userID]))
+	for _, lifecycle := range r.lifecycles[userID] {
+		list = append(list, lifecycle)
+	}
+	slices.SortFunc(list, func(a, b domain.RecordingLifecycle) int {
+		return b.SubmittedAt.Compare(a.SubmittedAt)
+	})
+	return list, nil
+}
+
+// PendingRecordings returns the recordings whose outcome is yet to be
+// delivered, oldest first
+func (r *RecordingLifecycles) PendingRecordings(ctx context.Context) ([]domain.RecordingLifecycle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var list []domain.RecordingLifecycle
+	for _, byID := range r.lifecycles {
+		for _, lifecycle := range byID {
+			if lifecycle.Stage.Pending() {
+				list = append(list, lifecycle)
+			}
+		}
+	}
+	slices.SortFunc(list, func(a, b domain.RecordingLifecycle) int {
+		return a.SubmittedAt.Compare(b.SubmittedAt)
+	})
+	return list, nil
+}
+
+// DeleteLifecycles stops tracking all of a user's recordings
+func (r *RecordingLifecycles) DeleteLifecycles(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.lifecycles, userID)
+	return nil
+}
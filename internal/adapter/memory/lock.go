@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Locker is an in-process LockPort implementation for single-replica setups
+type Locker struct {
+	mu    sync.Mutex
+	locks map[string]time.Time
+	clock domain.Clock
+}
+
+// NewLocker creates a Locker whose locks expire on clock
+func NewLocker(clock domain.Clock) *Locker {
+	return &Locker{locks: make(map[string]time.Time), clock: clock}
+}
+
+// Acquire takes the lock for key if it is free
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if expiresAt, ok := l.locks[key]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+
+	// Drop expired locks while we hold the mutex
+	for k, expiresAt := range l.locks {
+		if !now.Before(expiresAt) {
+			delete(l.locks, k)
+		}
+	}
+
+	l.locks[key] = now.Add(ttl)
+	return true, nil
+}
+
+// Release releases a lock
+func (l *Locker) Release(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.locks, key)
+	return nil
+}
+
+// Extend makes a lock expire after ttl from now, if it is still held
+func (l *Locker) Extend(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if expiresAt, ok := l.locks[key]; !ok || !now.Before(expiresAt) {
+		return false, nil
+	}
+	l.locks[key] = now.Add(ttl)
+	return true, nil
+}
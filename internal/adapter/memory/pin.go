@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Pins is an in-process PinPort implementation
+type Pins struct {
+	mu   sync.Mutex
+	pins map[string]map[string]domain.Pin
+}
+
+func NewPins() *Pins {
+	return &Pins{pins: make(map[string]map[string]domain.Pin)}
+}
+
+// PinRecording pins a recording, or updates its pin
+func (p *Pins) PinRecording(ctx context.Context, userID string, pin domain.Pin) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pins[userID] == nil {
+		p.pins[userID] = make(map[string]domain.Pin)
+	}
+	p.pins[userID][pin.RecordingID] = pin
+	return nil
+}
+
+// UnpinRecording unpins a recording, doing nothing if it was not pinned
+func (p *Pins) UnpinRecording(ctx context.Context, userID, recordingID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pins[userID], recordingID)
+	return nil
+}
+
+// ListPins returns the user's pinned recordings, latest pinned first
+func (p *Pins) ListPins(ctx context.Context, userID string) ([]domain.Pin, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list := make([]domain.Pin, 0, len(p.pins[userID]))
+	for _, pin := range p.pins[userID] {
+		list = append(list, pin)
+	}
+	slices.SortFunc(list, func(a, b domain.Pin) int {
+		return b.PinnedAt.Compare(a.PinnedAt)
+	})
+	return list, nil
+}
+
+// DeletePins unpins all of the user's recordings
+func (p *Pins) DeletePins(ctx context.Context, userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pins, userID)
+	return nil
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Positions is an in-process PositionPort implementation
+type Positions struct {
+	mu        sync.Mutex
+	positions map[string]domain.Ayah
+}
+
+func NewPositions() *Positions {
+	return &Positions{positions: make(map[string]domain.Ayah)}
+}
+
+// SetPosition records the ayah the user last recorded
+func (p *Positions) SetPosition(ctx context.Context, userID string, ayah domain.Ayah) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.positions[userID] = ayah
+	return nil
+}
+
+// GetPosition returns the ayah the user last recorded, or nil if they
+// recorded none
+func (p *Positions) GetPosition(ctx context.Context, userID string) (*domain.Ayah, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ayah, ok := p.positions[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &ayah, nil
+}
+
+// DeletePosition forgets the user's position
+func (p *Positions) DeletePosition(ctx context.Context, userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.positions, userID)
+	return nil
+}
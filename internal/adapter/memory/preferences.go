@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Preferences is an in-process PreferencesPort implementation
+type Preferences struct {
+	mu    sync.RWMutex
+	prefs map[string]domain.Preferences
+}
+
+func NewPreferences() *Preferences {
+	return &Preferences{prefs: make(map[string]domain.Preferences)}
+}
+
+// GetPreferences retrieves a user's preferences
+func (p *Preferences) GetPreferences(ctx context.Context, userID string) (domain.Preferences, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	prefs, ok := p.prefs[userID]
+	if !ok {
+		return domain.DefaultPreferences(), nil
+	}
+	return prefs, nil
+}
+
+// SetPreferences stores a user's preferences
+func (p *Preferences) SetPreferences(ctx context.Context, userID string, prefs domain.Preferences) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prefs[userID] = prefs
+	return nil
+}
+
+// DeletePreferences deletes a user's stored preferences
+func (p *Preferences) DeletePreferences(ctx context.Context, userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.prefs, userID)
+	return nil
+}
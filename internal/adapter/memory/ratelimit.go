@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// RateLimiter is an in-process RateLimiterPort implementation
+type RateLimiter struct {
+	mu      sync.Mutex
+	limits  map[domain.RateAction]domain.RateLimit
+	buckets map[string]*bucket
+	clock   domain.Clock
+}
+
+type bucket struct {
+	tokens float64
+	ts     time.Time
+	full   time.Time // when the bucket will be refilled completely
+}
+
+// NewRateLimiter creates a RateLimiter whose buckets refill on clock
+func NewRateLimiter(limits map[domain.RateAction]domain.RateLimit, clock domain.Clock) *RateLimiter {
+	return &RateLimiter{
+		limits:  limits,
+		buckets: make(map[string]*bucket),
+		clock:   clock,
+	}
+}
+
+// Allow takes a token from the bucket of action for key
+func (l *RateLimiter) Allow(ctx context.Context, action domain.RateAction, key string) (bool, time.Duration, error) {
+	limit, ok := l.limits[action]
+	if !ok {
+		return true, 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	rate := limit.TokensPerSecond()
+	burst := float64(limit.Burst)
+
+	// Full buckets behave like missing ones, so drop them to bound memory
+	for id, b := range l.buckets {
+		if now.After(b.full) {
+			delete(l.buckets, id)
+		}
+	}
+
+	id := string(action) + ":" + key
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: burst, ts: now}
+		l.buckets[id] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.ts).Seconds()*rate)
+	b.ts = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((burst - b.tokens) / rate * float64(time.Second)))
+
+	if allowed {
+		return true, 0, nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait, nil
+}
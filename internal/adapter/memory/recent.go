@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// RecentSurahs is an in-process RecentSurahPort implementation
+type RecentSurahs struct {
+	mu     sync.Mutex
+	surahs map[string][]int
+}
+
+func NewRecentSurahs() *RecentSurahs {
+	return &RecentSurahs{surahs: make(map[string][]int)}
+}
+
+// AddRecentSurah records that the user selected a surah, keeping the
+// domain.MaxRecentSurahs selected last
+func (r *RecentSurahs) AddRecentSurah(ctx context.Context, userID string, surah int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := slices.DeleteFunc(r.surahs[userID], func(n int) bool { return n == surah })
+	list = append([]int{surah}, list...)
+	r.surahs[userID] = list[:min(len(list), domain.MaxRecentSurahs)]
+	return nil
+}
+
+// RecentSurahs returns the surahs the user selected last, latest first
+func (r *RecentSurahs) RecentSurahs(ctx context.Context, userID string) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.surahs[userID]), nil
+}
+
+// DeleteRecentSurahs forgets the surahs the user selected
+func (r *RecentSurahs) DeleteRecentSurahs(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.surahs, userID)
+	return nil
+}
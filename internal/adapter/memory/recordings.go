@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// RecordingCache is an in-process RecordingCachePort implementation
+type RecordingCache struct {
+	mu      sync.RWMutex
+	entries map[string]recordingsEntry
+}
+
+type recordingsEntry struct {
+	recordings []domain.RecordingSummary
+	fetchedAt  time.Time
+}
+
+func NewRecordingCache() *RecordingCache {
+	return &RecordingCache{entries: make(map[string]recordingsEntry)}
+}
+
+// GetRecordings returns the cached summaries and when they were fetched
+func (c *RecordingCache) GetRecordings(ctx context.Context, userID string) ([]domain.RecordingSummary, time.Time, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry := c.entries[userID]
+	return entry.recordings, entry.fetchedAt, nil
+}
+
+// SetRecordings caches the summaries fetched at the given time
+func (c *RecordingCache) SetRecordings(ctx context.Context, userID string, recordings []domain.RecordingSummary, fetchedAt time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[userID] = recordingsEntry{recordings: recordings, fetchedAt: fetchedAt}
+	return nil
+}
+
+// DeleteRecordings invalidates the cached summaries
+func (c *RecordingCache) DeleteRecordings(ctx context.Context, userID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, userID)
+	return nil
+}
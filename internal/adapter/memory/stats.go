@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Stats is an in-process StatsPort implementation
+type Stats struct {
+	mu    sync.RWMutex
+	stats map[string][]domain.StatEntry
+}
+
+func NewStats() *Stats {
+	return &Stats{stats: make(map[string][]domain.StatEntry)}
+}
+
+// AppendStat adds an entry to the user's history
+func (s *Stats) AppendStat(ctx context.Context, userID string, stat domain.StatEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.stats[userID] {
+		if existing.RecordingID == stat.RecordingID {
+			return nil
+		}
+	}
+	s.stats[userID] = append(s.stats[userID], stat)
+	return nil
+}
+
+// ListStats returns the entries recorded at or after since, oldest first
+func (s *Stats) ListStats(ctx context.Context, userID string, since time.Time) ([]domain.StatEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats []domain.StatEntry
+	for _, stat := range s.stats[userID] {
+		if !stat.RecordedAt.Before(since) {
+			stats = append(stats, stat)
+		}
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].RecordedAt.Before(stats[j].RecordedAt)
+	})
+	return stats, nil
+}
+
+// DeleteStats deletes the user's whole history
+func (s *Stats) DeleteStats(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.stats, userID)
+	return nil
+}
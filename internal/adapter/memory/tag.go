@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Tags is an in-process TagPort implementation
+type Tags struct {
+	mu   sync.Mutex
+	tags map[string]map[string][]string
+}
+
+func NewTags() *Tags {
+	return &Tags{tags: make(map[string]map[string][]string)}
+}
+
+// SetTags replaces the tags of a recording, removing them all when tags is
+// empty
+func (t *Tags) SetTags(ctx context.Context, userID, recordingID string, tags []string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(tags) == 0 {
+		delete(t.tags[userID], recordingID)
+		return nil
+	}
+	if t.tags[userID] == nil {
+		t.tags[userID] = make(map[string][]string)
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	t.tags[userID][recordingID] = tags
+	return nil
+}
+
+// ListTags returns the tags of the user's tagged recordings by recording ID
+func (t *Tags) ListTags(ctx context.Context, userID string) (map[string][]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	list := maps.Clone(t.tags[userID])
+	for id, tags := range list {
+		list[id] = slices.Clone(tags)
+	}
+	return list, nil
+}
+
+// DeleteTags removes the tags of all of the user's recordings
+func (t *Tags) DeleteTags(ctx context.Context, userID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.tags, userID)
+	return nil
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// handledUpdate is an update ID and when it was handled
+type handledUpdate struct {
+	id int
+	at time.Time
+}
+
+// UpdateLog is an in-process UpdateLogPort implementation for
+// single-replica setups
+type UpdateLog struct {
+	mu      sync.Mutex
+	handled map[int]time.Time
+	order   []handledUpdate // oldest first
+	clock   domain.Clock
+}
+
+// NewUpdateLog creates an UpdateLog whose entries expire on clock
+func NewUpdateLog(clock domain.Clock) *UpdateLog {
+	return &UpdateLog{handled: make(map[int]time.Time), clock: clock}
+}
+
+// MarkHandled records an update and reports whether it was not handled
+// within the last ttl
+func (u *UpdateLog) MarkHandled(ctx context.Context, updateID int, ttl time.Duration) (bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.clock.Now()
+	for len(u.order) > 0 && now.Sub(u.order[0].at) >= ttl {
+		delete(u.handled, u.order[0].id)
+		u.order = u.order[1:]
+	}
+
+	if _, ok := u.handled[updateID]; ok {
+		return false, nil
+	}
+	u.handled[updateID] = now
+	u.order = append(u.order, handledUpdate{id: updateID, at: now})
+	return true, nil
+}
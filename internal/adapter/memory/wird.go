@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Wirds is an in-process WirdPort implementation
+type Wirds struct {
+	mu    sync.Mutex
+	wirds map[string]domain.Wird
+}
+
+func NewWirds() *Wirds {
+	return &Wirds{wirds: make(map[string]domain.Wird)}
+}
+
+// SaveWird adds or replaces the user's subscription
+func (w *Wirds) SaveWird(ctx context.Context, wird domain.Wird) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.wirds[wird.UserID] = wird
+	return nil
+}
+
+// GetWird returns the user's subscription, or nil if they have none
+func (w *Wirds) GetWird(ctx context.Context, userID string) (*domain.Wird, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wird, ok := w.wirds[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &wird, nil
+}
+
+// AllWirds returns the subscriptions of every user
+func (w *Wirds) AllWirds(ctx context.Context) ([]domain.Wird, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	list := make([]domain.Wird, 0, len(w.wirds))
+	for _, wird := range w.wirds {
+		list = append(list, wird)
+	}
+	return list, nil
+}
+
+// DeleteWird removes the user's subscription
+func (w *Wirds) DeleteWird(ctx context.Context, userID string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.wirds, userID)
+	return nil
+}
@@ -0,0 +1,98 @@
+package quranapi
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// CachedClient wraps a QuranAPIPort with a bounded in-process LRU of
+// finished recordings. Recordings that are still queued are never cached
+// since their result may change.
+type CachedClient struct {
+	domain.QuranAPIPort
+
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key       string
+	recording *domain.Recording
+}
+
+func NewCachedClient(api domain.QuranAPIPort, size int) *CachedClient {
+	return &CachedClient{
+		QuranAPIPort: api,
+		size:         size,
+		order:        list.New(),
+		entries:      make(map[string]*list.Element),
+	}
+}
+
+// GetRecording retrieves a recording by ID, from the cache when possible
+func (c *CachedClient) GetRecording(ctx context.Context, learnerID, recordingID string) (*domain.Recording, error) {
+	key := learnerID + ":" + recordingID
+	if recording, ok := c.get(key); ok {
+		return recording, nil
+	}
+
+	recording, err := c.QuranAPIPort.GetRecording(ctx, learnerID, recordingID)
+	if err != nil {
+		return nil, err
+	}
+	if recording.Status != domain.StatusQueued {
+		c.put(key, recording)
+	}
+	return recording, nil
+}
+
+// DeleteRecording deletes a recording and evicts it from the cache
+func (c *CachedClient) DeleteRecording(ctx context.Context, learnerID, recordingID string) error {
+	c.evict(learnerID + ":" + recordingID)
+	return c.QuranAPIPort.DeleteRecording(ctx, learnerID, recordingID)
+}
+
+func (c *CachedClient) get(key string) (*domain.Recording, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).recording, true
+}
+
+func (c *CachedClient) put(key string, recording *domain.Recording) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).recording = recording
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, recording: recording})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *CachedClient) evict(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
 )
 
 type Client struct {
@@ -29,8 +31,10 @@ func NewClient(baseURL, apiKey string) *Client {
 	}
 }
 
-// SubmitRecording submits a voice recording for analysis
-func (c *Client) SubmitRecording(ctx context.Context, learnerID, ayahID string, audioFile io.Reader) (*domain.Recording, error) {
+// SubmitRecording submits a voice recording for analysis, with its metadata
+// as form fields. The riwayah tells the API how the ayah is numbered.
+// Without an ayah ID, the API auto-detects the ayah.
+func (c *Client) SubmitRecording(ctx context.Context, learnerID, ayahID string, riwayah domain.Riwayah, audioFile io.Reader, meta domain.AudioMetadata) (*domain.Recording, error) {
 	// Read audio data
 	audioData, err := io.ReadAll(audioFile)
 	if err != nil {
@@ -41,7 +45,11 @@ func (c *Client) SubmitRecording(ctx context.Context, learnerID, ayahID string,
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
 
-	part, err := writer.CreateFormFile("file", "recording.wav")
+	format := meta.Format
+	if format == "" {
+		format = "wav"
+	}
+	part, err := writer.CreateFormFile("file", "recording."+format)
 	if err != nil {
 		return nil, fmt.Errorf("create form file: %w", err)
 	}
@@ -50,30 +58,36 @@ func (c *Client) SubmitRecording(ctx context.Context, learnerID, ayahID string,
 		return nil, fmt.Errorf("write audio data: %w", err)
 	}
 
+	if err := writeMetadata(writer, meta); err != nil {
+		return nil, fmt.Errorf("write metadata: %w", err)
+	}
+
 	if err := writer.Close(); err != nil {
 		return nil, fmt.Errorf("close writer: %w", err)
 	}
 
 	// Create request
-	url := fmt.Sprintf("%s/recordings?learner_id=%s&ayah_id=%s", c.baseURL, learnerID, ayahID)
+	url := fmt.Sprintf("%s/recordings?learner_id=%s&riwayah=%s", c.baseURL, learnerID, riwayah)
+	if ayahID != "" {
+		url += "&ayah_id=" + ayahID
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("x-api-key", c.apiKey)
+	c.setHeaders(req)
 
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
+		return nil, fmt.Errorf("%w: send request: %w", domain.ErrBackendUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, apiError(resp)
 	}
 
 	// Parse response
@@ -98,6 +112,41 @@ func (c *Client) SubmitRecording(ctx context.Context, learnerID, ayahID string,
 	return recording, nil
 }
 
+// writeMetadata adds the known audio metadata to the form
+// apiError returns the error of a response with an unexpected status. Refused
+// quotas and server failures wrap domain.ErrQuotaExceeded and
+// domain.ErrBackendUnavailable.
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	err := fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %w", domain.ErrQuotaExceeded, err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %w", domain.ErrBackendUnavailable, err)
+	}
+	return err
+}
+
+func writeMetadata(writer *multipart.Writer, meta domain.AudioMetadata) error {
+	if meta.Duration > 0 {
+		if err := writer.WriteField("duration", strconv.FormatFloat(meta.Duration.Seconds(), 'f', 3, 64)); err != nil {
+			return err
+		}
+	}
+	if meta.SampleRate > 0 {
+		if err := writer.WriteField("sample_rate", strconv.Itoa(meta.SampleRate)); err != nil {
+			return err
+		}
+	}
+	if meta.OriginalFormat != "" {
+		if err := writer.WriteField("original_format", meta.OriginalFormat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetRecording retrieves a recording by ID
 func (c *Client) GetRecording(ctx context.Context, learnerID, recordingID string) (*domain.Recording, error) {
 	url := fmt.Sprintf("%s/recordings?learner_id=%s&recording_ids=%s", c.baseURL, learnerID, recordingID)
@@ -106,17 +155,16 @@ func (c *Client) GetRecording(ctx context.Context, learnerID, recordingID string
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
-	req.Header.Set("x-api-key", c.apiKey)
+	c.setHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
+		return nil, fmt.Errorf("%w: send request: %w", domain.ErrBackendUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, apiError(resp)
 	}
 
 	var result struct {
@@ -143,17 +191,16 @@ func (c *Client) ListRecordings(ctx context.Context, learnerID string, limit int
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
-	req.Header.Set("x-api-key", c.apiKey)
+	c.setHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
+		return nil, fmt.Errorf("%w: send request: %w", domain.ErrBackendUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, apiError(resp)
 	}
 
 	var result struct {
@@ -172,6 +219,63 @@ func (c *Client) ListRecordings(ctx context.Context, learnerID string, limit int
 	return recordings, nil
 }
 
+// setHeaders authenticates a request and tags it with the request ID of its
+// context, if any, so API logs can be matched with the bot's
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", c.apiKey)
+	if id := requestid.From(req.Context()); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+}
+
+// pingLearnerID is the learner whose (empty) recordings list Ping fetches
+const pingLearnerID = "healthcheck"
+
+// Ping checks that the API is reachable and accepts the API key
+func (c *Client) Ping(ctx context.Context) error {
+	url := fmt.Sprintf("%s/recordings/%s?limit=1", c.baseURL, pingLearnerID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// DeleteRecording permanently deletes a recording and its analysis
+func (c *Client) DeleteRecording(ctx context.Context, learnerID, recordingID string) error {
+	url := fmt.Sprintf("%s/recordings?learner_id=%s&recording_ids=%s", c.baseURL, learnerID, recordingID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: send request: %w", domain.ErrBackendUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+
+	return nil
+}
+
 type recordingResponse struct {
 	RecordingID string          `json:"recording_id"`
 	LearnerID   string          `json:"learner_id"`
@@ -180,6 +284,8 @@ type recordingResponse struct {
 	CreatedAt   string          `json:"createdAt"`
 	UpdatedAt   string          `json:"updatedAt"`
 	Result      *resultResponse `json:"result"`
+	Error       string          `json:"error"`
+	Stage       string          `json:"stage"`
 }
 
 type resultResponse struct {
@@ -204,6 +310,11 @@ func mapRecording(r *recordingResponse) *domain.Recording {
 		LearnerID: r.LearnerID,
 		AyahID:    r.AyahID,
 		Status:    domain.RecordingStatus(r.Status),
+		Error:     r.Error,
+	}
+	if recording.Status == domain.StatusFailed && recording.Error == "" && r.Stage != "" {
+		// The stage tells at least what the analysis failed to do
+		recording.Error = "failed at stage " + r.Stage
 	}
 
 	if r.CreatedAt != "" {
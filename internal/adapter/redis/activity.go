@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	activityKey = "activity"
+
+	// activityRetention is how long users who stopped using the bot are
+	// remembered
+	activityRetention = 180 * 24 * time.Hour
+)
+
+// Activity keeps one sorted set of users scored by when they were last seen,
+// in unix milliseconds
+type Activity struct {
+	client *Client
+}
+
+func NewActivity(client *Client) *Activity {
+	return &Activity{client: client}
+}
+
+// TouchUser records that the user interacted with the bot at the given time,
+// and forgets users inactive for longer than the retention
+func (a *Activity) TouchUser(ctx context.Context, userID string, at time.Time) error {
+	key := a.client.Key(activityKey)
+	_, err := a.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAddGT(ctx, key, redis.Z{Score: float64(at.UnixMilli()), Member: userID})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(at.Add(-activityRetention).UnixMilli(), 10))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("touch user: %w", err)
+	}
+	return nil
+}
+
+// ActiveUsers returns the users who interacted at or after since, most
+// recent first
+func (a *Activity) ActiveUsers(ctx context.Context, since time.Time) ([]domain.ActiveUser, error) {
+	members, err := a.client.ZRevRangeByScoreWithScores(ctx, a.client.Key(activityKey), &redis.ZRangeBy{
+		Min: strconv.FormatInt(since.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get active users: %w", err)
+	}
+
+	users := make([]domain.ActiveUser, 0, len(members))
+	for _, m := range members {
+		users = append(users, domain.ActiveUser{
+			UserID:   m.Member.(string),
+			LastSeen: time.UnixMilli(int64(m.Score)),
+		})
+	}
+	return users, nil
+}
+
+// RemoveUser forgets the user
+func (a *Activity) RemoveUser(ctx context.Context, userID string) error {
+	if err := a.client.ZRem(ctx, a.client.Key(activityKey), userID).Err(); err != nil {
+		return fmt.Errorf("remove user activity: %w", err)
+	}
+	return nil
+}
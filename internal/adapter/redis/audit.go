@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	auditKeyPrefix = "audit:"
+
+	// auditCap is the number of entries kept per user
+	auditCap = 200
+
+	// auditTTL drops the logs of users who stopped using the bot
+	auditTTL = 30 * 24 * time.Hour
+)
+
+// AuditLog keeps each user's audit log in a capped Redis list, newest first
+type AuditLog struct {
+	client *Client
+}
+
+func NewAuditLog(client *Client) *AuditLog {
+	return &AuditLog{client: client}
+}
+
+// AppendAudit adds an entry, dropping the oldest ones beyond the cap
+func (a *AuditLog) AppendAudit(ctx context.Context, userID string, entry domain.AuditEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode audit entry: %w", err)
+	}
+
+	key := a.client.Key(auditKeyPrefix + userID)
+	_, err = a.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, raw)
+		pipe.LTrim(ctx, key, 0, auditCap-1)
+		pipe.Expire(ctx, key, auditTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("append audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAudit returns up to limit entries, newest first
+func (a *AuditLog) ListAudit(ctx context.Context, userID string, limit int) ([]domain.AuditEntry, error) {
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(limit) - 1
+	}
+
+	items, err := a.client.LRange(ctx, a.client.Key(auditKeyPrefix+userID), 0, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list audit entries: %w", err)
+	}
+
+	entries := make([]domain.AuditEntry, 0, len(items))
+	for _, item := range items {
+		var entry domain.AuditEntry
+		if err := json.Unmarshal([]byte(item), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// DeleteAudit deletes the user's whole log
+func (a *AuditLog) DeleteAudit(ctx context.Context, userID string) error {
+	return a.client.Del(ctx, a.client.Key(auditKeyPrefix+userID)).Err()
+}
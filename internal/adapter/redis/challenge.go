@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	challengeKeyPrefix       = "challenge:"
+	challengeAnswerKeyPrefix = "challenge:answer:"
+)
+
+// Challenges keeps each challenge, and each attempt at one by recording ID,
+// as JSON strings that expire on their own
+type Challenges struct {
+	client *Client
+}
+
+func NewChallenges(client *Client) *Challenges {
+	return &Challenges{client: client}
+}
+
+// SaveChallenge stores a challenge
+func (c *Challenges) SaveChallenge(ctx context.Context, challenge domain.Challenge, ttl time.Duration) error {
+	raw, err := json.Marshal(challenge)
+	if err != nil {
+		return fmt.Errorf("encode challenge: %w", err)
+	}
+	if err := c.client.Set(ctx, c.client.Key(challengeKeyPrefix+challenge.ID), raw, ttl).Err(); err != nil {
+		return fmt.Errorf("save challenge: %w", err)
+	}
+	return nil
+}
+
+// GetChallenge returns a challenge
+func (c *Challenges) GetChallenge(ctx context.Context, id string) (*domain.Challenge, error) {
+	raw, err := c.client.Get(ctx, c.client.Key(challengeKeyPrefix+id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, domain.ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get challenge: %w", err)
+	}
+	var challenge domain.Challenge
+	if err := json.Unmarshal(raw, &challenge); err != nil {
+		return nil, fmt.Errorf("decode challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// SaveAnswer stores a friend's attempt at a challenge
+func (c *Challenges) SaveAnswer(ctx context.Context, answer domain.ChallengeAnswer, ttl time.Duration) error {
+	raw, err := json.Marshal(answer)
+	if err != nil {
+		return fmt.Errorf("encode challenge answer: %w", err)
+	}
+	if err := c.client.Set(ctx, c.client.Key(challengeAnswerKeyPrefix+answer.RecordingID), raw, ttl).Err(); err != nil {
+		return fmt.Errorf("save challenge answer: %w", err)
+	}
+	return nil
+}
+
+// GetAnswer returns the challenge attempt a recording is, or nil if it is
+// none
+func (c *Challenges) GetAnswer(ctx context.Context, recordingID string) (*domain.ChallengeAnswer, error) {
+	raw, err := c.client.Get(ctx, c.client.Key(challengeAnswerKeyPrefix+recordingID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get challenge answer: %w", err)
+	}
+	var answer domain.ChallengeAnswer
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return nil, fmt.Errorf("decode challenge answer: %w", err)
+	}
+	return &answer, nil
+}
@@ -0,0 +1,70 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const chunkKeyPrefix = "recording_chunks:"
+
+// RecordingChunks keeps the chunks of each user's split recordings in a
+// Redis hash of JSON lists by the recording of their first chunk
+type RecordingChunks struct {
+	client *Client
+}
+
+func NewRecordingChunks(client *Client) *RecordingChunks {
+	return &RecordingChunks{client: client}
+}
+
+// SetChunks stores the chunks of a recording
+func (c *RecordingChunks) SetChunks(ctx context.Context, userID, recordingID string, chunks []domain.RecordingChunk) error {
+	raw, err := json.Marshal(chunks)
+	if err != nil {
+		return fmt.Errorf("encode chunks: %w", err)
+	}
+	if err := c.client.HSet(ctx, c.key(userID), recordingID, raw).Err(); err != nil {
+		return fmt.Errorf("set chunks: %w", err)
+	}
+	return nil
+}
+
+// ListChunks returns the chunks of the user's split recordings
+func (c *RecordingChunks) ListChunks(ctx context.Context, userID string) (map[string][]domain.RecordingChunk, error) {
+	fields, err := c.client.HGetAll(ctx, c.key(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list chunks: %w", err)
+	}
+
+	list := make(map[string][]domain.RecordingChunk, len(fields))
+	for recordingID, raw := range fields {
+		var chunks []domain.RecordingChunk
+		if err := json.Unmarshal([]byte(raw), &chunks); err != nil {
+			continue
+		}
+		list[recordingID] = chunks
+	}
+	return list, nil
+}
+
+// DeleteChunks forgets the chunks of one of the user's recordings, or of
+// all of them when recordingID is empty
+func (c *RecordingChunks) DeleteChunks(ctx context.Context, userID, recordingID string) error {
+	var err error
+	if recordingID == "" {
+		err = c.client.Del(ctx, c.key(userID)).Err()
+	} else {
+		err = c.client.HDel(ctx, c.key(userID), recordingID).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("delete chunks: %w", err)
+	}
+	return nil
+}
+
+func (c *RecordingChunks) key(userID string) string {
+	return c.client.Key(chunkKeyPrefix + userID)
+}
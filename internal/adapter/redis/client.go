@@ -0,0 +1,192 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Options configures the connection to Redis
+type Options struct {
+	// URL is a redis:// or rediss:// (TLS) URI. When set, it replaces Addr,
+	// Username, Password and DB.
+	URL string
+
+	Addr     string
+	Username string // ACL user, empty for the default user
+	Password string
+	DB       int
+
+	TLS  TLSOptions
+	Pool PoolOptions
+
+	// Namespace prefixes every key and channel, e.g. with the bot's username,
+	// so staging and production bots can share one Redis. Empty keeps the
+	// unprefixed keys.
+	Namespace string
+}
+
+// TLSOptions configures TLS for managed Redis services. TLS is also enabled
+// by a rediss:// URL.
+type TLSOptions struct {
+	Enabled            bool
+	CAFile             string // PEM bundle used instead of the system roots
+	CertFile           string // client certificate for mutual TLS
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// Client is the connection shared by all Redis-backed adapters. Every key and
+// channel they use goes through Key, so that several bots can share one
+// Redis instance.
+type Client struct {
+	*redis.Client
+	namespace string
+}
+
+// PoolOptions tunes the connection pool, timeouts and retries. Zero values
+// keep the go-redis defaults.
+type PoolOptions struct {
+	PoolSize        int // maximum connections, default 10 per CPU
+	MinIdleConns    int
+	PoolTimeout     time.Duration // wait for a free connection
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	MaxRetries      int // -1 disables retries
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// apply overrides the non-zero settings in options
+func (p PoolOptions) apply(options *redis.Options) {
+	if p.PoolSize != 0 {
+		options.PoolSize = p.PoolSize
+	}
+	if p.MinIdleConns != 0 {
+		options.MinIdleConns = p.MinIdleConns
+	}
+	if p.PoolTimeout != 0 {
+		options.PoolTimeout = p.PoolTimeout
+	}
+	if p.DialTimeout != 0 {
+		options.DialTimeout = p.DialTimeout
+	}
+	if p.ReadTimeout != 0 {
+		options.ReadTimeout = p.ReadTimeout
+	}
+	if p.WriteTimeout != 0 {
+		options.WriteTimeout = p.WriteTimeout
+	}
+	if p.MaxRetries != 0 {
+		options.MaxRetries = p.MaxRetries
+	}
+	if p.MinRetryBackoff != 0 {
+		options.MinRetryBackoff = p.MinRetryBackoff
+	}
+	if p.MaxRetryBackoff != 0 {
+		options.MaxRetryBackoff = p.MaxRetryBackoff
+	}
+}
+
+// NewClient connects to Redis and verifies the connection
+func NewClient(opts Options) (*Client, error) {
+	options, err := opts.redisOptions()
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(options)
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis: %w", err)
+	}
+
+	return &Client{Client: client, namespace: opts.Namespace}, nil
+}
+
+// WithNamespace returns a client sharing c's connections whose keys are
+// further prefixed with namespace, to keep the state of several bots apart.
+// Only c must be closed.
+func (c *Client) WithNamespace(namespace string) *Client {
+	if namespace == "" {
+		return c
+	}
+	if c.namespace != "" {
+		namespace = c.namespace + ":" + namespace
+	}
+	return &Client{Client: c.Client, namespace: namespace}
+}
+
+// Key prefixes key with the namespace, if any
+func (c *Client) Key(key string) string {
+	if c.namespace == "" {
+		return key
+	}
+	return c.namespace + ":" + key
+}
+
+func (o Options) redisOptions() (*redis.Options, error) {
+	var options *redis.Options
+	if o.URL != "" {
+		var err error
+		options, err = redis.ParseURL(o.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parse redis url: %w", err)
+		}
+	} else {
+		options = &redis.Options{
+			Addr:     o.Addr,
+			Username: o.Username,
+			Password: o.Password,
+			DB:       o.DB,
+		}
+	}
+	o.Pool.apply(options)
+
+	if !o.TLS.Enabled && options.TLSConfig == nil {
+		return options, nil
+	}
+
+	tlsConfig := options.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if o.TLS.ServerName != "" {
+		tlsConfig.ServerName = o.TLS.ServerName
+	}
+	tlsConfig.InsecureSkipVerify = o.TLS.InsecureSkipVerify
+
+	if o.TLS.CAFile != "" {
+		pem, err := os.ReadFile(o.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if o.TLS.CertFile != "" || o.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.TLS.CertFile, o.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	options.TLSConfig = tlsConfig
+	return options, nil
+}
@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const recordingEventsChannel = "events:recordings"
+
+// EventBus implements EventBusPort with Redis pub/sub, delivering each event
+// to every subscribed replica
+type EventBus struct {
+	client *Client
+}
+
+func NewEventBus(client *Client) *EventBus {
+	return &EventBus{client: client}
+}
+
+// Publish broadcasts a recording event
+func (e *EventBus) Publish(ctx context.Context, event domain.RecordingEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	if err := e.client.Publish(ctx, e.client.Key(recordingEventsChannel), payload).Err(); err != nil {
+		return fmt.Errorf("publish event: %w", err)
+	}
+	return nil
+}
+
+// Subscribe calls handler for every published event until ctx is done
+func (e *EventBus) Subscribe(ctx context.Context, handler func(ctx context.Context, event domain.RecordingEvent)) error {
+	sub := e.client.Subscribe(ctx, e.client.Key(recordingEventsChannel))
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+
+			var event domain.RecordingEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("Error decoding recording event: %v", err)
+				continue
+			}
+			handler(ctx, event)
+		}
+	}
+}
@@ -2,7 +2,12 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/escalopa/quran-read-bot/internal/domain"
@@ -10,83 +15,397 @@ import (
 )
 
 const (
-	stateKeyPrefix = "fsm:state:"
-	dataKeyPrefix  = "fsm:data:"
-	defaultTTL     = 24 * time.Hour
+	sessionKeyPrefix = "fsm:session:"
+	// expiringKey is a sorted set of the scope keys of mid-flow sessions,
+	// scored by the session's expiry in unix milliseconds
+	expiringKey  = "fsm:expiring"
+	versionField = "v"
+	defaultTTL   = 24 * time.Hour
+
+	// maxUpdateAttempts bounds the retries of UpdateSession under contention
+	maxUpdateAttempts = 5
+
+	// SessionVersion is the current session schema version. Bump it and
+	// register a Migration whenever state names or session fields change.
+	SessionVersion = 5
+)
+
+// Session hash fields
+const (
+	stateField         = "state"
+	flowField          = "flow"
+	contextField       = "context"
+	modeField          = "mode"
+	lastMessageIDField = "last_message_id"
 )
 
+// Session hash field of v3, when the recording a tag was typed for was kept
+// aside of the flows
+const v3TagRecordingField = "tag_recording_id"
+
+// Session hash field of v4, when the word drill was kept aside of the flows
+const v4DrillField = "drill"
+
+// Session hash fields of v2, when the manual recording flow was the only one
+const (
+	v2SurahField     = "surah"
+	v2AyahField      = "ayah"
+	v2AyahInputField = "ayah_input"
+)
+
+// popExpiredScript takes the users whose mid-flow session has expired off
+// the expiring set atomically, so each is popped by exactly one replica
+var popExpiredScript = redis.NewScript(`
+local users = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+if #users > 0 then
+	redis.call("ZREM", KEYS[1], unpack(users))
+end
+return users
+`)
+
+// Migration upgrades the raw hash fields of a session by one schema version.
+// Migrations run lazily when a session is read. Returning an error discards
+// the session so the user starts over instead of getting stuck.
+type Migration func(fields map[string]string) (map[string]string, error)
+
+// FSM stores each session in a single Redis hash so that the state and all
+// session data share one key and one TTL. Hashes are keyed by the
+// SessionScope key, so private sessions keep their pre-scope keys.
 type FSM struct {
-	client *redis.Client
+	client     *Client
+	clock      domain.Clock
+	migrations map[int]Migration
 }
 
-func NewFSM(addr, password string, db int) (*FSM, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
+// NewFSM creates an FSM whose sessions expire mid-flow on clock
+func NewFSM(client *Client, clock domain.Clock) *FSM {
+	f := &FSM{
+		client:     client,
+		clock:      clock,
+		migrations: make(map[int]Migration),
+	}
+
+	// Sessions written before versioning was introduced share the v1 layout
+	f.RegisterMigration(0, func(fields map[string]string) (map[string]string, error) {
+		return fields, nil
+	})
+
+	// v1 stored free-form data under "data:<key>" fields; v2 has one field
+	// per typed session attribute
+	f.RegisterMigration(1, func(fields map[string]string) (map[string]string, error) {
+		migrated := make(map[string]string, len(fields))
+		for field, value := range fields {
+			if key, ok := strings.CutPrefix(field, "data:"); ok {
+				field = key
+			}
+			migrated[field] = value
+		}
+		return migrated, nil
 	})
 
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// v2 had one field per attribute of the manual recording flow; v3 keeps
+	// the flow and its context encoded in JSON
+	f.RegisterMigration(2, func(fields map[string]string) (map[string]string, error) {
+		var flow domain.ManualRecordingFlow
+		flow.SurahNumber, _ = strconv.Atoi(fields[v2SurahField])
+		flow.AyahNumber, _ = strconv.Atoi(fields[v2AyahField])
+		flow.AyahInput = fields[v2AyahInputField]
+		delete(fields, v2SurahField)
+		delete(fields, v2AyahField)
+		delete(fields, v2AyahInputField)
+
+		if flow != (domain.ManualRecordingFlow{}) {
+			raw, err := json.Marshal(flow)
+			if err != nil {
+				return nil, err
+			}
+			fields[flowField] = string(domain.FlowManualRecording)
+			fields[contextField] = string(raw)
+		}
+		return fields, nil
+	})
+
+	// v3 kept the recording a tag was typed for in its own field; v4 has a
+	// tag flow pausing the flow the session was in
+	f.RegisterMigration(3, func(fields map[string]string) (map[string]string, error) {
+		recordingID := fields[v3TagRecordingField]
+		delete(fields, v3TagRecordingField)
+		if recordingID == "" {
+			return fields, nil
+		}
+
+		session := domain.Session{Flow: domain.Flow(fields[flowField])}
+		if raw := fields[contextField]; raw != "" {
+			session.Context = []byte(raw)
+		}
+		session.StartTag(recordingID)
+		fields[flowField] = string(session.Flow)
+		fields[contextField] = string(session.Context)
+		return fields, nil
+	})
 
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("connect to redis: %w", err)
+	// v4 kept the word drill in its own field; v5 has a word drill flow
+	// pausing the flow the session was in
+	f.RegisterMigration(4, func(fields map[string]string) (map[string]string, error) {
+		raw := fields[v4DrillField]
+		delete(fields, v4DrillField)
+		if raw == "" {
+			return fields, nil
+		}
+		var drill domain.WordDrill
+		if err := json.Unmarshal([]byte(raw), &drill); err != nil {
+			return nil, err
+		}
+
+		session := domain.Session{Flow: domain.Flow(fields[flowField])}
+		if raw := fields[contextField]; raw != "" {
+			session.Context = []byte(raw)
+		}
+		session.StartWordDrill(drill)
+		fields[flowField] = string(session.Flow)
+		fields[contextField] = string(session.Context)
+		return fields, nil
+	})
+
+	return f
+}
+
+// RegisterMigration registers the migration that upgrades sessions from
+// version from to version from+1
+func (f *FSM) RegisterMigration(from int, m Migration) {
+	f.migrations[from] = m
+}
+
+// GetSession reads a session with a single HGETALL
+func (f *FSM) GetSession(ctx context.Context, scope domain.SessionScope) (*domain.Session, error) {
+	fields, err := f.load(ctx, f.client, scope.Key())
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
 	}
+	return parseSession(fields), nil
+}
 
-	return &FSM{client: client}, nil
+// SaveSession replaces the session hash and refreshes its TTL in a single
+// MULTI/EXEC round trip
+func (f *FSM) SaveSession(ctx context.Context, scope domain.SessionScope, session *domain.Session) error {
+	_, err := f.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		f.writeSession(ctx, pipe, scope.Key(), session)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
 }
 
-func (f *FSM) Close() error {
-	return f.client.Close()
+// UpdateSession applies fn to the session with optimistic locking: the hash
+// is WATCHed while fn runs and the write is retried if another update won
+func (f *FSM) UpdateSession(ctx context.Context, scope domain.SessionScope, fn func(session *domain.Session) error) (*domain.Session, error) {
+	id := scope.Key()
+	key := f.sessionKey(id)
+
+	var session *domain.Session
+	update := func(tx *redis.Tx) error {
+		fields, err := f.load(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		session = parseSession(fields)
+		if err := fn(session); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			f.writeSession(ctx, pipe, id, session)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxUpdateAttempts; i++ {
+		err := f.client.Watch(ctx, update, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return session, nil
+	}
+	return nil, fmt.Errorf("update session: gave up after %d conflicting attempts", maxUpdateAttempts)
 }
 
-// SetState sets the current state for a user
-func (f *FSM) SetState(ctx context.Context, userID string, state domain.State) error {
-	key := stateKeyPrefix + userID
-	return f.client.Set(ctx, key, string(state), defaultTTL).Err()
+// ClearSession deletes a session
+func (f *FSM) ClearSession(ctx context.Context, scope domain.SessionScope) error {
+	return f.clear(ctx, []string{scope.Key()})
 }
 
-// GetState gets the current state for a user
-func (f *FSM) GetState(ctx context.Context, userID string) (domain.State, error) {
-	key := stateKeyPrefix + userID
-	val, err := f.client.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return domain.StateStart, nil
+// ClearUserSessions deletes the user's private session and SCANs for the
+// sessions they have in group chats
+func (f *FSM) ClearUserSessions(ctx context.Context, userID string) error {
+	ids := []string{domain.PrivateScope(userID).Key()}
+
+	iter := f.client.Scan(ctx, 0, f.sessionKey(userID)+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		ids = append(ids, strings.TrimPrefix(iter.Val(), f.sessionKey("")))
 	}
-	if err != nil {
-		return "", fmt.Errorf("get state: %w", err)
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("scan sessions: %w", err)
 	}
-	return domain.State(val), nil
+
+	return f.clear(ctx, ids)
 }
 
-// DeleteState deletes the state for a user
-func (f *FSM) DeleteState(ctx context.Context, userID string) error {
-	key := stateKeyPrefix + userID
-	return f.client.Del(ctx, key).Err()
+func (f *FSM) clear(ctx context.Context, ids []string) error {
+	_, err := f.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			pipe.Del(ctx, f.sessionKey(id))
+			pipe.ZRem(ctx, f.client.Key(expiringKey), id)
+		}
+		return nil
+	})
+	return err
 }
 
-// SetData sets temporary data for a user's current session
-func (f *FSM) SetData(ctx context.Context, userID, key, value string) error {
-	dataKey := fmt.Sprintf("%s%s:%s", dataKeyPrefix, userID, key)
-	return f.client.Set(ctx, dataKey, value, defaultTTL).Err()
+// PopExpiredSessions returns up to limit sessions that expired mid-flow
+func (f *FSM) PopExpiredSessions(ctx context.Context, limit int) ([]domain.SessionScope, error) {
+	now := strconv.FormatInt(f.clock.Now().UnixMilli(), 10)
+	ids, err := popExpiredScript.Run(ctx, f.client, []string{f.client.Key(expiringKey)}, now, limit).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("pop expired sessions: %w", err)
+	}
+
+	scopes := make([]domain.SessionScope, 0, len(ids))
+	for _, id := range ids {
+		scope, err := domain.ParseSessionScope(id)
+		if err != nil {
+			log.Printf("Skipping expired session: %v", err)
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
 }
 
-// GetData gets temporary data for a user's current session
-func (f *FSM) GetData(ctx context.Context, userID, key string) (string, error) {
-	dataKey := fmt.Sprintf("%s%s:%s", dataKeyPrefix, userID, key)
-	val, err := f.client.Get(ctx, dataKey).Result()
-	if err == redis.Nil {
-		return "", fmt.Errorf("data not found")
+// load reads the whole session hash through rd, migrating it to
+// SessionVersion first if it was written by an older schema. Migrations are
+// written outside of any WATCH, so an UpdateSession that triggers one simply
+// retries on the migrated hash.
+func (f *FSM) load(ctx context.Context, rd redis.Cmdable, id string) (map[string]string, error) {
+	key := f.sessionKey(id)
+
+	fields, err := rd.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return fields, nil
+	}
+
+	version, _ := strconv.Atoi(fields[versionField])
+	if version == SessionVersion {
+		return fields, nil
+	}
+
+	migrated, err := f.migrate(fields, version)
+	if err != nil {
+		log.Printf("Discarding session %s: %v", id, err)
+		if err := f.client.Del(ctx, key).Err(); err != nil {
+			return nil, fmt.Errorf("discard session: %w", err)
+		}
+		return map[string]string{}, nil
+	}
+
+	values := make([]string, 0, len(migrated)*2)
+	for k, v := range migrated {
+		values = append(values, k, v)
 	}
+	_, err = f.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, values)
+		pipe.Expire(ctx, key, defaultTTL)
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("get data: %w", err)
+		return nil, fmt.Errorf("store migrated session: %w", err)
+	}
+
+	return migrated, nil
+}
+
+// migrate applies the registered migrations from version up to SessionVersion
+func (f *FSM) migrate(fields map[string]string, version int) (map[string]string, error) {
+	if version > SessionVersion {
+		return nil, fmt.Errorf("session version %d is newer than supported %d", version, SessionVersion)
+	}
+
+	for v := version; v < SessionVersion; v++ {
+		m, ok := f.migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("no migration from session version %d", v)
+		}
+
+		var err error
+		fields, err = m(fields)
+		if err != nil {
+			return nil, fmt.Errorf("migrate session from version %d: %w", v, err)
+		}
+	}
+
+	fields[versionField] = strconv.Itoa(SessionVersion)
+	return fields, nil
+}
+
+func parseSession(fields map[string]string) *domain.Session {
+	session := domain.NewSession()
+	if state := fields[stateField]; state != "" {
+		session.State = domain.State(state)
+	}
+	session.Flow = domain.Flow(fields[flowField])
+	if raw := fields[contextField]; raw != "" {
+		session.Context = []byte(raw)
+	}
+	session.Mode = domain.Mode(fields[modeField])
+	session.LastMessageID, _ = strconv.Atoi(fields[lastMessageIDField])
+	return session
+}
+
+// writeSession queues the commands replacing the session hash, refreshing its
+// TTL and tracking its expiry while it is mid-flow
+func (f *FSM) writeSession(ctx context.Context, pipe redis.Pipeliner, id string, session *domain.Session) {
+	key := f.sessionKey(id)
+
+	fields := map[string]interface{}{
+		versionField: SessionVersion,
+		stateField:   string(session.State),
+	}
+	if session.Flow != "" {
+		fields[flowField] = string(session.Flow)
+	}
+	if len(session.Context) != 0 {
+		fields[contextField] = string(session.Context)
+	}
+	if session.Mode != "" {
+		fields[modeField] = string(session.Mode)
+	}
+	if session.LastMessageID != 0 {
+		fields[lastMessageIDField] = session.LastMessageID
+	}
+
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, defaultTTL)
+
+	if session.State.InFlow() {
+		expiresAt := f.clock.Now().Add(defaultTTL).UnixMilli()
+		pipe.ZAdd(ctx, f.client.Key(expiringKey), redis.Z{Score: float64(expiresAt), Member: id})
+	} else {
+		pipe.ZRem(ctx, f.client.Key(expiringKey), id)
 	}
-	return val, nil
 }
 
-// DeleteData deletes temporary data for a user
-func (f *FSM) DeleteData(ctx context.Context, userID, key string) error {
-	dataKey := fmt.Sprintf("%s%s:%s", dataKeyPrefix, userID, key)
-	return f.client.Del(ctx, dataKey).Err()
+// sessionKey returns the hash key of the session whose scope key is id
+func (f *FSM) sessionKey(id string) string {
+	return f.client.Key(sessionKeyPrefix + id)
 }
@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	goalKeyPrefix = "goal:"
+	goalUsersKey  = "goals:users"
+)
+
+// Goals keeps each user's goals in a Redis hash of JSON goals by surah
+// number. A set of user IDs indexes the users with goals.
+type Goals struct {
+	client *Client
+}
+
+func NewGoals(client *Client) *Goals {
+	return &Goals{client: client}
+}
+
+// SaveGoal adds a goal, or replaces the user's goal for the same surah
+func (g *Goals) SaveGoal(ctx context.Context, goal domain.Goal) error {
+	raw, err := json.Marshal(goal)
+	if err != nil {
+		return fmt.Errorf("encode goal: %w", err)
+	}
+
+	_, err = g.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, g.key(goal.UserID), strconv.Itoa(goal.SurahNumber), raw)
+		pipe.SAdd(ctx, g.client.Key(goalUsersKey), goal.UserID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("save goal: %w", err)
+	}
+	return nil
+}
+
+// ListGoals returns the user's goals, soonest deadline first
+func (g *Goals) ListGoals(ctx context.Context, userID string) ([]domain.Goal, error) {
+	fields, err := g.client.HGetAll(ctx, g.key(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list goals: %w", err)
+	}
+
+	list := make([]domain.Goal, 0, len(fields))
+	for _, raw := range fields {
+		var goal domain.Goal
+		if err := json.Unmarshal([]byte(raw), &goal); err != nil {
+			continue
+		}
+		list = append(list, goal)
+	}
+	sortGoals(list)
+	return list, nil
+}
+
+// AllGoals returns the goals of every user. Users left without goals are
+// dropped from the index.
+func (g *Goals) AllGoals(ctx context.Context) ([]domain.Goal, error) {
+	users, err := g.client.SMembers(ctx, g.client.Key(goalUsersKey)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list users with goals: %w", err)
+	}
+
+	var list []domain.Goal
+	for _, userID := range users {
+		goals, err := g.ListGoals(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(goals) == 0 {
+			g.client.SRem(ctx, g.client.Key(goalUsersKey), userID)
+			continue
+		}
+		list = append(list, goals...)
+	}
+	sortGoals(list)
+	return list, nil
+}
+
+// DeleteGoal removes the user's goal for a surah
+func (g *Goals) DeleteGoal(ctx context.Context, userID string, surahNumber int) error {
+	n, err := g.client.HDel(ctx, g.key(userID), strconv.Itoa(surahNumber)).Result()
+	if err != nil {
+		return fmt.Errorf("delete goal: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrGoalNotFound
+	}
+	return nil
+}
+
+// DeleteGoals removes all of a user's goals
+func (g *Goals) DeleteGoals(ctx context.Context, userID string) error {
+	_, err := g.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, g.key(userID))
+		pipe.SRem(ctx, g.client.Key(goalUsersKey), userID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete goals: %w", err)
+	}
+	return nil
+}
+
+func (g *Goals) key(userID string) string {
+	return g.client.Key(goalKeyPrefix + userID)
+}
+
+// sortGoals orders goals by deadline, then surah
+func sortGoals(list []domain.Goal) {
+	slices.SortFunc(list, func(a, b domain.Goal) int {
+		if c := a.Deadline.Compare(b.Deadline); c != 0 {
+			return c
+		}
+		return a.SurahNumber - b.SurahNumber
+	})
+}
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const khatmahsKey = "khatmahs"
+
+// Khatmahs keeps the khatmah of each user in one Redis hash of JSON
+// khatmahs by user ID
+type Khatmahs struct {
+	client *Client
+}
+
+func NewKhatmahs(client *Client) *Khatmahs {
+	return &Khatmahs{client: client}
+}
+
+// SaveKhatmah adds or replaces the user's khatmah
+func (k *Khatmahs) SaveKhatmah(ctx context.Context, khatmah domain.Khatmah) error {
+	raw, err := json.Marshal(khatmah)
+	if err != nil {
+		return fmt.Errorf("encode khatmah: %w", err)
+	}
+	if err := k.client.HSet(ctx, k.client.Key(khatmahsKey), khatmah.UserID, raw).Err(); err != nil {
+		return fmt.Errorf("save khatmah: %w", err)
+	}
+	return nil
+}
+
+// GetKhatmah returns the user's khatmah, or nil if they have none
+func (k *Khatmahs) GetKhatmah(ctx context.Context, userID string) (*domain.Khatmah, error) {
+	raw, err := k.client.HGet(ctx, k.client.Key(khatmahsKey), userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get khatmah: %w", err)
+	}
+	var khatmah domain.Khatmah
+	if err := json.Unmarshal([]byte(raw), &khatmah); err != nil {
+		return nil, fmt.Errorf("decode khatmah: %w", err)
+	}
+	return &khatmah, nil
+}
+
+// DeleteKhatmah removes the user's khatmah
+func (k *Khatmahs) DeleteKhatmah(ctx context.Context, userID string) error {
+	if err := k.client.HDel(ctx, k.client.Key(khatmahsKey), userID).Err(); err != nil {
+		return fmt.Errorf("delete khatmah: %w", err)
+	}
+	return nil
+}
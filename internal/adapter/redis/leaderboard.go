@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	leaderboardKeyPrefix     = "leaderboard:"
+	leaderboardSeenKeyPrefix = "leaderboard:seen:"
+
+	// Boards outlive their period so the previous one can still be shown
+	weeklyBoardTTL  = 15 * 24 * time.Hour
+	monthlyBoardTTL = 62 * 24 * time.Hour
+)
+
+// addScoreScript increments both boards only the first time a recording is
+// scored.
+// KEYS: seen set, weekly board, monthly board
+// ARGV: recording ID, user ID, points, weekly TTL, monthly TTL (seconds)
+var addScoreScript = redis.NewScript(`
+if redis.call("SADD", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("EXPIRE", KEYS[1], ARGV[5])
+redis.call("ZINCRBY", KEYS[2], ARGV[3], ARGV[2])
+redis.call("EXPIRE", KEYS[2], ARGV[4])
+redis.call("ZINCRBY", KEYS[3], ARGV[3], ARGV[2])
+redis.call("EXPIRE", KEYS[3], ARGV[5])
+return 1
+`)
+
+// Leaderboard keeps one sorted set per period, scored by total points
+type Leaderboard struct {
+	client *Client
+}
+
+func NewLeaderboard(client *Client) *Leaderboard {
+	return &Leaderboard{client: client}
+}
+
+// AddScore adds the points of a completed recording to the current boards
+func (l *Leaderboard) AddScore(ctx context.Context, userID, recordingID string, points float64, at time.Time) error {
+	keys := []string{
+		l.client.Key(leaderboardSeenKeyPrefix + domain.LeaderboardMonthly.PeriodID(at)),
+		l.client.Key(boardKey(domain.LeaderboardWeekly, at)),
+		l.client.Key(boardKey(domain.LeaderboardMonthly, at)),
+	}
+	err := addScoreScript.Run(ctx, l.client, keys,
+		recordingID, userID, points,
+		int(weeklyBoardTTL.Seconds()), int(monthlyBoardTTL.Seconds()),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("add score: %w", err)
+	}
+	return nil
+}
+
+// Top returns the highest ranked users of the period containing at
+func (l *Leaderboard) Top(ctx context.Context, period domain.LeaderboardPeriod, at time.Time, limit int) ([]domain.LeaderboardEntry, error) {
+	members, err := l.client.ZRevRangeWithScores(ctx, l.client.Key(boardKey(period, at)), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get leaderboard: %w", err)
+	}
+
+	entries := make([]domain.LeaderboardEntry, 0, len(members))
+	for i, m := range members {
+		entries = append(entries, domain.LeaderboardEntry{
+			UserID: m.Member.(string),
+			Rank:   i + 1,
+			Score:  m.Score,
+		})
+	}
+	return entries, nil
+}
+
+// Rank returns the user's position in the period containing at
+func (l *Leaderboard) Rank(ctx context.Context, period domain.LeaderboardPeriod, at time.Time, userID string) (*domain.LeaderboardEntry, error) {
+	key := l.client.Key(boardKey(period, at))
+
+	rank, err := l.client.ZRevRank(ctx, key, userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get rank: %w", err)
+	}
+
+	score, err := l.client.ZScore(ctx, key, userID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get score: %w", err)
+	}
+
+	return &domain.LeaderboardEntry{UserID: userID, Rank: int(rank) + 1, Score: score}, nil
+}
+
+// RemoveUser removes the user from the current boards
+func (l *Leaderboard) RemoveUser(ctx context.Context, userID string, at time.Time) error {
+	_, err := l.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, l.client.Key(boardKey(domain.LeaderboardWeekly, at)), userID)
+		pipe.ZRem(ctx, l.client.Key(boardKey(domain.LeaderboardMonthly, at)), userID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("remove user from leaderboard: %w", err)
+	}
+	return nil
+}
+
+func boardKey(period domain.LeaderboardPeriod, at time.Time) string {
+	return leaderboardKeyPrefix + string(period) + ":" + period.PeriodID(at)
+}
@@ -0,0 +1,192 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	lifecycleKeyPrefix  = "lifecycle:"
+	lifecyclePendingKey = "lifecycles:pending"
+
+	// lifecycleTTL stops tracking the recordings of users who stopped using
+	// the bot
+	lifecycleTTL = 30 * 24 * time.Hour
+)
+
+// RecordingLifecycles keeps each user's tracked recordings in a Redis hash
+// of JSON lifecycles by recording ID. A set of "<user>:<recording>" members
+// indexes the pending ones across users.
+type RecordingLifecycles struct {
+	client *Client
+}
+
+func NewRecordingLifecycles(client *Client) *RecordingLifecycles {
+	return &RecordingLifecycles{client: client}
+}
+
+// TrackRecording starts tracking a recording
+func (r *RecordingLifecycles) TrackRecording(ctx context.Context, lifecycle domain.RecordingLifecycle) error {
+	raw, err := json.Marshal(lifecycle)
+	if err != nil {
+		return fmt.Errorf("encode lifecycle: %w", err)
+	}
+
+	key := r.key(lifecycle.UserID)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, lifecycle.RecordingID, raw)
+		pipe.Expire(ctx, key, lifecycleTTL)
+		r.index(ctx, pipe, lifecycle)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("track recording: %w", err)
+	}
+	return nil
+}
+
+// AdvanceRecording moves a recording to a stage with optimistic locking: the
+// hash is WATCHed while the stage is checked, so only one of concurrent
+// callers moves it
+func (r *RecordingLifecycles) AdvanceRecording(ctx context.Context, userID, recordingID string, to domain.RecordingStage, at time.Time) (bool, error) {
+	key := r.key(userID)
+
+	var moved bool
+	advance := func(tx *redis.Tx) error {
+		raw, err := tx.HGet(ctx, key, recordingID).Result()
+		if errors.Is(err, redis.Nil) {
+			return domain.ErrRecordingNotTracked
+		}
+		if err != nil {
+			return fmt.Errorf("get lifecycle: %w", err)
+		}
+
+		var lifecycle domain.RecordingLifecycle
+		if err := json.Unmarshal([]byte(raw), &lifecycle); err != nil {
+			return fmt.Errorf("decode lifecycle: %w", err)
+		}
+		moved = lifecycle.Advance(to, at)
+		if !moved {
+			return nil
+		}
+
+		updated, err := json.Marshal(lifecycle)
+		if err != nil {
+			return fmt.Errorf("encode lifecycle: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, recordingID, updated)
+			r.index(ctx, pipe, lifecycle)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxUpdateAttempts; i++ {
+		err := r.client.Watch(ctx, advance, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return moved, nil
+	}
+	return false, fmt.Errorf("advance recording: gave up after %d conflicting attempts", maxUpdateAttempts)
+}
+
+// ListLifecycles returns the user's tracked recordings, newest first
+func (r *RecordingLifecycles) ListLifecycles(ctx context.Context, userID string) ([]domain.RecordingLifecycle, error) {
+	fields, err := r.client.HGetAll(ctx, r.key(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list lifecycles: %w", err)
+	}
+
+	list := make([]domain.RecordingLifecycle, 0, len(fields))
+	for _, raw := range fields {
+		var lifecycle domain.RecordingLifecycle
+		if err := json.Unmarshal([]byte(raw), &lifecycle); err != nil {
+			continue
+		}
+		list = append(list, lifecycle)
+	}
+	slices.SortFunc(list, func(a, b domain.RecordingLifecycle) int {
+		return b.SubmittedAt.Compare(a.SubmittedAt)
+	})
+	return list, nil
+}
+
+// PendingRecordings returns the recordings whose outcome is yet to be
+// delivered, oldest first. Members whose lifecycle expired are dropped from
+// the index.
+func (r *RecordingLifecycles) PendingRecordings(ctx context.Context) ([]domain.RecordingLifecycle, error) {
+	members, err := r.client.SMembers(ctx, r.client.Key(lifecyclePendingKey)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list pending recordings: %w", err)
+	}
+
+	var list []domain.RecordingLifecycle
+	for _, member := range members {
+		userID, recordingID, _ := strings.Cut(member, ":")
+		raw, err := r.client.HGet(ctx, r.key(userID), recordingID).Result()
+		if errors.Is(err, redis.Nil) {
+			r.client.SRem(ctx, r.client.Key(lifecyclePendingKey), member)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get lifecycle: %w", err)
+		}
+
+		var lifecycle domain.RecordingLifecycle
+		if err := json.Unmarshal([]byte(raw), &lifecycle); err != nil || !lifecycle.Stage.Pending() {
+			continue
+		}
+		list = append(list, lifecycle)
+	}
+	slices.SortFunc(list, func(a, b domain.RecordingLifecycle) int {
+		return a.SubmittedAt.Compare(b.SubmittedAt)
+	})
+	return list, nil
+}
+
+// DeleteLifecycles stops tracking all of a user's recordings
+func (r *RecordingLifecycles) DeleteLifecycles(ctx context.Context, userID string) error {
+	ids, err := r.client.HKeys(ctx, r.key(userID)).Result()
+	if err != nil {
+		return fmt.Errorf("list lifecycles: %w", err)
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.key(userID))
+		for _, id := range ids {
+			pipe.SRem(ctx, r.client.Key(lifecyclePendingKey), userID+":"+id)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete lifecycles: %w", err)
+	}
+	return nil
+}
+
+// index adds a pending lifecycle to the pending set, and removes the others
+func (r *RecordingLifecycles) index(ctx context.Context, pipe redis.Pipeliner, lifecycle domain.RecordingLifecycle) {
+	member := lifecycle.UserID + ":" + lifecycle.RecordingID
+	if lifecycle.Stage.Pending() {
+		pipe.SAdd(ctx, r.client.Key(lifecyclePendingKey), member)
+	} else {
+		pipe.SRem(ctx, r.client.Key(lifecyclePendingKey), member)
+	}
+}
+
+func (r *RecordingLifecycles) key(userID string) string {
+	return r.client.Key(lifecycleKeyPrefix + userID)
+}
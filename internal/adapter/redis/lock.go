@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const lockKeyPrefix = "lock:"
+
+// releaseScript deletes the lock only if it is still owned by the caller
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// extendScript resets the expiry of the lock only if it is still owned by
+// the caller
+var extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Locker implements LockPort with SET NX locks owned by this process
+type Locker struct {
+	client *Client
+	owner  string
+}
+
+func NewLocker(client *Client) *Locker {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+
+	return &Locker{
+		client: client,
+		owner:  hex.EncodeToString(buf),
+	}
+}
+
+// Acquire takes the lock for key if it is free
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := l.client.SetNX(ctx, l.client.Key(lockKeyPrefix+key), l.owner, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("acquire lock: %w", err)
+	}
+	return ok, nil
+}
+
+// Release releases a lock previously taken by this process
+func (l *Locker) Release(ctx context.Context, key string) error {
+	if err := releaseScript.Run(ctx, l.client, []string{l.client.Key(lockKeyPrefix + key)}, l.owner).Err(); err != nil {
+		return fmt.Errorf("release lock: %w", err)
+	}
+	return nil
+}
+
+// Extend makes a lock taken by this process expire after ttl from now
+func (l *Locker) Extend(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	extended, err := extendScript.Run(ctx, l.client, []string{l.client.Key(lockKeyPrefix + key)}, l.owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("extend lock: %w", err)
+	}
+	return extended == 1, nil
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const pinKeyPrefix = "pins:"
+
+// Pins keeps each user's pinned recordings in a Redis hash of JSON pins by
+// recording ID
+type Pins struct {
+	client *Client
+}
+
+func NewPins(client *Client) *Pins {
+	return &Pins{client: client}
+}
+
+// PinRecording pins a recording, or updates its pin
+func (p *Pins) PinRecording(ctx context.Context, userID string, pin domain.Pin) error {
+	raw, err := json.Marshal(pin)
+	if err != nil {
+		return fmt.Errorf("encode pin: %w", err)
+	}
+	if err := p.client.HSet(ctx, p.key(userID), pin.RecordingID, raw).Err(); err != nil {
+		return fmt.Errorf("pin recording: %w", err)
+	}
+	return nil
+}
+
+// UnpinRecording unpins a recording, doing nothing if it was not pinned
+func (p *Pins) UnpinRecording(ctx context.Context, userID, recordingID string) error {
+	if err := p.client.HDel(ctx, p.key(userID), recordingID).Err(); err != nil {
+		return fmt.Errorf("unpin recording: %w", err)
+	}
+	return nil
+}
+
+// ListPins returns the user's pinned recordings, latest pinned first
+func (p *Pins) ListPins(ctx context.Context, userID string) ([]domain.Pin, error) {
+	fields, err := p.client.HGetAll(ctx, p.key(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list pins: %w", err)
+	}
+
+	list := make([]domain.Pin, 0, len(fields))
+	for _, raw := range fields {
+		var pin domain.Pin
+		if err := json.Unmarshal([]byte(raw), &pin); err != nil {
+			continue
+		}
+		list = append(list, pin)
+	}
+	slices.SortFunc(list, func(a, b domain.Pin) int {
+		return b.PinnedAt.Compare(a.PinnedAt)
+	})
+	return list, nil
+}
+
+// DeletePins unpins all of the user's recordings
+func (p *Pins) DeletePins(ctx context.Context, userID string) error {
+	if err := p.client.Del(ctx, p.key(userID)).Err(); err != nil {
+		return fmt.Errorf("delete pins: %w", err)
+	}
+	return nil
+}
+
+func (p *Pins) key(userID string) string {
+	return p.client.Key(pinKeyPrefix + userID)
+}
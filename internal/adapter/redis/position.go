@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const positionsKey = "positions"
+
+// Positions keeps the last ayah each user recorded in one Redis hash of
+// "surah:ayah" by user ID
+type Positions struct {
+	client *Client
+}
+
+func NewPositions(client *Client) *Positions {
+	return &Positions{client: client}
+}
+
+// SetPosition records the ayah the user last recorded
+func (p *Positions) SetPosition(ctx context.Context, userID string, ayah domain.Ayah) error {
+	if err := p.client.HSet(ctx, p.client.Key(positionsKey), userID, ayah.String()).Err(); err != nil {
+		return fmt.Errorf("set position: %w", err)
+	}
+	return nil
+}
+
+// GetPosition returns the ayah the user last recorded, or nil if they
+// recorded none
+func (p *Positions) GetPosition(ctx context.Context, userID string) (*domain.Ayah, error) {
+	raw, err := p.client.HGet(ctx, p.client.Key(positionsKey), userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get position: %w", err)
+	}
+	ayah, err := domain.ParseAyahID(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decode position: %w", err)
+	}
+	return &ayah, nil
+}
+
+// DeletePosition forgets the user's position
+func (p *Positions) DeletePosition(ctx context.Context, userID string) error {
+	if err := p.client.HDel(ctx, p.client.Key(positionsKey), userID).Err(); err != nil {
+		return fmt.Errorf("delete position: %w", err)
+	}
+	return nil
+}
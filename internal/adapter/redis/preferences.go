@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const prefsKeyPrefix = "prefs:"
+
+// Preferences stores per-user settings in a Redis hash without expiry
+type Preferences struct {
+	client *Client
+}
+
+func NewPreferences(client *Client) *Preferences {
+	return &Preferences{client: client}
+}
+
+// GetPreferences retrieves a user's preferences
+func (p *Preferences) GetPreferences(ctx context.Context, userID string) (domain.Preferences, error) {
+	prefs := domain.DefaultPreferences()
+
+	fields, err := p.client.HGetAll(ctx, p.client.Key(prefsKeyPrefix+userID)).Result()
+	if err != nil {
+		return prefs, fmt.Errorf("get preferences: %w", err)
+	}
+
+	if v, ok := fields["language"]; ok {
+		prefs.Language = domain.Language(v)
+	}
+	if v, ok := fields["min_similarity"]; ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			prefs.MinSimilarity = f
+		}
+	}
+	if v, ok := fields["default_mode"]; ok {
+		prefs.DefaultMode = domain.Mode(v)
+	}
+	if v, ok := fields["reciter"]; ok {
+		prefs.Reciter = v
+	}
+	if v, ok := fields["notifications"]; ok {
+		prefs.Notifications = v == "1"
+	}
+	if v, ok := fields["enhance_audio"]; ok {
+		prefs.EnhanceAudio = v == "1"
+	}
+	if v, ok := fields["riwayah"]; ok {
+		prefs.Riwayah = domain.Riwayah(v)
+	}
+	if v, ok := fields["timezone"]; ok {
+		prefs.Timezone = v
+	}
+	if v, ok := fields["day_start_hour"]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			prefs.DayStartHour = n
+		}
+	}
+	if v, ok := fields["separate_basmala"]; ok {
+		prefs.SeparateBasmala = v == "1"
+	}
+
+	return prefs, nil
+}
+
+// SetPreferences stores a user's preferences
+func (p *Preferences) SetPreferences(ctx context.Context, userID string, prefs domain.Preferences) error {
+	err := p.client.HSet(ctx, p.client.Key(prefsKeyPrefix+userID),
+		"language", string(prefs.Language),
+		"min_similarity", strconv.FormatFloat(prefs.MinSimilarity, 'f', -1, 64),
+		"default_mode", string(prefs.DefaultMode),
+		"reciter", prefs.Reciter,
+		"notifications", formatBool(prefs.Notifications),
+		"enhance_audio", formatBool(prefs.EnhanceAudio),
+		"riwayah", string(prefs.Riwayah),
+		"timezone", prefs.Timezone,
+		"day_start_hour", strconv.Itoa(prefs.DayStartHour),
+		"separate_basmala", formatBool(prefs.SeparateBasmala),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("set preferences: %w", err)
+	}
+	return nil
+}
+
+// DeletePreferences deletes a user's stored preferences
+func (p *Preferences) DeletePreferences(ctx context.Context, userID string) error {
+	if err := p.client.Del(ctx, p.client.Key(prefsKeyPrefix+userID)).Err(); err != nil {
+		return fmt.Errorf("delete preferences: %w", err)
+	}
+	return nil
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+// tokenBucketScript refills the bucket for the time elapsed since the last
+// call and takes one token if available. It uses the Redis clock so that all
+// replicas agree on the elapsed time.
+// KEYS: bucket
+// ARGV: refill rate (tokens per millisecond), burst
+// Returns {allowed (0/1), milliseconds until the next token}
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+return {allowed, wait}
+`)
+
+// RateLimiter implements RateLimiterPort with token buckets stored in Redis
+// hashes, shared by all replicas
+type RateLimiter struct {
+	client *Client
+	limits map[domain.RateAction]domain.RateLimit
+}
+
+func NewRateLimiter(client *Client, limits map[domain.RateAction]domain.RateLimit) *RateLimiter {
+	return &RateLimiter{client: client, limits: limits}
+}
+
+// Allow takes a token from the bucket of action for key
+func (l *RateLimiter) Allow(ctx context.Context, action domain.RateAction, key string) (bool, time.Duration, error) {
+	limit, ok := l.limits[action]
+	if !ok {
+		return true, 0, nil
+	}
+
+	bucket := l.client.Key(rateLimitKeyPrefix + string(action) + ":" + key)
+	perMilli := limit.TokensPerSecond() / 1000
+	res, err := tokenBucketScript.Run(ctx, l.client, []string{bucket}, perMilli, limit.Burst).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("rate limit %s: %w", action, err)
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
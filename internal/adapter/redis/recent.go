@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const recentSurahsKeyPrefix = "recent_surahs:"
+
+// RecentSurahs keeps the surahs each user selected last in a Redis list,
+// latest first
+type RecentSurahs struct {
+	client *Client
+}
+
+func NewRecentSurahs(client *Client) *RecentSurahs {
+	return &RecentSurahs{client: client}
+}
+
+// AddRecentSurah moves the surah to the front of the user's list, keeping
+// the domain.MaxRecentSurahs selected last
+func (r *RecentSurahs) AddRecentSurah(ctx context.Context, userID string, surah int) error {
+	key := r.key(userID)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, key, 0, surah)
+		pipe.LPush(ctx, key, surah)
+		pipe.LTrim(ctx, key, 0, domain.MaxRecentSurahs-1)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add recent surah: %w", err)
+	}
+	return nil
+}
+
+// RecentSurahs returns the surahs the user selected last, latest first
+func (r *RecentSurahs) RecentSurahs(ctx context.Context, userID string) ([]int, error) {
+	raw, err := r.client.LRange(ctx, r.key(userID), 0, domain.MaxRecentSurahs-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get recent surahs: %w", err)
+	}
+	surahs := make([]int, 0, len(raw))
+	for _, v := range raw {
+		if n, err := strconv.Atoi(v); err == nil {
+			surahs = append(surahs, n)
+		}
+	}
+	return surahs, nil
+}
+
+// DeleteRecentSurahs forgets the surahs the user selected
+func (r *RecentSurahs) DeleteRecentSurahs(ctx context.Context, userID string) error {
+	if err := r.client.Del(ctx, r.key(userID)).Err(); err != nil {
+		return fmt.Errorf("delete recent surahs: %w", err)
+	}
+	return nil
+}
+
+func (r *RecentSurahs) key(userID string) string {
+	return r.client.Key(recentSurahsKeyPrefix + userID)
+}
@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	recordingsKeyPrefix = "recordings:"
+
+	// recordingsTTL bounds how long a stale list can be served while the
+	// upstream API is unavailable
+	recordingsTTL = 7 * 24 * time.Hour
+)
+
+// RecordingCache stores each user's recording summaries as a JSON document
+type RecordingCache struct {
+	client *Client
+}
+
+type cachedRecordings struct {
+	FetchedAt  time.Time                 `json:"fetched_at"`
+	Recordings []domain.RecordingSummary `json:"recordings"`
+}
+
+func NewRecordingCache(client *Client) *RecordingCache {
+	return &RecordingCache{client: client}
+}
+
+// GetRecordings returns the cached summaries and when they were fetched
+func (c *RecordingCache) GetRecordings(ctx context.Context, userID string) ([]domain.RecordingSummary, time.Time, error) {
+	raw, err := c.client.Get(ctx, c.client.Key(recordingsKeyPrefix+userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("get recordings: %w", err)
+	}
+
+	var cached cachedRecordings
+	if err := json.Unmarshal(raw, &cached); err != nil {
+		return nil, time.Time{}, fmt.Errorf("decode recordings: %w", err)
+	}
+	return cached.Recordings, cached.FetchedAt, nil
+}
+
+// SetRecordings caches the summaries fetched at the given time
+func (c *RecordingCache) SetRecordings(ctx context.Context, userID string, recordings []domain.RecordingSummary, fetchedAt time.Time) error {
+	raw, err := json.Marshal(cachedRecordings{FetchedAt: fetchedAt, Recordings: recordings})
+	if err != nil {
+		return fmt.Errorf("encode recordings: %w", err)
+	}
+	if err := c.client.Set(ctx, c.client.Key(recordingsKeyPrefix+userID), raw, recordingsTTL).Err(); err != nil {
+		return fmt.Errorf("set recordings: %w", err)
+	}
+	return nil
+}
+
+// DeleteRecordings invalidates the cached summaries
+func (c *RecordingCache) DeleteRecordings(ctx context.Context, userID string) error {
+	return c.client.Del(ctx, c.client.Key(recordingsKeyPrefix+userID)).Err()
+}
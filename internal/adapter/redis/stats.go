@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	statsKeyPrefix     = "stats:"
+	statsSeenKeyPrefix = "stats:seen:"
+)
+
+// Stats keeps each user's history in a Redis stream. A companion set of
+// recording IDs makes appends idempotent.
+type Stats struct {
+	client *Client
+}
+
+func NewStats(client *Client) *Stats {
+	return &Stats{client: client}
+}
+
+// AppendStat adds an entry to the user's history
+func (s *Stats) AppendStat(ctx context.Context, userID string, stat domain.StatEntry) error {
+	added, err := s.client.SAdd(ctx, s.client.Key(statsSeenKeyPrefix+userID), stat.RecordingID).Result()
+	if err != nil {
+		return fmt.Errorf("mark stat: %w", err)
+	}
+	if added == 0 {
+		return nil
+	}
+
+	err = s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.client.Key(statsKeyPrefix + userID),
+		Values: map[string]interface{}{
+			"recording_id": stat.RecordingID,
+			"ayah_id":      stat.AyahID,
+			"accuracy":     stat.Accuracy,
+			"wer":          stat.WER,
+			"recorded_at":  stat.RecordedAt.UnixMilli(),
+		},
+	}).Err()
+	if err != nil {
+		// Unmark so a later attempt can append it
+		s.client.SRem(ctx, s.client.Key(statsSeenKeyPrefix+userID), stat.RecordingID)
+		return fmt.Errorf("append stat: %w", err)
+	}
+	return nil
+}
+
+// ListStats returns the entries recorded at or after since, oldest first
+func (s *Stats) ListStats(ctx context.Context, userID string, since time.Time) ([]domain.StatEntry, error) {
+	// Entries are appended after the recording finished, so the stream ID is
+	// never older than recorded_at and can bound the range
+	start := "-"
+	if !since.IsZero() {
+		start = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+
+	messages, err := s.client.XRange(ctx, s.client.Key(statsKeyPrefix+userID), start, "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("list stats: %w", err)
+	}
+
+	stats := make([]domain.StatEntry, 0, len(messages))
+	for _, msg := range messages {
+		stat := parseStat(msg.Values)
+		if stat.RecordedAt.Before(since) {
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].RecordedAt.Before(stats[j].RecordedAt)
+	})
+	return stats, nil
+}
+
+// DeleteStats deletes the user's whole history
+func (s *Stats) DeleteStats(ctx context.Context, userID string) error {
+	return s.client.Del(ctx, s.client.Key(statsKeyPrefix+userID), s.client.Key(statsSeenKeyPrefix+userID)).Err()
+}
+
+func parseStat(values map[string]interface{}) domain.StatEntry {
+	str := func(key string) string {
+		v, _ := values[key].(string)
+		return v
+	}
+
+	accuracy, _ := strconv.ParseFloat(str("accuracy"), 64)
+	wer, _ := strconv.ParseFloat(str("wer"), 64)
+	recordedAt, _ := strconv.ParseInt(str("recorded_at"), 10, 64)
+
+	return domain.StatEntry{
+		RecordingID: str("recording_id"),
+		AyahID:      str("ayah_id"),
+		Accuracy:    accuracy,
+		WER:         wer,
+		RecordedAt:  time.UnixMilli(recordedAt),
+	}
+}
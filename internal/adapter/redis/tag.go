@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+const tagKeyPrefix = "tags:"
+
+// Tags keeps the tags of each user's recordings in a Redis hash of JSON
+// lists by recording ID
+type Tags struct {
+	client *Client
+}
+
+func NewTags(client *Client) *Tags {
+	return &Tags{client: client}
+}
+
+// SetTags replaces the tags of a recording, removing them all when tags is
+// empty
+func (t *Tags) SetTags(ctx context.Context, userID, recordingID string, tags []string) error {
+	if len(tags) == 0 {
+		if err := t.client.HDel(ctx, t.key(userID), recordingID).Err(); err != nil {
+			return fmt.Errorf("delete tags: %w", err)
+		}
+		return nil
+	}
+
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	raw, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("encode tags: %w", err)
+	}
+	if err := t.client.HSet(ctx, t.key(userID), recordingID, raw).Err(); err != nil {
+		return fmt.Errorf("set tags: %w", err)
+	}
+	return nil
+}
+
+// ListTags returns the tags of the user's tagged recordings by recording ID
+func (t *Tags) ListTags(ctx context.Context, userID string) (map[string][]string, error) {
+	fields, err := t.client.HGetAll(ctx, t.key(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+
+	list := make(map[string][]string, len(fields))
+	for recordingID, raw := range fields {
+		var tags []string
+		if err := json.Unmarshal([]byte(raw), &tags); err != nil {
+			continue
+		}
+		list[recordingID] = tags
+	}
+	return list, nil
+}
+
+// DeleteTags removes the tags of all of the user's recordings
+func (t *Tags) DeleteTags(ctx context.Context, userID string) error {
+	if err := t.client.Del(ctx, t.key(userID)).Err(); err != nil {
+		return fmt.Errorf("delete tags: %w", err)
+	}
+	return nil
+}
+
+func (t *Tags) key(userID string) string {
+	return t.client.Key(tagKeyPrefix + userID)
+}
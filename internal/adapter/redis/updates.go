@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const handledUpdatesKey = "updates:handled"
+
+// markHandledScript drops the updates handled longer than ttl ago from the
+// set, then adds the update unless it is still in it. The set expires when
+// no update arrives for ttl.
+// KEYS: set of update IDs scored by when they were handled
+// ARGV: update ID, ttl in milliseconds
+// Returns 1 if the update was added
+var markHandledScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - ttl)
+local added = redis.call("ZADD", KEYS[1], "NX", now, ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ttl)
+return added
+`)
+
+// UpdateLog implements UpdateLogPort with a Redis sorted set of update IDs,
+// shared by all replicas
+type UpdateLog struct {
+	client *Client
+}
+
+func NewUpdateLog(client *Client) *UpdateLog {
+	return &UpdateLog{client: client}
+}
+
+// MarkHandled records an update and reports whether it was not handled
+// within the last ttl
+func (u *UpdateLog) MarkHandled(ctx context.Context, updateID int, ttl time.Duration) (bool, error) {
+	added, err := markHandledScript.Run(ctx, u.client, []string{u.client.Key(handledUpdatesKey)}, updateID, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("mark update handled: %w", err)
+	}
+	return added == 1, nil
+}
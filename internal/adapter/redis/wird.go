@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const wirdsKey = "wirds"
+
+// Wirds keeps the daily wird subscriptions in one Redis hash of JSON
+// subscriptions by user ID
+type Wirds struct {
+	client *Client
+}
+
+func NewWirds(client *Client) *Wirds {
+	return &Wirds{client: client}
+}
+
+// SaveWird adds or replaces the user's subscription
+func (w *Wirds) SaveWird(ctx context.Context, wird domain.Wird) error {
+	raw, err := json.Marshal(wird)
+	if err != nil {
+		return fmt.Errorf("encode wird: %w", err)
+	}
+	if err := w.client.HSet(ctx, w.client.Key(wirdsKey), wird.UserID, raw).Err(); err != nil {
+		return fmt.Errorf("save wird: %w", err)
+	}
+	return nil
+}
+
+// GetWird returns the user's subscription, or nil if they have none
+func (w *Wirds) GetWird(ctx context.Context, userID string) (*domain.Wird, error) {
+	raw, err := w.client.HGet(ctx, w.client.Key(wirdsKey), userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get wird: %w", err)
+	}
+	var wird domain.Wird
+	if err := json.Unmarshal([]byte(raw), &wird); err != nil {
+		return nil, fmt.Errorf("decode wird: %w", err)
+	}
+	return &wird, nil
+}
+
+// AllWirds returns the subscriptions of every user
+func (w *Wirds) AllWirds(ctx context.Context) ([]domain.Wird, error) {
+	fields, err := w.client.HGetAll(ctx, w.client.Key(wirdsKey)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list wirds: %w", err)
+	}
+
+	list := make([]domain.Wird, 0, len(fields))
+	for _, raw := range fields {
+		var wird domain.Wird
+		if err := json.Unmarshal([]byte(raw), &wird); err != nil {
+			continue
+		}
+		list = append(list, wird)
+	}
+	return list, nil
+}
+
+// DeleteWird removes the user's subscription
+func (w *Wirds) DeleteWird(ctx context.Context, userID string) error {
+	if err := w.client.HDel(ctx, w.client.Key(wirdsKey), userID).Err(); err != nil {
+		return fmt.Errorf("delete wird: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Object metadata headers holding the audio metadata
+const (
+	metaDuration       = "X-Amz-Meta-Duration-Ms"
+	metaSampleRate     = "X-Amz-Meta-Sample-Rate"
+	metaFormat         = "X-Amz-Meta-Format"
+	metaOriginalFormat = "X-Amz-Meta-Original-Format"
+)
+
+// contentTypes maps audio formats to the content type objects are stored with
+var contentTypes = map[string]string{
+	"wav":  "audio/wav",
+	"flac": "audio/flac",
+	"ogg":  "audio/ogg",
+}
+
+// Archive stores the audio of every submitted recording in an S3-compatible
+// bucket, as <prefix><userID>/<recordingID>, so it can be played back later
+type Archive struct {
+	client *client
+	prefix string
+}
+
+// NewArchive creates an archive in the given bucket. Keys are prefixed with
+// prefix, e.g. "recordings/", to share a bucket with other data.
+func NewArchive(opts Options, prefix string) (*Archive, error) {
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{client: c, prefix: prefix}, nil
+}
+
+// PutRecordingAudio stores the audio of a recording
+func (a *Archive) PutRecordingAudio(ctx context.Context, userID, recordingID string, audio domain.ArchivedAudio) error {
+	key, err := a.key(userID, recordingID)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	contentType, ok := contentTypes[audio.Meta.Format]
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	header.Set(metaDuration, strconv.FormatInt(audio.Meta.Duration.Milliseconds(), 10))
+	header.Set(metaSampleRate, strconv.Itoa(audio.Meta.SampleRate))
+	header.Set(metaFormat, audio.Meta.Format)
+	header.Set(metaOriginalFormat, audio.Meta.OriginalFormat)
+
+	resp, err := a.client.do(ctx, http.MethodPut, key, nil, header, audio.Data)
+	if err != nil {
+		return fmt.Errorf("put object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("put object", resp)
+	}
+	return nil
+}
+
+// GetRecordingAudio returns the audio of a recording, or nil if it was not
+// archived
+func (a *Archive) GetRecordingAudio(ctx context.Context, userID, recordingID string) (*domain.ArchivedAudio, error) {
+	key, err := a.key(userID, recordingID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.client.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("get object", resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read object: %w", err)
+	}
+
+	durationMs, _ := strconv.ParseInt(resp.Header.Get(metaDuration), 10, 64)
+	sampleRate, _ := strconv.Atoi(resp.Header.Get(metaSampleRate))
+
+	return &domain.ArchivedAudio{
+		Data: data,
+		Meta: domain.AudioMetadata{
+			Duration:       time.Duration(durationMs) * time.Millisecond,
+			SampleRate:     sampleRate,
+			Format:         resp.Header.Get(metaFormat),
+			OriginalFormat: resp.Header.Get(metaOriginalFormat),
+		},
+	}, nil
+}
+
+// DeleteUserAudio deletes the audio of all the user's recordings
+func (a *Archive) DeleteUserAudio(ctx context.Context, userID string) error {
+	prefix, err := a.key(userID, "")
+	if err != nil {
+		return err
+	}
+
+	keys, err := a.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := a.delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// listResult is the part of a ListObjectsV2 response the archive reads
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns the keys of all objects starting with prefix
+func (a *Archive) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := a.client.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list objects: %w", err)
+		}
+
+		var result listResult
+		if resp.StatusCode != http.StatusOK {
+			err = responseError("list objects", resp)
+		} else if decodeErr := xml.NewDecoder(resp.Body).Decode(&result); decodeErr != nil {
+			err = fmt.Errorf("decode object list: %w", decodeErr)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (a *Archive) delete(ctx context.Context, key string) error {
+	resp, err := a.client.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return responseError("delete object", resp)
+	}
+	return nil
+}
+
+// key returns the key of a recording's audio, or the prefix of all the
+// user's recordings when recordingID is empty
+func (a *Archive) key(userID, recordingID string) (string, error) {
+	if !validKeyPart(userID) || (recordingID != "" && !validKeyPart(recordingID)) {
+		return "", fmt.Errorf("invalid recording key: %q/%q", userID, recordingID)
+	}
+	return a.prefix + userID + "/" + recordingID, nil
+}
+
+// validKeyPart reports whether s can be used as a key segment without
+// escaping into another user's prefix
+func validKeyPart(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, "/\\")
+}
+
+// responseError builds an error from an S3 error response
+func responseError(op string, resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err == nil && body.Code != "" {
+		return fmt.Errorf("%s failed with status %d: %s: %s", op, resp.StatusCode, body.Code, body.Message)
+	}
+	return fmt.Errorf("%s failed with status: %d", op, resp.StatusCode)
+}
@@ -0,0 +1,127 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/sigv4"
+)
+
+// Options configures the connection to an S3-compatible service
+type Options struct {
+	Endpoint  string // e.g. "https://s3.eu-central-1.amazonaws.com" or "http://minio:9000"
+	Region    string // defaults to us-east-1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// client sends requests signed with AWS Signature Version 4 to a bucket,
+// using path-style URLs so that it works with MinIO and other self-hosted
+// services as well as AWS
+type client struct {
+	endpoint   *url.URL
+	region     string
+	bucket     string
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+}
+
+func newClient(opts Options) (*client, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(opts.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint must be an http(s) URL: %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+
+	region := opts.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &client{
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    opts.Bucket,
+		accessKey: opts.AccessKey,
+		secretKey: opts.SecretKey,
+		httpClient: &http.Client{
+			Timeout: time.Minute,
+		},
+	}, nil
+}
+
+// do sends a signed request for an object key, or the bucket itself when key
+// is empty. The caller closes the response body.
+func (c *client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = u.Path + "/" + c.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = encodePath(u.Path)
+	u.RawQuery = encodeQuery(query)
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	sigv4.Sign(req, body, sigv4.Credentials{AccessKey: c.accessKey, SecretKey: c.secretKey}, c.region, "s3", time.Now().UTC())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	return resp, nil
+}
+
+// encodePath escapes every path segment as SigV4 expects, keeping slashes
+func encodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = encodeComponent(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// encodeQuery encodes query parameters sorted by name, as SigV4 expects
+func encodeQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, encodeComponent(name)+"="+encodeComponent(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// encodeComponent percent-encodes everything but unreserved characters
+func encodeComponent(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
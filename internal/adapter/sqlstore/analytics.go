@@ -0,0 +1,40 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// WriteEvents stores a batch of analytics events in a single transaction
+func (s *Store) WriteEvents(ctx context.Context, events []domain.AnalyticsEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind(`
+		INSERT INTO analytics_events (name, user_id, at, properties) VALUES (?, ?, ?, ?)`))
+	if err != nil {
+		return fmt.Errorf("prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, event := range events {
+		properties, err := json.Marshal(event.Properties)
+		if err != nil {
+			return fmt.Errorf("encode properties: %w", err)
+		}
+		if _, err := stmt.ExecContext(ctx, string(event.Name), event.UserID, event.At, string(properties)); err != nil {
+			return fmt.Errorf("insert event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit events: %w", err)
+	}
+	return nil
+}
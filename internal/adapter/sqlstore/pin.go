@@ -0,0 +1,66 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// PinRecording pins a recording, or updates its pin
+func (s *Store) PinRecording(ctx context.Context, userID string, pin domain.Pin) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO pins (user_id, recording_id, ayah_id, pinned_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, recording_id) DO UPDATE SET
+			ayah_id = excluded.ayah_id,
+			pinned_at = excluded.pinned_at`),
+		userID, pin.RecordingID, pin.AyahID, pin.PinnedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("pin recording: %w", err)
+	}
+	return nil
+}
+
+// UnpinRecording unpins a recording, doing nothing if it was not pinned
+func (s *Store) UnpinRecording(ctx context.Context, userID, recordingID string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM pins WHERE user_id = ? AND recording_id = ?`), userID, recordingID)
+	if err != nil {
+		return fmt.Errorf("unpin recording: %w", err)
+	}
+	return nil
+}
+
+// ListPins returns the user's pinned recordings, latest pinned first
+func (s *Store) ListPins(ctx context.Context, userID string) ([]domain.Pin, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT recording_id, ayah_id, pinned_at
+		FROM pins WHERE user_id = ?
+		ORDER BY pinned_at DESC`), userID)
+	if err != nil {
+		return nil, fmt.Errorf("list pins: %w", err)
+	}
+	defer rows.Close()
+
+	var pins []domain.Pin
+	for rows.Next() {
+		var pin domain.Pin
+		if err := rows.Scan(&pin.RecordingID, &pin.AyahID, &pin.PinnedAt); err != nil {
+			return nil, fmt.Errorf("scan pin: %w", err)
+		}
+		pins = append(pins, pin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list pins: %w", err)
+	}
+	return pins, nil
+}
+
+// DeletePins unpins all of the user's recordings
+func (s *Store) DeletePins(ctx context.Context, userID string) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM pins WHERE user_id = ?`), userID); err != nil {
+		return fmt.Errorf("delete pins: %w", err)
+	}
+	return nil
+}
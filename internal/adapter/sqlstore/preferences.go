@@ -0,0 +1,66 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// GetPreferences retrieves a user's preferences from the users table
+func (s *Store) GetPreferences(ctx context.Context, userID string) (domain.Preferences, error) {
+	prefs := domain.DefaultPreferences()
+
+	var language, preferences string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT language, preferences FROM users WHERE id = ?`), userID).
+		Scan(&language, &preferences)
+	if errors.Is(err, sql.ErrNoRows) {
+		return prefs, nil
+	}
+	if err != nil {
+		return prefs, fmt.Errorf("get preferences: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(preferences), &prefs); err != nil {
+		return prefs, fmt.Errorf("decode preferences: %w", err)
+	}
+	if language != "" {
+		prefs.Language = domain.Language(language)
+	}
+
+	return prefs, nil
+}
+
+// SetPreferences stores a user's preferences, creating the profile if needed
+func (s *Store) SetPreferences(ctx context.Context, userID string, prefs domain.Preferences) error {
+	preferences, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("encode preferences: %w", err)
+	}
+
+	now := s.clock.Now()
+	_, err = s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO users (id, language, preferences, registered_at, last_active_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			language = excluded.language,
+			preferences = excluded.preferences`),
+		userID, string(prefs.Language), string(preferences), now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("set preferences: %w", err)
+	}
+	return nil
+}
+
+// DeletePreferences resets a user's preferences to the defaults
+func (s *Store) DeletePreferences(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`UPDATE users SET language = '', preferences = '{}' WHERE id = ?`), userID)
+	if err != nil {
+		return fmt.Errorf("delete preferences: %w", err)
+	}
+	return nil
+}
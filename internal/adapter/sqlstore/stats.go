@@ -0,0 +1,56 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// AppendStat adds an entry to the user's history
+func (s *Store) AppendStat(ctx context.Context, userID string, stat domain.StatEntry) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO stats (user_id, recording_id, ayah_id, accuracy, wer, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, recording_id) DO NOTHING`),
+		userID, stat.RecordingID, stat.AyahID, stat.Accuracy, stat.WER, stat.RecordedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("append stat: %w", err)
+	}
+	return nil
+}
+
+// ListStats returns the entries recorded at or after since, oldest first
+func (s *Store) ListStats(ctx context.Context, userID string, since time.Time) ([]domain.StatEntry, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT recording_id, ayah_id, accuracy, wer, recorded_at
+		FROM stats WHERE user_id = ? AND recorded_at >= ?
+		ORDER BY recorded_at`), userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("list stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []domain.StatEntry
+	for rows.Next() {
+		var stat domain.StatEntry
+		if err := rows.Scan(&stat.RecordingID, &stat.AyahID, &stat.Accuracy, &stat.WER, &stat.RecordedAt); err != nil {
+			return nil, fmt.Errorf("scan stat: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list stats: %w", err)
+	}
+	return stats, nil
+}
+
+// DeleteStats deletes the user's whole history
+func (s *Store) DeleteStats(ctx context.Context, userID string) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM stats WHERE user_id = ?`), userID); err != nil {
+		return fmt.Errorf("delete stats: %w", err)
+	}
+	return nil
+}
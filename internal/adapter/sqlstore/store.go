@@ -0,0 +1,209 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// dialect captures the differences between the supported SQL databases
+type dialect struct {
+	driver string
+	// numbered reports whether the driver expects $1, $2, ... placeholders
+	// instead of ?
+	numbered bool
+	// maxOpenConns limits the connection pool, 0 means unlimited
+	maxOpenConns int
+	// lockRow is appended to a SELECT to lock the rows read until the end of
+	// the transaction
+	lockRow string
+	schema  []string
+}
+
+var postgresDialect = dialect{
+	driver:   "postgres",
+	numbered: true,
+	lockRow:  " FOR UPDATE",
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS users (
+			id                TEXT PRIMARY KEY,
+			language          TEXT NOT NULL DEFAULT '',
+			preferences       TEXT NOT NULL DEFAULT '{}',
+			registered_at     TIMESTAMPTZ NOT NULL,
+			last_active_at    TIMESTAMPTZ NOT NULL,
+			last_recording_at TIMESTAMPTZ,
+			streak_days       INTEGER NOT NULL DEFAULT 0,
+			best_streak       INTEGER NOT NULL DEFAULT 0,
+			total_recordings  INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS learners (
+			id           TEXT PRIMARY KEY,
+			display_name TEXT NOT NULL DEFAULT '',
+			level        TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS stats (
+			user_id      TEXT NOT NULL,
+			recording_id TEXT NOT NULL,
+			ayah_id      TEXT NOT NULL,
+			accuracy     DOUBLE PRECISION NOT NULL,
+			wer          DOUBLE PRECISION NOT NULL,
+			recorded_at  TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (user_id, recording_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS stats_user_recorded_at ON stats (user_id, recorded_at)`,
+		`CREATE TABLE IF NOT EXISTS pins (
+			user_id      TEXT NOT NULL,
+			recording_id TEXT NOT NULL,
+			ayah_id      TEXT NOT NULL,
+			pinned_at    TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (user_id, recording_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			user_id      TEXT NOT NULL,
+			recording_id TEXT NOT NULL,
+			tag          TEXT NOT NULL,
+			PRIMARY KEY (user_id, recording_id, tag)
+		)`,
+		`CREATE TABLE IF NOT EXISTS analytics_events (
+			name       TEXT NOT NULL,
+			user_id    TEXT NOT NULL,
+			at         TIMESTAMPTZ NOT NULL,
+			properties TEXT NOT NULL DEFAULT '{}'
+		)`,
+		`CREATE INDEX IF NOT EXISTS analytics_events_name_at ON analytics_events (name, at)`,
+	},
+}
+
+// sqliteDialect targets single-instance deployments. SQLite allows a single
+// writer, so the pool is limited to one connection, which also serializes
+// transactions without locking rows.
+var sqliteDialect = dialect{
+	driver:       "sqlite",
+	maxOpenConns: 1,
+	schema: []string{
+		`PRAGMA journal_mode = WAL`,
+		`CREATE TABLE IF NOT EXISTS users (
+			id                TEXT PRIMARY KEY,
+			language          TEXT NOT NULL DEFAULT '',
+			preferences       TEXT NOT NULL DEFAULT '{}',
+			registered_at     TIMESTAMP NOT NULL,
+			last_active_at    TIMESTAMP NOT NULL,
+			last_recording_at TIMESTAMP,
+			streak_days       INTEGER NOT NULL DEFAULT 0,
+			best_streak       INTEGER NOT NULL DEFAULT 0,
+			total_recordings  INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS learners (
+			id           TEXT PRIMARY KEY,
+			display_name TEXT NOT NULL DEFAULT '',
+			level        TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS stats (
+			user_id      TEXT NOT NULL,
+			recording_id TEXT NOT NULL,
+			ayah_id      TEXT NOT NULL,
+			accuracy     REAL NOT NULL,
+			wer          REAL NOT NULL,
+			recorded_at  TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, recording_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS stats_user_recorded_at ON stats (user_id, recorded_at)`,
+		`CREATE TABLE IF NOT EXISTS pins (
+			user_id      TEXT NOT NULL,
+			recording_id TEXT NOT NULL,
+			ayah_id      TEXT NOT NULL,
+			pinned_at    TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, recording_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			user_id      TEXT NOT NULL,
+			recording_id TEXT NOT NULL,
+			tag          TEXT NOT NULL,
+			PRIMARY KEY (user_id, recording_id, tag)
+		)`,
+		`CREATE TABLE IF NOT EXISTS analytics_events (
+			name       TEXT NOT NULL,
+			user_id    TEXT NOT NULL,
+			at         TIMESTAMP NOT NULL,
+			properties TEXT NOT NULL DEFAULT '{}'
+		)`,
+		`CREATE INDEX IF NOT EXISTS analytics_events_name_at ON analytics_events (name, at)`,
+	},
+}
+
+// Store implements the persistent repositories on top of database/sql
+type Store struct {
+	db      *sql.DB
+	dialect dialect
+	clock   domain.Clock
+}
+
+// NewPostgres connects to Postgres using the given DSN and applies the
+// schema. Profiles it creates are dated on clock.
+func NewPostgres(dsn string, clock domain.Clock) (*Store, error) {
+	return open(postgresDialect, dsn, clock)
+}
+
+// NewSQLite opens (creating if needed) the SQLite database at the given path
+// and applies the schema. Profiles it creates are dated on clock.
+func NewSQLite(path string, clock domain.Clock) (*Store, error) {
+	return open(sqliteDialect, path, clock)
+}
+
+func open(d dialect, dsn string, clock domain.Clock) (*Store, error) {
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", d.driver, err)
+	}
+	if d.maxOpenConns > 0 {
+		db.SetMaxOpenConns(d.maxOpenConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to %s: %w", d.driver, err)
+	}
+
+	for _, stmt := range d.schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("apply schema: %w", err)
+		}
+	}
+
+	return &Store{db: db, dialect: d, clock: clock}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// rebind rewrites ? placeholders into the dialect's native form
+func (s *Store) rebind(query string) string {
+	if !s.dialect.numbered {
+		return query
+	}
+
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
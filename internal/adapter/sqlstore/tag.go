@@ -0,0 +1,70 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetTags replaces the tags of a recording, removing them all when tags is
+// empty
+func (s *Store) SetTags(ctx context.Context, userID, recordingID string, tags []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, s.rebind(`DELETE FROM tags WHERE user_id = ? AND recording_id = ?`), userID, recordingID)
+	if err != nil {
+		return fmt.Errorf("delete tags: %w", err)
+	}
+	for _, tag := range tags {
+		_, err := tx.ExecContext(ctx, s.rebind(`
+			INSERT INTO tags (user_id, recording_id, tag)
+			VALUES (?, ?, ?)
+			ON CONFLICT (user_id, recording_id, tag) DO NOTHING`),
+			userID, recordingID, tag,
+		)
+		if err != nil {
+			return fmt.Errorf("insert tag: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tags: %w", err)
+	}
+	return nil
+}
+
+// ListTags returns the tags of the user's tagged recordings by recording ID
+func (s *Store) ListTags(ctx context.Context, userID string) (map[string][]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT recording_id, tag
+		FROM tags WHERE user_id = ?
+		ORDER BY recording_id, tag`), userID)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var recordingID, tag string
+		if err := rows.Scan(&recordingID, &tag); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		tags[recordingID] = append(tags[recordingID], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	return tags, nil
+}
+
+// DeleteTags removes the tags of all of the user's recordings
+func (s *Store) DeleteTags(ctx context.Context, userID string) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM tags WHERE user_id = ?`), userID); err != nil {
+		return fmt.Errorf("delete tags: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// GetUser retrieves a user profile
+func (s *Store) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT u.language, u.preferences, u.registered_at, u.last_active_at, u.last_recording_at,
+		       u.streak_days, u.best_streak, u.total_recordings,
+		       COALESCE(l.display_name, ''), COALESCE(l.level, '')
+		FROM users u LEFT JOIN learners l ON l.id = u.id
+		WHERE u.id = ?`), userID)
+
+	var (
+		user            = domain.User{ID: userID, Preferences: domain.DefaultPreferences()}
+		language        string
+		preferences     string
+		lastRecordingAt sql.NullTime
+		level           string
+	)
+	err := row.Scan(&language, &preferences, &user.RegisteredAt, &user.LastActiveAt, &lastRecordingAt,
+		&user.StreakDays, &user.BestStreak, &user.TotalRecordings,
+		&user.DisplayName, &level)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	user.LastRecordingAt = lastRecordingAt.Time
+	user.Level = domain.LearnerLevel(level)
+	if err := json.Unmarshal([]byte(preferences), &user.Preferences); err != nil {
+		return nil, fmt.Errorf("decode preferences: %w", err)
+	}
+	if language != "" {
+		user.Preferences.Language = domain.Language(language)
+	}
+
+	return &user, nil
+}
+
+// UpdateUser applies fn to a user profile, or to a new one registered at now
+// if none exists, and saves its activity and aggregates in a transaction
+// holding the profile's row
+func (s *Store) UpdateUser(ctx context.Context, userID string, now time.Time, fn func(user *domain.User)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		user            = domain.User{ID: userID, Preferences: domain.DefaultPreferences()}
+		lastRecordingAt sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, s.rebind(`
+		SELECT registered_at, last_active_at, last_recording_at, streak_days, best_streak, total_recordings
+		FROM users WHERE id = ?`+s.dialect.lockRow), userID).
+		Scan(&user.RegisteredAt, &user.LastActiveAt, &lastRecordingAt, &user.StreakDays, &user.BestStreak, &user.TotalRecordings)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		user.RegisteredAt = now
+	case err != nil:
+		return fmt.Errorf("get user: %w", err)
+	}
+	user.LastRecordingAt = lastRecordingAt.Time
+
+	fn(&user)
+
+	lastRecordingAt = sql.NullTime{Time: user.LastRecordingAt, Valid: !user.LastRecordingAt.IsZero()}
+	_, err = tx.ExecContext(ctx, s.rebind(`
+		INSERT INTO users (id, registered_at, last_active_at, last_recording_at, streak_days, best_streak, total_recordings)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			last_active_at = excluded.last_active_at,
+			last_recording_at = excluded.last_recording_at,
+			streak_days = excluded.streak_days,
+			best_streak = excluded.best_streak,
+			total_recordings = excluded.total_recordings`),
+		user.ID, user.RegisteredAt, user.LastActiveAt, lastRecordingAt,
+		user.StreakDays, user.BestStreak, user.TotalRecordings,
+	)
+	if err != nil {
+		return fmt.Errorf("save user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit user: %w", err)
+	}
+	return nil
+}
+
+// SetDisplayName stores the display name of a learner, creating their
+// learner details at the beginner level if needed
+func (s *Store) SetDisplayName(ctx context.Context, userID, displayName string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO learners (id, display_name, level)
+		VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET display_name = excluded.display_name`),
+		userID, displayName, string(domain.LevelBeginner),
+	)
+	if err != nil {
+		return fmt.Errorf("set display name: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser deletes a user profile and its learner details
+func (s *Store) DeleteUser(ctx context.Context, userID string) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM learners WHERE id = ?`), userID); err != nil {
+		return fmt.Errorf("delete learner: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM users WHERE id = ?`), userID); err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// commandAdmin dispatches the admin subcommands. It is hidden from users who
+// are not admins, who get the unknown command reply.
+func (b *Bot) commandAdmin(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	if !b.service.IsAdmin(userID) {
+		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "error.unknown_command"))
+		return
+	}
+
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) == 2 && args[0] == "user" {
+		b.adminUser(ctx, msg.Chat.ID, lang, args[1])
+		return
+	}
+
+	b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "admin.usage"))
+}
+
+// adminUser shows the session and latest audit entries of a user
+func (b *Bot) adminUser(ctx context.Context, chatID int64, lang domain.Language, targetID string) {
+	report, err := b.service.InspectUser(ctx, targetID)
+	if err != nil {
+		requestid.Printf(ctx, "Error inspecting user %s: %v", targetID, err)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(b.i18n.Get(lang, "admin.user_title", targetID))
+	text.WriteString("\n\n")
+
+	session := report.Session
+	text.WriteString(fmt.Sprintf("%s: %s", b.i18n.Get(lang, "admin.state"), session.State))
+	if session.Flow != "" && session.Flow != domain.FlowManualRecording {
+		text.WriteString(fmt.Sprintf(", %s", session.Flow))
+	}
+	if flow, err := session.ManualRecording(); err == nil {
+		if flow.SurahNumber != 0 {
+			text.WriteString(fmt.Sprintf(", %d", flow.SurahNumber))
+			if flow.AyahNumber != 0 {
+				text.WriteString(fmt.Sprintf(":%d", flow.AyahNumber))
+			}
+		}
+		if flow.AyahInput != "" {
+			text.WriteString(fmt.Sprintf(" (📝 %s)", flow.AyahInput))
+		}
+	}
+	text.WriteString("\n\n")
+
+	if len(report.Audit) == 0 {
+		text.WriteString(b.i18n.Get(lang, "admin.no_audit"))
+	}
+	for _, entry := range report.Audit {
+		text.WriteString(formatAuditEntry(entry))
+		text.WriteString("\n")
+	}
+
+	b.sendMessage(chatID, text.String())
+}
+
+func formatAuditEntry(entry domain.AuditEntry) string {
+	line := fmt.Sprintf("%s %s", entry.At.UTC().Format("01-02 15:04:05"), entry.Action)
+	if entry.ChatID != 0 {
+		line += fmt.Sprintf(" [%d]", entry.ChatID)
+	}
+	if entry.Action == domain.AuditTransition {
+		line += fmt.Sprintf(" %s → %s", entry.From, entry.To)
+	}
+	if entry.Detail != "" {
+		line += ": " + entry.Detail
+	}
+	return line
+}
@@ -1,128 +1,118 @@
 package telegram
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"net/http"
-	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/escalopa/quran-read-bot/internal/domain"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-// downloadFile downloads a file from Telegram
-func (b *Bot) downloadFile(fileURL string) ([]byte, error) {
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		return nil, fmt.Errorf("download file: %w", err)
-	}
-	defer resp.Body.Close()
+// telegramMaxDownload is the largest file the Bot API lets bots download
+const telegramMaxDownload = 20 << 20
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download failed with status: %d", resp.StatusCode)
-	}
+// errAudioTooLong is returned for attachments over the duration limit,
+// before they are downloaded
+var errAudioTooLong = errors.New("audio too long")
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read file: %w", err)
-	}
-
-	return data, nil
+// audioExtensions are the file extensions of documents taken as recordings
+var audioExtensions = map[string]bool{
+	"ogg": true, "oga": true, "opus": true, "mp3": true, "m4a": true, "aac": true, "amr": true, "wav": true,
 }
 
-// convertOGGtoWAV converts OGG audio to WAV format using FFmpeg
-func convertOGGtoWAV(oggData []byte) ([]byte, error) {
-	// Check if FFmpeg is available
-	if _, err := exec.LookPath("ffmpeg"); err != nil {
-		return nil, fmt.Errorf("ffmpeg not found: %w", err)
-	}
-
-	// Create unique temporary OGG file
-	oggFile, err := os.CreateTemp("", "quran-audio-*.ogg")
-	if err != nil {
-		return nil, fmt.Errorf("create temp ogg file: %w", err)
-	}
-	oggPath := oggFile.Name()
-
-	// Create unique temporary WAV file
-	wavFile, err := os.CreateTemp("", "quran-audio-*.wav")
-	if err != nil {
-		oggFile.Close()
-		os.Remove(oggPath)
-		return nil, fmt.Errorf("create temp wav file: %w", err)
-	}
-	wavPath := wavFile.Name()
-	wavFile.Close() // Close immediately since ffmpeg will write to it
+// audioInput is an audio file attached to a message
+type audioInput struct {
+	FileID       string
+	FileUniqueID string
+	MimeType     string
+	Duration     time.Duration // as reported by the sender, 0 if unknown
+	FileSize     int           // in bytes, 0 if unknown
+}
 
-	// Cleanup temporary files
-	defer func() {
-		os.Remove(oggPath)
-		os.Remove(wavPath)
-	}()
+// audioLimits bounds the attachments accepted for processing. Zero values
+// mean no limit.
+type audioLimits struct {
+	maxDuration time.Duration
+	maxFileSize int
+}
 
-	// Write OGG data to temporary file
-	if _, err := oggFile.Write(oggData); err != nil {
-		oggFile.Close()
-		return nil, fmt.Errorf("write ogg data: %w", err)
+// check rejects attachments over the limits, using the metadata sent by
+// Telegram so that oversized files are never downloaded
+func (l audioLimits) check(audio *audioInput) error {
+	if l.maxDuration > 0 && audio.Duration > l.maxDuration {
+		return fmt.Errorf("%w: %s", errAudioTooLong, audio.Duration)
 	}
-
-	if err := oggFile.Close(); err != nil {
-		return nil, fmt.Errorf("close ogg file: %w", err)
+	if l.maxFileSize > 0 && audio.FileSize > l.maxFileSize {
+		return fmt.Errorf("%w: %d bytes", domain.ErrAudioTooLarge, audio.FileSize)
 	}
+	return nil
+}
 
-	// Convert using FFmpeg
-	// -i input file
-	// -ar 16000 sample rate (16kHz is good for speech)
-	// -ac 1 mono audio
-	// -y overwrite output file
-	cmd := exec.Command("ffmpeg",
-		"-i", oggPath,
-		"-ar", "16000",
-		"-ac", "1",
-		"-y",
-		wavPath,
-	)
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		log.Printf("FFmpeg error: %s", stderr.String())
-		return nil, fmt.Errorf("ffmpeg conversion failed: %w", err)
+// audioAttachment returns the voice note, audio file or audio document of a
+// message, nil if it has none
+func audioAttachment(msg *tgbotapi.Message) *audioInput {
+	switch {
+	case msg.Voice != nil:
+		return &audioInput{
+			FileID:       msg.Voice.FileID,
+			FileUniqueID: msg.Voice.FileUniqueID,
+			MimeType:     msg.Voice.MimeType,
+			Duration:     time.Duration(msg.Voice.Duration) * time.Second,
+			FileSize:     msg.Voice.FileSize,
+		}
+	case msg.Audio != nil:
+		return &audioInput{
+			FileID:       msg.Audio.FileID,
+			FileUniqueID: msg.Audio.FileUniqueID,
+			MimeType:     msg.Audio.MimeType,
+			Duration:     time.Duration(msg.Audio.Duration) * time.Second,
+			FileSize:     msg.Audio.FileSize,
+		}
+	case msg.Document != nil && isAudioDocument(msg.Document):
+		return &audioInput{
+			FileID:       msg.Document.FileID,
+			FileUniqueID: msg.Document.FileUniqueID,
+			MimeType:     msg.Document.MimeType,
+			FileSize:     msg.Document.FileSize,
+		}
 	}
+	return nil
+}
 
-	// Read converted WAV file
-	wavData, err := os.ReadFile(wavPath)
-	if err != nil {
-		return nil, fmt.Errorf("read wav file: %w", err)
+// isAudioDocument reports whether a document looks like an audio file, by
+// MIME type or file extension
+func isAudioDocument(doc *tgbotapi.Document) bool {
+	if strings.HasPrefix(doc.MimeType, "audio/") {
+		return true
 	}
-
-	return wavData, nil
+	return audioExtensions[strings.ToLower(strings.TrimPrefix(filepath.Ext(doc.FileName), "."))]
 }
 
-// processVoiceMessage downloads and converts a Telegram voice message to WAV
-func (b *Bot) processVoiceMessage(fileID string) (io.Reader, error) {
-	// Get file info from Telegram
-	fileConfig := tgbotapi.FileConfig{FileID: fileID}
-	file, err := b.api.GetFile(fileConfig)
-	if err != nil {
-		return nil, fmt.Errorf("get file info: %w", err)
+// maxFileSizeMB is the size limit of recordings, in MB, for error messages
+func (b *Bot) maxFileSizeMB() int {
+	if b.limits.maxFileSize > 0 && b.limits.maxFileSize < telegramMaxDownload {
+		return b.limits.maxFileSize / (1 << 20)
 	}
+	return telegramMaxDownload / (1 << 20)
+}
 
-	// Download OGG file
-	fileURL := file.Link(b.api.Token)
-	oggData, err := b.downloadFile(fileURL)
+// prepareAudio resolves the download URL of a Telegram voice note or audio
+// file and has the service download and convert it for submission
+func (b *Bot) prepareAudio(ctx context.Context, userID string, audio *audioInput) (io.Reader, domain.AudioMetadata, error) {
+	file, err := b.api.GetFile(tgbotapi.FileConfig{FileID: audio.FileID})
 	if err != nil {
-		return nil, fmt.Errorf("download file: %w", err)
+		return nil, domain.AudioMetadata{}, fmt.Errorf("get file info: %w", err)
 	}
 
-	// Convert to WAV
-	wavData, err := convertOGGtoWAV(oggData)
-	if err != nil {
-		return nil, fmt.Errorf("convert audio: %w", err)
+	src := domain.AudioSource{
+		URL:      file.Link(b.api.Token),
+		MimeType: audio.MimeType,
+		Duration: audio.Duration,
 	}
-
-	return bytes.NewReader(wavData), nil
+	return b.service.PrepareAudio(ctx, userID, src)
 }
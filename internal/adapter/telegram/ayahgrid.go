@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxAyahGridAyahs is the number of ayahs of the longest surah whose
+	// ayahs are picked on a grid of buttons in one tap; the ayahs of longer
+	// surahs are typed on the digit pad
+	maxAyahGridAyahs = 30
+	ayahGridColumns  = 5
+	ayahGridPageSize = 20
+)
+
+// ayahKeyboard returns the keyboard to pick an ayah of a surah of count
+// ayahs: a page of the grid of its ayah numbers for short surahs, and the
+// digit pad for the others
+func (b *Bot) ayahKeyboard(lang domain.Language, count, page int) tgbotapi.InlineKeyboardMarkup {
+	if count > maxAyahGridAyahs {
+		return b.getAyahKeyboard(lang, "")
+	}
+
+	totalPages := (count + ayahGridPageSize - 1) / ayahGridPageSize
+	page = min(max(page, 0), totalPages-1)
+	first := page*ayahGridPageSize + 1
+	last := min(first+ayahGridPageSize-1, count)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for ayah := first; ayah <= last; ayah++ {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(strconv.Itoa(ayah), fmt.Sprintf("ayah:%d", ayah)))
+		if len(row) == ayahGridColumns || ayah == last {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+
+	if totalPages > 1 {
+		var navRow []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ "+b.i18n.Get(lang, "nav.prev"), fmt.Sprintf("apage:%d", page-1)))
+		}
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, totalPages), "noop"))
+		if page < totalPages-1 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "nav.next")+" ➡️", fmt.Sprintf("apage:%d", page+1)))
+		}
+		rows = append(rows, navRow)
+	}
+
+	// Ranges are typed on the digit pad
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➖ "+b.i18n.Get(lang, "ayah.range"), "ayahpad"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// editAyahSelection shows the ayah selection of the selected surah with a
+// keyboard: a page of the ayah grid, or the digit pad if pad is set
+func (b *Bot) editAyahSelection(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language, page int, pad bool) {
+	flow, err := b.service.ManualRecording(ctx, scope)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting session: %v", err)
+		return
+	}
+	surahs := b.service.GetAllSurahs()
+	if flow.SurahNumber < 1 || flow.SurahNumber > len(surahs) {
+		return
+	}
+
+	count := b.service.AyahCount(ctx, scope.UserID, surahs[flow.SurahNumber-1])
+	text := b.i18n.Get(lang, "ayah.select", b.i18n.GetSurahName(lang, flow.SurahNumber), count)
+	keyboard := b.ayahKeyboard(lang, count, page)
+	if pad {
+		keyboard = b.getAyahKeyboard(lang, "")
+	}
+	b.editMessageWithKeyboard(msg, text, keyboard)
+}
+
+// handleAyahPick selects the ayah tapped on the grid and asks for its
+// recording
+func (b *Bot) handleAyahPick(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language, ayah string) {
+	chatID := msg.Chat.ID
+	if err := b.service.HandleAyahInput(ctx, scope, ayah); err != nil {
+		requestid.Printf(ctx, "Error handling ayah input: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.invalid_ayah"))
+		return
+	}
+
+	b.send(tgbotapi.NewDeleteMessage(chatID, msg.MessageID))
+	b.sendMessage(chatID, b.recordingPrompt(ctx, scope, lang))
+}
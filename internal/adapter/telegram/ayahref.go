@@ -0,0 +1,41 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleAyahRefText offers to go to the ayah of a reference the user typed,
+// e.g. "18:10", "2 255" or "Al-Kahf 10", whatever the step of the menus.
+// It reports false for other texts and ayahs missing from the user's
+// riwayah.
+func (b *Bot) handleAyahRefText(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language) bool {
+	text := strings.TrimSpace(msg.Text)
+	ayah, ok := b.service.ParseAyahRef(ctx, scope.UserID, text)
+	if !ok || !b.service.ValidAyah(ctx, scope.UserID, ayah) {
+		return false
+	}
+
+	name := b.i18n.GetSurahName(lang, ayah.SurahNumber)
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "jump.go", name, ayah.AyahNumber), "jump:"+ayah.String()),
+	)}
+	// "18:10" is a local time too, which the user may have sent to set
+	// their time zone
+	if _, isTime := b.service.LocalTimeOffset(text); isTime && msg.Chat.IsPrivate() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "ayahref.local_time", text), "tztime:"+text),
+		))
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, b.i18n.Get(lang, "ayahref.confirm", name, ayah.AyahNumber))
+	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+	return true
+}
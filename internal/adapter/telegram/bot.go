@@ -2,24 +2,101 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"runtime"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/escalopa/quran-read-bot/internal/application"
 	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/metrics"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+const (
+	// voiceLockTTL bounds how long a processed voice file is remembered for
+	// deduplication
+	voiceLockTTL = 10 * time.Minute
+	// updateDedupTTL bounds how long handled updates are remembered. Telegram
+	// keeps undelivered updates for 24 hours.
+	updateDedupTTL = 24 * time.Hour
+	// notifyLockTTL bounds how long a sent result notification is remembered
+	notifyLockTTL = 24 * time.Hour
+
+	// expirySweepInterval is how often sessions that expired mid-flow are
+	// looked up, and expirySweepBatch how many users are popped at a time
+	expirySweepInterval = time.Minute
+	expirySweepBatch    = 100
+)
+
 type Bot struct {
-	api      *tgbotapi.BotAPI
-	service  *application.BotService
-	i18n     domain.I18nPort
-	commands map[string]CommandHandler
-	cancel   context.CancelFunc
+	api          *tgbotapi.BotAPI
+	service      *application.BotService
+	i18n         domain.I18nPort
+	sender       *sender
+	locks        domain.LockPort
+	limiter      domain.RateLimiterPort // nil for no pacing
+	updates      domain.UpdateLogPort   // nil to handle redelivered updates again
+	transcoder   *transcoder
+	limits       audioLimits
+	referenceURL string   // URL template of reference recitations, empty if disabled
+	webhook      *webhook // nil to long poll for updates
+	commands     map[string]CommandHandler
+	cancel       context.CancelFunc
+}
+
+// BotOption configures optional behavior of Bot
+type BotOption func(*Bot)
+
+// WithTranscodeLimit converts recordings on concurrency background workers,
+// queueing up to maxQueued more (0 for no limit) before turning recordings
+// away
+func WithTranscodeLimit(concurrency, maxQueued int) BotOption {
+	return func(b *Bot) {
+		b.transcoder = newTranscoder(concurrency, maxQueued)
+	}
 }
 
-func NewBot(token string, service *application.BotService, i18n domain.I18nPort) (*Bot, error) {
+// WithAudioLimits rejects recordings longer than maxDuration or larger than
+// maxFileSize bytes before downloading them. Zero values mean no limit.
+func WithAudioLimits(maxDuration time.Duration, maxFileSize int) BotOption {
+	return func(b *Bot) {
+		b.limits = audioLimits{maxDuration: maxDuration, maxFileSize: maxFileSize}
+	}
+}
+
+// WithUpdateLog skips updates delivered again, e.g. after reconnects or
+// webhook retries, that updates already records as handled
+func WithUpdateLog(updates domain.UpdateLogPort) BotOption {
+	return func(b *Bot) {
+		b.updates = updates
+	}
+}
+
+// WithRateLimiter keeps outgoing messages within Telegram's limits with
+// limiter, shared by every replica of the bot. Broadcasts also take a token
+// of domain.RateActionBroadcast per message.
+func WithRateLimiter(limiter domain.RateLimiterPort) BotOption {
+	return func(b *Bot) {
+		b.limiter = limiter
+	}
+}
+
+// WithReferenceAudio offers the reference recitation of the ayah next to
+// results. urlTemplate is the URL of an ayah's clip, with {reciter} replaced
+// by the user's reciter and {ayah} by the ayah ID (XXXYYY format).
+func WithReferenceAudio(urlTemplate string) BotOption {
+	return func(b *Bot) {
+		b.referenceURL = urlTemplate
+	}
+}
+
+func NewBot(token string, service *application.BotService, i18n domain.I18nPort, locks domain.LockPort, opts ...BotOption) (*Bot, error) {
 	api, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, fmt.Errorf("create bot: %w", err)
@@ -29,8 +106,19 @@ func NewBot(token string, service *application.BotService, i18n domain.I18nPort)
 		api:      api,
 		service:  service,
 		i18n:     i18n,
+		locks:    locks,
 		commands: make(map[string]CommandHandler),
 	}
+	for _, opt := range opts {
+		opt(bot)
+	}
+	bot.sender = newSender(api, bot.limiter)
+	if bot.transcoder == nil {
+		bot.transcoder = newTranscoder(runtime.NumCPU(), 0)
+	}
+	if bot.webhook != nil {
+		bot.webhook.secret = webhookSecret(token)
+	}
 
 	// Register commands
 	bot.registerCommands()
@@ -42,12 +130,46 @@ func (b *Bot) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	b.cancel = cancel
 
-	log.Printf("Authorized on account %s", b.api.Self.UserName)
+	requestid.Printf(ctx, "Authorized on account %s", b.api.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	var updates tgbotapi.UpdatesChannel
+	if b.webhook != nil {
+		if err := b.setWebhook(); err != nil {
+			return err
+		}
+		requestid.Printf(ctx, "Receiving updates at %s", b.webhook.url)
+		updates = b.webhook.updates
+	} else {
+		if err := b.deleteWebhook(); err != nil {
+			return err
+		}
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		updates = b.api.GetUpdatesChan(u)
+	}
 
-	updates := b.api.GetUpdatesChan(u)
+	// Deliver result notifications published by any replica
+	go func() {
+		if err := b.service.SubscribeRecordingEvents(ctx, b.notifyRecordingEvent); err != nil {
+			requestid.Printf(ctx, "Error subscribing to recording events: %v", err)
+		}
+	}()
+
+	// Convert and submit recordings in the background
+	go b.transcoder.run(ctx)
+
+	// Run the jobs shared by the replicas on the one holding the lease
+	go b.lead(ctx,
+		// Watch again the recordings whose outcome was not delivered
+		// before the last restart
+		b.service.ResumeRecordings,
+		// Tell users whose session expired mid-flow to start over
+		b.sweepExpiredSessions,
+		// Send users the progress of their memorization goals
+		b.nudgeGoals,
+		// Send subscribed users the ayah of their day
+		b.sendWirds,
+	)
 
 	for {
 		select {
@@ -59,21 +181,50 @@ func (b *Bot) Start(ctx context.Context) error {
 	}
 }
 
+// Ping checks that Telegram is reachable and accepts the bot token
+func (b *Bot) Ping(ctx context.Context) error {
+	if _, err := b.api.GetMe(); err != nil {
+		return fmt.Errorf("get me: %w", withoutURL(err))
+	}
+	return nil
+}
+
+// CheckToken checks that Telegram is reachable and accepts token, without
+// creating a bot
+func CheckToken(token string) error {
+	if _, err := tgbotapi.NewBotAPI(token); err != nil {
+		return fmt.Errorf("get me: %w", withoutURL(err))
+	}
+	return nil
+}
+
 func (b *Bot) Stop() error {
 	if b.cancel != nil {
 		b.cancel()
 	}
-	b.api.StopReceivingUpdates()
+	if b.webhook == nil {
+		b.api.StopReceivingUpdates()
+	}
 	return nil
 }
 
 func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	ctx = requestid.With(ctx, requestid.New())
+
+	if !b.firstDelivery(ctx, update.UpdateID) {
+		requestid.Printf(ctx, "Skipping update %d delivered again", update.UpdateID)
+		return
+	}
+
 	userID := b.getUserID(update)
 	if userID == "" {
 		return
 	}
 
 	lang := b.service.GetUserLanguage(ctx, userID)
+	defer b.recoverPanic(ctx, "update "+strconv.Itoa(update.UpdateID), updateChatID(update), lang)
+	b.service.TouchUser(ctx, userID)
+	b.service.RegisterLearner(ctx, userID, displayName(update))
 
 	// Handle commands
 	if update.Message != nil && update.Message.IsCommand() {
@@ -81,10 +232,12 @@ func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
 		return
 	}
 
-	// Handle voice messages
-	if update.Message != nil && update.Message.Voice != nil {
-		b.handleVoice(ctx, update.Message, lang)
-		return
+	// Handle voice messages and audio files
+	if update.Message != nil {
+		if audio := audioAttachment(update.Message); audio != nil {
+			b.handleVoice(ctx, update.Message, audio, lang)
+			return
+		}
 	}
 
 	// Handle callback queries (button presses)
@@ -93,6 +246,12 @@ func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
 		return
 	}
 
+	// Handle locations shared to detect the time zone
+	if update.Message != nil && update.Message.Location != nil {
+		b.handleLocation(ctx, update.Message, lang)
+		return
+	}
+
 	// Handle text messages (ayah number input)
 	if update.Message != nil && update.Message.Text != "" {
 		b.handleText(ctx, update.Message, lang)
@@ -109,15 +268,19 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message, lang dom
 		return
 	}
 
+	b.service.TrackCommand(strconv.FormatInt(msg.From.ID, 10), cmd)
+	defer metrics.CommandDuration.Since(cmd, time.Now())
 	handler(ctx, msg)
 }
 
 func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQuery, lang domain.Language) {
 	userID := strconv.FormatInt(callback.From.ID, 10)
 	chatID := callback.Message.Chat.ID
+	scope := sessionScope(callback.From, callback.Message.Chat)
+	defer metrics.CallbackDuration.Since(callbackKind(callback.Data), time.Now())
 
 	// Answer callback to remove loading state
-	b.api.Send(tgbotapi.NewCallback(callback.ID, ""))
+	b.send(tgbotapi.NewCallback(callback.ID, ""))
 
 	// Parse callback data
 	data := callback.Data
@@ -125,12 +288,12 @@ func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQue
 	// Handle language selection
 	if len(data) > 5 && data[:5] == "lang:" {
 		newLang := domain.Language(data[5:])
-		if err := b.service.HandleStart(ctx, userID, newLang); err != nil {
-			log.Printf("Error setting language: %v", err)
+		if err := b.service.HandleStart(ctx, scope, newLang); err != nil {
+			requestid.Printf(ctx, "Error setting language: %v", err)
 			return
 		}
 		b.sendMessage(chatID, b.i18n.Get(newLang, "language.changed"))
-		b.sendSurahSelection(ctx, chatID, userID, newLang, 0)
+		b.sendSurahSelection(ctx, chatID, scope, newLang, 0)
 		return
 	}
 
@@ -149,9 +312,13 @@ func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQue
 			return
 		}
 
-		if err := b.service.HandleSurahSelection(ctx, userID, surahNum); err != nil {
-			log.Printf("Error selecting surah: %v", err)
-			b.answerCallbackAlert(callback.ID, b.i18n.Get(lang, "error.generic"))
+		if err := b.service.HandleSurahSelection(ctx, scope, surahNum); err != nil {
+			requestid.Printf(ctx, "Error selecting surah: %v", err)
+			if errors.Is(err, domain.ErrInvalidTransition) {
+				b.restartFlow(ctx, chatID, scope, lang, err)
+				return
+			}
+			b.answerCallbackAlert(callback.ID, b.errorMessage(ctx, lang, err, "error.generic"))
 			return
 		}
 
@@ -160,30 +327,43 @@ func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQue
 		surah := surahs[surahNum-1]
 		surahName := b.i18n.GetSurahName(lang, surahNum)
 
-		// Clear any previous ayah input
-		b.service.ClearAyahInput(ctx, userID)
-
 		// Edit the message to show ayah selection
-		msg := b.i18n.Get(lang, "ayah.select", surahName, surah.Ayahs)
-		b.editMessageWithKeyboard(callback.Message, msg, b.getAyahKeyboard(lang, ""))
+		count := b.service.AyahCount(ctx, scope.UserID, surah)
+		msg := b.i18n.Get(lang, "ayah.select", surahName, count)
+		b.editMessageWithKeyboard(callback.Message, msg, b.ayahKeyboard(lang, count, 0))
+		return
+	}
+
+	// Handle the ayah grid of short surahs
+	if len(data) > 5 && data[:5] == "ayah:" {
+		b.handleAyahPick(ctx, callback.Message, scope, lang, data[5:])
+		return
+	}
+	if len(data) > 6 && data[:6] == "apage:" {
+		page, _ := strconv.Atoi(data[6:])
+		b.editAyahSelection(ctx, callback.Message, scope, lang, page, false)
+		return
+	}
+	if data == "ayahpad" {
+		b.editAyahSelection(ctx, callback.Message, scope, lang, 0, true)
 		return
 	}
 
 	// Handle digit input
 	if len(data) > 6 && data[:6] == "digit:" {
-		b.handleDigitInput(ctx, callback.Message, userID, lang, data[6:])
+		b.handleDigitInput(ctx, callback.Message, scope, lang, data[6:])
 		return
 	}
 
 	// Handle clear/backspace
 	if data == "clear" {
-		b.handleClearDigit(ctx, callback.Message, userID, lang)
+		b.handleClearDigit(ctx, callback.Message, scope, lang)
 		return
 	}
 
 	// Handle done (when ayah number is entered)
 	if data == "done" {
-		b.handleAyahDone(ctx, callback.Message, userID, lang)
+		b.handleAyahDone(ctx, callback.Message, scope, lang)
 		return
 	}
 
@@ -197,15 +377,53 @@ func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQue
 	// Handle new recording button
 	if data == "newrecord" {
 		chatID := callback.Message.Chat.ID
-		if err := b.service.HandleStart(ctx, userID, lang); err != nil {
-			log.Printf("Error handling start: %v", err)
+		if err := b.service.HandleStart(ctx, scope, lang); err != nil {
+			requestid.Printf(ctx, "Error handling start: %v", err)
 			return
 		}
 		// Delete the previous message
 		deleteMsg := tgbotapi.NewDeleteMessage(chatID, callback.Message.MessageID)
-		b.api.Send(deleteMsg)
+		b.send(deleteMsg)
 		// Show surah selection
-		b.sendSurahSelection(ctx, chatID, userID, lang, 0)
+		b.sendSurahSelection(ctx, chatID, scope, lang, 0)
+		return
+	}
+
+	// Handle continuing after the last ayah recorded
+	if data == "continue" {
+		b.continueRecording(ctx, callback.Message.Chat.ID, scope, lang)
+		return
+	}
+
+	if len(data) > 6 && data[:6] == "retry:" {
+		b.handleRetry(ctx, callback.Message.Chat.ID, scope, lang, data[6:])
+		return
+	}
+
+	if len(data) > 5 && data[:5] == "jump:" {
+		b.handleJump(ctx, callback.Message, scope, lang, data[5:])
+		return
+	}
+
+	if len(data) > 6 && data[:6] == "drill:" {
+		b.handleDrillCallback(ctx, callback.Message, scope, lang, data[6:])
+		return
+	}
+
+	if len(data) > 5 && data[:5] == "weak:" {
+		b.handleWeakPick(ctx, callback.Message.Chat.ID, scope, lang, data[5:])
+		return
+	}
+
+	// The first missing ayah of a surah in progress is picked like a weak
+	// one, keeping the list
+	if len(data) > 9 && data[:9] == "progress:" {
+		b.handleWeakPick(ctx, callback.Message.Chat.ID, scope, lang, data[9:])
+		return
+	}
+
+	if len(data) > 7 && data[:7] == "recite:" {
+		b.handleRecite(ctx, callback.Message.Chat.ID, scope, lang, data[7:])
 		return
 	}
 
@@ -214,7 +432,7 @@ func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQue
 		page, _ := strconv.Atoi(data[8:])
 		recordings, err := b.service.ListRecordings(ctx, userID, 50)
 		if err != nil {
-			log.Printf("Error listing recordings: %v", err)
+			requestid.Printf(ctx, "Error listing recordings: %v", err)
 			return
 		}
 		b.editRecordingsList(callback.Message, userID, lang, recordings, page)
@@ -228,11 +446,179 @@ func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQue
 		return
 	}
 
+	// Handle comparisons of attempts at the same ayah
+	if len(data) > 4 && data[:4] == "cmp:" {
+		b.handleCompareCallback(ctx, callback.Message, userID, lang, data[4:])
+		return
+	}
+
+	// Handle challenges to friends
+	if len(data) > 5 && data[:5] == "chal:" {
+		b.handleCreateChallenge(ctx, chatID, userID, lang, data[5:])
+		return
+	}
+
+	// Handle leaderboard period switch
+	if len(data) > 3 && data[:3] == "lb:" {
+		period := domain.LeaderboardPeriod(data[3:])
+		if period != domain.LeaderboardWeekly && period != domain.LeaderboardMonthly {
+			return
+		}
+		b.handleLeaderboardPeriod(ctx, callback.Message, userID, lang, period)
+		return
+	}
+
+	// Handle reference recitation requests
+	if len(data) > 4 && data[:4] == "ref:" {
+		b.handleReferenceAudio(ctx, chatID, userID, lang, data[4:])
+		return
+	}
+
+	// Handle playback of the user's own recordings
+	if len(data) > 5 && data[:5] == "play:" {
+		b.handlePlayRecording(ctx, chatID, userID, lang, data[5:])
+		return
+	}
+
+	// Handle range breakdown navigation
+	if len(data) > 4 && data[:4] == "rng:" {
+		b.handleRangeBreakdown(ctx, callback.Message, userID, lang, data[4:])
+		return
+	}
+
+	// Handle settings toggles
+	if len(data) > 9 && data[:9] == "settings:" {
+		b.handleSettingToggle(ctx, callback.Message, userID, lang, data[9:])
+		return
+	}
+
+	// Handle time zones picked among the suggestions
+	if len(data) > 3 && data[:3] == "tz:" {
+		b.handleTimezonePick(ctx, callback.Message, userID, lang, data[3:])
+		return
+	}
+
+	// Handle local times typed that were taken for an ayah
+	if len(data) > 7 && data[:7] == "tztime:" {
+		b.handleLocalTimePick(ctx, callback.Message, lang, data[7:])
+		return
+	}
+
+	// Handle memorization goals
+	if data == "goalnew" {
+		b.handleGoalSurahPage(callback.Message, lang, 0)
+		return
+	}
+
+	if len(data) > 9 && data[:9] == "goalpage:" {
+		page, _ := strconv.Atoi(data[9:])
+		b.handleGoalSurahPage(callback.Message, lang, page)
+		return
+	}
+
+	if len(data) > 10 && data[:10] == "goalsurah:" {
+		surahNumber, _ := strconv.Atoi(data[10:])
+		b.handleGoalSurah(callback.Message, lang, surahNumber)
+		return
+	}
+
+	if len(data) > 9 && data[:9] == "goaldays:" {
+		b.handleGoalDeadline(ctx, callback.Message, userID, lang, data[9:])
+		return
+	}
+
+	if len(data) > 8 && data[:8] == "goaldel:" {
+		surahNumber, _ := strconv.Atoi(data[8:])
+		b.handleGoalRemove(ctx, callback.Message, userID, lang, surahNumber)
+		return
+	}
+
+	// Handle the khatmah tracker
+	if len(data) > 8 && data[:8] == "khatmah:" {
+		b.handleKhatmahCallback(ctx, callback.Message, scope, lang, data[8:])
+		return
+	}
+
+	// Handle the daily wird
+	if len(data) > 9 && data[:9] == "wirdplan:" {
+		b.handleWirdPlan(ctx, callback.Message, userID, lang, domain.WirdPlan(data[9:]))
+		return
+	}
+
+	if data == "wirdstop" {
+		b.handleWirdStop(ctx, callback.Message, userID, lang)
+		return
+	}
+
+	if len(data) > 8 && data[:8] == "wirdrec:" {
+		b.handleWirdRecord(ctx, chatID, scope, lang, data[8:])
+		return
+	}
+
+	// Handle account deletion confirmation
+	if data == "delmydata:confirm" {
+		b.handleDeleteMyData(ctx, callback.Message, userID, lang)
+		return
+	}
+
+	if data == "delmydata:cancel" {
+		b.editMessageText(callback.Message, b.i18n.Get(lang, "delete.cancelled"))
+		return
+	}
+
+	// Handle pinned recordings
+	if len(data) > 4 && data[:4] == "pin:" {
+		b.handlePin(ctx, callback.Message, userID, lang, data[4:], true)
+		return
+	}
+
+	if len(data) > 6 && data[:6] == "unpin:" {
+		b.handlePin(ctx, callback.Message, userID, lang, data[6:], false)
+		return
+	}
+
+	if data == "pins" {
+		b.handlePinnedList(ctx, callback.Message, userID, lang)
+		return
+	}
+
+	// Handle recording tags
+	if len(data) > 5 && data[:5] == "tags:" {
+		b.handleTagEditor(ctx, callback.Message, userID, lang, data[5:])
+		return
+	}
+
+	if len(data) > 7 && data[:7] == "addtag:" {
+		b.handleTagCallback(ctx, callback.Message, userID, lang, data[7:], true)
+		return
+	}
+
+	if len(data) > 6 && data[:6] == "untag:" {
+		b.handleTagCallback(ctx, callback.Message, userID, lang, data[6:], false)
+		return
+	}
+
+	if len(data) > 7 && data[:7] == "newtag:" {
+		b.handleNewTag(ctx, callback.Message, scope, lang, data[7:])
+		return
+	}
+
+	if data == "tagfilter" {
+		b.handleTagFilter(ctx, callback.Message, userID, lang)
+		return
+	}
+
+	if len(data) > 7 && data[:7] == "rectag:" {
+		index, _ := strconv.Atoi(data[7:])
+		b.handleTaggedList(ctx, callback.Message, userID, lang, index)
+		return
+	}
+
 	// Handle back to recordings list
 	if data == "backtorecs" {
 		recordings, err := b.service.ListRecordings(ctx, userID, 50)
 		if err != nil {
-			log.Printf("Error listing recordings: %v", err)
+			requestid.Printf(ctx, "Error listing recordings: %v", err)
 			return
 		}
 		b.editRecordingsList(callback.Message, userID, lang, recordings, 0)
@@ -240,65 +626,215 @@ func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQue
 	}
 }
 
+// callbackKinds are the prefixes of the callback data of the bot's buttons,
+// up to the first ":"
+var callbackKinds = map[string]bool{
+	"lang": true, "spage": true, "surah": true, "ayah": true, "apage": true, "ayahpad": true, "digit": true, "check": true,
+	"recpage": true, "viewrec": true, "cmp": true, "chal": true, "lb": true, "ref": true, "play": true, "rng": true,
+	"settings": true, "tz": true, "tztime": true, "delmydata": true, "backtorecs": true, "pin": true, "unpin": true, "pins": true,
+	"tags": true, "addtag": true, "untag": true, "newtag": true, "tagfilter": true, "rectag": true,
+	"continue": true, "retry": true, "recite": true, "jump": true, "weak": true, "progress": true, "drill": true, "goalnew": true, "goalpage": true, "goalsurah": true, "goaldays": true, "goaldel": true,
+	"khatmah": true, "wirdplan": true, "wirdstop": true, "wirdrec": true,
+}
+
+// callbackKind returns the prefix of callback data the bot knows, or "other",
+// to label metrics without letting clients add labels
+func callbackKind(data string) string {
+	kind, _, _ := strings.Cut(data, ":")
+	if callbackKinds[kind] {
+		return kind
+	}
+	return "other"
+}
+
 func (b *Bot) handleText(ctx context.Context, msg *tgbotapi.Message, lang domain.Language) {
-	userID := strconv.FormatInt(msg.From.ID, 10)
+	scope := sessionScope(msg.From, msg.Chat)
 	chatID := msg.Chat.ID
 
-	state, err := b.service.GetCurrentState(ctx, userID)
+	state, err := b.service.GetCurrentState(ctx, scope)
 	if err != nil {
-		log.Printf("Error getting state: %v", err)
-		b.sendMessage(chatID, b.i18n.Get(lang, "error.generic"))
+		requestid.Printf(ctx, "Error getting state: %v", err)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	// Take a new tag the user was asked for, then go to an ayah typed as a
+	// reference, e.g. "18:10", from any step
+	if b.handleTagText(ctx, msg, scope, lang) {
+		return
+	}
+	if b.handleAyahRefText(ctx, msg, scope, lang) {
 		return
 	}
 
 	// Handle ayah number input
 	if state == domain.StateEnterAyah {
-		if err := b.service.HandleAyahInput(ctx, userID, msg.Text); err != nil {
-			b.sendMessage(chatID, b.i18n.Get(lang, "error.invalid_ayah"))
+		if err := b.service.HandleAyahInput(ctx, scope, msg.Text); err != nil {
+			if errors.Is(err, domain.ErrInvalidTransition) {
+				b.restartFlow(ctx, chatID, scope, lang, err)
+				return
+			}
+			b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
 			return
 		}
 
 		// Prompt for recording
-		b.sendMessage(chatID, b.i18n.Get(lang, "recording.prompt"))
+		b.sendMessage(chatID, b.recordingPrompt(ctx, scope, lang))
 		return
 	}
 
-	// For other states, show help
+	// Check the ayah typed from memory in typed mode
+	if state == domain.StateWaitRecording && b.handleTypedAyah(ctx, msg, scope, lang) {
+		return
+	}
+
+	// For other states, take a time zone or local time, or show help
+	if b.handleTimezoneText(ctx, msg, lang) {
+		return
+	}
 	b.sendMessage(chatID, b.i18n.Get(lang, "help.message"))
 }
 
-func (b *Bot) handleVoice(ctx context.Context, msg *tgbotapi.Message, lang domain.Language) {
-	userID := strconv.FormatInt(msg.From.ID, 10)
+func (b *Bot) handleVoice(ctx context.Context, msg *tgbotapi.Message, audio *audioInput, lang domain.Language) {
+	scope := sessionScope(msg.From, msg.Chat)
+	userID := scope.UserID
 	chatID := msg.Chat.ID
 
-	state, err := b.service.GetCurrentState(ctx, userID)
+	// Skip voice files sent again that another replica is already processing
+	fileLock := fmt.Sprintf("voice:%s:%s", userID, audio.FileUniqueID)
+	if !b.acquireLock(ctx, fileLock, voiceLockTTL) {
+		return
+	}
+
+	// A caption such as "2:255" or "Al-Baqarah 255" selects the ayah
+	// recorded, whatever the state, skipping the menus
+	if ayah, ok := b.service.ParseAyahRef(ctx, userID, msg.Caption); ok {
+		if err := b.service.RecordAyah(ctx, scope, ayah); err != nil {
+			requestid.Printf(ctx, "Error selecting ayah from caption: %v", err)
+			b.releaseLock(ctx, fileLock)
+			b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+			return
+		}
+	}
+
+	// During a word drill, voice messages are recordings of the word
+	// drilled
+	if b.handleDrillVoice(ctx, msg, scope, audio, fileLock, lang) {
+		return
+	}
+
+	// While picking a surah, a short voice message may say the ayah to go
+	// to instead
+	state, err := b.service.GetCurrentState(ctx, scope)
+	if err == nil && state == domain.StateSelectSurah && b.handleSpokenAyah(ctx, msg, scope, audio, fileLock, lang) {
+		return
+	}
 	if err != nil || state != domain.StateWaitRecording {
+		b.releaseLock(ctx, fileLock)
 		b.sendMessage(chatID, b.i18n.Get(lang, "error.unexpected_voice"))
 		return
 	}
 
+	// Reject oversized recordings before spending bandwidth and ffmpeg time
+	if err := b.limits.check(audio); err != nil {
+		b.releaseLock(ctx, fileLock)
+		if errors.Is(err, errAudioTooLong) {
+			b.sendMessage(chatID, b.i18n.Get(lang, "error.audio_too_long", int(b.limits.maxDuration.Seconds())))
+		} else {
+			b.sendMessage(chatID, b.i18n.Get(lang, "error.audio_too_large", b.limits.maxFileSize/(1<<20)))
+		}
+		return
+	}
+
+	if allowed, retryAfter := b.service.AllowSubmission(ctx, userID); !allowed {
+		b.releaseLock(ctx, fileLock)
+		minutes := int(math.Ceil(retryAfter.Minutes()))
+		b.sendMessage(chatID, b.i18n.Get(lang, "error.rate_limited", minutes))
+		return
+	}
+
+	// Hand the download and conversion over to the transcoding workers,
+	// keeping the ID of the update that sent the recording
+	reqID := requestid.From(ctx)
+	queued := time.Now()
+	position, err := b.transcoder.submit(func(ctx context.Context) {
+		ctx = requestid.With(ctx, reqID)
+		defer b.recoverPanic(ctx, "recording submission", chatID, lang)
+		metrics.VoiceStageDuration.Since("queue", queued)
+		b.submitRecording(ctx, scope, chatID, audio, fileLock, lang)
+	})
+	if err != nil {
+		b.releaseLock(ctx, fileLock)
+		if errors.Is(err, errTranscodeQueueFull) {
+			b.sendMessage(chatID, b.i18n.Get(lang, "error.queue_full"))
+		}
+		return
+	}
+
 	// Send processing message
-	b.sendMessage(chatID, b.i18n.Get(lang, "recording.processing"))
+	if position > 0 {
+		b.sendMessage(chatID, b.i18n.Get(lang, "recording.queued", position))
+	} else {
+		b.sendMessage(chatID, b.i18n.Get(lang, "recording.processing"))
+	}
+}
 
-	// Process voice message (download and convert to WAV)
-	audioReader, err := b.processVoiceMessage(msg.Voice.FileID)
+// submitRecording downloads and converts a recording, submits it and tells
+// the user what to do next. It runs on a transcoding worker.
+func (b *Bot) submitRecording(ctx context.Context, scope domain.SessionScope, chatID int64, audio *audioInput, fileLock string, lang domain.Language) {
+	userID := scope.UserID
+
+	// Process voice message (download and convert)
+	audioReader, meta, err := b.prepareAudio(ctx, userID, audio)
 	if err != nil {
-		log.Printf("Error processing voice message: %v", err)
-		b.sendMessage(chatID, b.i18n.Get(lang, "error.audio_conversion"))
+		requestid.Printf(ctx, "Error processing voice message: %v", err)
+		b.releaseLock(ctx, fileLock)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.audio_conversion"))
 		return
 	}
 
 	// Submit recording to API
-	recording, err := b.service.HandleRecording(ctx, userID, audioReader)
+	submission, err := b.service.HandleRecording(ctx, scope, audioReader, meta)
 	if err != nil {
-		log.Printf("Error handling recording: %v", err)
-		b.sendMessage(chatID, b.i18n.Get(lang, "error.recording_failed"))
+		requestid.Printf(ctx, "Error handling recording: %v", err)
+		b.releaseLock(ctx, fileLock)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.recording_failed"))
 		return
 	}
+	b.sendSubmission(ctx, scope, chatID, lang, submission)
+}
+
+// sendSubmission tells the user a recording was submitted, watches it, and
+// asks what to do next
+func (b *Bot) sendSubmission(ctx context.Context, scope domain.SessionScope, chatID int64, lang domain.Language, submission *application.Submission) {
+	userID := scope.UserID
+	recording := submission.Recording
 
-	// Send success message with recording ID
+	// Send success message with recording ID, refreshed with its place in
+	// the queue until the analysis is finished, then with its details
 	successMsg := b.i18n.Get(lang, "recording.submitted", recording.ID)
-	b.sendMessage(chatID, successMsg)
+	sent, err := b.send(tgbotapi.NewMessage(chatID, successMsg))
+	if err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+
+	// Notify the user once the analysis is finished
+	b.service.WatchRecording(ctx, userID, chatID, recording.ID, b.liveRecording(sent, userID, recording.ID, lang, successMsg))
+
+	// Ask for the next ayah of a range, and break the whole range down once
+	// it is recorded
+	if ayahs := submission.Range; ayahs != nil {
+		if submission.Next != nil {
+			b.sendMessage(chatID, b.recordingPrompt(ctx, scope, lang))
+			return
+		}
+		b.sendRangeBreakdown(ctx, chatID, userID, lang, *ayahs, submission.RangeStartedAt)
+		return
+	}
 
 	// Offer to check status or create new recording
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -312,33 +848,28 @@ func (b *Bot) handleVoice(ctx context.Context, msg *tgbotapi.Message, lang domai
 				"newrecord",
 			),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.continue"), "continue"),
+		),
 	)
 
 	replyMsg := tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "recording.what_next"))
 	replyMsg.ReplyMarkup = keyboard
-	b.api.Send(replyMsg)
+	b.send(replyMsg)
 }
 
-func (b *Bot) handleDigitInput(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, digit string) {
-	// Get current input
-	currentInput := b.service.GetAyahInput(ctx, userID)
-
-	// Append digit (limit to 3 digits for ayah number)
-	if len(currentInput) < 3 {
-		currentInput += digit
-		if err := b.service.SetAyahInput(ctx, userID, currentInput); err != nil {
-			log.Printf("Error setting ayah input: %v", err)
-			return
-		}
-	}
-
-	// Get selected surah info
-	surahNum, err := b.service.GetSelectedSurah(ctx, userID)
+func (b *Bot) handleDigitInput(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language, digit string) {
+	flow, err := b.service.UpdateAyahInput(ctx, scope, func(input string) string {
+		return appendAyahKey(input, digit)
+	})
 	if err != nil {
-		log.Printf("Error getting selected surah: %v", err)
+		requestid.Printf(ctx, "Error setting ayah input: %v", err)
 		return
 	}
+	currentInput := flow.AyahInput
 
+	// Get selected surah info
+	surahNum := flow.SurahNumber
 	surahs := b.service.GetAllSurahs()
 	if surahNum < 1 || surahNum > len(surahs) {
 		return
@@ -347,7 +878,7 @@ func (b *Bot) handleDigitInput(ctx context.Context, msg *tgbotapi.Message, userI
 	surahName := b.i18n.GetSurahName(lang, surahNum)
 
 	// Update message with current input
-	text := b.i18n.Get(lang, "ayah.select", surahName, surah.Ayahs)
+	text := b.i18n.Get(lang, "ayah.select", surahName, b.service.AyahCount(ctx, scope.UserID, surah))
 	if currentInput != "" {
 		text += fmt.Sprintf("\n\n📝 %s", currentInput)
 	}
@@ -355,26 +886,22 @@ func (b *Bot) handleDigitInput(ctx context.Context, msg *tgbotapi.Message, userI
 	b.editMessageWithKeyboard(msg, text, b.getAyahKeyboard(lang, currentInput))
 }
 
-func (b *Bot) handleClearDigit(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language) {
-	// Get current input
-	currentInput := b.service.GetAyahInput(ctx, userID)
-
+func (b *Bot) handleClearDigit(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language) {
 	// Remove last digit
-	if len(currentInput) > 0 {
-		currentInput = currentInput[:len(currentInput)-1]
-		if err := b.service.SetAyahInput(ctx, userID, currentInput); err != nil {
-			log.Printf("Error setting ayah input: %v", err)
-			return
+	flow, err := b.service.UpdateAyahInput(ctx, scope, func(input string) string {
+		if len(input) > 0 {
+			return input[:len(input)-1]
 		}
-	}
-
-	// Get selected surah info
-	surahNum, err := b.service.GetSelectedSurah(ctx, userID)
+		return input
+	})
 	if err != nil {
-		log.Printf("Error getting selected surah: %v", err)
+		requestid.Printf(ctx, "Error setting ayah input: %v", err)
 		return
 	}
+	currentInput := flow.AyahInput
 
+	// Get selected surah info
+	surahNum := flow.SurahNumber
 	surahs := b.service.GetAllSurahs()
 	if surahNum < 1 || surahNum > len(surahs) {
 		return
@@ -383,7 +910,7 @@ func (b *Bot) handleClearDigit(ctx context.Context, msg *tgbotapi.Message, userI
 	surahName := b.i18n.GetSurahName(lang, surahNum)
 
 	// Update message with current input
-	text := b.i18n.Get(lang, "ayah.select", surahName, surah.Ayahs)
+	text := b.i18n.Get(lang, "ayah.select", surahName, b.service.AyahCount(ctx, scope.UserID, surah))
 	if currentInput != "" {
 		text += fmt.Sprintf("\n\n📝 %s", currentInput)
 	}
@@ -391,20 +918,26 @@ func (b *Bot) handleClearDigit(ctx context.Context, msg *tgbotapi.Message, userI
 	b.editMessageWithKeyboard(msg, text, b.getAyahKeyboard(lang, currentInput))
 }
 
-func (b *Bot) handleAyahDone(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language) {
+func (b *Bot) handleAyahDone(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language) {
 	chatID := msg.Chat.ID
 
+	flow, err := b.service.ManualRecording(ctx, scope)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting session: %v", err)
+		return
+	}
+
 	// Get accumulated input
-	ayahInput := b.service.GetAyahInput(ctx, userID)
+	ayahInput := flow.AyahInput
+	surahNum := flow.SurahNumber
 
 	if ayahInput == "" {
 		// Edit message to show error
-		surahNum, _ := b.service.GetSelectedSurah(ctx, userID)
 		surahs := b.service.GetAllSurahs()
 		if surahNum >= 1 && surahNum <= len(surahs) {
 			surah := surahs[surahNum-1]
 			surahName := b.i18n.GetSurahName(lang, surahNum)
-			text := b.i18n.Get(lang, "ayah.select", surahName, surah.Ayahs)
+			text := b.i18n.Get(lang, "ayah.select", surahName, b.service.AyahCount(ctx, scope.UserID, surah))
 			text += "\n\n⚠️ " + b.i18n.Get(lang, "error.invalid_ayah")
 			b.editMessageWithKeyboard(msg, text, b.getAyahKeyboard(lang, ""))
 		}
@@ -412,36 +945,134 @@ func (b *Bot) handleAyahDone(ctx context.Context, msg *tgbotapi.Message, userID
 	}
 
 	// Process ayah number
-	if err := b.service.HandleAyahInput(ctx, userID, ayahInput); err != nil {
-		log.Printf("Error handling ayah input: %v", err)
+	if err := b.service.HandleAyahInput(ctx, scope, ayahInput); err != nil {
+		requestid.Printf(ctx, "Error handling ayah input: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		errText := b.errorMessage(ctx, lang, err, "error.invalid_ayah")
 
 		// Edit message to show error
-		surahNum, _ := b.service.GetSelectedSurah(ctx, userID)
 		surahs := b.service.GetAllSurahs()
 		if surahNum >= 1 && surahNum <= len(surahs) {
 			surah := surahs[surahNum-1]
 			surahName := b.i18n.GetSurahName(lang, surahNum)
-			text := b.i18n.Get(lang, "ayah.select", surahName, surah.Ayahs)
-			text += "\n\n⚠️ " + b.i18n.Get(lang, "error.invalid_ayah")
+			text := b.i18n.Get(lang, "ayah.select", surahName, b.service.AyahCount(ctx, scope.UserID, surah))
+			text += "\n\n⚠️ " + errText
 			b.editMessageWithKeyboard(msg, text, b.getAyahKeyboard(lang, ayahInput))
 		}
 		return
 	}
 
-	// Clear input after successful submission
-	b.service.ClearAyahInput(ctx, userID)
-
 	// Delete the keyboard message
 	deleteMsg := tgbotapi.NewDeleteMessage(chatID, msg.MessageID)
-	b.api.Send(deleteMsg)
+	b.send(deleteMsg)
 
 	// Send prompt for recording
-	b.sendMessage(chatID, b.i18n.Get(lang, "recording.prompt"))
+	b.sendMessage(chatID, b.recordingPrompt(ctx, scope, lang))
+}
+
+// recordingPrompt asks for the recording of the selected ayah, or to type it
+// in typed mode, noting where it is in a range and when it is an ayah of
+// prostration
+func (b *Bot) recordingPrompt(ctx context.Context, scope domain.SessionScope, lang domain.Language) string {
+	text := b.i18n.Get(lang, "recording.prompt")
+	if b.service.SessionMode(ctx, scope) == domain.ModeTyped {
+		text = b.i18n.Get(lang, "type.prompt")
+	}
+	flow, err := b.service.ManualRecording(ctx, scope)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting session: %v", err)
+		return text
+	}
+	if flow.IsRange() {
+		position := flow.AyahNumber - flow.RangeStart + 1
+		total := flow.RangeEnd - flow.RangeStart + 1
+		text = b.i18n.Get(lang, "range.next", flow.AyahNumber, position, total) + "\n\n" + text
+	}
+	if b.service.HasSajdah(ctx, scope.UserID, flow.Ayah()) {
+		text += "\n\n" + b.i18n.Get(lang, "ayah.sajdah")
+	}
+	return text
+}
+
+func (b *Bot) handleDeleteMyData(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language) {
+	b.editMessageText(msg, b.i18n.Get(lang, "delete.in_progress"))
+
+	if err := b.service.DeleteUserData(ctx, userID); err != nil {
+		requestid.Printf(ctx, "Error deleting user data: %v", err)
+		b.editMessageText(msg, b.i18n.Get(lang, "delete.failed"))
+		return
+	}
+
+	// The language preference is gone too, so confirm in the language the
+	// user requested the deletion in
+	b.editMessageText(msg, b.i18n.Get(lang, "delete.done"))
+}
+
+// restartFlow tells the user their session expired or was out of sync, as
+// err says, and shows the surah selection again
+func (b *Bot) restartFlow(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language, err error) {
+	b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.flow_reset"))
+	b.sendSurahSelection(ctx, chatID, scope, lang, 0)
+}
+
+// sweepExpiredSessions periodically notifies users whose session expired in
+// the middle of a flow, until ctx is done
+func (b *Bot) sweepExpiredSessions(ctx context.Context) {
+	ticker := time.NewTicker(expirySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			scopes, err := b.service.PopExpiredSessions(ctx, expirySweepBatch)
+			if err != nil {
+				requestid.Printf(ctx, "Error popping expired sessions: %v", err)
+				break
+			}
+			for _, scope := range scopes {
+				b.notifySessionExpired(ctx, scope)
+			}
+			if len(scopes) < expirySweepBatch {
+				break
+			}
+		}
+	}
+}
+
+// notifySessionExpired sends the session expiry notice to the session's chat
+func (b *Bot) notifySessionExpired(ctx context.Context, scope domain.SessionScope) {
+	chatID, err := b.chatID(scope)
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing user ID %s: %v", scope.UserID, err)
+		return
+	}
+
+	lang := b.service.GetUserLanguage(ctx, scope.UserID)
+	if _, err := b.send(tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "session.expired"))); err != nil {
+		requestid.Printf(ctx, "Error sending session expiry notice to %s: %v", scope, err)
+	}
+}
+
+// chatID returns the chat of a scope. The private chat's ID equals the user
+// ID.
+func (b *Bot) chatID(scope domain.SessionScope) (int64, error) {
+	if scope.IsGroup() {
+		return scope.ChatID, nil
+	}
+	return strconv.ParseInt(scope.UserID, 10, 64)
 }
 
 func (b *Bot) sendMessage(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
-	if _, err := b.api.Send(msg); err != nil {
+	if _, err := b.send(msg); err != nil {
 		log.Printf("Error sending message: %v", err)
 	}
 }
@@ -457,22 +1088,57 @@ func (b *Bot) sendLanguageSelection(chatID int64, currentLang domain.Language) {
 
 	msg := tgbotapi.NewMessage(chatID, b.i18n.Get(currentLang, "language.select"))
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(msg)
 }
 
-func (b *Bot) sendSurahSelection(ctx context.Context, chatID int64, userID string, lang domain.Language, page int) {
-	keyboard := b.getSurahKeyboard(lang, page)
+func (b *Bot) sendSurahSelection(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language, page int) {
+	keyboard := b.withRecentSurahs(ctx, scope.UserID, lang, b.getSurahKeyboard(lang, page, "surah", "spage"))
 	msg := tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "surah.select"))
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	sent, err := b.send(msg)
+	if err != nil {
+		requestid.Printf(ctx, "Error sending surah selection: %v", err)
+		return
+	}
+
+	// Remove the keyboard of the previous flow so stale buttons can't be used
+	previous, err := b.service.SetLastMessageID(ctx, scope, sent.MessageID)
+	if err != nil {
+		requestid.Printf(ctx, "Error saving last message: %v", err)
+		return
+	}
+	if previous != 0 && previous != sent.MessageID {
+		b.removeKeyboard(chatID, previous)
+	}
 }
 
 func (b *Bot) editSurahSelection(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, page int) {
-	keyboard := b.getSurahKeyboard(lang, page)
+	keyboard := b.withRecentSurahs(ctx, userID, lang, b.getSurahKeyboard(lang, page, "surah", "spage"))
 	b.editMessageWithKeyboard(msg, b.i18n.Get(lang, "surah.select"), keyboard)
 }
 
-func (b *Bot) getSurahKeyboard(lang domain.Language, page int) tgbotapi.InlineKeyboardMarkup {
+// withRecentSurahs prepends a row of the surahs the user selected last to a
+// surah keyboard, latest first
+func (b *Bot) withRecentSurahs(ctx context.Context, userID string, lang domain.Language, keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	surahs := b.service.RecentSurahs(ctx, userID)
+	if len(surahs) == 0 {
+		return keyboard
+	}
+
+	row := make([]tgbotapi.InlineKeyboardButton, 0, len(surahs))
+	for _, surah := range surahs {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			b.i18n.Get(lang, "surah.recent", b.i18n.GetSurahName(lang, surah)),
+			fmt.Sprintf("surah:%d", surah),
+		))
+	}
+	keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	return keyboard
+}
+
+// getSurahKeyboard returns a page of surah buttons sending "<kind>:<surah>",
+// and navigation buttons sending "<pageKind>:<page>"
+func (b *Bot) getSurahKeyboard(lang domain.Language, page int, kind, pageKind string) tgbotapi.InlineKeyboardMarkup {
 	surahs := b.service.GetAllSurahs()
 
 	const itemsPerPage = 10
@@ -499,7 +1165,7 @@ func (b *Bot) getSurahKeyboard(lang domain.Language, page int) tgbotapi.InlineKe
 		name1 := b.i18n.GetSurahName(lang, surah1.Number)
 		btn1 := tgbotapi.NewInlineKeyboardButtonData(
 			fmt.Sprintf("%d. %s", surah1.Number, name1),
-			fmt.Sprintf("surah:%d", surah1.Number),
+			fmt.Sprintf("%s:%d", kind, surah1.Number),
 		)
 
 		if i+1 < end {
@@ -507,7 +1173,7 @@ func (b *Bot) getSurahKeyboard(lang domain.Language, page int) tgbotapi.InlineKe
 			name2 := b.i18n.GetSurahName(lang, surah2.Number)
 			btn2 := tgbotapi.NewInlineKeyboardButtonData(
 				fmt.Sprintf("%d. %s", surah2.Number, name2),
-				fmt.Sprintf("surah:%d", surah2.Number),
+				fmt.Sprintf("%s:%d", kind, surah2.Number),
 			)
 			rows = append(rows, tgbotapi.NewInlineKeyboardRow(btn1, btn2))
 		} else {
@@ -519,14 +1185,14 @@ func (b *Bot) getSurahKeyboard(lang domain.Language, page int) tgbotapi.InlineKe
 	if totalPages > 1 {
 		var navRow []tgbotapi.InlineKeyboardButton
 		if page > 0 {
-			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ "+b.i18n.Get(lang, "nav.prev"), fmt.Sprintf("spage:%d", page-1)))
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ "+b.i18n.Get(lang, "nav.prev"), fmt.Sprintf("%s:%d", pageKind, page-1)))
 		}
 		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(
 			fmt.Sprintf("%d/%d", page+1, totalPages),
 			"noop",
 		))
 		if page < totalPages-1 {
-			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "nav.next")+" ➡️", fmt.Sprintf("spage:%d", page+1)))
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "nav.next")+" ➡️", fmt.Sprintf("%s:%d", pageKind, page+1)))
 		}
 		rows = append(rows, navRow)
 	}
@@ -534,6 +1200,22 @@ func (b *Bot) getSurahKeyboard(lang domain.Language, page int) tgbotapi.InlineKe
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
+// appendAyahKey appends a key of the ayah keyboard to the input: digits, up
+// to 3 per ayah number, and one "-" between the first and last ayah of a
+// range
+func appendAyahKey(input, key string) string {
+	first, last, isRange := strings.Cut(input, "-")
+	switch {
+	case key == "-":
+		if isRange || first == "" {
+			return input
+		}
+	case isRange && len(last) >= 3, !isRange && len(first) >= 3:
+		return input
+	}
+	return input + key
+}
+
 func (b *Bot) getAyahKeyboard(lang domain.Language, currentInput string) tgbotapi.InlineKeyboardMarkup {
 	// Telephone-style number keyboard (3x3 + bottom row)
 	return tgbotapi.NewInlineKeyboardMarkup(
@@ -557,24 +1239,102 @@ func (b *Bot) getAyahKeyboard(lang domain.Language, currentInput string) tgbotap
 			tgbotapi.NewInlineKeyboardButtonData("0", "digit:0"),
 			tgbotapi.NewInlineKeyboardButtonData("✅ "+b.i18n.Get(lang, "nav.done"), "done"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➖ "+b.i18n.Get(lang, "ayah.range"), "digit:-"),
+		),
 	)
 }
 
 func (b *Bot) editMessageWithKeyboard(msg *tgbotapi.Message, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
 	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text)
 	edit.ReplyMarkup = &keyboard
-	if _, err := b.api.Send(edit); err != nil {
+	if _, err := b.send(edit); err != nil {
+		log.Printf("Error editing message: %v", err)
+	}
+}
+
+func (b *Bot) editMessageText(msg *tgbotapi.Message, text string) {
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text)
+	if _, err := b.send(edit); err != nil {
 		log.Printf("Error editing message: %v", err)
 	}
 }
 
+func (b *Bot) removeKeyboard(chatID int64, messageID int) {
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	// The message may be gone or already without a keyboard
+	b.request(edit)
+}
+
 func (b *Bot) answerCallbackAlert(callbackID, text string) {
 	callback := tgbotapi.NewCallbackWithAlert(callbackID, text)
-	if _, err := b.api.Request(callback); err != nil {
+	if _, err := b.request(callback); err != nil {
 		log.Printf("Error answering callback: %v", err)
 	}
 }
 
+// firstDelivery reports whether an update is delivered for the first time.
+// If the update log is unavailable the update is handled anyway rather than
+// dropped.
+func (b *Bot) firstDelivery(ctx context.Context, updateID int) bool {
+	if b.updates == nil {
+		return true
+	}
+	first, err := b.updates.MarkHandled(ctx, updateID, updateDedupTTL)
+	if err != nil {
+		requestid.Printf(ctx, "Error recording update %d: %v", updateID, err)
+		return true
+	}
+	return first
+}
+
+// acquireLock takes a deduplication lock. If the lock store is unavailable
+// the work is done anyway rather than dropped.
+func (b *Bot) acquireLock(ctx context.Context, key string, ttl time.Duration) bool {
+	ok, err := b.locks.Acquire(ctx, key, ttl)
+	if err != nil {
+		requestid.Printf(ctx, "Error acquiring lock %s: %v", key, err)
+		return true
+	}
+	return ok
+}
+
+// releaseLock releases a deduplication lock so the same input can be retried
+func (b *Bot) releaseLock(ctx context.Context, key string) {
+	if err := b.locks.Release(ctx, key); err != nil {
+		requestid.Printf(ctx, "Error releasing lock %s: %v", key, err)
+	}
+}
+
+// sessionScope returns the session a user works in: their private session,
+// or a separate one in every group chat
+func sessionScope(from *tgbotapi.User, chat *tgbotapi.Chat) domain.SessionScope {
+	scope := domain.PrivateScope(strconv.FormatInt(from.ID, 10))
+	if chat != nil && !chat.IsPrivate() {
+		scope.ChatID = chat.ID
+	}
+	return scope
+}
+
+// displayName returns the first name the sender of an update goes by on
+// Telegram. Last names and usernames are left out, since display names show
+// on leaderboards.
+func displayName(update tgbotapi.Update) string {
+	var from *tgbotapi.User
+	switch {
+	case update.Message != nil:
+		from = update.Message.From
+	case update.CallbackQuery != nil:
+		from = update.CallbackQuery.From
+	}
+	if from == nil {
+		return ""
+	}
+	return strings.TrimSpace(from.FirstName)
+}
+
 func (b *Bot) getUserID(update tgbotapi.Update) string {
 	if update.Message != nil && update.Message.From != nil {
 		return strconv.FormatInt(update.Message.From.ID, 10)
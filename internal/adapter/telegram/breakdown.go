@@ -0,0 +1,195 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// breakdownOpsLimit is the number of words of the shown ayah listed in a
+// range breakdown. With a line per ayah of the range in the table, ten at
+// most, it keeps the message well under Telegram's 4096 characters.
+const breakdownOpsLimit = 10
+
+// rangeBreakdown is a page of the per-ayah breakdown of a range recorded in
+// manual mode. It is encoded whole in the callback data of its buttons as
+// "rng:<surah>:<first>:<last>:<since>:<index>", so pages need no storage.
+type rangeBreakdown struct {
+	ayahs domain.AyahRange
+	since time.Time // when the first ayah was recorded
+	index int       // of the ayah shown in the range
+}
+
+func (r rangeBreakdown) data(index int) string {
+	return fmt.Sprintf("rng:%d:%d:%d:%d:%d", r.ayahs.Start.SurahNumber, r.ayahs.Start.AyahNumber,
+		r.ayahs.End.AyahNumber, r.since.Unix(), index)
+}
+
+// parseRangeBreakdown decodes the callback data after "rng:"
+func parseRangeBreakdown(data string) (rangeBreakdown, error) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 5 {
+		return rangeBreakdown{}, fmt.Errorf("malformed range breakdown %q", data)
+	}
+	var numbers [5]int64
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return rangeBreakdown{}, fmt.Errorf("malformed range breakdown %q: %w", data, err)
+		}
+		numbers[i] = n
+	}
+	surah := int(numbers[0])
+	return rangeBreakdown{
+		ayahs: domain.AyahRange{
+			Start: domain.Ayah{SurahNumber: surah, AyahNumber: int(numbers[1])},
+			End:   domain.Ayah{SurahNumber: surah, AyahNumber: int(numbers[2])},
+		},
+		since: time.Unix(numbers[3], 0),
+		index: int(numbers[4]),
+	}, nil
+}
+
+// sendRangeBreakdown sends the breakdown of a range just recorded, showing
+// its first ayah
+func (b *Bot) sendRangeBreakdown(ctx context.Context, chatID int64, userID string, lang domain.Language, ayahs domain.AyahRange, since time.Time) {
+	text, keyboard, err := b.formatRangeBreakdown(ctx, userID, lang, rangeBreakdown{ayahs: ayahs, since: since})
+	if err != nil {
+		requestid.Printf(ctx, "Error getting range recordings: %v", err)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	msg.ParseMode = "HTML"
+	if _, err := b.send(msg); err != nil {
+		requestid.Printf(ctx, "Error sending range breakdown: %v", err)
+	}
+}
+
+// handleRangeBreakdown shows another ayah of a range breakdown, or refreshes
+// it
+func (b *Bot) handleRangeBreakdown(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, data string) {
+	breakdown, err := parseRangeBreakdown(data)
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing callback: %v", err)
+		return
+	}
+	text, keyboard, err := b.formatRangeBreakdown(ctx, userID, lang, breakdown)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting range recordings: %v", err)
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text)
+	edit.ReplyMarkup = &keyboard
+	edit.ParseMode = "HTML"
+	b.send(edit)
+}
+
+// formatRangeBreakdown formats a compact table of the results of every ayah
+// of a range, then the details of the ayah shown, with buttons to move
+// between ayahs
+func (b *Bot) formatRangeBreakdown(ctx context.Context, userID string, lang domain.Language, breakdown rangeBreakdown) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	recordings, err := b.service.RangeRecordings(ctx, userID, breakdown.ayahs, breakdown.since)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	if len(recordings) == 0 {
+		return "", tgbotapi.InlineKeyboardMarkup{}, fmt.Errorf("empty range %s", breakdown.ayahs)
+	}
+	index := min(max(breakdown.index, 0), len(recordings)-1)
+
+	var text strings.Builder
+	surah := breakdown.ayahs.Start.SurahNumber
+	text.WriteString(fmt.Sprintf("<b>%s</b>\n\n", b.i18n.Get(lang, "range.title",
+		b.i18n.GetSurahName(lang, surah), breakdown.ayahs.Start.AyahNumber, breakdown.ayahs.End.AyahNumber)))
+
+	for i, summary := range recordings {
+		marker := "▫️"
+		if i == index {
+			marker = "▶️"
+		}
+		ayahNumber := breakdown.ayahs.Start.AyahNumber + i
+		switch {
+		case summary == nil:
+			text.WriteString(fmt.Sprintf("%s <code>%3d</code> ⏳\n", marker, ayahNumber))
+		case summary.WER != nil:
+			accuracy := int(math.Round(domain.Accuracy(*summary.WER) * 100))
+			text.WriteString(fmt.Sprintf("%s <code>%3d</code> %s %d%%\n", marker, ayahNumber, b.getStatusEmoji(summary.Status), accuracy))
+		default:
+			text.WriteString(fmt.Sprintf("%s <code>%3d</code> %s\n", marker, ayahNumber, b.getStatusEmoji(summary.Status)))
+		}
+	}
+	text.WriteString("\n")
+
+	shown := recordings[index]
+	if shown == nil {
+		text.WriteString(b.i18n.Get(lang, "range.pending"))
+	} else {
+		text.WriteString(b.formatBreakdownAyah(ctx, userID, lang, shown.ID))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if index > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀", breakdown.data(index-1)))
+	}
+	nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(
+		b.i18n.Get(lang, "range.position", index+1, len(recordings)),
+		"noop",
+	))
+	if index < len(recordings)-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶", breakdown.data(index+1)))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{nav}
+	if shown != nil {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.view_results"), "viewrec:"+shown.ID),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.refresh"), breakdown.data(index)),
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.new"), "newrecord"),
+	))
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// formatBreakdownAyah formats the result of the recording of the ayah shown
+// in a range breakdown: its grade, mistakes and first words
+func (b *Bot) formatBreakdownAyah(ctx context.Context, userID string, lang domain.Language, recordingID string) string {
+	recording, err := b.service.GetRecording(ctx, userID, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting recording: %v", err)
+		return b.errorMessage(ctx, lang, err, "error.recording_not_found")
+	}
+	if recording.Result == nil {
+		return b.i18n.Get(lang, "recording.processing")
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>%s</b>\n", b.service.FormatGrade(lang, recording.Result.WER)))
+	if mistakes := b.service.FormatMistakes(lang, recording.Result); mistakes != "" {
+		text.WriteString(mistakes + "\n")
+	}
+	for i, op := range recording.Result.Ops {
+		if i >= breakdownOpsLimit {
+			text.WriteString(fmt.Sprintf("... (%d %s)\n", len(recording.Result.Ops)-breakdownOpsLimit, b.i18n.Get(lang, "recording.more_words")))
+			break
+		}
+		if op.Op == domain.OpBasmala {
+			text.WriteString(fmt.Sprintf("%s <code>%s</code> (%s)\n", b.getOpEmoji(op.Op), op.HypAr, b.i18n.Get(lang, "recording.basmala")))
+			continue
+		}
+		text.WriteString(fmt.Sprintf("%s <code>%s</code>\n", b.getOpEmoji(op.Op), op.RefAr))
+	}
+	return text.String()
+}
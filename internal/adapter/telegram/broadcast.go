@@ -0,0 +1,75 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/application"
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// QueueStats returns the state of the recording job queue
+func (b *Bot) QueueStats() application.QueueStats {
+	return b.transcoder.stats()
+}
+
+// Broadcast sends text to every user, one message at a time, and returns how
+// many messages were sent and how many failed, e.g. because the user blocked
+// the bot. Messages go out at the pace of domain.RateActionBroadcast, shared
+// by every replica, set below the overall send limit to leave room for
+// interactive replies. It stops early once ctx is done.
+func (b *Bot) Broadcast(ctx context.Context, userIDs []string, text string) (sent, failed int) {
+	for _, userID := range userIDs {
+		if err := waitRate(ctx, b.limiter, domain.RateActionBroadcast, "all"); err != nil {
+			return sent, failed
+		}
+
+		chatID, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			failed++
+			continue
+		}
+		if _, err := b.sender.send(ctx, tgbotapi.NewMessage(chatID, text)); err != nil {
+			requestid.Printf(ctx, "Error broadcasting to user %s: %v", userID, err)
+			failed++
+			continue
+		}
+		sent++
+	}
+	return sent, failed
+}
+
+// Notify sends a plain text message to a chat, e.g. an operator alert
+func (b *Bot) Notify(chatID int64, text string) error {
+	if _, err := b.send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+	return nil
+}
+
+// waitRate blocks until limiter allows one more action for key and takes
+// it. Limiter failures let the action through; a nil limiter never waits.
+func waitRate(ctx context.Context, limiter domain.RateLimiterPort, action domain.RateAction, key string) error {
+	if limiter == nil {
+		return ctx.Err()
+	}
+	for {
+		allowed, retryAfter, err := limiter.Allow(ctx, action, key)
+		if err != nil {
+			requestid.Printf(ctx, "Error checking %s rate: %v", action, err)
+			return ctx.Err()
+		}
+		if allowed {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/escalopa/quran-read-bot/internal/application"
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// challengeStartPrefix starts the /start payload of challenge links, e.g.
+// "ch_0123456789abcdef"
+const challengeStartPrefix = "ch_"
+
+// handleCreateChallenge creates a challenge on the ayah of a recording and
+// sends the link to share with friends
+func (b *Bot) handleCreateChallenge(ctx context.Context, chatID int64, userID string, lang domain.Language, recordingID string) {
+	challenge, err := b.service.CreateChallenge(ctx, userID, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error creating challenge: %v", err)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	ayah := b.ayahOf(ctx, challenge.AyahID)
+	surahName := b.i18n.GetSurahName(lang, ayah.SurahNumber)
+	link := fmt.Sprintf("https://t.me/%s?start=%s%s", b.api.Self.UserName, challengeStartPrefix, challenge.ID)
+	invitation := b.i18n.Get(lang, "challenge.invitation", surahName, ayah.AyahNumber)
+	share := "https://t.me/share/url?url=" + url.QueryEscape(link) + "&text=" + url.QueryEscape(invitation)
+
+	msg := tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "challenge.created", surahName, ayah.AyahNumber, link))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(b.i18n.Get(lang, "challenge.share"), share),
+		),
+	)
+	if _, err := b.send(msg); err != nil {
+		requestid.Printf(ctx, "Error sending challenge: %v", err)
+	}
+}
+
+// acceptChallenge selects the ayah of the challenge a friend opened the link
+// of and asks for its recording
+func (b *Bot) acceptChallenge(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language, id string) {
+	challenge, err := b.service.AcceptChallenge(ctx, scope, id)
+	if err != nil {
+		requestid.Printf(ctx, "Error accepting challenge %s: %v", id, err)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		b.sendSurahSelection(ctx, chatID, scope, lang, 0)
+		return
+	}
+
+	ayah := b.ayahOf(ctx, challenge.AyahID)
+	text := b.i18n.Get(lang, "challenge.accepted", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+	b.sendMessage(chatID, text+"\n\n"+b.recordingPrompt(ctx, scope, lang))
+}
+
+// notifyChallengeOutcome tells a friend who took a challenge, and the
+// challenger, how their attempts compare once the friend's one is analyzed
+func (b *Bot) notifyChallengeOutcome(ctx context.Context, event domain.RecordingEvent) {
+	outcome, err := b.service.ChallengeOutcome(ctx, event.UserID, event.RecordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting challenge outcome: %v", err)
+		return
+	}
+	if outcome == nil {
+		return
+	}
+
+	friendChat, err := b.chatID(domain.SessionScope{UserID: event.UserID, ChatID: event.ChatID})
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing user ID %s: %v", event.UserID, err)
+		return
+	}
+	lang := b.service.GetUserLanguage(ctx, event.UserID)
+	text := b.formatChallengeOutcome(ctx, lang, outcome, outcome.FriendAccuracy, outcome.ChallengerAccuracy, outcome.ChallengerName)
+	b.sendMessage(friendChat, text)
+
+	challengerChat, err := b.chatID(domain.PrivateScope(outcome.Challenge.UserID))
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing user ID %s: %v", outcome.Challenge.UserID, err)
+		return
+	}
+	lang = b.service.GetUserLanguage(ctx, outcome.Challenge.UserID)
+	text = b.formatChallengeOutcome(ctx, lang, outcome, outcome.ChallengerAccuracy, outcome.FriendAccuracy, outcome.FriendName)
+	b.sendMessage(challengerChat, text)
+}
+
+// formatChallengeOutcome formats the outcome of a challenge as seen by one
+// side, whose accuracy is ours, against the other named other
+func (b *Bot) formatChallengeOutcome(ctx context.Context, lang domain.Language, outcome *application.ChallengeOutcome, ours, theirs float64, other string) string {
+	if other == "" {
+		other = b.i18n.Get(lang, "challenge.friend")
+	}
+	ourPercent := int(math.Round(ours * 100))
+	theirPercent := int(math.Round(theirs * 100))
+
+	verdict := "challenge.tie"
+	switch {
+	case ourPercent > theirPercent:
+		verdict = "challenge.won"
+	case ourPercent < theirPercent:
+		verdict = "challenge.lost"
+	}
+
+	ayah := b.ayahOf(ctx, outcome.Challenge.AyahID)
+	return b.i18n.Get(lang, "challenge.outcome", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber,
+		ourPercent, other, theirPercent) + "\n\n" + b.i18n.Get(lang, verdict)
+}
+
+// challengeID returns the challenge ID of a /start payload, or false if it
+// is not a challenge link
+func challengeID(payload string) (string, bool) {
+	if len(payload) <= len(challengeStartPrefix) || payload[:len(challengeStartPrefix)] != challengeStartPrefix {
+		return "", false
+	}
+	id := payload[len(challengeStartPrefix):]
+	if _, err := strconv.ParseUint(id, 16, 64); err != nil {
+		return "", false
+	}
+	return id, true
+}
@@ -2,9 +2,13 @@ package telegram
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"strconv"
 
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -14,43 +18,72 @@ type CommandHandler func(ctx context.Context, msg *tgbotapi.Message)
 func (b *Bot) registerCommands() {
 	// Register command handlers
 	b.commands = map[string]CommandHandler{
-		"start":     b.commandStart,
-		"help":      b.commandHelp,
-		"language":  b.commandLanguage,
-		"myrecords": b.commandMyRecords,
-		"newrecord": b.commandNewRecord,
+		"start":        b.commandStart,
+		"help":         b.commandHelp,
+		"language":     b.commandLanguage,
+		"settings":     b.commandSettings,
+		"myrecords":    b.commandMyRecords,
+		"last":         b.commandLast,
+		"weak":         b.commandWeak,
+		"progress":     b.commandProgress,
+		"newrecord":    b.commandNewRecord,
+		"continue":     b.commandContinue,
+		"type":         b.commandType,
+		"leaderboard":  b.commandLeaderboard,
+		"goal":         b.commandGoal,
+		"wird":         b.commandWird,
+		"khatmah":      b.commandKhatmah,
+		"exportmydata": b.commandExportMyData,
+		"deletemydata": b.commandDeleteMyData,
+		"admin":        b.commandAdmin,
 	}
 
 	// Set bot commands for Telegram UI
 	commands := []tgbotapi.BotCommand{
 		{Command: "start", Description: "Start the bot"},
 		{Command: "newrecord", Description: "Create a new recording"},
+		{Command: "continue", Description: "Continue after the last ayah recorded"},
+		{Command: "type", Description: "Type an ayah from memory"},
 		{Command: "myrecords", Description: "View my recordings"},
+		{Command: "last", Description: "Show my latest result"},
+		{Command: "weak", Description: "List my weakest ayahs to practice"},
+		{Command: "progress", Description: "See how much of each surah I recited"},
+		{Command: "leaderboard", Description: "Show the leaderboard"},
+		{Command: "goal", Description: "Set a memorization goal"},
+		{Command: "wird", Description: "Get an ayah to recite every day"},
+		{Command: "khatmah", Description: "Read the whole Quran in 30 or 60 days"},
 		{Command: "language", Description: "Change language"},
+		{Command: "settings", Description: "Change settings"},
+		{Command: "exportmydata", Description: "Export all my data"},
+		{Command: "deletemydata", Description: "Delete all my data"},
 		{Command: "help", Description: "Show help"},
 	}
 
 	cmdConfig := tgbotapi.NewSetMyCommands(commands...)
-	if _, err := b.api.Request(cmdConfig); err != nil {
+	if _, err := b.request(cmdConfig); err != nil {
 		log.Printf("Error setting bot commands: %v", err)
 	}
 }
 
 func (b *Bot) commandStart(ctx context.Context, msg *tgbotapi.Message) {
-	userID := strconv.FormatInt(msg.From.ID, 10)
-	lang := b.service.GetUserLanguage(ctx, userID)
+	scope := sessionScope(msg.From, msg.Chat)
+	lang := b.service.GetUserLanguage(ctx, scope.UserID)
 
-	if err := b.service.HandleStart(ctx, userID, lang); err != nil {
-		log.Printf("Error handling start: %v", err)
-		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "error.generic"))
+	if err := b.service.HandleStart(ctx, scope, lang); err != nil {
+		requestid.Printf(ctx, "Error handling start: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
 		return
 	}
 
 	// Send welcome message
 	b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "welcome.message"))
 
-	// Show surah selection
-	b.sendSurahSelection(ctx, msg.Chat.ID, userID, lang, 0)
+	// Take the challenge of a friend's link, or show surah selection
+	if id, ok := challengeID(msg.CommandArguments()); ok {
+		b.acceptChallenge(ctx, msg.Chat.ID, scope, lang, id)
+		return
+	}
+	b.sendSurahSelection(ctx, msg.Chat.ID, scope, lang, 0)
 }
 
 func (b *Bot) commandHelp(ctx context.Context, msg *tgbotapi.Message) {
@@ -66,16 +99,40 @@ func (b *Bot) commandLanguage(ctx context.Context, msg *tgbotapi.Message) {
 }
 
 func (b *Bot) commandNewRecord(ctx context.Context, msg *tgbotapi.Message) {
-	userID := strconv.FormatInt(msg.From.ID, 10)
-	lang := b.service.GetUserLanguage(ctx, userID)
+	scope := sessionScope(msg.From, msg.Chat)
+	lang := b.service.GetUserLanguage(ctx, scope.UserID)
+
+	if err := b.service.HandleStart(ctx, scope, lang); err != nil {
+		requestid.Printf(ctx, "Error handling start: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	b.sendSurahSelection(ctx, msg.Chat.ID, scope, lang, 0)
+}
 
-	if err := b.service.HandleStart(ctx, userID, lang); err != nil {
-		log.Printf("Error handling start: %v", err)
-		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "error.generic"))
+func (b *Bot) commandContinue(ctx context.Context, msg *tgbotapi.Message) {
+	scope := sessionScope(msg.From, msg.Chat)
+	lang := b.service.GetUserLanguage(ctx, scope.UserID)
+	b.continueRecording(ctx, msg.Chat.ID, scope, lang)
+}
+
+// continueRecording selects the ayah after the last one the user recorded
+// and asks for its recording
+func (b *Bot) continueRecording(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language) {
+	ayah, err := b.service.ContinueRecording(ctx, scope)
+	if err != nil {
+		requestid.Printf(ctx, "Error continuing recording: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
 		return
 	}
 
-	b.sendSurahSelection(ctx, msg.Chat.ID, userID, lang, 0)
+	text := b.i18n.Get(lang, "continue.ayah", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+	b.sendMessage(chatID, text+"\n\n"+b.recordingPrompt(ctx, scope, lang))
 }
 
 func (b *Bot) commandMyRecords(ctx context.Context, msg *tgbotapi.Message) {
@@ -85,8 +142,8 @@ func (b *Bot) commandMyRecords(ctx context.Context, msg *tgbotapi.Message) {
 	// Fetch recordings
 	recordings, err := b.service.ListRecordings(ctx, userID, 10)
 	if err != nil {
-		log.Printf("Error listing recordings: %v", err)
-		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "error.generic"))
+		requestid.Printf(ctx, "Error listing recordings: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
 		return
 	}
 
@@ -97,3 +154,86 @@ func (b *Bot) commandMyRecords(ctx context.Context, msg *tgbotapi.Message) {
 
 	b.sendRecordingsList(msg.Chat.ID, userID, lang, recordings, 0)
 }
+
+// commandLast shows the details of the user's most recent recording
+func (b *Bot) commandLast(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	recordings, err := b.service.ListRecordings(ctx, userID, 1)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing recordings: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	if len(recordings) == 0 {
+		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "recordings.empty"))
+		return
+	}
+
+	recording, err := b.service.GetRecording(ctx, userID, recordings[0].ID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting recording: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.recording_not_found"))
+		return
+	}
+	b.sendRecordingDetails(ctx, msg.Chat.ID, userID, lang, recording)
+}
+
+func (b *Bot) commandLeaderboard(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	text, keyboard, err := b.formatLeaderboard(ctx, userID, lang, domain.LeaderboardWeekly)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting leaderboard: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	reply.ReplyMarkup = keyboard
+	reply.ParseMode = "HTML"
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
+
+func (b *Bot) commandExportMyData(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	data, err := b.service.ExportUserData(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error exporting user data: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(msg.Chat.ID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("quran-bot-data-%s.json", userID),
+		Bytes: data,
+	})
+	doc.Caption = b.i18n.Get(lang, "export.caption")
+	if _, err := b.send(doc); err != nil {
+		requestid.Printf(ctx, "Error sending data export: %v", err)
+	}
+}
+
+func (b *Bot) commandDeleteMyData(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 "+b.i18n.Get(lang, "delete.confirm"), "delmydata:confirm"),
+			tgbotapi.NewInlineKeyboardButtonData("❌ "+b.i18n.Get(lang, "delete.cancel"), "delmydata:cancel"),
+		),
+	)
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, b.i18n.Get(lang, "delete.prompt"))
+	reply.ReplyMarkup = keyboard
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
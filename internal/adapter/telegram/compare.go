@@ -0,0 +1,120 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// practiceButtonRow returns the row with the buttons comparing a recording
+// with another attempt and challenging friends on its ayah, or nil if it has
+// no result yet
+func (b *Bot) practiceButtonRow(lang domain.Language, recording *domain.Recording) []tgbotapi.InlineKeyboardButton {
+	if recording.Result == nil {
+		return nil
+	}
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "compare.button"), "cmp:"+recording.ID),
+	)
+	if b.service.ChallengesEnabled() {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "challenge.button"), "chal:"+recording.ID))
+	}
+	return row
+}
+
+// handleCompareCallback handles "cmp:<id>", listing the attempts to compare
+// a recording with, and "cmp:<id>:<created>", comparing it with the attempt
+// made at that unix time. Attempts are picked by time as two recording IDs
+// may not fit in the 64 bytes of callback data.
+func (b *Bot) handleCompareCallback(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, data string) {
+	recordingID, created := data, ""
+	if i := strings.LastIndex(data, ":"); i >= 0 {
+		recordingID, created = data[:i], data[i+1:]
+	}
+
+	recording, err := b.service.GetRecording(ctx, userID, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting recording: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.recording_not_found"))
+		return
+	}
+	attempts, err := b.service.Attempts(ctx, userID, recording)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing attempts: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	if created == "" {
+		b.showAttempts(msg, lang, recording, attempts)
+		return
+	}
+
+	unix, _ := strconv.ParseInt(created, 10, 64)
+	for _, attempt := range attempts {
+		if attempt.CreatedAt.Unix() == unix {
+			b.showComparison(ctx, msg, userID, lang, recording.ID, attempt.ID)
+			return
+		}
+	}
+	b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "error.recording_not_found"))
+}
+
+// showAttempts shows the other attempts at the ayah of a recording to pick
+// one to compare it with
+func (b *Bot) showAttempts(msg *tgbotapi.Message, lang domain.Language, recording *domain.Recording, attempts []domain.RecordingSummary) {
+	back := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ "+b.i18n.Get(lang, "nav.back"), "viewrec:"+recording.ID),
+	)
+	if len(attempts) == 0 {
+		b.editMessageWithKeyboard(msg, b.i18n.Get(lang, "compare.none"), tgbotapi.NewInlineKeyboardMarkup(back))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, attempt := range attempts {
+		accuracy := int(math.Round(domain.Accuracy(*attempt.WER) * 100))
+		label := fmt.Sprintf("%s %d%% - %s", b.getStatusEmoji(attempt.Status), accuracy, attempt.CreatedAt.Format("2006-01-02 15:04"))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("cmp:%s:%d", recording.ID, attempt.CreatedAt.Unix())),
+		))
+	}
+	rows = append(rows, back)
+
+	b.editMessageWithKeyboard(msg, b.i18n.Get(lang, "compare.select"), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// showComparison shows how two attempts at an ayah compare
+func (b *Bot) showComparison(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, recordingID, otherID string) {
+	comparison, err := b.service.CompareRecordings(ctx, userID, recordingID, otherID)
+	if err != nil {
+		requestid.Printf(ctx, "Error comparing recordings: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	ayah := b.ayahOf(ctx, comparison.After.AyahID)
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>%s</b>\n", b.i18n.Get(lang, "compare.title",
+		b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)))
+	text.WriteString(fmt.Sprintf("<i>%s → %s</i>\n\n",
+		b.service.UserTime(ctx, userID, comparison.Before.CreatedAt).Format("2006-01-02 15:04"),
+		b.service.UserTime(ctx, userID, comparison.After.CreatedAt).Format("2006-01-02 15:04")))
+	text.WriteString(b.service.FormatComparison(lang, comparison))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ "+b.i18n.Get(lang, "nav.back"), "cmp:"+recordingID),
+		),
+	)
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text.String())
+	edit.ReplyMarkup = &keyboard
+	edit.ParseMode = "HTML"
+	b.send(edit)
+}
@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// drillButtonRow returns the button to drill the words a recording got
+// wrong, nil if word drills are disabled or no word was substituted
+func (b *Bot) drillButtonRow(lang domain.Language, recording *domain.Recording) []tgbotapi.InlineKeyboardButton {
+	if !b.service.WordDrillsEnabled() {
+		return nil
+	}
+	drill, ok := domain.NewWordDrill(*recording)
+	if !ok {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "drill.button", len(drill.Words)), "drill:start:"+recording.ID),
+	)
+}
+
+// handleDrillCallback handles the word drill buttons: "start:<id>", "skip"
+// and "stop"
+func (b *Bot) handleDrillCallback(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language, data string) {
+	chatID := msg.Chat.ID
+	switch {
+	case strings.HasPrefix(data, "start:"):
+		drill, err := b.service.StartWordDrill(ctx, scope, strings.TrimPrefix(data, "start:"))
+		if err != nil {
+			requestid.Printf(ctx, "Error starting word drill: %v", err)
+			b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+			return
+		}
+		b.sendMessage(chatID, b.i18n.Get(lang, "drill.intro", b.ayahLabel(lang, drill.AyahID), len(drill.Words)))
+		b.sendDrillWord(ctx, chatID, lang, drill)
+	case data == "skip":
+		b.removeKeyboard(chatID, msg.MessageID)
+		drill, err := b.service.SkipDrillWord(ctx, scope)
+		if err != nil {
+			requestid.Printf(ctx, "Error skipping drill word: %v", err)
+			b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+			return
+		}
+		b.sendDrillWord(ctx, chatID, lang, drill)
+	case data == "stop":
+		if err := b.service.StopWordDrill(ctx, scope); err != nil {
+			requestid.Printf(ctx, "Error stopping word drill: %v", err)
+			return
+		}
+		b.editMessageText(msg, b.i18n.Get(lang, "drill.stopped"))
+	}
+}
+
+// sendDrillWord asks for a recording of the word to record next, or sends
+// how the drill went once it is done
+func (b *Bot) sendDrillWord(ctx context.Context, chatID int64, lang domain.Language, drill domain.WordDrill) {
+	if drill.Done() {
+		b.sendMessage(chatID, b.i18n.Get(lang, "drill.done", drill.Passed, len(drill.Words)))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if row := b.referenceButtonRow(lang, drill.AyahID); row != nil {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "drill.skip"), "drill:skip"),
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "drill.stop"), "drill:stop"),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "drill.word", drill.Current+1, len(drill.Words), drill.Word()))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.send(msg); err != nil {
+		requestid.Printf(ctx, "Error sending drill word: %v", err)
+	}
+}
+
+// handleDrillVoice takes a voice message sent during a word drill for a
+// recording of the word drilled, and checks it. It reports false if the
+// user is not drilling words.
+func (b *Bot) handleDrillVoice(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, audio *audioInput, fileLock string, lang domain.Language) bool {
+	drill, err := b.service.WordDrill(ctx, scope)
+	if err != nil || drill == nil {
+		return false
+	}
+	chatID := msg.Chat.ID
+
+	if err := b.limits.check(audio); err != nil {
+		b.releaseLock(ctx, fileLock)
+		if errors.Is(err, errAudioTooLong) {
+			b.sendMessage(chatID, b.i18n.Get(lang, "error.audio_too_long", int(b.limits.maxDuration.Seconds())))
+		} else {
+			b.sendMessage(chatID, b.i18n.Get(lang, "error.audio_too_large", b.limits.maxFileSize/(1<<20)))
+		}
+		return true
+	}
+	if allowed, retryAfter := b.service.AllowSubmission(ctx, scope.UserID); !allowed {
+		b.releaseLock(ctx, fileLock)
+		b.sendMessage(chatID, b.i18n.Get(lang, "error.rate_limited", int(math.Ceil(retryAfter.Minutes()))))
+		return true
+	}
+
+	listening, err := b.send(tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "drill.listening")))
+	if err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+
+	// Convert and transcribe on a transcoding worker, as recordings are
+	reqID := requestid.From(ctx)
+	_, err = b.transcoder.submit(func(ctx context.Context) {
+		ctx = requestid.With(ctx, reqID)
+		defer b.recoverPanic(ctx, "word drill", chatID, lang)
+		b.checkDrillWord(ctx, chatID, &listening, scope, audio, fileLock, lang)
+	})
+	if err != nil {
+		b.releaseLock(ctx, fileLock)
+		if errors.Is(err, errTranscodeQueueFull) {
+			b.editMessageText(&listening, b.i18n.Get(lang, "error.queue_full"))
+		}
+	}
+	return true
+}
+
+// checkDrillWord transcribes a recording of the word drilled, turns the
+// listening message into whether the word was heard, and asks for the
+// word to record next
+func (b *Bot) checkDrillWord(ctx context.Context, chatID int64, listening *tgbotapi.Message, scope domain.SessionScope, audio *audioInput, fileLock string, lang domain.Language) {
+	audioReader, meta, err := b.prepareAudio(ctx, scope.UserID, audio)
+	if err != nil {
+		requestid.Printf(ctx, "Error processing voice message: %v", err)
+		b.releaseLock(ctx, fileLock)
+		b.editMessageText(listening, b.errorMessage(ctx, lang, err, "error.audio_conversion"))
+		return
+	}
+
+	check, err := b.service.CheckDrillWord(ctx, scope, audioReader, meta)
+	if err != nil {
+		requestid.Printf(ctx, "Error checking drill word: %v", err)
+		b.releaseLock(ctx, fileLock)
+		b.editMessageText(listening, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	switch {
+	case check.Passed:
+		b.editMessageText(listening, b.i18n.Get(lang, "drill.passed", check.Word))
+	case check.Heard == "":
+		b.editMessageText(listening, b.i18n.Get(lang, "drill.not_heard", check.Word))
+	default:
+		b.editMessageText(listening, b.i18n.Get(lang, "drill.failed", check.Word, check.Heard))
+	}
+	b.sendDrillWord(ctx, chatID, lang, check.Drill)
+}
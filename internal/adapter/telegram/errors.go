@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// errorKeys maps the errors users can act on to their message. They are
+// tried in order, so errors wrapping several of them, e.g. an expired
+// session that is also an invalid transition, get the most specific one.
+var errorKeys = []struct {
+	err error
+	key string
+}{
+	{domain.ErrSessionExpired, "error.session_expired"},
+	{domain.ErrInvalidTransition, "error.flow_reset"},
+	{domain.ErrInvalidAyah, "error.invalid_ayah"},
+	{domain.ErrAudioTooShort, "error.audio_too_short"},
+	{domain.ErrUnsupportedAudio, "error.unsupported_audio"},
+	{domain.ErrSilentAudio, "error.silent_audio"},
+	{domain.ErrNonSpeechAudio, "error.non_speech_audio"},
+	{domain.ErrQuotaExceeded, "error.quota_exceeded"},
+	{domain.ErrBackendUnavailable, "error.backend_unavailable"},
+	{domain.ErrTooManyGoals, "error.too_many_goals"},
+	{domain.ErrNoPosition, "error.no_position"},
+	{domain.ErrNotComparable, "error.not_comparable"},
+	{domain.ErrChallengeNotFound, "error.challenge_not_found"},
+	{domain.ErrOwnChallenge, "error.own_challenge"},
+	{domain.ErrNoKhatmah, "error.no_khatmah"},
+	{domain.ErrInvalidTimezone, "error.invalid_timezone"},
+	{domain.ErrTooManyPins, "error.too_many_pins"},
+	{domain.ErrInvalidTag, "error.invalid_tag"},
+	{domain.ErrTooManyTags, "error.too_many_tags"},
+	{domain.ErrNoAyahText, "error.no_ayah_text"},
+	{domain.ErrNothingToDrill, "error.nothing_to_drill"},
+	{domain.ErrNoWordDrill, "error.no_word_drill"},
+}
+
+// errorMessage returns the localized message telling the user what went
+// wrong, or the fallback key with the request ID for errors they cannot act
+// on
+func (b *Bot) errorMessage(ctx context.Context, lang domain.Language, err error, fallback string) string {
+	if errors.Is(err, domain.ErrAudioTooLarge) {
+		return b.i18n.Get(lang, "error.audio_too_large", b.maxFileSizeMB())
+	}
+	for _, e := range errorKeys {
+		if errors.Is(err, e.err) {
+			return b.i18n.Get(lang, e.key)
+		}
+	}
+	return b.errorText(ctx, lang, fallback)
+}
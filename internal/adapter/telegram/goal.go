@@ -0,0 +1,215 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// goalNudgeInterval is how often goals are checked for a due progress
+	// message
+	goalNudgeInterval = time.Hour
+
+	// goalNudgeLockTTL bounds how long a sent progress message is remembered
+	goalNudgeLockTTL = 24 * time.Hour
+)
+
+// goalDeadlines are the deadlines offered for new goals, in days
+var goalDeadlines = []int{7, 14, 30, 90}
+
+func (b *Bot) commandGoal(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	text, keyboard, err := b.formatGoals(ctx, userID, lang)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting goals: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	reply.ReplyMarkup = keyboard
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
+
+// formatGoals formats the user's goals with a keyboard to set a new one or
+// remove one
+func (b *Bot) formatGoals(ctx context.Context, userID string, lang domain.Language) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	goals, err := b.service.Goals(ctx, userID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var text strings.Builder
+	text.WriteString(b.i18n.Get(lang, "goal.title") + "\n\n")
+	if len(goals) == 0 {
+		text.WriteString(b.i18n.Get(lang, "goal.empty"))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, progress := range goals {
+		name := b.i18n.GetSurahName(lang, progress.Goal.SurahNumber)
+		text.WriteString(b.i18n.Get(lang, "goal.item", name, progress.Memorized, progress.Ayahs,
+			progress.Goal.Deadline.Format(time.DateOnly)) + "\n")
+		text.WriteString(b.goalStatus(lang, progress) + "\n\n")
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			b.i18n.Get(lang, "goal.remove", name),
+			fmt.Sprintf("goaldel:%d", progress.Goal.SurahNumber),
+		)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "goal.new"), "goalnew"),
+	))
+
+	return strings.TrimSpace(text.String()), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// goalStatus returns the line telling how a goal is going
+func (b *Bot) goalStatus(lang domain.Language, progress domain.GoalProgress) string {
+	switch {
+	case progress.Done():
+		return b.i18n.Get(lang, "goal.done")
+	case progress.Overdue():
+		return b.i18n.Get(lang, "goal.overdue")
+	case progress.OnTrack():
+		return b.i18n.Get(lang, "goal.on_track", progress.AyahsPerDay())
+	default:
+		return b.i18n.Get(lang, "goal.behind", progress.AyahsPerDay())
+	}
+}
+
+// handleGoalList shows the user's goals in place of the message
+func (b *Bot) handleGoalList(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language) {
+	text, keyboard, err := b.formatGoals(ctx, userID, lang)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting goals: %v", err)
+		return
+	}
+	b.editMessageWithKeyboard(msg, text, keyboard)
+}
+
+// handleGoalSurahPage shows a page of the surahs a goal can be set for
+func (b *Bot) handleGoalSurahPage(msg *tgbotapi.Message, lang domain.Language, page int) {
+	keyboard := b.getSurahKeyboard(lang, page, "goalsurah", "goalpage")
+	b.editMessageWithKeyboard(msg, b.i18n.Get(lang, "goal.select_surah"), keyboard)
+}
+
+// handleGoalSurah asks by when the surah picked for a goal should be
+// memorized
+func (b *Bot) handleGoalSurah(msg *tgbotapi.Message, lang domain.Language, surahNumber int) {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, days := range goalDeadlines {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			b.i18n.Get(lang, "goal.days", days),
+			fmt.Sprintf("goaldays:%d:%d", surahNumber, days),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ "+b.i18n.Get(lang, "nav.back"), "goalnew"),
+		),
+	)
+	text := b.i18n.Get(lang, "goal.select_deadline", b.i18n.GetSurahName(lang, surahNumber))
+	b.editMessageWithKeyboard(msg, text, keyboard)
+}
+
+// handleGoalDeadline sets the goal picked, e.g. "114:30" for An-Nas within 30
+// days
+func (b *Bot) handleGoalDeadline(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, data string) {
+	surah, days, _ := strings.Cut(data, ":")
+	surahNumber, _ := strconv.Atoi(surah)
+	numDays, _ := strconv.Atoi(days)
+
+	goal, err := b.service.SetGoal(ctx, userID, surahNumber, numDays)
+	if err != nil {
+		requestid.Printf(ctx, "Error setting goal: %v", err)
+		b.editMessageText(msg, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	b.editMessageText(msg, b.i18n.Get(lang, "goal.set",
+		b.i18n.GetSurahName(lang, goal.SurahNumber), goal.Deadline.Format(time.DateOnly)))
+}
+
+// handleGoalRemove removes a goal and refreshes the list
+func (b *Bot) handleGoalRemove(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, surahNumber int) {
+	if err := b.service.RemoveGoal(ctx, userID, surahNumber); err != nil {
+		requestid.Printf(ctx, "Error removing goal: %v", err)
+	}
+	b.handleGoalList(ctx, msg, userID, lang)
+}
+
+// nudgeGoals periodically sends users the progress of their goals, until
+// ctx is done
+func (b *Bot) nudgeGoals(ctx context.Context) {
+	ticker := time.NewTicker(goalNudgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		due, err := b.service.GoalNudges(ctx)
+		if err != nil {
+			requestid.Printf(ctx, "Error listing goal nudges: %v", err)
+			continue
+		}
+		for _, progress := range due {
+			b.sendGoalNudge(ctx, progress)
+		}
+	}
+}
+
+// sendGoalNudge sends the progress of a goal to the user's private chat,
+// once across replicas
+func (b *Bot) sendGoalNudge(ctx context.Context, progress domain.GoalProgress) {
+	goal := progress.Goal
+	lock := fmt.Sprintf("goal:%s:%d:%s:%d", goal.UserID, goal.SurahNumber, goal.Deadline.Format(time.DateOnly), progress.DaysLeft)
+	if !b.acquireLock(ctx, lock, goalNudgeLockTTL) {
+		return
+	}
+
+	chatID, err := b.chatID(domain.PrivateScope(goal.UserID))
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing user ID %s: %v", goal.UserID, err)
+		return
+	}
+
+	lang := b.service.GetUserLanguage(ctx, goal.UserID)
+	name := b.i18n.GetSurahName(lang, goal.SurahNumber)
+	var text string
+	switch {
+	case progress.Done():
+		text = b.i18n.Get(lang, "goal.nudge_done", name)
+	case progress.Overdue():
+		text = b.i18n.Get(lang, "goal.nudge_overdue", name, progress.Memorized, progress.Ayahs)
+	case progress.OnTrack():
+		text = b.i18n.Get(lang, "goal.nudge_on_track", name, progress.Memorized, progress.Ayahs, progress.AyahsPerDay(), progress.DaysLeft)
+	default:
+		text = b.i18n.Get(lang, "goal.nudge_behind", name, progress.Memorized, progress.Ayahs, progress.DaysLeft, progress.AyahsPerDay())
+	}
+
+	if _, err := b.send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		requestid.Printf(ctx, "Error sending goal progress to %s: %v", goal.UserID, err)
+		b.releaseLock(ctx, lock)
+		return
+	}
+	if err := b.service.MarkNudged(ctx, progress); err != nil {
+		requestid.Printf(ctx, "Error marking goal of %s nudged: %v", goal.UserID, err)
+	}
+}
@@ -0,0 +1,151 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// khatmahBarWidth is the number of cells of the khatmah progress bar
+const khatmahBarWidth = 15
+
+func (b *Bot) commandKhatmah(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	text, keyboard, err := b.formatKhatmah(ctx, userID, lang)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting khatmah: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	reply.ReplyMarkup = keyboard
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
+
+// formatKhatmah formats the progress of the user's khatmah, or the plans to
+// start one, with a keyboard to go on reading or stop it
+func (b *Bot) formatKhatmah(ctx context.Context, userID string, lang domain.Language) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	progress, err := b.service.KhatmahProgress(ctx, userID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var text strings.Builder
+	text.WriteString(b.i18n.Get(lang, "khatmah.title") + "\n\n")
+
+	if progress == nil {
+		text.WriteString(b.i18n.Get(lang, "khatmah.empty"))
+		var row []tgbotapi.InlineKeyboardButton
+		for _, days := range domain.KhatmahPlans {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+				b.i18n.Get(lang, "khatmah.plan", days),
+				fmt.Sprintf("khatmah:start:%d", days),
+			))
+		}
+		return text.String(), tgbotapi.NewInlineKeyboardMarkup(row), nil
+	}
+
+	khatmah := progress.Khatmah
+	text.WriteString(fmt.Sprintf("%s %d%%\n", progressBar(progress.Read, progress.Total, khatmahBarWidth),
+		progress.Read*100/progress.Total))
+	text.WriteString(b.i18n.Get(lang, "khatmah.portions", len(khatmah.Completed), khatmah.Days) + "\n\n")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if khatmah.Done() {
+		text.WriteString(b.i18n.Get(lang, "khatmah.done"))
+	} else {
+		text.WriteString(b.i18n.Get(lang, "khatmah.day", progress.Day, khatmah.Days, b.formatPortion(lang, progress.Today)) + "\n")
+		switch behind := progress.Behind(); {
+		case behind > 0:
+			text.WriteString(b.i18n.Get(lang, "khatmah.behind", behind))
+		case behind < 0:
+			text.WriteString(b.i18n.Get(lang, "khatmah.ahead", -behind))
+		default:
+			text.WriteString(b.i18n.Get(lang, "khatmah.on_track"))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "khatmah.continue",
+				b.i18n.GetSurahName(lang, khatmah.Next.SurahNumber), khatmah.Next.AyahNumber), "khatmah:continue"),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "khatmah.stop"), "khatmah:stop"),
+	))
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// formatPortion formats a portion of a khatmah, e.g. "Al-Baqarah 142 –
+// Al-Baqarah 252"
+func (b *Bot) formatPortion(lang domain.Language, portion domain.AyahRange) string {
+	return fmt.Sprintf("%s %d – %s %d",
+		b.i18n.GetSurahName(lang, portion.Start.SurahNumber), portion.Start.AyahNumber,
+		b.i18n.GetSurahName(lang, portion.End.SurahNumber), portion.End.AyahNumber)
+}
+
+// handleKhatmahCallback handles the khatmah buttons: "start:<days>",
+// "continue" and "stop"
+func (b *Bot) handleKhatmahCallback(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language, data string) {
+	switch {
+	case data == "continue":
+		b.continueKhatmah(ctx, msg.Chat.ID, scope, lang)
+		return
+	case data == "stop":
+		if err := b.service.StopKhatmah(ctx, scope.UserID); err != nil {
+			requestid.Printf(ctx, "Error stopping khatmah: %v", err)
+		}
+	case strings.HasPrefix(data, "start:"):
+		days, _ := strconv.Atoi(strings.TrimPrefix(data, "start:"))
+		if _, err := b.service.StartKhatmah(ctx, scope.UserID, days); err != nil {
+			requestid.Printf(ctx, "Error starting khatmah: %v", err)
+			b.editMessageText(msg, b.errorMessage(ctx, lang, err, "error.generic"))
+			return
+		}
+	default:
+		return
+	}
+
+	text, keyboard, err := b.formatKhatmah(ctx, scope.UserID, lang)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting khatmah: %v", err)
+		return
+	}
+	b.editMessageWithKeyboard(msg, text, keyboard)
+}
+
+// continueKhatmah selects the next ayah of the user's khatmah for recording
+func (b *Bot) continueKhatmah(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language) {
+	ayah, err := b.service.ContinueKhatmah(ctx, scope)
+	if err != nil {
+		requestid.Printf(ctx, "Error continuing khatmah: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	text := b.i18n.Get(lang, "continue.ayah", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+	b.sendMessage(chatID, text+"\n\n"+b.recordingPrompt(ctx, scope, lang))
+}
+
+// progressBar draws done out of total as a bar of width cells
+func progressBar(done, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = min(done*width/total, width)
+	}
+	return strings.Repeat("▓", filled) + strings.Repeat("░", width-filled)
+}
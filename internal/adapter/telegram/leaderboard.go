@@ -0,0 +1,106 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const leaderboardSize = 10
+
+// handleLeaderboardPeriod switches the leaderboard message to another period
+func (b *Bot) handleLeaderboardPeriod(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, period domain.LeaderboardPeriod) {
+	text, keyboard, err := b.formatLeaderboard(ctx, userID, lang, period)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting leaderboard: %v", err)
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text)
+	edit.ReplyMarkup = &keyboard
+	edit.ParseMode = "HTML"
+	b.send(edit)
+}
+
+// formatLeaderboard formats the leaderboard of a period with a keyboard to
+// switch between periods
+func (b *Bot) formatLeaderboard(ctx context.Context, userID string, lang domain.Language, period domain.LeaderboardPeriod) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	top, me, err := b.service.GetLeaderboard(ctx, userID, period, leaderboardSize)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>%s</b>\n\n", b.i18n.Get(lang, "leaderboard.title_"+string(period))))
+
+	if len(top) == 0 {
+		text.WriteString(b.i18n.Get(lang, "leaderboard.empty"))
+	}
+
+	inTop := false
+	for _, entry := range top {
+		name := maskUserID(entry.UserID)
+		if entry.DisplayName != "" {
+			name = html.EscapeString(entry.DisplayName)
+		}
+		if entry.UserID == userID {
+			name = "<b>" + b.i18n.Get(lang, "leaderboard.you") + "</b>"
+			inTop = true
+		}
+		text.WriteString(fmt.Sprintf("%s %s — %.0f %s\n",
+			rankBadge(entry.Rank), name, entry.Score, b.i18n.Get(lang, "leaderboard.points")))
+	}
+
+	if !inTop {
+		text.WriteString("\n")
+		if me != nil {
+			text.WriteString(b.i18n.Get(lang, "leaderboard.your_rank", me.Rank, int(me.Score)))
+		} else {
+			text.WriteString(b.i18n.Get(lang, "leaderboard.not_ranked"))
+		}
+	}
+
+	weekly := b.i18n.Get(lang, "leaderboard.weekly")
+	monthly := b.i18n.Get(lang, "leaderboard.monthly")
+	if period == domain.LeaderboardWeekly {
+		weekly = "• " + weekly + " •"
+	} else {
+		monthly = "• " + monthly + " •"
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(weekly, "lb:"+string(domain.LeaderboardWeekly)),
+			tgbotapi.NewInlineKeyboardButtonData(monthly, "lb:"+string(domain.LeaderboardMonthly)),
+		),
+	)
+
+	return text.String(), keyboard, nil
+}
+
+// rankBadge returns a medal for the podium and the rank number otherwise
+func rankBadge(rank int) string {
+	switch rank {
+	case 1:
+		return "🥇"
+	case 2:
+		return "🥈"
+	case 3:
+		return "🥉"
+	default:
+		return fmt.Sprintf("%d.", rank)
+	}
+}
+
+// maskUserID hides all but the last digits of a Telegram user ID so other
+// users can't be identified from the leaderboard
+func maskUserID(userID string) string {
+	if len(userID) <= 4 {
+		return "#" + userID
+	}
+	return "#…" + userID[len(userID)-4:]
+}
@@ -0,0 +1,83 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+const (
+	// leaseKey is the lock held by the replica running the background jobs
+	leaseKey = "lease:jobs"
+
+	// leaseTTL is how long the lease outlives a replica that stopped
+	// renewing it, e.g. after a crash, and leaseRenewInterval how often it
+	// is renewed, or tried to be taken by the other replicas
+	leaseTTL           = 30 * time.Second
+	leaseRenewInterval = 10 * time.Second
+)
+
+// lead runs jobs on the one replica holding the lease, so that background
+// work such as sweeps and scheduled messages is not done once per replica.
+// Replicas without the lease try to take it every leaseRenewInterval, and
+// the jobs start over on the one that does once the holder stopped. It
+// returns once ctx is done.
+func (b *Bot) lead(ctx context.Context, jobs ...func(ctx context.Context)) {
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := b.locks.Acquire(ctx, leaseKey, leaseTTL)
+		if err != nil {
+			requestid.Printf(ctx, "Error acquiring lease: %v", err)
+		}
+		if ok {
+			requestid.Printf(ctx, "Took the lease, running the background jobs")
+			b.holdLease(ctx, ticker, jobs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// holdLease runs jobs while renewing the lease, until ctx is done or the
+// lease is lost, then stops them and gives the lease up
+func (b *Bot) holdLease(ctx context.Context, ticker *time.Ticker, jobs []func(ctx context.Context)) {
+	jobsCtx, stop := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job(jobsCtx)
+		}()
+	}
+	defer func() {
+		stop()
+		wg.Wait()
+		b.releaseLock(context.WithoutCancel(ctx), leaseKey)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ok, err := b.locks.Extend(ctx, leaseKey, leaseTTL)
+		if err != nil {
+			requestid.Printf(ctx, "Error renewing lease: %v", err)
+		}
+		if !ok {
+			requestid.Printf(ctx, "Lost the lease, stopping the background jobs")
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/application"
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// liveEditInterval is the least time between two edits of a submitted
+	// message while its recording is queued, well under Telegram's limit on
+	// edits in a chat
+	liveEditInterval = 10 * time.Second
+
+	// maxLiveEdits bounds the edits of a submitted message while its
+	// recording is queued. The final details are shown regardless.
+	maxLiveEdits = 20
+)
+
+// liveRecording returns the callback refreshing in place the message telling
+// a recording was submitted, whose text is submitted: it shows the
+// recording's place in the queue, then its details once the analysis
+// finished, or a hint to check it once polling timed out. While queued the
+// message is only edited when the text changes, at most every
+// liveEditInterval and maxLiveEdits times. It reports whether the details
+// were shown, so that the result is not notified again. It is nil if the
+// message was not sent.
+func (b *Bot) liveRecording(msg tgbotapi.Message, userID, recordingID string, lang domain.Language, submitted string) func(ctx context.Context, update application.WatchUpdate) bool {
+	if msg.MessageID == 0 {
+		return nil
+	}
+	current := submitted
+	var edits int
+	var editedAt time.Time
+
+	return func(ctx context.Context, update application.WatchUpdate) bool {
+		switch {
+		case update.Recording != nil:
+			if err := b.editRecordingDetails(ctx, &msg, userID, lang, update.Recording); err != nil {
+				requestid.Printf(ctx, "Error showing result of recording %s: %v", recordingID, err)
+				return false
+			}
+			return true
+		case update.TimedOut:
+			keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.check_status"),
+					fmt.Sprintf("check:%s", recordingID)),
+			))
+			b.editMessageWithKeyboard(&msg, submitted+"\n\n"+b.i18n.Get(lang, "recording.still_processing"), keyboard)
+		default:
+			text := submitted + "\n\n" + b.formatQueuePosition(lang, update.Queue)
+			if text == current || edits >= maxLiveEdits || time.Since(editedAt) < liveEditInterval {
+				return false
+			}
+			current, editedAt = text, time.Now()
+			edits++
+			b.editMessageText(&msg, text)
+		}
+		return false
+	}
+}
+
+// formatQueuePosition formats a place in the queue, e.g. "You're #3 in
+// queue, ~20s remaining", the time rounded up to 5 seconds or the minute
+func (b *Bot) formatQueuePosition(lang domain.Language, position application.QueuePosition) string {
+	if position.Remaining >= time.Minute {
+		minutes := int((position.Remaining + time.Minute - 1) / time.Minute)
+		return b.i18n.Get(lang, "recording.queue_minutes", position.Position, minutes)
+	}
+	seconds := int((position.Remaining+5*time.Second-1)/(5*time.Second)) * 5
+	return b.i18n.Get(lang, "recording.queue_seconds", position.Position, seconds)
+}
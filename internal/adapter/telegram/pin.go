@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pinButtonRow returns the button that pins a recording, or unpins it, nil
+// if pins are disabled
+func (b *Bot) pinButtonRow(ctx context.Context, userID string, lang domain.Language, recordingID string) []tgbotapi.InlineKeyboardButton {
+	if !b.service.PinsEnabled() {
+		return nil
+	}
+	pinned, err := b.service.IsPinned(ctx, userID, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting pins: %v", err)
+		return nil
+	}
+	if pinned {
+		return tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "pin.remove"), "unpin:"+recordingID),
+		)
+	}
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "pin.add"), "pin:"+recordingID),
+	)
+}
+
+// Tabs of the recordings list
+const (
+	tabAll = iota
+	tabPinned
+	tabTags
+)
+
+// recordingsTabRow returns the tabs of the recordings list, all recordings,
+// the pinned ones and those with a tag, marking the current one. It is nil
+// if pins and tags are disabled.
+func (b *Bot) recordingsTabRow(lang domain.Language, current int) []tgbotapi.InlineKeyboardButton {
+	if !b.service.PinsEnabled() && !b.service.TagsEnabled() {
+		return nil
+	}
+	tab := func(index int, key, data string) tgbotapi.InlineKeyboardButton {
+		text := b.i18n.Get(lang, key)
+		if index == current {
+			text = "· " + text + " ·"
+		}
+		return tgbotapi.NewInlineKeyboardButtonData(text, data)
+	}
+
+	row := tgbotapi.NewInlineKeyboardRow(tab(tabAll, "recordings.tab_all", "backtorecs"))
+	if b.service.PinsEnabled() {
+		row = append(row, tab(tabPinned, "recordings.tab_pinned", "pins"))
+	}
+	if b.service.TagsEnabled() {
+		row = append(row, tab(tabTags, "recordings.tab_tags", "tagfilter"))
+	}
+	return row
+}
+
+// handlePin pins or unpins a recording, then shows its details again
+func (b *Bot) handlePin(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, recordingID string, pin bool) {
+	var err error
+	if pin {
+		err = b.service.PinRecording(ctx, userID, recordingID)
+	} else {
+		err = b.service.UnpinRecording(ctx, userID, recordingID)
+	}
+	if err != nil {
+		requestid.Printf(ctx, "Error pinning recording: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	b.handleViewRecording(ctx, msg, userID, lang, recordingID)
+}
+
+// handlePinnedList shows the user's pinned recordings in place of the
+// recordings list
+func (b *Bot) handlePinnedList(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language) {
+	pins, err := b.service.Pins(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing pins: %v", err)
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>%s</b>\n\n", b.i18n.Get(lang, "pin.title")))
+	if len(pins) == 0 {
+		text.WriteString(b.i18n.Get(lang, "pin.empty"))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{b.recordingsTabRow(lang, tabPinned)}
+	for _, pin := range pins {
+		date := b.service.UserTime(ctx, userID, pin.PinnedAt).Format("2006-01-02")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("📌 %s - %s", b.ayahLabel(lang, pin.AyahID), date),
+				"viewrec:"+pin.RecordingID,
+			),
+		))
+	}
+
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text.String())
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	edit.ReplyMarkup = &keyboard
+	edit.ParseMode = "HTML"
+	b.send(edit)
+}
@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handlePlayRecording sends the archived audio of one of the user's
+// recordings back to them, so they can listen to it next to the analysis
+func (b *Bot) handlePlayRecording(ctx context.Context, chatID int64, userID string, lang domain.Language, recordingID string) {
+	recording, err := b.service.GetRecording(ctx, userID, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting recording: %v", err)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.recording_not_found"))
+		return
+	}
+
+	audio, err := b.service.RecordingAudio(ctx, userID, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting recording audio: %v", err)
+	}
+	if audio == nil {
+		b.sendMessage(chatID, b.i18n.Get(lang, "error.recording_audio_unavailable"))
+		return
+	}
+
+	ayah := b.ayahOf(ctx, recording.AyahID)
+	caption := b.i18n.Get(lang, "recording.play_caption", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+
+	// Converting to a voice message runs ffmpeg, so it goes through the
+	// transcoding workers like submissions do
+	reqID := requestid.From(ctx)
+	_, err = b.transcoder.submit(func(ctx context.Context) {
+		ctx = requestid.With(ctx, reqID)
+		defer b.recoverPanic(ctx, "recording playback", chatID, lang)
+		b.sendRecordingAudio(ctx, chatID, lang, recordingID, audio, caption)
+	})
+	if errors.Is(err, errTranscodeQueueFull) {
+		b.sendMessage(chatID, b.i18n.Get(lang, "error.queue_full"))
+	}
+}
+
+// sendRecordingAudio sends audio as a voice message, converting it to
+// OGG/Opus if needed. When it cannot be converted, it is sent as a file.
+func (b *Bot) sendRecordingAudio(ctx context.Context, chatID int64, lang domain.Language, recordingID string, audio *domain.ArchivedAudio, caption string) {
+	data, err := b.service.VoiceAudio(ctx, audio)
+	if err != nil {
+		requestid.Printf(ctx, "Error converting recording %s to a voice message: %v", recordingID, err)
+	}
+
+	var msg tgbotapi.Chattable
+	if data != nil {
+		voice := tgbotapi.NewVoice(chatID, tgbotapi.FileBytes{Name: recordingID + ".ogg", Bytes: data})
+		voice.Caption = caption
+		msg = voice
+	} else {
+		format := audio.Meta.Format
+		if format == "" {
+			format = "wav"
+		}
+		file := tgbotapi.NewAudio(chatID, tgbotapi.FileBytes{Name: recordingID + "." + format, Bytes: audio.Data})
+		file.Caption = caption
+		msg = file
+	}
+
+	if _, err := b.send(msg); err != nil {
+		requestid.Printf(ctx, "Error sending recording audio: %v", err)
+		b.sendMessage(chatID, b.i18n.Get(lang, "error.recording_audio_unavailable"))
+	}
+}
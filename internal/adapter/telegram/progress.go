@@ -0,0 +1,87 @@
+package telegram
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// progressListLimit is the number of surahs in progress listed, which
+	// keeps the message well under Telegram's 4096 characters
+	progressListLimit = 20
+
+	// progressButtonLimit is the number of surahs in progress offered to
+	// continue from their first missing ayah
+	progressButtonLimit = 5
+)
+
+// commandProgress shows how much of each surah the user has recited, the
+// surahs closest to completion first
+func (b *Bot) commandProgress(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	surahs, err := b.service.Progress(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting progress: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	var complete int
+	var started []domain.SurahProgress
+	for _, p := range surahs {
+		if p.Complete() {
+			complete++
+			continue
+		}
+		if p.RecitedCount() > 0 {
+			started = append(started, p)
+		}
+	}
+	if complete == 0 && len(started) == 0 {
+		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "progress.empty"))
+		return
+	}
+	slices.SortStableFunc(started, func(x, y domain.SurahProgress) int {
+		return cmp.Compare(y.Completion(), x.Completion())
+	})
+
+	var text strings.Builder
+	text.WriteString(b.i18n.Get(lang, "progress.title") + "\n\n")
+	if complete > 0 {
+		text.WriteString(b.i18n.Get(lang, "progress.complete", complete) + "\n")
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, p := range started {
+		if i == progressListLimit {
+			text.WriteString(b.i18n.Get(lang, "progress.more", len(started)-i) + "\n")
+			break
+		}
+		name := b.i18n.GetSurahName(lang, p.SurahNumber)
+		text.WriteString(b.i18n.Get(lang, "progress.item",
+			name, p.RecitedCount(), p.Ayahs, p.Completion()*100, p.AverageAccuracy()*100) + "\n")
+		if missing := p.Missing(); i < progressButtonLimit && len(missing) > 0 {
+			next := domain.Ayah{SurahNumber: p.SurahNumber, AyahNumber: missing[0]}
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "jump.go", name, next.AyahNumber),
+					"progress:"+next.String()),
+			))
+		}
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text.String())
+	if len(rows) > 0 {
+		reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
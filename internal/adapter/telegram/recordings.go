@@ -3,11 +3,12 @@ package telegram
 import (
 	"context"
 	"fmt"
-	"log"
+	"html"
 	"strings"
 	"time"
 
 	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -17,17 +18,26 @@ func (b *Bot) handleCheckRecording(ctx context.Context, msg *tgbotapi.Message, u
 
 	recording, err := b.service.GetRecording(ctx, userID, recordingID)
 	if err != nil {
-		log.Printf("Error getting recording: %v", err)
-		b.sendMessage(chatID, b.i18n.Get(lang, "error.recording_not_found"))
+		requestid.Printf(ctx, "Error getting recording: %v", err)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.recording_not_found"))
 		return
 	}
 
-	// Format recording details
-	text := b.formatRecordingDetails(lang, recording)
-
 	// Send as new message or edit existing
 	deleteMsg := tgbotapi.NewDeleteMessage(chatID, msg.MessageID)
-	b.api.Send(deleteMsg)
+	b.send(deleteMsg)
+
+	b.sendRecordingDetails(ctx, chatID, userID, lang, recording)
+}
+
+// sendRecordingDetails sends the details of a recording, with buttons to
+// refresh them and move on
+func (b *Bot) sendRecordingDetails(ctx context.Context, chatID int64, userID string, lang domain.Language, recording *domain.Recording) {
+	b.service.MarkReviewed(ctx, userID, recording)
+	recordingID := recording.ID
+
+	// Format recording details
+	text := b.formatRecordingDetails(ctx, userID, lang, recording)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -47,23 +57,52 @@ func (b *Bot) handleCheckRecording(ctx context.Context, msg *tgbotapi.Message, u
 			),
 		),
 	)
+	if row := b.referenceButtonRow(lang, recording.AyahID); recording.Result != nil && row != nil {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if row := b.practiceButtonRow(lang, recording); row != nil {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if row := append(b.pinButtonRow(ctx, userID, lang, recordingID), b.tagButton(lang, recordingID)...); len(row) > 0 {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if row := b.retryButtonRow(lang, recording); row != nil {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if row := b.drillButtonRow(lang, recording); row != nil {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if b.service.AudioArchiveEnabled() {
+		row := tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.play"), "play:"+recordingID),
+		)
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
 
 	newMsg := tgbotapi.NewMessage(chatID, text)
 	newMsg.ReplyMarkup = keyboard
 	newMsg.ParseMode = "HTML"
-	b.api.Send(newMsg)
+	b.send(newMsg)
 }
 
 // handleViewRecording shows details of a specific recording
 func (b *Bot) handleViewRecording(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, recordingID string) {
 	recording, err := b.service.GetRecording(ctx, userID, recordingID)
 	if err != nil {
-		log.Printf("Error getting recording: %v", err)
-		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "error.recording_not_found"))
+		requestid.Printf(ctx, "Error getting recording: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.recording_not_found"))
 		return
 	}
+	b.service.MarkReviewed(ctx, userID, recording)
+	b.editRecordingDetails(ctx, msg, userID, lang, recording)
+}
 
-	text := b.formatRecordingDetails(lang, recording)
+// editRecordingDetails edits a message into the details of a recording,
+// with buttons to refresh them and go back to the list. It fails if the
+// message could not be edited.
+func (b *Bot) editRecordingDetails(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, recording *domain.Recording) error {
+	recordingID := recording.ID
+	text := b.formatRecordingDetails(ctx, userID, lang, recording)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -79,35 +118,51 @@ func (b *Bot) handleViewRecording(ctx context.Context, msg *tgbotapi.Message, us
 			),
 		),
 	)
+	if row := b.referenceButtonRow(lang, recording.AyahID); recording.Result != nil && row != nil {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if row := b.practiceButtonRow(lang, recording); row != nil {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if row := append(b.pinButtonRow(ctx, userID, lang, recordingID), b.tagButton(lang, recordingID)...); len(row) > 0 {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if row := b.retryButtonRow(lang, recording); row != nil {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
+	if row := b.drillButtonRow(lang, recording); row != nil {
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
 
 	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text)
 	edit.ReplyMarkup = &keyboard
 	edit.ParseMode = "HTML"
-	b.api.Send(edit)
+	_, err := b.send(edit)
+	return err
 }
 
 // sendRecordingsList sends a paginated list of recordings
-func (b *Bot) sendRecordingsList(chatID int64, userID string, lang domain.Language, recordings []*domain.Recording, page int) {
+func (b *Bot) sendRecordingsList(chatID int64, userID string, lang domain.Language, recordings []domain.RecordingSummary, page int) {
 	text, keyboard := b.formatRecordingsList(lang, recordings, page)
 
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
 	msg.ParseMode = "HTML"
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // editRecordingsList edits message with paginated list of recordings
-func (b *Bot) editRecordingsList(msg *tgbotapi.Message, userID string, lang domain.Language, recordings []*domain.Recording, page int) {
+func (b *Bot) editRecordingsList(msg *tgbotapi.Message, userID string, lang domain.Language, recordings []domain.RecordingSummary, page int) {
 	text, keyboard := b.formatRecordingsList(lang, recordings, page)
 
 	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text)
 	edit.ReplyMarkup = &keyboard
 	edit.ParseMode = "HTML"
-	b.api.Send(edit)
+	b.send(edit)
 }
 
 // formatRecordingsList formats recordings into paginated list with keyboard
-func (b *Bot) formatRecordingsList(lang domain.Language, recordings []*domain.Recording, page int) (string, tgbotapi.InlineKeyboardMarkup) {
+func (b *Bot) formatRecordingsList(lang domain.Language, recordings []domain.RecordingSummary, page int) (string, tgbotapi.InlineKeyboardMarkup) {
 	const itemsPerPage = 5
 	totalPages := (len(recordings) + itemsPerPage - 1) / itemsPerPage
 
@@ -129,21 +184,13 @@ func (b *Bot) formatRecordingsList(lang domain.Language, recordings []*domain.Re
 	text.WriteString(fmt.Sprintf("%s: %d\n\n", b.i18n.Get(lang, "recordings.total"), len(recordings)))
 
 	var rows [][]tgbotapi.InlineKeyboardButton
+	if row := b.recordingsTabRow(lang, tabAll); row != nil {
+		rows = append(rows, row)
+	}
 
 	// Add recording buttons
 	for i := start; i < end; i++ {
-		rec := recordings[i]
-		status := b.getStatusEmoji(rec.Status)
-		date := rec.CreatedAt.Format("2006-01-02 15:04")
-
-		// Parse ayah ID to get surah and ayah numbers
-		surahNum, ayahNum := b.parseAyahID(rec.AyahID)
-		surahName := b.i18n.GetSurahName(lang, surahNum)
-
-		btnText := fmt.Sprintf("%s %s:%d - %s", status, surahName, ayahNum, date)
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(btnText, fmt.Sprintf("viewrec:%s", rec.ID)),
-		))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(b.recordingButton(lang, recordings[i])))
 	}
 
 	// Add navigation buttons
@@ -179,31 +226,72 @@ func (b *Bot) formatRecordingsList(lang domain.Language, recordings []*domain.Re
 	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
+// recordingButton returns the button of a recording in a list, opening its
+// details
+func (b *Bot) recordingButton(lang domain.Language, rec domain.RecordingSummary) tgbotapi.InlineKeyboardButton {
+	status := b.getStatusEmoji(rec.Status)
+	if rec.Status == domain.StatusDone && rec.Stage == domain.StageNotified {
+		// Notified but never opened
+		status += "🆕"
+	}
+	date := rec.CreatedAt.Format("2006-01-02 15:04")
+
+	btnText := fmt.Sprintf("%s %s - %s", status, b.ayahLabel(lang, rec.AyahID), date)
+	return tgbotapi.NewInlineKeyboardButtonData(btnText, fmt.Sprintf("viewrec:%s", rec.ID))
+}
+
+// ayahLabel labels a recording with the surah name and ayah number of its
+// ayah, or the raw ID if it is malformed
+func (b *Bot) ayahLabel(lang domain.Language, ayahID string) string {
+	ayah, err := domain.ParseAyahID(ayahID)
+	if err != nil {
+		return ayahID
+	}
+	return fmt.Sprintf("%s:%d", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+}
+
 // formatRecordingDetails formats detailed recording information
-func (b *Bot) formatRecordingDetails(lang domain.Language, recording *domain.Recording) string {
+func (b *Bot) formatRecordingDetails(ctx context.Context, userID string, lang domain.Language, recording *domain.Recording) string {
 	var text strings.Builder
 
 	text.WriteString(fmt.Sprintf("<b>%s</b>\n\n", b.i18n.Get(lang, "recording.details")))
 	text.WriteString(fmt.Sprintf("🆔 ID: <code>%s</code>\n", recording.ID))
 
 	// Parse and format ayah ID to be more readable
-	surahNum, ayahNum := b.parseAyahID(recording.AyahID)
-	surahName := b.i18n.GetSurahName(lang, surahNum)
+	ayah := b.ayahOf(ctx, recording.AyahID)
+	surahName := b.i18n.GetSurahName(lang, ayah.SurahNumber)
 	text.WriteString(fmt.Sprintf("📖 Surah: <b>%s</b>\n", surahName))
-	text.WriteString(fmt.Sprintf("📄 %s: <b>%d</b>\n", b.i18n.Get(lang, "ayah.ayah"), ayahNum))
+	text.WriteString(fmt.Sprintf("📄 %s: <b>%d</b>\n", b.i18n.Get(lang, "ayah.ayah"), ayah.AyahNumber))
+	if b.service.HasSajdah(ctx, userID, ayah) {
+		text.WriteString(b.i18n.Get(lang, "ayah.sajdah") + "\n")
+	}
 	text.WriteString(fmt.Sprintf("📅 %s: %s\n",
 		b.i18n.Get(lang, "recording.created"),
-		recording.CreatedAt.Format(time.RFC822),
+		b.service.UserTime(ctx, userID, recording.CreatedAt).Format(time.RFC822),
 	))
+	if tags, err := b.service.RecordingTags(ctx, userID, recording.ID); err == nil && len(tags) > 0 {
+		text.WriteString(fmt.Sprintf("🏷 %s: %s\n", b.i18n.Get(lang, "tags.label"), html.EscapeString(strings.Join(tags, ", "))))
+	}
 	text.WriteString(fmt.Sprintf("🔄 %s: %s %s\n\n",
 		b.i18n.Get(lang, "recording.status"),
 		b.getStatusEmoji(recording.Status),
 		recording.Status,
 	))
 
+	if recording.Status == domain.StatusFailed {
+		text.WriteString(b.failureText(lang, recording) + "\n\n")
+	}
+
 	// Show results if available
 	if recording.Result != nil {
 		text.WriteString(fmt.Sprintf("<b>%s</b>\n", b.i18n.Get(lang, "recording.results")))
+		text.WriteString(fmt.Sprintf("<b>%s</b>\n", b.service.FormatGrade(lang, recording.Result.WER)))
+		if mistakes := b.service.FormatMistakes(lang, recording.Result); mistakes != "" {
+			text.WriteString(mistakes + "\n")
+		}
+		if tip := b.service.FormatCoachingTip(lang, recording.Result); tip != "" {
+			text.WriteString(tip + "\n")
+		}
 		text.WriteString(fmt.Sprintf("📊 WER: <b>%.2f%%</b>\n\n", recording.Result.WER*100))
 
 		if len(recording.Result.Ops) > 0 {
@@ -217,6 +305,10 @@ func (b *Bot) formatRecordingDetails(lang domain.Language, recording *domain.Rec
 					break
 				}
 				emoji := b.getOpEmoji(op.Op)
+				if op.Op == domain.OpBasmala {
+					text.WriteString(fmt.Sprintf("%s <code>%s</code> (%s)\n", emoji, op.HypAr, b.i18n.Get(lang, "recording.basmala")))
+					continue
+				}
 				text.WriteString(fmt.Sprintf("%s <code>%s</code>\n", emoji, op.RefAr))
 			}
 		}
@@ -234,6 +326,107 @@ func (b *Bot) formatRecordingDetails(lang domain.Language, recording *domain.Rec
 	return text.String()
 }
 
+// notifyRecordingEvent tells the user that their recording was analyzed.
+// Every replica receives the event; the notification lock and the
+// recording's lifecycle ensure exactly one of them sends the message, once.
+func (b *Bot) notifyRecordingEvent(ctx context.Context, event domain.RecordingEvent) {
+	if !b.acquireLock(ctx, "notify:"+event.RecordingID, notifyLockTTL) {
+		return
+	}
+	if !b.service.ClaimNotification(ctx, event) {
+		return
+	}
+	chatID, err := b.chatID(domain.SessionScope{UserID: event.UserID, ChatID: event.ChatID})
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing user ID %s: %v", event.UserID, err)
+		return
+	}
+
+	if event.Status == domain.StatusDone {
+		defer b.notifyChallengeOutcome(ctx, event)
+	}
+	// The result was shown in place of the message telling the recording
+	// was submitted, so it is only notified when that failed
+	if event.Shown {
+		return
+	}
+
+	lang := b.service.GetUserLanguage(ctx, event.UserID)
+	ayah := b.ayahOf(ctx, event.AyahID)
+	surahName := b.i18n.GetSurahName(lang, ayah.SurahNumber)
+
+	key := "notify.recording_done"
+	if event.Status == domain.StatusFailed {
+		key = "notify.recording_failed"
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				b.i18n.Get(lang, "recording.view_results"),
+				fmt.Sprintf("viewrec:%s", event.RecordingID),
+			),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.continue"), "continue"),
+		),
+	)
+
+	text := b.i18n.Get(lang, key, surahName, ayah.AyahNumber)
+	if b.service.HasSajdah(ctx, event.UserID, ayah) {
+		text += "\n" + b.i18n.Get(lang, "ayah.sajdah")
+	}
+
+	// Explain failures and offer to record the ayah again
+	if event.Status == domain.StatusFailed {
+		recording, err := b.service.GetRecording(ctx, event.UserID, event.RecordingID)
+		if err != nil {
+			requestid.Printf(ctx, "Error getting recording: %v", err)
+			recording = &domain.Recording{ID: event.RecordingID, Status: event.Status}
+		}
+		text += "\n\n" + b.failureText(lang, recording)
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{b.retryButtonRow(lang, recording)}, keyboard.InlineKeyboard...)
+	}
+
+	// Show the grade of successful results, and attach the timeline of
+	// mistakes when possible
+	var timeline []byte
+	if event.Status == domain.StatusDone {
+		recording, err := b.service.GetRecording(ctx, event.UserID, event.RecordingID)
+		if err != nil {
+			requestid.Printf(ctx, "Error getting recording: %v", err)
+		} else if recording.Result != nil {
+			text += "\n\n" + b.service.FormatGrade(lang, recording.Result.WER)
+			if mistakes := b.service.FormatMistakes(lang, recording.Result); mistakes != "" {
+				text += "\n" + mistakes
+			}
+			if tip := b.service.FormatCoachingTip(lang, recording.Result); tip != "" {
+				text += "\n" + tip
+			}
+			timeline, _ = renderTimeline(recording.Result.Ops)
+			if row := b.drillButtonRow(lang, recording); row != nil {
+				keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+			}
+		}
+	}
+	if timeline != nil {
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "timeline.png", Bytes: timeline})
+		photo.Caption = text + "\n\n" + b.i18n.Get(lang, "timeline.legend")
+		photo.ReplyMarkup = keyboard
+		_, err := b.send(photo)
+		if err == nil {
+			return
+		}
+		requestid.Printf(ctx, "Error sending result timeline: %v", err)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	if _, err := b.send(msg); err != nil {
+		requestid.Printf(ctx, "Error sending result notification: %v", err)
+	}
+}
+
 // getStatusEmoji returns emoji for recording status
 func (b *Bot) getStatusEmoji(status domain.RecordingStatus) string {
 	switch status {
@@ -259,21 +452,19 @@ func (b *Bot) getOpEmoji(op domain.OpType) string {
 		return "❌"
 	case domain.OpInsertion:
 		return "➕"
+	case domain.OpBasmala:
+		return "📿"
 	default:
 		return "❓"
 	}
 }
 
-// parseAyahID parses ayah ID (XXXYYY format) to surah and ayah numbers
-func (b *Bot) parseAyahID(ayahID string) (surahNum int, ayahNum int) {
-	if len(ayahID) != 6 {
-		return 0, 0
+// ayahOf parses the ayah ID of a recording for display. Malformed IDs are
+// logged and shown as the zero ayah rather than failing the whole message.
+func (b *Bot) ayahOf(ctx context.Context, ayahID string) domain.Ayah {
+	ayah, err := domain.ParseAyahID(ayahID)
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing ayah ID: %v", err)
 	}
-
-	// Parse surah number (first 3 digits)
-	fmt.Sscanf(ayahID[:3], "%d", &surahNum)
-	// Parse ayah number (last 3 digits)
-	fmt.Sscanf(ayahID[3:], "%d", &ayahNum)
-
-	return surahNum, ayahNum
+	return ayah
 }
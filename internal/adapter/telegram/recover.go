@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recoverPanic, deferred by handlers, turns a panic into a log line with the
+// stack, a count on the operator dashboard and alerts, and an apology to the
+// user, instead of crashing the bot or leaving the user without an answer.
+// chatID may be 0 when there is nobody to tell.
+func (b *Bot) recoverPanic(ctx context.Context, where string, chatID int64, lang domain.Language) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	requestid.Printf(ctx, "Panic in %s: %v\n%s", where, r, debug.Stack())
+	b.service.RecordPanic()
+
+	if chatID != 0 {
+		b.sendMessage(chatID, b.errorText(ctx, lang, "error.unexpected"))
+	}
+}
+
+// updateChatID returns the chat an update came from, 0 if none
+func updateChatID(update tgbotapi.Update) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	}
+	return 0
+}
+
+// errorText returns the localized error message for key followed by the
+// request ID, for users to quote when reporting the problem
+func (b *Bot) errorText(ctx context.Context, lang domain.Language, key string, args ...any) string {
+	text := b.i18n.Get(lang, key, args...)
+	if id := requestid.From(ctx); id != "" {
+		text += "\n\n" + b.i18n.Get(lang, "error.reference", id)
+	}
+	return text
+}
@@ -0,0 +1,53 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// referenceAudioURL fills the reference audio URL template for an ayah
+// (XXXYYY format, numbered in riwayah) recited by reciter
+func (b *Bot) referenceAudioURL(reciter string, riwayah domain.Riwayah, ayahID string) string {
+	return strings.NewReplacer("{reciter}", reciter, "{riwayah}", string(riwayah), "{ayah}", ayahID).Replace(b.referenceURL)
+}
+
+// referenceButtonRow returns the button that sends the reference recitation
+// of an ayah, nil if reference audio is disabled
+func (b *Bot) referenceButtonRow(lang domain.Language, ayahID string) []tgbotapi.InlineKeyboardButton {
+	if b.referenceURL == "" {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.reference"), "ref:"+ayahID),
+	)
+}
+
+// handleReferenceAudio sends the clip of an ayah by the user's reference
+// reciter, so they can hear the correct rendition right after their result
+func (b *Bot) handleReferenceAudio(ctx context.Context, chatID int64, userID string, lang domain.Language, ayahID string) {
+	if b.referenceURL == "" {
+		return
+	}
+	ayah, err := domain.ParseAyahID(ayahID)
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing reference ayah: %v", err)
+		return
+	}
+
+	prefs, err := b.service.GetPreferences(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting preferences: %v", err)
+		prefs = domain.DefaultPreferences()
+	}
+
+	audio := tgbotapi.NewAudio(chatID, tgbotapi.FileURL(b.referenceAudioURL(prefs.Reciter, prefs.Riwayah, ayahID)))
+	audio.Caption = b.i18n.Get(lang, "recording.reference_caption", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+	if _, err := b.send(audio); err != nil {
+		requestid.Printf(ctx, "Error sending reference audio: %v", err)
+		b.sendMessage(chatID, b.i18n.Get(lang, "error.reference_unavailable"))
+	}
+}
@@ -0,0 +1,71 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// retryButtonRow returns the button to submit the ayah of a failed
+// recording again, nil for other recordings
+func (b *Bot) retryButtonRow(lang domain.Language, recording *domain.Recording) []tgbotapi.InlineKeyboardButton {
+	if recording.Status != domain.StatusFailed {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "retry.button"), "retry:"+recording.ID),
+	)
+}
+
+// failureText explains why the analysis of a failed recording failed and
+// what to do about it
+func (b *Bot) failureText(lang domain.Language, recording *domain.Recording) string {
+	return b.i18n.Get(lang, "failure."+string(recording.FailureReason()))
+}
+
+// handleRetry selects the ayah of a recording again and resubmits the
+// latest recording of it if it is still cached, or else asks for a new one
+func (b *Bot) handleRetry(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language, recordingID string) {
+	ayah, audio, err := b.service.RetryRecording(ctx, scope, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error retrying recording: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.recording_not_found"))
+		return
+	}
+
+	if audio != nil {
+		b.resubmit(ctx, chatID, scope, lang, ayah, audio)
+		return
+	}
+
+	text := b.i18n.Get(lang, "retry.ayah", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+	b.sendMessage(chatID, text+"\n\n"+b.recordingPrompt(ctx, scope, lang))
+}
+
+// resubmit submits the cached audio of the ayah again
+func (b *Bot) resubmit(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language, ayah domain.Ayah, audio *domain.CachedAudio) {
+	if allowed, retryAfter := b.service.AllowSubmission(ctx, scope.UserID); !allowed {
+		minutes := int(math.Ceil(retryAfter.Minutes()))
+		b.sendMessage(chatID, b.i18n.Get(lang, "error.rate_limited", minutes))
+		return
+	}
+
+	submission, err := b.service.HandleRecording(ctx, scope, bytes.NewReader(audio.Data), audio.Meta)
+	if err != nil {
+		requestid.Printf(ctx, "Error resubmitting recording: %v", err)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.recording_failed"))
+		return
+	}
+
+	b.sendMessage(chatID, b.i18n.Get(lang, "retry.resubmitted", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber))
+	b.sendSubmission(ctx, scope, chatID, lang, submission)
+}
@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxSendRetries bounds how often a message is sent again after Telegram
+	// asked to slow down, and maxRetryAfter how long it is waited for
+	maxSendRetries = 3
+	maxRetryAfter  = time.Minute
+)
+
+// sender sends requests to the Telegram API within its rate limits, waiting
+// for a free slot instead of being throttled, and sends requests again when
+// Telegram asks to retry later. The limits are the buckets of a
+// RateLimiterPort, shared by every replica of the bot: domain.RateActionSend
+// for the bot overall and domain.RateActionPrivateSend or
+// domain.RateActionGroupSend for each chat.
+type sender struct {
+	api     *tgbotapi.BotAPI
+	limiter domain.RateLimiterPort // nil for no limits
+}
+
+func newSender(api *tgbotapi.BotAPI, limiter domain.RateLimiterPort) *sender {
+	return &sender{api: api, limiter: limiter}
+}
+
+// send sends a request and decodes the message it returns
+func (s *sender) send(ctx context.Context, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	for attempt := 0; ; attempt++ {
+		if err := s.wait(ctx, chatOf(c)); err != nil {
+			return tgbotapi.Message{}, err
+		}
+		msg, err := s.api.Send(c)
+		if !s.retry(ctx, err, attempt) {
+			return msg, err
+		}
+	}
+}
+
+// request sends a request and returns Telegram's raw response
+func (s *sender) request(ctx context.Context, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	for attempt := 0; ; attempt++ {
+		if err := s.wait(ctx, chatOf(c)); err != nil {
+			return nil, err
+		}
+		resp, err := s.api.Request(c)
+		if !s.retry(ctx, err, attempt) {
+			return resp, err
+		}
+	}
+}
+
+// retry reports whether err asks to send again later, after waiting for as
+// long as Telegram asked
+func (s *sender) retry(ctx context.Context, err error, attempt int) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 429 || attempt >= maxSendRetries {
+		return false
+	}
+	retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+	if retryAfter > maxRetryAfter {
+		return false
+	}
+
+	log.Printf("Telegram rate limit hit, retrying in %s", retryAfter)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(retryAfter):
+		return true
+	}
+}
+
+// wait blocks until a message to chatID fits in the limits of the chat and
+// of the bot, and takes its slots. Requests not bound to a chat, with
+// chatID 0, are not limited. The chat's slot is taken first so that a slot
+// of the bot, which every chat competes for, is not held while waiting.
+func (s *sender) wait(ctx context.Context, chatID int64) error {
+	if chatID == 0 {
+		return nil
+	}
+	chat := domain.RateActionPrivateSend
+	if chatID < 0 {
+		chat = domain.RateActionGroupSend
+	}
+	if err := waitRate(ctx, s.limiter, chat, strconv.FormatInt(chatID, 10)); err != nil {
+		return err
+	}
+	return waitRate(ctx, s.limiter, domain.RateActionSend, "all")
+}
+
+// chatOf returns the chat a request sends or edits a message in, or 0
+func chatOf(c tgbotapi.Chattable) int64 {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return c.ChatID
+	case tgbotapi.PhotoConfig:
+		return c.ChatID
+	case tgbotapi.DocumentConfig:
+		return c.ChatID
+	case tgbotapi.AudioConfig:
+		return c.ChatID
+	case tgbotapi.VoiceConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return c.ChatID
+	case tgbotapi.DeleteMessageConfig:
+		return c.ChatID
+	}
+	return 0
+}
+
+// send sends an interactive message within Telegram's rate limits
+func (b *Bot) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return b.sender.send(context.Background(), c)
+}
+
+// request sends an interactive request within Telegram's rate limits
+func (b *Bot) request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return b.sender.request(context.Background(), c)
+}
@@ -0,0 +1,105 @@
+package telegram
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Settings toggled from the /settings keyboard, as sent in callback data
+const (
+	settingEnhanceAudio    = "enhance_audio"
+	settingSeparateBasmala = "separate_basmala"
+	settingRiwayah         = "riwayah"
+	settingTimezone        = "timezone"
+)
+
+func (b *Bot) commandSettings(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	prefs, err := b.service.GetPreferences(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting preferences: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, b.i18n.Get(lang, "settings.title"))
+	reply.ReplyMarkup = b.getSettingsKeyboard(lang, prefs)
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
+
+// handleSettingToggle flips a setting and refreshes the settings keyboard
+func (b *Bot) handleSettingToggle(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, setting string) {
+	var toggle func(prefs *domain.Preferences)
+	switch setting {
+	case settingEnhanceAudio:
+		toggle = func(prefs *domain.Preferences) { prefs.EnhanceAudio = !prefs.EnhanceAudio }
+	case settingSeparateBasmala:
+		toggle = func(prefs *domain.Preferences) { prefs.SeparateBasmala = !prefs.SeparateBasmala }
+	case settingRiwayah:
+		toggle = func(prefs *domain.Preferences) { prefs.Riwayah = nextRiwayah(prefs.Riwayah) }
+	case settingTimezone:
+		b.sendTimezonePrompt(ctx, msg.Chat.ID, userID, lang)
+		return
+	default:
+		return
+	}
+
+	prefs, err := b.service.UpdatePreferences(ctx, userID, toggle)
+	if err != nil {
+		requestid.Printf(ctx, "Error updating preferences: %v", err)
+		return
+	}
+
+	b.editMessageWithKeyboard(msg, b.i18n.Get(lang, "settings.title"), b.getSettingsKeyboard(lang, prefs))
+}
+
+func (b *Bot) getSettingsKeyboard(lang domain.Language, prefs domain.Preferences) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				b.i18n.Get(lang, "settings.enhance_audio", b.onOff(lang, prefs.EnhanceAudio)),
+				"settings:"+settingEnhanceAudio,
+			),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				b.i18n.Get(lang, "settings.separate_basmala", b.onOff(lang, prefs.SeparateBasmala)),
+				"settings:"+settingSeparateBasmala,
+			),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				b.i18n.Get(lang, "settings.riwayah", b.i18n.Get(lang, "riwayah."+string(prefs.Riwayah))),
+				"settings:"+settingRiwayah,
+			),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				b.i18n.Get(lang, "settings.timezone", timezoneName(prefs.Timezone)),
+				"settings:"+settingTimezone,
+			),
+		),
+	)
+}
+
+// nextRiwayah returns the riwayah after r in domain.Riwayat, wrapping around
+func nextRiwayah(r domain.Riwayah) domain.Riwayah {
+	i := slices.Index(domain.Riwayat, r)
+	return domain.Riwayat[(i+1)%len(domain.Riwayat)]
+}
+
+func (b *Bot) onOff(lang domain.Language, on bool) string {
+	if on {
+		return b.i18n.Get(lang, "settings.on")
+	}
+	return b.i18n.Get(lang, "settings.off")
+}
@@ -0,0 +1,106 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxSpokenDuration is the longest voice message taken for the ayah to go
+// to said aloud while picking a surah, e.g. "سورة الكهف آية عشرة"; longer
+// ones are recitations sent too early
+const maxSpokenDuration = 8 * time.Second
+
+// handleSpokenAyah takes a short voice message sent while picking a surah
+// for the ayah to go to said aloud, and offers to go to the ayah heard. It
+// reports false if spoken navigation is disabled or the message is too long
+// to be one.
+func (b *Bot) handleSpokenAyah(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, audio *audioInput, fileLock string, lang domain.Language) bool {
+	if !b.service.SpokenNavigationEnabled() || audio.Duration == 0 || audio.Duration > maxSpokenDuration {
+		return false
+	}
+	chatID := msg.Chat.ID
+
+	if allowed, retryAfter := b.service.AllowSubmission(ctx, scope.UserID); !allowed {
+		b.releaseLock(ctx, fileLock)
+		b.sendMessage(chatID, b.i18n.Get(lang, "error.rate_limited", int(math.Ceil(retryAfter.Minutes()))))
+		return true
+	}
+
+	listening, err := b.send(tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "spoken.listening")))
+	if err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+
+	// Convert and transcribe on a transcoding worker, as recordings are
+	reqID := requestid.From(ctx)
+	_, err = b.transcoder.submit(func(ctx context.Context) {
+		ctx = requestid.With(ctx, reqID)
+		defer b.recoverPanic(ctx, "spoken navigation", chatID, lang)
+		b.detectSpokenAyah(ctx, &listening, scope.UserID, audio, fileLock, lang)
+	})
+	if err != nil {
+		b.releaseLock(ctx, fileLock)
+		if errors.Is(err, errTranscodeQueueFull) {
+			b.editMessageText(&listening, b.i18n.Get(lang, "error.queue_full"))
+		}
+	}
+	return true
+}
+
+// detectSpokenAyah transcribes a spoken reference to an ayah and turns the
+// listening message into a button to go to the ayah heard
+func (b *Bot) detectSpokenAyah(ctx context.Context, listening *tgbotapi.Message, userID string, audio *audioInput, fileLock string, lang domain.Language) {
+	audioReader, meta, err := b.prepareAudio(ctx, userID, audio)
+	if err != nil {
+		requestid.Printf(ctx, "Error processing voice message: %v", err)
+		b.releaseLock(ctx, fileLock)
+		b.editMessageText(listening, b.errorMessage(ctx, lang, err, "error.audio_conversion"))
+		return
+	}
+
+	ayah, ok, err := b.service.DetectSpokenAyah(ctx, userID, audioReader, meta)
+	if err != nil {
+		requestid.Printf(ctx, "Error detecting spoken ayah: %v", err)
+		b.releaseLock(ctx, fileLock)
+		b.editMessageText(listening, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	if !ok {
+		b.editMessageText(listening, b.i18n.Get(lang, "spoken.not_found"))
+		return
+	}
+
+	name := b.i18n.GetSurahName(lang, ayah.SurahNumber)
+	b.editMessageWithKeyboard(listening, b.i18n.Get(lang, "spoken.found", name, ayah.AyahNumber),
+		tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "jump.go", name, ayah.AyahNumber), "jump:"+ayah.String()),
+		)))
+}
+
+// handleJump selects the ayah the user confirmed going to and asks for its
+// recording
+func (b *Bot) handleJump(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language, ayahID string) {
+	ayah, err := domain.ParseAyahID(ayahID)
+	if err == nil {
+		err = b.service.RecordAyah(ctx, scope, ayah)
+	}
+	if err != nil {
+		requestid.Printf(ctx, "Error jumping to ayah: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, msg.Chat.ID, scope, lang, err)
+			return
+		}
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	text := b.i18n.Get(lang, "jump.ayah", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+	b.editMessageText(msg, text)
+	b.sendMessage(msg.Chat.ID, b.recordingPrompt(ctx, scope, lang))
+}
@@ -0,0 +1,249 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// tagButton returns the button opening the tags of a recording, none if
+// tags are disabled
+func (b *Bot) tagButton(lang domain.Language, recordingID string) []tgbotapi.InlineKeyboardButton {
+	if !b.service.TagsEnabled() {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "tags.button"), "tags:"+recordingID),
+	)
+}
+
+// tagChoices returns the tags offered to add to a recording: the user's own
+// and the suggested ones, sorted. Buttons refer to them by index, since
+// tags may not fit in callback data.
+func (b *Bot) tagChoices(ctx context.Context, userID string, lang domain.Language) []string {
+	tags, err := b.service.UserTags(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing tags: %v", err)
+	}
+	for _, suggestion := range strings.Split(b.i18n.Get(lang, "tags.suggestions"), ",") {
+		if tag, err := domain.NormalizeTag(suggestion); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// formatTagEditor formats the tags of a recording with buttons to remove
+// them, add the user's other tags or type a new one
+func (b *Bot) formatTagEditor(ctx context.Context, userID string, lang domain.Language, recordingID string) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	tags, err := b.service.RecordingTags(ctx, userID, recordingID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>%s</b>\n\n", b.i18n.Get(lang, "tags.title")))
+	if len(tags) == 0 {
+		text.WriteString(b.i18n.Get(lang, "tags.none"))
+	} else {
+		text.WriteString(html.EscapeString(strings.Join(tags, ", ")))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, tag := range tags {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✖️ "+tag, fmt.Sprintf("untag:%s:%d", recordingID, i)),
+		))
+	}
+	if len(tags) < domain.MaxTagsPerRecording {
+		var row []tgbotapi.InlineKeyboardButton
+		for i, tag := range b.tagChoices(ctx, userID, lang) {
+			if slices.Contains(tags, tag) {
+				continue
+			}
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("➕ "+tag, fmt.Sprintf("addtag:%s:%d", recordingID, i)))
+			if len(row) == 2 {
+				rows = append(rows, row)
+				row = nil
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "tags.new"), "newtag:"+recordingID),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "nav.back"), "viewrec:"+recordingID),
+	))
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// handleTagEditor shows the tags of a recording in place of its details
+func (b *Bot) handleTagEditor(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, recordingID string) {
+	text, keyboard, err := b.formatTagEditor(ctx, userID, lang, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting tags: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text)
+	edit.ReplyMarkup = &keyboard
+	edit.ParseMode = "HTML"
+	b.send(edit)
+}
+
+// handleTagCallback handles the buttons of the tag editor, "addtag:<id>:<i>"
+// and "untag:<id>:<i>", then shows the tags again
+func (b *Bot) handleTagCallback(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, data string, add bool) {
+	sep := strings.LastIndex(data, ":")
+	if sep < 0 {
+		return
+	}
+	recordingID := data[:sep]
+	index, err := strconv.Atoi(data[sep+1:])
+	if err != nil {
+		return
+	}
+
+	if add {
+		choices := b.tagChoices(ctx, userID, lang)
+		if index < 0 || index >= len(choices) {
+			return
+		}
+		_, err = b.service.TagRecording(ctx, userID, recordingID, choices[index])
+	} else {
+		tags, listErr := b.service.RecordingTags(ctx, userID, recordingID)
+		if listErr != nil || index < 0 || index >= len(tags) {
+			err = listErr
+		} else {
+			err = b.service.UntagRecording(ctx, userID, recordingID, tags[index])
+		}
+	}
+	if err != nil {
+		requestid.Printf(ctx, "Error tagging recording: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	b.handleTagEditor(ctx, msg, userID, lang, recordingID)
+}
+
+// handleNewTag asks the user to type a new tag for a recording
+func (b *Bot) handleNewTag(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language, recordingID string) {
+	if err := b.service.AwaitTag(ctx, scope, recordingID); err != nil {
+		requestid.Printf(ctx, "Error awaiting tag: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "tags.prompt", domain.MaxTagLength))
+}
+
+// handleTagText adds the text the user sent as the new tag they asked to
+// type, then sends the tags of the recording. It reports false if they were
+// not typing a tag.
+func (b *Bot) handleTagText(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language) bool {
+	recordingID, err := b.service.TagFromText(ctx, scope, msg.Text)
+	if recordingID == "" && err == nil {
+		return false
+	}
+	if err != nil {
+		requestid.Printf(ctx, "Error tagging recording: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return true
+	}
+
+	text, keyboard, err := b.formatTagEditor(ctx, scope.UserID, lang, recordingID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting tags: %v", err)
+		return true
+	}
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	reply.ReplyMarkup = keyboard
+	reply.ParseMode = "HTML"
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+	return true
+}
+
+// handleTagFilter shows the user's tags to filter their recordings by
+func (b *Bot) handleTagFilter(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language) {
+	tags, err := b.service.UserTags(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing tags: %v", err)
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>%s</b>\n\n", b.i18n.Get(lang, "tags.filter_title")))
+	if len(tags) == 0 {
+		text.WriteString(b.i18n.Get(lang, "tags.filter_empty"))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{b.recordingsTabRow(lang, tabTags)}
+	var row []tgbotapi.InlineKeyboardButton
+	for i, tag := range tags {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("🏷 "+tag, fmt.Sprintf("rectag:%d", i)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text.String())
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	edit.ReplyMarkup = &keyboard
+	edit.ParseMode = "HTML"
+	b.send(edit)
+}
+
+// handleTaggedList shows the user's recordings with the tag at an index of
+// their tags
+func (b *Bot) handleTaggedList(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, index int) {
+	tags, err := b.service.UserTags(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing tags: %v", err)
+		return
+	}
+	if index < 0 || index >= len(tags) {
+		b.handleTagFilter(ctx, msg, userID, lang)
+		return
+	}
+	tag := tags[index]
+
+	recordings, err := b.service.TaggedRecordings(ctx, userID, tag)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing recordings: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>🏷 %s</b>\n\n", html.EscapeString(tag)))
+	text.WriteString(fmt.Sprintf("%s: %d\n", b.i18n.Get(lang, "recordings.total"), len(recordings)))
+
+	rows := [][]tgbotapi.InlineKeyboardButton{b.recordingsTabRow(lang, tabTags)}
+	for _, rec := range recordings {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(b.recordingButton(lang, rec)))
+	}
+
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text.String())
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	edit.ReplyMarkup = &keyboard
+	edit.ParseMode = "HTML"
+	b.send(edit)
+}
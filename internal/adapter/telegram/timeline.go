@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const (
+	timelineWidth   = 800
+	timelineHeight  = 120
+	timelinePadding = 10
+)
+
+var (
+	timelineBackground = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	timelineAxis       = color.RGBA{R: 0xbd, G: 0xbd, B: 0xbd, A: 0xff}
+
+	// timelineColors matches the emoji of getOpEmoji and the legend
+	timelineColors = map[domain.OpType]color.RGBA{
+		domain.OpCorrect:      {R: 0x66, G: 0xbb, B: 0x6a, A: 0xff},
+		domain.OpSubstitution: {R: 0xff, G: 0x98, B: 0x00, A: 0xff},
+		domain.OpDeletion:     {R: 0xe5, G: 0x39, B: 0x35, A: 0xff},
+		domain.OpInsertion:    {R: 0x1e, G: 0x88, B: 0xe5, A: 0xff},
+		domain.OpBasmala:      {R: 0x8e, G: 0x24, B: 0xaa, A: 0xff},
+	}
+)
+
+// renderTimeline draws the words of a recording along its duration as a PNG,
+// using the timings of the operations: correct words and the basmala as low
+// blocks and mistakes as full-height blocks, so users see where in their recitation
+// they went wrong. ok is false if the operations carry no timings.
+func renderTimeline(ops []domain.Operation) (data []byte, ok bool) {
+	var end float64
+	for _, op := range ops {
+		end = math.Max(end, math.Max(op.TStart, op.TEnd))
+	}
+	if end <= 0 {
+		return nil, false
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, timelineWidth, timelineHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: timelineBackground}, image.Point{}, draw.Src)
+
+	width := float64(timelineWidth - 2*timelinePadding)
+	x := func(t float64) int {
+		return timelinePadding + int(math.Round(t/end*width))
+	}
+	mid := timelineHeight / 2
+
+	// Axis with a tick every second
+	fill(img, timelinePadding, mid, timelineWidth-timelinePadding, mid+1, timelineAxis)
+	for t := 0.0; t <= end; t++ {
+		fill(img, x(t), mid-4, x(t)+1, mid+5, timelineAxis)
+	}
+
+	// Correct words first, so that mistakes are drawn on top of them
+	for _, correct := range []bool{true, false} {
+		for _, op := range ops {
+			if op.Op.IsMistake() == correct {
+				continue
+			}
+			c, ok := timelineColors[op.Op]
+			if !ok {
+				continue
+			}
+
+			x0, x1 := x(op.TStart), x(op.TEnd)
+			if x1-x0 < 5 { // words without a duration, e.g. deletions
+				x0, x1 = x0-2, x0+3
+			}
+
+			top, bottom := mid-12, mid+12
+			if !correct {
+				top, bottom = timelinePadding, timelineHeight-timelinePadding
+			}
+			fill(img, x0, top, x1-1, bottom, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// fill paints the rectangle [x0, x1) x [y0, y1)
+func fill(img draw.Image, x0, y0, x1, y1 int, c color.Color) {
+	draw.Draw(img, image.Rect(x0, y0, x1, y1), &image.Uniform{C: c}, image.Point{}, draw.Src)
+}
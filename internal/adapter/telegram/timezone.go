@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sendTimezonePrompt asks the user for their time zone: their location, to
+// detect its offset, their local time, or the name of the zone
+func (b *Bot) sendTimezonePrompt(ctx context.Context, chatID int64, userID string, lang domain.Language) {
+	prefs, err := b.service.GetPreferences(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting preferences: %v", err)
+		prefs = domain.DefaultPreferences()
+	}
+
+	keyboard := tgbotapi.NewReplyKeyboard(tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButtonLocation(b.i18n.Get(lang, "timezone.share_location")),
+	))
+	keyboard.OneTimeKeyboard = true
+
+	msg := tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "timezone.prompt", timezoneName(prefs.Timezone)))
+	msg.ReplyMarkup = keyboard
+	if _, err := b.send(msg); err != nil {
+		requestid.Printf(ctx, "Error sending timezone prompt: %v", err)
+	}
+}
+
+// handleLocation suggests the time zones at the offset of a location the
+// user shared in their private chat
+func (b *Bot) handleLocation(ctx context.Context, msg *tgbotapi.Message, lang domain.Language) {
+	if !msg.Chat.IsPrivate() {
+		return
+	}
+	offset := domain.LongitudeOffset(msg.Location.Longitude)
+	b.sendTimezoneSuggestions(ctx, msg.Chat.ID, lang, offset)
+}
+
+// handleTimezoneText sets the time zone the user typed, e.g. "Europe/Moscow",
+// or suggests the zones of the local time they typed, e.g. "14:30", in
+// their private chat. It reports false for other texts.
+func (b *Bot) handleTimezoneText(ctx context.Context, msg *tgbotapi.Message, lang domain.Language) bool {
+	if !msg.Chat.IsPrivate() {
+		return false
+	}
+	text := strings.TrimSpace(msg.Text)
+	if offset, ok := b.service.LocalTimeOffset(text); ok {
+		b.sendTimezoneSuggestions(ctx, msg.Chat.ID, lang, offset)
+		return true
+	}
+	if !strings.Contains(text, "/") || !domain.ValidTimezone(text) {
+		return false
+	}
+	b.setTimezone(ctx, msg.Chat.ID, strconv.FormatInt(msg.From.ID, 10), lang, text)
+	return true
+}
+
+// sendTimezoneSuggestions offers the time zones at a UTC offset to pick
+// from
+func (b *Bot) sendTimezoneSuggestions(ctx context.Context, chatID int64, lang domain.Language, offset time.Duration) {
+	zones := b.service.SuggestTimezones(offset)
+	if len(zones) == 0 {
+		b.sendMessage(chatID, b.i18n.Get(lang, "timezone.none", formatOffset(offset)))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, zone := range zones {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(zone, "tz:"+zone),
+		))
+	}
+	msg := tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "timezone.suggest", formatOffset(offset)))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.send(msg); err != nil {
+		requestid.Printf(ctx, "Error sending timezone suggestions: %v", err)
+	}
+}
+
+// handleLocalTimePick suggests the time zones of a local time the user
+// typed, e.g. "18:10", once they told it was not an ayah
+func (b *Bot) handleLocalTimePick(ctx context.Context, msg *tgbotapi.Message, lang domain.Language, local string) {
+	offset, ok := b.service.LocalTimeOffset(local)
+	if !ok {
+		return
+	}
+	b.send(tgbotapi.NewDeleteMessage(msg.Chat.ID, msg.MessageID))
+	b.sendTimezoneSuggestions(ctx, msg.Chat.ID, lang, offset)
+}
+
+// handleTimezonePick sets the time zone picked among the suggestions
+func (b *Bot) handleTimezonePick(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, timezone string) {
+	b.send(tgbotapi.NewDeleteMessage(msg.Chat.ID, msg.MessageID))
+	b.setTimezone(ctx, msg.Chat.ID, userID, lang, timezone)
+}
+
+// setTimezone sets the user's time zone and removes the keyboard to share
+// their location
+func (b *Bot) setTimezone(ctx context.Context, chatID int64, userID string, lang domain.Language, timezone string) {
+	if _, err := b.service.SetTimezone(ctx, userID, timezone); err != nil {
+		requestid.Printf(ctx, "Error setting timezone: %v", err)
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, b.i18n.Get(lang, "timezone.set", timezone,
+		b.service.UserTime(ctx, userID, b.service.Now()).Format("15:04")))
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(false)
+	if _, err := b.send(msg); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
+
+// timezoneName returns the name of a time zone to show, UTC when unset
+func timezoneName(timezone string) string {
+	if timezone == "" {
+		return "UTC"
+	}
+	return timezone
+}
+
+// formatOffset formats a UTC offset, e.g. "UTC+3" or "UTC+5:30"
+func formatOffset(offset time.Duration) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	hours, minutes := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+	if minutes == 0 {
+		return fmt.Sprintf("UTC%s%d", sign, hours)
+	}
+	return fmt.Sprintf("UTC%s%d:%02d", sign, hours, minutes)
+}
@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/application"
+)
+
+// errTranscodeQueueFull is returned when too many jobs are already waiting
+var errTranscodeQueueFull = errors.New("transcoding queue is full")
+
+// transcodeJob downloads, converts and submits one recording
+type transcodeJob func(ctx context.Context)
+
+// transcoder runs recording jobs on a fixed pool of workers, off the update
+// handlers, so a burst of voice messages neither blocks update handling nor
+// runs more ffmpeg processes than the limit. Jobs beyond the free workers
+// wait in a FIFO queue, up to maxQueued of them.
+type transcoder struct {
+	workers   int
+	maxQueued int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []transcodeJob
+	idle   int
+	closed bool
+}
+
+func newTranscoder(workers, maxQueued int) *transcoder {
+	t := &transcoder{
+		workers:   workers,
+		maxQueued: maxQueued,
+	}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// run starts the workers and stops them once ctx is done. Queued jobs that
+// have not started by then are dropped.
+func (t *transcoder) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < t.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.work(ctx)
+		}()
+	}
+
+	<-ctx.Done()
+	t.mu.Lock()
+	t.closed = true
+	t.queue = nil
+	t.cond.Broadcast()
+	t.mu.Unlock()
+	wg.Wait()
+}
+
+// work runs queued jobs until the transcoder is closed
+func (t *transcoder) work(ctx context.Context) {
+	for {
+		t.mu.Lock()
+		t.idle++
+		for len(t.queue) == 0 && !t.closed {
+			t.cond.Wait()
+		}
+		t.idle--
+		if t.closed {
+			t.mu.Unlock()
+			return
+		}
+		job := t.queue[0]
+		t.queue = t.queue[1:]
+		t.mu.Unlock()
+
+		job(ctx)
+	}
+}
+
+// submit queues a job and returns its position in the queue, starting at 1,
+// or 0 if a worker is free to start it right away
+func (t *transcoder) submit(job transcodeJob) (position int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return 0, context.Canceled
+	}
+
+	waiting := len(t.queue) - t.idle // jobs ahead that no free worker will take
+	if waiting >= 0 && t.maxQueued > 0 && waiting >= t.maxQueued {
+		return 0, errTranscodeQueueFull
+	}
+
+	t.queue = append(t.queue, job)
+	t.cond.Signal()
+
+	if waiting < 0 {
+		return 0, nil
+	}
+	return waiting + 1, nil
+}
+
+// stats returns how many jobs are waiting and running
+func (t *transcoder) stats() application.QueueStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return application.QueueStats{
+		Queued:  max(len(t.queue)-t.idle, 0),
+		Running: t.workers - t.idle,
+		Workers: t.workers,
+	}
+}
@@ -0,0 +1,153 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/application"
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxTypedOps is the number of words shown at most in the feedback on an
+// ayah typed from memory
+const maxTypedOps = 30
+
+// commandType switches to typing ayahs from memory: the ayah waiting for a
+// recording, or the next one selected
+func (b *Bot) commandType(ctx context.Context, msg *tgbotapi.Message) {
+	scope := sessionScope(msg.From, msg.Chat)
+	lang := b.service.GetUserLanguage(ctx, scope.UserID)
+
+	if !b.service.TypingEnabled() {
+		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "type.disabled"))
+		return
+	}
+
+	state, err := b.service.GetCurrentState(ctx, scope)
+	if err == nil && state == domain.StateWaitRecording {
+		if err := b.service.SetMode(ctx, scope, domain.ModeTyped); err != nil {
+			requestid.Printf(ctx, "Error setting mode: %v", err)
+			b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+			return
+		}
+		b.sendMessage(msg.Chat.ID, b.recordingPrompt(ctx, scope, lang))
+		return
+	}
+
+	if err := b.service.HandleStart(ctx, scope, lang); err != nil {
+		requestid.Printf(ctx, "Error handling start: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	if err := b.service.SetMode(ctx, scope, domain.ModeTyped); err != nil {
+		requestid.Printf(ctx, "Error setting mode: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "type.start"))
+	b.sendSurahSelection(ctx, msg.Chat.ID, scope, lang, 0)
+}
+
+// handleTypedAyah checks the ayah the user typed from memory and replies
+// with the feedback on each word. It reports false if the user is not
+// typing the selected ayah.
+func (b *Bot) handleTypedAyah(ctx context.Context, msg *tgbotapi.Message, scope domain.SessionScope, lang domain.Language) bool {
+	if b.service.SessionMode(ctx, scope) != domain.ModeTyped {
+		return false
+	}
+	chatID := msg.Chat.ID
+
+	check, err := b.service.CheckTypedAyah(ctx, scope, msg.Text)
+	if err != nil {
+		requestid.Printf(ctx, "Error checking typed ayah: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return true
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return true
+	}
+
+	reply := tgbotapi.NewMessage(chatID, b.formatTypedCheck(lang, check))
+	reply.ParseMode = "HTML"
+	if check.Next == nil {
+		reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.continue"), "continue"),
+				tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "recording.new"), "newrecord"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "type.recite"), "recite:"+check.Ayah.String()),
+			),
+		)
+	}
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending typed ayah feedback: %v", err)
+	}
+
+	// Ask for the next ayah of a range
+	if check.Next != nil {
+		b.sendMessage(chatID, b.recordingPrompt(ctx, scope, lang))
+	}
+	return true
+}
+
+// formatTypedCheck formats the feedback on an ayah typed from memory like
+// the results of a recording: the grade, the mistakes and each word
+func (b *Bot) formatTypedCheck(lang domain.Language, check *application.TypedCheck) string {
+	result := &check.Result
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<b>⌨️ %s</b>\n\n", html.EscapeString(b.ayahLabel(lang, check.Ayah.String()))))
+	text.WriteString(fmt.Sprintf("<b>%s</b>\n", b.service.FormatGrade(lang, result.WER)))
+	if mistakes := b.service.FormatMistakes(lang, result); mistakes != "" {
+		text.WriteString(mistakes + "\n")
+	}
+	text.WriteString(fmt.Sprintf("📊 WER: <b>%.2f%%</b>\n\n", result.WER*100))
+
+	text.WriteString(fmt.Sprintf("<b>%s:</b>\n", b.i18n.Get(lang, "recording.analysis")))
+	for i, op := range result.Ops {
+		if i >= maxTypedOps {
+			text.WriteString(fmt.Sprintf("\n... (%d %s)\n", len(result.Ops)-maxTypedOps, b.i18n.Get(lang, "recording.more_words")))
+			break
+		}
+		emoji := b.getOpEmoji(op.Op)
+		switch op.Op {
+		case domain.OpSubstitution:
+			text.WriteString(fmt.Sprintf("%s <code>%s</code> %s\n", emoji, html.EscapeString(op.RefAr),
+				b.i18n.Get(lang, "type.typed", html.EscapeString(op.HypAr))))
+		case domain.OpInsertion:
+			text.WriteString(fmt.Sprintf("%s <s>%s</s>\n", emoji, html.EscapeString(op.HypAr)))
+		default:
+			text.WriteString(fmt.Sprintf("%s <code>%s</code>\n", emoji, html.EscapeString(op.RefAr)))
+		}
+	}
+	return text.String()
+}
+
+// handleRecite selects an ayah typed from memory again, to recite it aloud
+// this time
+func (b *Bot) handleRecite(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language, ayahID string) {
+	ayah, err := domain.ParseAyahID(ayahID)
+	if err == nil {
+		err = b.service.RecordAyah(ctx, scope, ayah)
+	}
+	if err == nil {
+		err = b.service.SetMode(ctx, scope, domain.ModeManual)
+	}
+	if err != nil {
+		requestid.Printf(ctx, "Error reciting typed ayah: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	b.sendMessage(chatID, b.recordingPrompt(ctx, scope, lang))
+}
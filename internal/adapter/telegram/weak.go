@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (b *Bot) commandWeak(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	weak, err := b.service.WeakAyahs(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting weak ayahs: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	if len(weak) == 0 {
+		b.sendMessage(msg.Chat.ID, b.i18n.Get(lang, "weak.empty", int(domain.WeakAyahAccuracy*100)))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(b.i18n.Get(lang, "weak.title") + "\n\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, ayah := range weak {
+		name := b.i18n.GetSurahName(lang, ayah.Ayah.SurahNumber)
+		text.WriteString(fmt.Sprintf("%d. ", i+1) + b.i18n.Get(lang, "weak.item",
+			name, ayah.Ayah.AyahNumber, ayah.Accuracy*100, ayah.Attempts) + "\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "weak.record", name, ayah.Ayah.AyahNumber),
+				"weak:"+ayah.Ayah.String()),
+		))
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text.String())
+	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
+
+// handleWeakPick selects a weak ayah, or another picked from a list, for
+// recording, keeping the list to pick the next one from
+func (b *Bot) handleWeakPick(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language, ayahID string) {
+	ayah, err := domain.ParseAyahID(ayahID)
+	if err == nil {
+		err = b.service.RecordAyah(ctx, scope, ayah)
+	}
+	if err != nil {
+		requestid.Printf(ctx, "Error selecting weak ayah: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	text := b.i18n.Get(lang, "jump.ayah", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber)
+	b.sendMessage(chatID, text+"\n\n"+b.recordingPrompt(ctx, scope, lang))
+}
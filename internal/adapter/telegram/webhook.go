@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// webhookQueueSize bounds the updates received over the webhook and not
+	// yet picked up by the update loop
+	webhookQueueSize = 100
+
+	// webhookSecretHeader carries the secret token Telegram sends with every
+	// update delivered to the webhook
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+// webhook receives updates pushed by Telegram over HTTP
+type webhook struct {
+	url     string
+	secret  string
+	updates chan tgbotapi.Update
+}
+
+// WithWebhook receives updates from Telegram at url, served by ServeHTTP,
+// instead of long polling. Replicas behind a load balancer then share the
+// updates of one bot, each delivered to a single replica.
+func WithWebhook(url string) BotOption {
+	return func(b *Bot) {
+		b.webhook = &webhook{
+			url:     url,
+			updates: make(chan tgbotapi.Update, webhookQueueSize),
+		}
+	}
+}
+
+// webhookSecret derives the secret token of the webhook from the bot token,
+// so every replica expects the same one without further configuration
+func webhookSecret(token string) string {
+	sum := sha256.Sum256([]byte("quran-read-bot webhook:" + token))
+	return hex.EncodeToString(sum[:])
+}
+
+// setWebhook registers the webhook with Telegram. Every replica registers
+// the same URL and secret, so it does not matter which one starts last.
+func (b *Bot) setWebhook() error {
+	params := tgbotapi.Params{
+		"url":          b.webhook.url,
+		"secret_token": b.webhook.secret,
+	}
+	if _, err := b.api.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("set webhook: %w", withoutURL(err))
+	}
+	return nil
+}
+
+// deleteWebhook removes a webhook left over from an earlier deployment,
+// without which long polling fails
+func (b *Bot) deleteWebhook() error {
+	if _, err := b.request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("delete webhook: %w", withoutURL(err))
+	}
+	return nil
+}
+
+// ServeHTTP receives an update Telegram delivered to the webhook. Updates
+// that do not carry the secret token are rejected. When the update loop
+// falls behind, updates are refused so Telegram delivers them again later,
+// possibly to another replica.
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if b.webhook == nil {
+		http.NotFound(w, r)
+		return
+	}
+	secret := r.Header.Get(webhookSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(b.webhook.secret)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	update, err := b.api.HandleUpdate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case b.webhook.updates <- *update:
+	default:
+		log.Printf("Webhook queue full, refusing update %d", update.UpdateID)
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}
+}
+
+// withoutURL drops the request URL from errors of the Telegram API, which
+// contains the bot token
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/application"
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// wirdSendInterval is how often subscriptions are checked for a due
+	// ayah of the day
+	wirdSendInterval = time.Hour
+
+	// wirdLockTTL bounds how long a sent ayah of the day is remembered
+	wirdLockTTL = 48 * time.Hour
+)
+
+func (b *Bot) commandWird(ctx context.Context, msg *tgbotapi.Message) {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	lang := b.service.GetUserLanguage(ctx, userID)
+
+	text, keyboard, err := b.formatWird(ctx, userID, lang)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting wird: %v", err)
+		b.sendMessage(msg.Chat.ID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	reply.ReplyMarkup = keyboard
+	if _, err := b.send(reply); err != nil {
+		requestid.Printf(ctx, "Error sending message: %v", err)
+	}
+}
+
+// formatWird formats the user's daily wird subscription with a keyboard to
+// pick a plan or stop it
+func (b *Bot) formatWird(ctx context.Context, userID string, lang domain.Language) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	wird, err := b.service.Wird(ctx, userID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var text strings.Builder
+	text.WriteString(b.i18n.Get(lang, "wird.title") + "\n\n")
+	if wird == nil {
+		text.WriteString(b.i18n.Get(lang, "wird.off"))
+	} else {
+		text.WriteString(b.i18n.Get(lang, "wird.on", b.i18n.Get(lang, "wird.plan."+string(wird.Plan))))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, plan := range domain.WirdPlans {
+		label := b.i18n.Get(lang, "wird.plan."+string(plan))
+		if wird != nil && wird.Plan == plan {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "wirdplan:"+string(plan)),
+		))
+	}
+	if wird != nil {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "wird.stop"), "wirdstop"),
+		))
+	}
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// handleWirdPlan subscribes the user to the daily wird with the plan picked
+func (b *Bot) handleWirdPlan(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language, plan domain.WirdPlan) {
+	if _, err := b.service.StartWird(ctx, userID, plan); err != nil {
+		requestid.Printf(ctx, "Error starting wird: %v", err)
+		b.editMessageText(msg, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	b.refreshWird(ctx, msg, userID, lang)
+}
+
+// handleWirdStop unsubscribes the user from the daily wird
+func (b *Bot) handleWirdStop(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language) {
+	if err := b.service.StopWird(ctx, userID); err != nil {
+		requestid.Printf(ctx, "Error stopping wird: %v", err)
+	}
+	b.refreshWird(ctx, msg, userID, lang)
+}
+
+// refreshWird shows the user's subscription in place of the message
+func (b *Bot) refreshWird(ctx context.Context, msg *tgbotapi.Message, userID string, lang domain.Language) {
+	text, keyboard, err := b.formatWird(ctx, userID, lang)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting wird: %v", err)
+		return
+	}
+	b.editMessageWithKeyboard(msg, text, keyboard)
+}
+
+// handleWirdRecord selects the ayah of a wird, e.g. "2:255", ready to be
+// recorded
+func (b *Bot) handleWirdRecord(ctx context.Context, chatID int64, scope domain.SessionScope, lang domain.Language, ayahID string) {
+	ayah, err := domain.ParseAyahID(ayahID)
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing wird ayah: %v", err)
+		return
+	}
+	if err := b.service.RecordAyah(ctx, scope, ayah); err != nil {
+		requestid.Printf(ctx, "Error selecting wird ayah: %v", err)
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			b.restartFlow(ctx, chatID, scope, lang, err)
+			return
+		}
+		b.sendMessage(chatID, b.errorMessage(ctx, lang, err, "error.generic"))
+		return
+	}
+	b.sendMessage(chatID, b.recordingPrompt(ctx, scope, lang))
+}
+
+// sendWirds periodically sends subscribed users the ayah of their day,
+// until ctx is done
+func (b *Bot) sendWirds(ctx context.Context) {
+	ticker := time.NewTicker(wirdSendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		due, err := b.service.WirdsDue(ctx)
+		if err != nil {
+			requestid.Printf(ctx, "Error listing due wirds: %v", err)
+			continue
+		}
+		for _, wird := range due {
+			b.sendWird(ctx, wird)
+		}
+	}
+}
+
+// sendWird sends the ayah of the day to the user's private chat, with its
+// reference recitation when enabled, once across replicas
+func (b *Bot) sendWird(ctx context.Context, daily application.DailyWird) {
+	userID := daily.Wird.UserID
+	lock := fmt.Sprintf("wird:%s:%s", userID, daily.Day.Format(time.DateOnly))
+	if !b.acquireLock(ctx, lock, wirdLockTTL) {
+		return
+	}
+
+	chatID, err := b.chatID(domain.PrivateScope(userID))
+	if err != nil {
+		requestid.Printf(ctx, "Error parsing user ID %s: %v", userID, err)
+		return
+	}
+
+	lang := b.service.GetUserLanguage(ctx, userID)
+	ayah := daily.Ayah
+	text := b.i18n.Get(lang, "wird.message", b.i18n.GetSurahName(lang, ayah.SurahNumber), ayah.AyahNumber,
+		b.i18n.Get(lang, "wird.plan."+string(daily.Wird.Plan)))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.i18n.Get(lang, "wird.record"), "wirdrec:"+ayah.String()),
+	))
+
+	if _, err := b.send(b.wirdMessage(ctx, chatID, userID, ayah, text, keyboard)); err != nil {
+		requestid.Printf(ctx, "Error sending wird to %s: %v", userID, err)
+		b.releaseLock(ctx, lock)
+		return
+	}
+	if err := b.service.MarkWirdSent(ctx, daily); err != nil {
+		requestid.Printf(ctx, "Error marking wird of %s sent: %v", userID, err)
+	}
+}
+
+// wirdMessage returns the message of the ayah of the day: the reference
+// recitation captioned with text, or text alone without reference audio
+func (b *Bot) wirdMessage(ctx context.Context, chatID int64, userID string, ayah domain.Ayah, text string, keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.Chattable {
+	if b.referenceURL == "" {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = keyboard
+		return msg
+	}
+
+	prefs, err := b.service.GetPreferences(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting preferences: %v", err)
+		prefs = domain.DefaultPreferences()
+	}
+	ayahID := domain.FormatAyahID(ayah.SurahNumber, ayah.AyahNumber)
+	audio := tgbotapi.NewAudio(chatID, tgbotapi.FileURL(b.referenceAudioURL(prefs.Reciter, prefs.Riwayah, ayahID)))
+	audio.Caption = text
+	audio.ReplyMarkup = keyboard
+	return audio
+}
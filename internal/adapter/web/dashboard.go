@@ -0,0 +1,266 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/application"
+)
+
+// maxBroadcastLength is the longest text Telegram accepts in a message
+const maxBroadcastLength = 4096
+
+// Options configures the dashboard server
+type Options struct {
+	Addr     string // e.g. ":8081"
+	Username string
+	Password string
+}
+
+// broadcastStatus is the progress of the latest broadcast
+type broadcastStatus struct {
+	Started  time.Time
+	Targets  int
+	Sent     int
+	Failed   int
+	Running  bool
+	Error    string
+	Finished time.Time
+}
+
+// Dashboard is a small web UI for operators: active users, error rates,
+// queue depth and recent recordings, plus a form to broadcast a message to
+// active users. Every page is behind HTTP basic auth.
+type Dashboard struct {
+	opts    Options
+	service *application.BotService
+	bot     application.Messenger
+
+	mu        sync.Mutex
+	broadcast *broadcastStatus
+}
+
+func NewDashboard(opts Options, service *application.BotService, bot application.Messenger) *Dashboard {
+	return &Dashboard{
+		opts:    opts,
+		service: service,
+		bot:     bot,
+	}
+}
+
+// Run serves the dashboard until ctx is done
+func (d *Dashboard) Run(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.handleIndex)
+	mux.HandleFunc("POST /broadcast", func(w http.ResponseWriter, r *http.Request) {
+		d.handleBroadcast(ctx, w, r)
+	})
+
+	server := &http.Server{
+		Addr:              d.opts.Addr,
+		Handler:           d.authenticate(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("Dashboard listening on %s", d.opts.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Error serving dashboard: %v", err)
+	}
+}
+
+// authenticate lets through requests with the configured credentials
+func (d *Dashboard) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(username), []byte(d.opts.Username)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(password), []byte(d.opts.Password)) == 1
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dashboard", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *Dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
+	overview, err := d.service.Overview(r.Context())
+	if err != nil {
+		log.Printf("Error building dashboard overview: %v", err)
+		http.Error(w, "failed to load overview", http.StatusInternalServerError)
+		return
+	}
+
+	d.mu.Lock()
+	var broadcast *broadcastStatus
+	if d.broadcast != nil {
+		status := *d.broadcast
+		broadcast = &status
+	}
+	d.mu.Unlock()
+
+	data := struct {
+		Overview  *application.Overview
+		Queue     application.QueueStats
+		Broadcast *broadcastStatus
+		Now       time.Time
+	}{overview, d.bot.QueueStats(), broadcast, d.service.Now()}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering dashboard: %v", err)
+	}
+}
+
+// handleBroadcast starts sending a message to the users active over the
+// last given number of days. It runs in the background, bound to the
+// server's lifetime rather than the request, and only one at a time.
+func (d *Dashboard) handleBroadcast(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	// Basic auth credentials are sent by the browser along with cross-site
+	// form posts, so reject them
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request", http.StatusForbidden)
+		return
+	}
+
+	text := strings.TrimSpace(r.FormValue("text"))
+	if text == "" || len([]rune(text)) > maxBroadcastLength {
+		http.Error(w, "message must be between 1 and 4096 characters", http.StatusBadRequest)
+		return
+	}
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil || days < 1 || days > 180 {
+		http.Error(w, "days must be between 1 and 180", http.StatusBadRequest)
+		return
+	}
+
+	userIDs, err := d.service.BroadcastTargets(r.Context(), d.service.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("Error listing broadcast targets: %v", err)
+		http.Error(w, "failed to list active users", http.StatusInternalServerError)
+		return
+	}
+
+	d.mu.Lock()
+	if d.broadcast != nil && d.broadcast.Running {
+		d.mu.Unlock()
+		http.Error(w, "a broadcast is already running", http.StatusConflict)
+		return
+	}
+	d.broadcast = &broadcastStatus{Started: d.service.Now(), Targets: len(userIDs), Running: true}
+	d.mu.Unlock()
+
+	log.Printf("Broadcasting to %d users active over the last %d days", len(userIDs), days)
+	go func() {
+		sent, failed := d.bot.Broadcast(ctx, userIDs, text)
+		log.Printf("Broadcast finished: %d sent, %d failed", sent, failed)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.broadcast.Sent, d.broadcast.Failed = sent, failed
+		d.broadcast.Running = false
+		d.broadcast.Finished = d.service.Now()
+		if ctx.Err() != nil {
+			d.broadcast.Error = "interrupted by shutdown"
+		}
+	}()
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// sameOrigin reports whether a request was sent from a page of the
+// dashboard, as told by its Origin header or, when browsers leave it out,
+// its Referer. Requests with neither are refused, as their origin is
+// unknown.
+func sameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" || source == "null" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		return false
+	}
+	u, err := url.Parse(source)
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"percent": func(rate float64) string {
+		return strconv.FormatFloat(rate*100, 'f', 1, 64) + "%"
+	},
+	"count": func(n int) string {
+		if n < 0 {
+			return "n/a"
+		}
+		return strconv.Itoa(n)
+	},
+	"ago": func(now, t time.Time) string {
+		return now.Sub(t).Round(time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Quran Recite Bot</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: .3em .8em; text-align: left; }
+textarea { width: 100%; height: 6em; }
+</style>
+</head>
+<body>
+<h1>Quran Recite Bot</h1>
+
+<h2>Active users</h2>
+<table>
+<tr><th>Last 24 hours</th><th>Last 7 days</th><th>Last 30 days</th></tr>
+<tr><td>{{count .Overview.ActiveDay}}</td><td>{{count .Overview.ActiveWeek}}</td><td>{{count .Overview.ActiveMonth}}</td></tr>
+</table>
+
+<h2>Recording queue</h2>
+<table>
+<tr><th>Queued</th><th>Running</th><th>Workers</th></tr>
+<tr><td>{{.Queue.Queued}}</td><td>{{.Queue.Running}}</td><td>{{.Queue.Workers}}</td></tr>
+</table>
+
+<h2>Errors over the last hour</h2>
+<p>Figures of the instance serving this page.</p>
+<table>
+<tr><th>Operation</th><th>Total</th><th>Failed</th><th>Error rate</th></tr>
+{{range .Overview.Operations}}<tr><td>{{.Operation}}</td><td>{{.Total}}</td><td>{{.Failed}}</td><td>{{percent .ErrorRate}}</td></tr>
+{{end}}</table>
+<p>Panics recovered from: {{.Overview.Panics}}</p>
+
+<h2>Recent recordings</h2>
+{{if .Overview.Recent}}<table>
+<tr><th>User</th><th>Ayah</th><th>Recording</th><th>Submitted</th></tr>
+{{range .Overview.Recent}}<tr><td>{{.UserID}}</td><td>{{.AyahID}}</td><td>{{.RecordingID}}</td><td>{{ago $.Now .At}} ago</td></tr>
+{{end}}</table>{{else}}<p>None yet.</p>{{end}}
+
+<h2>Broadcast</h2>
+{{with .Broadcast}}<p>
+{{if .Running}}Sending to {{.Targets}} users, started {{ago $.Now .Started}} ago.
+{{else}}Last broadcast: {{.Sent}} of {{.Targets}} sent, {{.Failed}} failed{{if .Error}} ({{.Error}}){{end}}, finished {{ago $.Now .Finished}} ago.{{end}}
+</p>{{end}}
+<form method="post" action="/broadcast">
+<p><textarea name="text" maxlength="4096" required></textarea></p>
+<p>To users active over the last <input type="number" name="days" value="30" min="1" max="180"> days
+<button type="submit">Send</button></p>
+</form>
+</body>
+</html>
+`))
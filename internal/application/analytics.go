@@ -0,0 +1,138 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const (
+	// analyticsBuffer is how many events wait to be written before new ones
+	// are dropped, so a slow sink never holds up users
+	analyticsBuffer = 1024
+
+	// analyticsBatch is the most events written at once
+	analyticsBatch = 100
+
+	// analyticsFlushInterval is how long events wait for a batch to fill
+	analyticsFlushInterval = 5 * time.Second
+
+	// analyticsFlushTimeout bounds each write to the sink
+	analyticsFlushTimeout = 10 * time.Second
+)
+
+// WithAnalytics emits usage events (commands, modes, funnel steps, abandoned
+// flows and result accuracy) to sink. Events are written in batches by
+// RunAnalytics.
+func WithAnalytics(sink domain.AnalyticsSinkPort) Option {
+	return func(s *BotService) {
+		s.analytics = sink
+		s.events = make(chan domain.AnalyticsEvent, analyticsBuffer)
+		s.hooks = append(s.hooks, s.trackTransition)
+	}
+}
+
+// TrackCommand records that the user used a command
+func (s *BotService) TrackCommand(userID, command string) {
+	s.track(userID, domain.EventCommand, map[string]string{"command": command})
+}
+
+// RunAnalytics writes events to the sink in batches until ctx is done, then
+// writes the ones still waiting. It returns right away if analytics is
+// disabled.
+func (s *BotService) RunAnalytics(ctx context.Context) {
+	if s.analytics == nil {
+		return
+	}
+
+	ticker := time.NewTicker(analyticsFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]domain.AnalyticsEvent, 0, analyticsBatch)
+	for {
+		select {
+		case event := <-s.events:
+			batch = append(batch, event)
+			if len(batch) < analyticsBatch {
+				continue
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			for len(s.events) > 0 && len(batch) < cap(batch) {
+				batch = append(batch, <-s.events)
+			}
+			s.writeEvents(context.Background(), batch)
+			return
+		}
+		s.writeEvents(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// writeEvents writes a batch to the sink. Analytics is best-effort: failed
+// batches are logged and dropped.
+func (s *BotService) writeEvents(ctx context.Context, batch []domain.AnalyticsEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyticsFlushTimeout)
+	defer cancel()
+
+	if err := s.analytics.WriteEvents(ctx, batch); err != nil {
+		log.Printf("Error writing %d analytics events: %v", len(batch), err)
+	}
+}
+
+// track queues an event, dropping it if the buffer is full
+func (s *BotService) track(userID string, name domain.AnalyticsEventName, props map[string]string) {
+	if s.analytics == nil {
+		return
+	}
+
+	event := domain.AnalyticsEvent{
+		Name:       name,
+		UserID:     analyticsUserID(userID),
+		At:         s.clock.Now().UTC(),
+		Properties: props,
+	}
+	select {
+	case s.events <- event:
+	default:
+		log.Printf("Analytics buffer full, dropping %s event", name)
+	}
+}
+
+// trackTransition is a TransitionHook recording every step of the funnel
+func (s *BotService) trackTransition(ctx context.Context, scope domain.SessionScope, from, to domain.State) {
+	if from == to {
+		return
+	}
+	s.track(scope.UserID, domain.EventFunnelStep, map[string]string{"from": string(from), "to": string(to)})
+}
+
+// trackResult records the accuracy of a finished recording, in buckets of
+// 10 points
+func (s *BotService) trackResult(userID string, recording *domain.Recording) {
+	stat, ok := recording.Stat()
+	if !ok {
+		return
+	}
+	bucket := min(int(stat.Accuracy*100)/10*10, 90)
+	s.track(userID, domain.EventResult, map[string]string{
+		"recording_id": recording.ID,
+		"accuracy":     strconv.Itoa(bucket) + "-" + strconv.Itoa(bucket+10),
+	})
+}
+
+// analyticsUserID derives the pseudonymous ID events carry instead of the
+// Telegram user ID
+func analyticsUserID(userID string) string {
+	sum := sha256.Sum256([]byte("quran-read-bot:" + userID))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,146 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// WithAudioProcessor sets the processor preparing recordings for analysis
+func WithAudioProcessor(audio domain.AudioProcessorPort) Option {
+	return func(s *BotService) {
+		s.audio = audio
+	}
+}
+
+// PrepareAudio downloads and converts a recording received by a chat
+// adapter, applying the user's audio settings, so it can be passed to
+// HandleRecording
+func (s *BotService) PrepareAudio(ctx context.Context, userID string, src domain.AudioSource) (io.Reader, domain.AudioMetadata, error) {
+	if s.audio == nil {
+		return nil, domain.AudioMetadata{}, errors.New("no audio processor configured")
+	}
+
+	prefs, err := s.GetPreferences(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting preferences: %v", err)
+		prefs = domain.DefaultPreferences()
+	}
+
+	data, meta, err := s.audio.PrepareAudio(ctx, src, domain.AudioProcessOptions{Enhance: prefs.EnhanceAudio})
+	s.monitor.Record(OpConversion, err, s.clock.Now())
+	if err != nil {
+		return nil, domain.AudioMetadata{}, fmt.Errorf("prepare audio: %w", err)
+	}
+	return bytes.NewReader(data), meta, nil
+}
+
+// SplitRecording cuts a prepared recording into chunks of at most
+// maxDuration at pauses in the recitation, for submission one by one. The
+// chunks' results are put back together with MergeChunkResults.
+func (s *BotService) SplitRecording(ctx context.Context, data []byte, meta domain.AudioMetadata, maxDuration time.Duration) ([]domain.AudioChunk, error) {
+	if s.audio == nil {
+		return nil, errors.New("no audio processor configured")
+	}
+
+	chunks, err := s.audio.SplitAudio(ctx, data, meta, maxDuration)
+	if err != nil {
+		return nil, fmt.Errorf("split audio: %w", err)
+	}
+	return chunks, nil
+}
+
+// VoiceAudio converts archived audio to OGG/Opus for playback as a voice
+// message
+func (s *BotService) VoiceAudio(ctx context.Context, audio *domain.ArchivedAudio) ([]byte, error) {
+	if s.audio == nil {
+		return nil, errors.New("no audio processor configured")
+	}
+	return s.audio.EncodeVoice(ctx, audio.Data, audio.Meta.Format)
+}
+
+// WithAudioCache keeps the audio of each user's latest submission so it can
+// be resubmitted without recording again
+func WithAudioCache(cache domain.AudioCachePort) Option {
+	return func(s *BotService) {
+		s.audioCache = cache
+	}
+}
+
+// WithAudioArchive keeps the audio of every submission in long-term storage
+func WithAudioArchive(archive domain.AudioArchivePort) Option {
+	return func(s *BotService) {
+		s.archive = archive
+	}
+}
+
+// LatestAudio returns the audio of the user's latest submission, or nil if
+// it is not cached (anymore)
+func (s *BotService) LatestAudio(ctx context.Context, userID string) (*domain.CachedAudio, error) {
+	if s.audioCache == nil {
+		return nil, nil
+	}
+
+	audio, err := s.audioCache.GetAudio(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get cached audio: %w", err)
+	}
+	return audio, nil
+}
+
+// AudioArchiveEnabled reports whether submitted audio is archived and can be
+// played back
+func (s *BotService) AudioArchiveEnabled() bool {
+	return s.archive != nil
+}
+
+// RecordingAudio returns the archived audio of one of the user's recordings,
+// or nil if it was not archived
+func (s *BotService) RecordingAudio(ctx context.Context, userID, recordingID string) (*domain.ArchivedAudio, error) {
+	if s.archive == nil {
+		return nil, nil
+	}
+
+	audio, err := s.archive.GetRecordingAudio(ctx, userID, recordingID)
+	if err != nil {
+		return nil, fmt.Errorf("get archived audio: %w", err)
+	}
+	return audio, nil
+}
+
+// cacheAudio stores the audio of a submission. Failures are only logged: the
+// cache is a convenience and must not block the submission.
+func (s *BotService) cacheAudio(ctx context.Context, userID, ayahID string, data []byte, meta domain.AudioMetadata) {
+	if s.audioCache == nil {
+		return
+	}
+
+	audio := domain.CachedAudio{
+		AyahID:   ayahID,
+		Data:     data,
+		Meta:     meta,
+		StoredAt: s.clock.Now(),
+	}
+	if err := s.audioCache.PutAudio(ctx, userID, audio); err != nil {
+		requestid.Printf(ctx, "Error caching audio of user %s: %v", userID, err)
+	}
+}
+
+// archiveAudio stores the audio of a submitted recording. Like cacheAudio,
+// failures are only logged.
+func (s *BotService) archiveAudio(ctx context.Context, userID, recordingID string, data []byte, meta domain.AudioMetadata) {
+	if s.archive == nil {
+		return
+	}
+
+	audio := domain.ArchivedAudio{Data: data, Meta: meta}
+	if err := s.archive.PutRecordingAudio(ctx, userID, recordingID, audio); err != nil {
+		requestid.Printf(ctx, "Error archiving audio of recording %s: %v", recordingID, err)
+	}
+}
@@ -0,0 +1,86 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// adminAuditLimit is the number of audit entries shown to admins
+const adminAuditLimit = 30
+
+// WithAuditLog records every state transition and notable action in a
+// per-user audit log
+func WithAuditLog(audit domain.AuditLogPort) Option {
+	return func(s *BotService) {
+		s.audit = audit
+		s.hooks = append(s.hooks, s.auditTransition)
+	}
+}
+
+// WithAdmins grants the given users access to the admin commands
+func WithAdmins(userIDs ...string) Option {
+	return func(s *BotService) {
+		if s.admins == nil {
+			s.admins = make(map[string]bool)
+		}
+		for _, id := range userIDs {
+			s.admins[id] = true
+		}
+	}
+}
+
+// IsAdmin reports whether the user may use the admin commands
+func (s *BotService) IsAdmin(userID string) bool {
+	return s.admins[userID]
+}
+
+// UserReport is what admins see about a user when diagnosing a report
+type UserReport struct {
+	Session *domain.Session
+	Audit   []domain.AuditEntry // newest first
+}
+
+// InspectUser returns the current private session and latest audit entries
+// of a user
+func (s *BotService) InspectUser(ctx context.Context, userID string) (*UserReport, error) {
+	session, err := s.fsm.GetSession(ctx, domain.PrivateScope(userID))
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+
+	report := &UserReport{Session: session}
+	if s.audit != nil {
+		report.Audit, err = s.audit.ListAudit(ctx, userID, adminAuditLimit)
+		if err != nil {
+			return nil, fmt.Errorf("list audit entries: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// auditTransition is a TransitionHook adding every transition to the log
+func (s *BotService) auditTransition(ctx context.Context, scope domain.SessionScope, from, to domain.State) {
+	s.recordAudit(ctx, scope.UserID, domain.AuditEntry{
+		Action: domain.AuditTransition,
+		ChatID: scope.ChatID,
+		From:   from,
+		To:     to,
+	})
+}
+
+// recordAudit appends an entry to the user's audit log. Auditing is
+// best-effort and never fails the caller.
+func (s *BotService) recordAudit(ctx context.Context, userID string, entry domain.AuditEntry) {
+	if s.audit == nil {
+		return
+	}
+	if entry.At.IsZero() {
+		entry.At = s.clock.Now().UTC()
+	}
+	if err := s.audit.AppendAudit(ctx, userID, entry); err != nil {
+		requestid.Printf(ctx, "Error auditing %s of user %s: %v", entry.Action, userID, err)
+	}
+}
@@ -0,0 +1,40 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// rangeClockSkew is how much earlier than the start of a range its
+// recordings may be dated by the API, whose clock is not the bot's
+const rangeClockSkew = time.Minute
+
+// RangeRecordings returns the latest recording of each ayah of a range
+// recorded in manual mode since a time, in the order of the range. Ayahs
+// without one, e.g. whose recording is not listed yet, are nil.
+func (s *BotService) RangeRecordings(ctx context.Context, userID string, ayahs domain.AyahRange, since time.Time) ([]*domain.RecordingSummary, error) {
+	summaries, err := s.ListRecordings(ctx, userID, recordingsCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := ayahs.AyahIDs(s.preferences(ctx, userID).Riwayah)
+	index := make(map[string]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+
+	list := make([]*domain.RecordingSummary, len(ids))
+	for _, summary := range summaries {
+		i, ok := index[summary.AyahID]
+		if !ok || summary.CreatedAt.Before(since.Add(-rangeClockSkew)) {
+			continue
+		}
+		if list[i] == nil || summary.CreatedAt.After(list[i].CreatedAt) {
+			list[i] = &summary
+		}
+	}
+	return list, nil
+}
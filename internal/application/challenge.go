@@ -0,0 +1,166 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// challengeTTL is how long friends can take a challenge, and how long their
+// attempts are matched with it
+const challengeTTL = 7 * 24 * time.Hour
+
+var errChallengesDisabled = errors.New("challenges are not kept")
+
+// WithChallenges lets users challenge friends to recite an ayah better than
+// they did
+func WithChallenges(challenges domain.ChallengePort) Option {
+	return func(s *BotService) {
+		s.challenges = challenges
+	}
+}
+
+// ChallengesEnabled reports whether users can challenge friends
+func (s *BotService) ChallengesEnabled() bool {
+	return s.challenges != nil
+}
+
+// CreateChallenge challenges friends to recite the ayah of one of the user's
+// analyzed recordings
+func (s *BotService) CreateChallenge(ctx context.Context, userID, recordingID string) (*domain.Challenge, error) {
+	if s.challenges == nil {
+		return nil, errChallengesDisabled
+	}
+	recording, err := s.GetRecording(ctx, userID, recordingID)
+	if err != nil {
+		return nil, err
+	}
+	if recording.Result == nil {
+		return nil, fmt.Errorf("%w: recording %s is not analyzed", domain.ErrNotComparable, recordingID)
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	challenge := domain.Challenge{
+		ID:          hex.EncodeToString(id),
+		UserID:      userID,
+		RecordingID: recording.ID,
+		AyahID:      recording.AyahID,
+		CreatedAt:   s.clock.Now(),
+	}
+	if err := s.challenges.SaveChallenge(ctx, challenge, challengeTTL); err != nil {
+		return nil, fmt.Errorf("save challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// AcceptChallenge selects the ayah of a challenge for the user to record.
+// It fails with domain.ErrChallengeNotFound for unknown or expired
+// challenges, and domain.ErrOwnChallenge for the challenger.
+func (s *BotService) AcceptChallenge(ctx context.Context, scope domain.SessionScope, id string) (*domain.Challenge, error) {
+	if s.challenges == nil {
+		return nil, domain.ErrChallengeNotFound
+	}
+	challenge, err := s.challenges.GetChallenge(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID == scope.UserID {
+		return nil, domain.ErrOwnChallenge
+	}
+	ayah, err := domain.ParseAyahID(challenge.AyahID)
+	if err != nil {
+		return nil, err
+	}
+
+	flow := domain.ManualRecordingFlow{SurahNumber: ayah.SurahNumber, AyahNumber: ayah.AyahNumber, ChallengeID: id}
+	if err := s.selectAyah(ctx, scope, flow); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// ChallengeOutcome is how a friend's attempt at a challenge went against
+// the challenger's
+type ChallengeOutcome struct {
+	Challenge domain.Challenge
+	Answer    domain.ChallengeAnswer
+
+	// Display names, empty when unknown
+	ChallengerName string
+	FriendName     string
+
+	// Accuracy of each attempt, 0..1
+	ChallengerAccuracy float64
+	FriendAccuracy     float64
+}
+
+// ChallengeOutcome returns the outcome of the challenge a finished recording
+// of the user is an attempt at, or nil if it is none
+func (s *BotService) ChallengeOutcome(ctx context.Context, userID, recordingID string) (*ChallengeOutcome, error) {
+	if s.challenges == nil {
+		return nil, nil
+	}
+	answer, err := s.challenges.GetAnswer(ctx, recordingID)
+	if err != nil {
+		return nil, fmt.Errorf("get challenge answer: %w", err)
+	}
+	if answer == nil || answer.UserID != userID {
+		return nil, nil
+	}
+	challenge, err := s.challenges.GetChallenge(ctx, answer.ChallengeID)
+	if err != nil {
+		return nil, fmt.Errorf("get challenge: %w", err)
+	}
+
+	theirs, err := s.GetRecording(ctx, challenge.UserID, challenge.RecordingID)
+	if err != nil {
+		return nil, fmt.Errorf("get challenger recording: %w", err)
+	}
+	ours, err := s.GetRecording(ctx, userID, recordingID)
+	if err != nil {
+		return nil, fmt.Errorf("get recording: %w", err)
+	}
+	if theirs.Result == nil || ours.Result == nil {
+		return nil, fmt.Errorf("%w: challenge %s", domain.ErrNotComparable, challenge.ID)
+	}
+
+	return &ChallengeOutcome{
+		Challenge:          *challenge,
+		Answer:             *answer,
+		ChallengerName:     s.displayName(ctx, challenge.UserID),
+		FriendName:         s.displayName(ctx, userID),
+		ChallengerAccuracy: domain.Accuracy(theirs.Result.WER),
+		FriendAccuracy:     domain.Accuracy(ours.Result.WER),
+	}, nil
+}
+
+// answerChallenge records that a recording is an attempt at a challenge.
+// Challenges are best-effort and never fail the caller.
+func (s *BotService) answerChallenge(ctx context.Context, answer domain.ChallengeAnswer) {
+	if s.challenges == nil {
+		return
+	}
+	if err := s.challenges.SaveAnswer(ctx, answer, challengeTTL); err != nil {
+		requestid.Printf(ctx, "Error saving answer to challenge %s: %v", answer.ChallengeID, err)
+	}
+}
+
+// displayName returns the display name of a learner, or an empty string if
+// it is unknown
+func (s *BotService) displayName(ctx context.Context, userID string) string {
+	learner, err := s.Learner(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrUserNotFound) {
+			requestid.Printf(ctx, "Error getting learner %s: %v", userID, err)
+		}
+		return ""
+	}
+	return learner.DisplayName
+}
@@ -0,0 +1,204 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// WithChunkedSubmission splits recordings longer than maxDuration at pauses
+// in the recitation and submits each chunk as a recording of its own,
+// which the API analyses more accurately than a long one. The chunks are
+// remembered in chunks under the recording of the first one, which then
+// stands for the whole recording with the results of the chunks merged. A
+// zero maxDuration submits recordings whole but still merges the chunks of
+// those submitted before.
+func WithChunkedSubmission(maxDuration time.Duration, chunks domain.RecordingChunkPort) Option {
+	return func(s *BotService) {
+		s.chunkDuration = maxDuration
+		s.chunks = chunks
+	}
+}
+
+// splits reports whether a recording is submitted in chunks
+func (s *BotService) splits(meta domain.AudioMetadata) bool {
+	return s.chunks != nil && s.audio != nil && s.chunkDuration > 0 && meta.Duration > s.chunkDuration
+}
+
+// submitRecording submits a recording for analysis, in chunks if it is
+// long. data is the audio read from audioFile when it was kept.
+func (s *BotService) submitRecording(ctx context.Context, userID, ayahID string, riwayah domain.Riwayah, audioFile io.Reader, data []byte, meta domain.AudioMetadata) (*domain.Recording, error) {
+	if !s.splits(meta) {
+		return s.quranAPI.SubmitRecording(ctx, userID, ayahID, riwayah, audioFile, meta)
+	}
+
+	parts, err := s.SplitRecording(ctx, data, meta, s.chunkDuration)
+	if err != nil || len(parts) < 2 {
+		if err != nil {
+			requestid.Printf(ctx, "Error splitting recording, submitting it whole: %v", err)
+		}
+		return s.quranAPI.SubmitRecording(ctx, userID, ayahID, riwayah, bytes.NewReader(data), meta)
+	}
+
+	var first *domain.Recording
+	chunks := make([]domain.RecordingChunk, 0, len(parts))
+	for _, part := range parts {
+		partMeta := meta
+		partMeta.Duration = part.Duration
+		recording, err := s.quranAPI.SubmitRecording(ctx, userID, ayahID, riwayah, bytes.NewReader(part.Data), partMeta)
+		if err != nil {
+			s.deleteChunks(ctx, userID, chunks)
+			return nil, fmt.Errorf("submit chunk at %s: %w", part.Offset, err)
+		}
+		if first == nil {
+			first = recording
+		}
+		chunks = append(chunks, domain.RecordingChunk{RecordingID: recording.ID, Offset: part.Offset, Duration: part.Duration})
+	}
+
+	if err := s.chunks.SetChunks(ctx, userID, first.ID, chunks); err != nil {
+		s.deleteChunks(ctx, userID, chunks)
+		return nil, fmt.Errorf("store chunks: %w", err)
+	}
+	requestid.Printf(ctx, "Submitted recording %s in %d chunks", first.ID, len(chunks))
+	return first, nil
+}
+
+// deleteChunks deletes the recordings of chunks submitted before the
+// submission of the whole recording failed. Failures are only logged.
+func (s *BotService) deleteChunks(ctx context.Context, userID string, chunks []domain.RecordingChunk) {
+	ctx = context.WithoutCancel(ctx)
+	for _, chunk := range chunks {
+		if err := s.quranAPI.DeleteRecording(ctx, userID, chunk.RecordingID); err != nil {
+			requestid.Printf(ctx, "Error deleting chunk recording %s: %v", chunk.RecordingID, err)
+		}
+	}
+}
+
+// chunkedAPI wraps a QuranAPIPort so that a recording submitted in chunks
+// reads as the single recording of its first chunk: its status and result
+// are those of all its chunks merged, the other chunks are hidden from lists,
+// and deleting it deletes every chunk.
+type chunkedAPI struct {
+	domain.QuranAPIPort
+	chunks domain.RecordingChunkPort
+}
+
+// GetRecording retrieves a recording by ID, merged with its other chunks
+func (a *chunkedAPI) GetRecording(ctx context.Context, learnerID, recordingID string) (*domain.Recording, error) {
+	split, err := a.chunks.ListChunks(ctx, learnerID)
+	if err != nil {
+		return nil, fmt.Errorf("list chunks: %w", err)
+	}
+	chunks, ok := split[recordingID]
+	if !ok {
+		return a.QuranAPIPort.GetRecording(ctx, learnerID, recordingID)
+	}
+	return a.merge(ctx, learnerID, chunks, nil)
+}
+
+// ListRecordings lists the learner's recordings, a recording submitted in
+// chunks counting once
+func (a *chunkedAPI) ListRecordings(ctx context.Context, learnerID string, limit int) ([]*domain.Recording, error) {
+	recordings, err := a.QuranAPIPort.ListRecordings(ctx, learnerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	split, err := a.chunks.ListChunks(ctx, learnerID)
+	if err != nil {
+		return nil, fmt.Errorf("list chunks: %w", err)
+	}
+	if len(split) == 0 {
+		return recordings, nil
+	}
+
+	listed := make(map[string]*domain.Recording, len(recordings))
+	later := make(map[string]bool)
+	for _, rec := range recordings {
+		listed[rec.ID] = rec
+	}
+	for _, chunks := range split {
+		for _, chunk := range chunks[1:] {
+			later[chunk.RecordingID] = true
+		}
+	}
+
+	merged := make([]*domain.Recording, 0, len(recordings))
+	for _, rec := range recordings {
+		if later[rec.ID] {
+			continue
+		}
+		if chunks, ok := split[rec.ID]; ok {
+			if rec, err = a.merge(ctx, learnerID, chunks, listed); err != nil {
+				return nil, err
+			}
+		}
+		merged = append(merged, rec)
+	}
+	return merged, nil
+}
+
+// DeleteRecording deletes a recording, and its other chunks if it was
+// submitted in chunks
+func (a *chunkedAPI) DeleteRecording(ctx context.Context, learnerID, recordingID string) error {
+	split, err := a.chunks.ListChunks(ctx, learnerID)
+	if err != nil {
+		return fmt.Errorf("list chunks: %w", err)
+	}
+	chunks, ok := split[recordingID]
+	if !ok {
+		return a.QuranAPIPort.DeleteRecording(ctx, learnerID, recordingID)
+	}
+
+	for _, chunk := range chunks {
+		if err := a.QuranAPIPort.DeleteRecording(ctx, learnerID, chunk.RecordingID); err != nil {
+			return fmt.Errorf("delete chunk %s: %w", chunk.RecordingID, err)
+		}
+	}
+	return a.chunks.DeleteChunks(ctx, learnerID, recordingID)
+}
+
+// merge returns the recording of the first chunk with the status of all the
+// chunks: failed if one failed, queued while one is queued, and done with
+// the merged result once all are. Chunks found in listed are not fetched.
+func (a *chunkedAPI) merge(ctx context.Context, learnerID string, chunks []domain.RecordingChunk, listed map[string]*domain.Recording) (*domain.Recording, error) {
+	var merged *domain.Recording
+	results := make([]ChunkResult, 0, len(chunks))
+	for _, chunk := range chunks {
+		rec, ok := listed[chunk.RecordingID]
+		if !ok {
+			var err error
+			if rec, err = a.QuranAPIPort.GetRecording(ctx, learnerID, chunk.RecordingID); err != nil {
+				return nil, fmt.Errorf("get chunk %s: %w", chunk.RecordingID, err)
+			}
+		}
+
+		if merged == nil {
+			copied := *rec
+			copied.Status = domain.StatusDone
+			copied.Result = nil
+			merged = &copied
+		}
+		if rec.UpdatedAt.After(merged.UpdatedAt) {
+			merged.UpdatedAt = rec.UpdatedAt
+		}
+		switch {
+		case rec.Status == domain.StatusFailed:
+			merged.Status = domain.StatusFailed
+			merged.Error = rec.Error
+		case rec.Status == domain.StatusQueued && merged.Status != domain.StatusFailed:
+			merged.Status = domain.StatusQueued
+		}
+		results = append(results, ChunkResult{Offset: chunk.Offset, Duration: chunk.Duration, Result: rec.Result})
+	}
+
+	if merged.Status == domain.StatusDone {
+		merged.Result = MergeChunkResults(results)
+	}
+	return merged, nil
+}
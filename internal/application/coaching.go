@@ -0,0 +1,112 @@
+package application
+
+import "github.com/escalopa/quran-read-bot/internal/domain"
+
+const (
+	// maxWordsPerSecond is the fastest pace, over the recited words, not
+	// taken for rushing; measured tartil stays well below it
+	maxWordsPerSecond = 3.0
+
+	// minRecognizedShare is the least share of the ayah's words recognized
+	// in a recording not taken for unclear audio
+	minRecognizedShare = 0.5
+
+	// manyInsertions is how many added words, and minInsertionShare which
+	// share of the ayah's words, make insertions the thing to work on
+	manyInsertions    = 3
+	minInsertionShare = 0.25
+)
+
+// resultFacts are the measures of a result coaching rules look at
+type resultFacts struct {
+	reference  int     // words of the ayah
+	recognized int     // words of the ayah recognized, right or wrong
+	insertions int     // words added
+	pace       float64 // recited words per second, 0 without timings
+	mistakes   MistakeSummary
+}
+
+func factsOf(result *domain.RecordingResult) resultFacts {
+	facts := resultFacts{mistakes: SummarizeMistakes(result.Ops)}
+
+	var recited int
+	var start, end float64
+	for _, op := range result.Ops {
+		switch op.Op {
+		case domain.OpCorrect, domain.OpSubstitution:
+			facts.reference++
+			facts.recognized++
+		case domain.OpDeletion:
+			facts.reference++
+		case domain.OpInsertion:
+			facts.insertions++
+		}
+		if op.Op == domain.OpDeletion || op.TEnd <= op.TStart {
+			continue
+		}
+		if recited == 0 || op.TStart < start {
+			start = op.TStart
+		}
+		end = max(end, op.TEnd)
+		recited++
+	}
+	if recited > 1 && end > start {
+		facts.pace = float64(recited) / (end - start)
+	}
+	return facts
+}
+
+// coachingRule suggests the tip of its name, translated under "tip.<name>",
+// when it applies to a result
+type coachingRule struct {
+	name    string
+	applies func(f resultFacts) bool
+}
+
+// coachingRules are tried in order and the first that applies gives the
+// tip, so the likely cause of mistakes comes before the mistakes themselves
+var coachingRules = []coachingRule{
+	{"unclear", func(f resultFacts) bool {
+		return f.reference > 0 && float64(f.recognized) < minRecognizedShare*float64(f.reference)
+	}},
+	{"rushed", func(f resultFacts) bool {
+		return f.pace > maxWordsPerSecond && f.mistakes.Total > 0
+	}},
+	{"insertions", func(f resultFacts) bool {
+		return f.insertions >= manyInsertions && float64(f.insertions) >= minInsertionShare*float64(f.reference)
+	}},
+	{"skipped", mainMistake(MistakeSkipped)},
+	{"wrong", mainMistake(MistakeWrong)},
+	{"pronunciation", mainMistake(MistakePronunciation)},
+	{"extra", mainMistake(MistakeExtra)},
+}
+
+// mainMistake applies when most mistakes of a result are of category
+func mainMistake(category MistakeCategory) func(f resultFacts) bool {
+	return func(f resultFacts) bool {
+		main, ok := f.mistakes.Main()
+		return ok && main == category
+	}
+}
+
+// CoachingTip returns the name of the one tip that suits a result best, or
+// false if it has nothing to improve
+func CoachingTip(result *domain.RecordingResult) (string, bool) {
+	facts := factsOf(result)
+	for _, rule := range coachingRules {
+		if rule.applies(facts) {
+			return rule.name, true
+		}
+	}
+	return "", false
+}
+
+// FormatCoachingTip returns the localized tip for a result, or an empty
+// string if it has nothing to improve
+func (s *BotService) FormatCoachingTip(lang domain.Language, result *domain.RecordingResult) string {
+	tip, ok := CoachingTip(result)
+	if !ok {
+		return ""
+	}
+	return s.i18n.Get(lang, "tip."+tip)
+}
@@ -0,0 +1,143 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+const (
+	// maxAttempts is the number of other attempts offered to compare a
+	// recording with
+	maxAttempts = 5
+
+	// maxComparedWords is the number of improved or regressed words listed
+	// in a comparison
+	maxComparedWords = 10
+)
+
+// AttemptComparison is how a later attempt at an ayah went compared to an
+// earlier one
+type AttemptComparison struct {
+	Before *domain.Recording
+	After  *domain.Recording
+
+	// AccuracyDelta is the accuracy of After minus that of Before, -1..1
+	AccuracyDelta float64
+	// Improved are the words wrong in Before and right in After, Regressed
+	// the other way around, in the order of the ayah
+	Improved  []string
+	Regressed []string
+}
+
+// CompareAttempts compares two recordings of the same ayah, in the order
+// they were made. It fails with domain.ErrNotComparable unless both are of
+// the same ayah and have a result.
+func CompareAttempts(a, b *domain.Recording) (AttemptComparison, error) {
+	if a.AyahID != b.AyahID {
+		return AttemptComparison{}, fmt.Errorf("%w: ayahs %s and %s differ", domain.ErrNotComparable, a.AyahID, b.AyahID)
+	}
+	if a.Result == nil || b.Result == nil {
+		return AttemptComparison{}, fmt.Errorf("%w: recording without a result", domain.ErrNotComparable)
+	}
+	if b.CreatedAt.Before(a.CreatedAt) {
+		a, b = b, a
+	}
+
+	comparison := AttemptComparison{
+		Before:        a,
+		After:         b,
+		AccuracyDelta: domain.Accuracy(b.Result.WER) - domain.Accuracy(a.Result.WER),
+	}
+
+	// Both results have the words of the ayah in order, whatever was
+	// recited around them
+	before, after := referenceWords(a.Result.Ops), referenceWords(b.Result.Ops)
+	if len(before) != len(after) {
+		return comparison, nil
+	}
+	for i, word := range after {
+		switch {
+		case !before[i].Op.IsMistake() && word.Op.IsMistake():
+			comparison.Regressed = append(comparison.Regressed, word.RefAr)
+		case before[i].Op.IsMistake() && !word.Op.IsMistake():
+			comparison.Improved = append(comparison.Improved, word.RefAr)
+		}
+	}
+	return comparison, nil
+}
+
+// referenceWords returns the operations on the words of the ayah, leaving
+// out words recited in addition to them
+func referenceWords(ops []domain.Operation) []domain.Operation {
+	words := make([]domain.Operation, 0, len(ops))
+	for _, op := range ops {
+		if op.Op != domain.OpInsertion && op.Op != domain.OpBasmala {
+			words = append(words, op)
+		}
+	}
+	return words
+}
+
+// Attempts returns the user's other analyzed recordings of the ayah of a
+// recording, newest first, to compare it with
+func (s *BotService) Attempts(ctx context.Context, userID string, recording *domain.Recording) ([]domain.RecordingSummary, error) {
+	summaries, err := s.ListRecordings(ctx, userID, recordingsCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	var attempts []domain.RecordingSummary
+	for _, summary := range summaries {
+		if summary.ID == recording.ID || summary.AyahID != recording.AyahID || summary.WER == nil {
+			continue
+		}
+		attempts = append(attempts, summary)
+		if len(attempts) == maxAttempts {
+			break
+		}
+	}
+	return attempts, nil
+}
+
+// CompareRecordings compares two of the user's recordings of the same ayah
+func (s *BotService) CompareRecordings(ctx context.Context, userID, recordingID, otherID string) (AttemptComparison, error) {
+	a, err := s.GetRecording(ctx, userID, recordingID)
+	if err != nil {
+		return AttemptComparison{}, err
+	}
+	b, err := s.GetRecording(ctx, userID, otherID)
+	if err != nil {
+		return AttemptComparison{}, err
+	}
+	return CompareAttempts(a, b)
+}
+
+// FormatComparison formats how the accuracy and words of the later attempt
+// changed from the earlier one
+func (s *BotService) FormatComparison(lang domain.Language, comparison AttemptComparison) string {
+	before := int(math.Round(domain.Accuracy(comparison.Before.Result.WER) * 100))
+	after := int(math.Round(domain.Accuracy(comparison.After.Result.WER) * 100))
+
+	lines := []string{s.i18n.Get(lang, "compare.accuracy", before, after, after-before)}
+	if len(comparison.Improved) > 0 {
+		lines = append(lines, s.i18n.Get(lang, "compare.improved", formatWords(comparison.Improved)))
+	}
+	if len(comparison.Regressed) > 0 {
+		lines = append(lines, s.i18n.Get(lang, "compare.regressed", formatWords(comparison.Regressed)))
+	}
+	if len(comparison.Improved) == 0 && len(comparison.Regressed) == 0 {
+		lines = append(lines, s.i18n.Get(lang, "compare.unchanged"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatWords lists words, up to maxComparedWords of them
+func formatWords(words []string) string {
+	if len(words) > maxComparedWords {
+		return strings.Join(words[:maxComparedWords], "، ") + " …"
+	}
+	return strings.Join(words, "، ")
+}
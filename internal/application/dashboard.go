@@ -0,0 +1,124 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// WithActivity keeps track of when each user last interacted with the bot
+func WithActivity(activity domain.ActivityPort) Option {
+	return func(s *BotService) {
+		s.activity = activity
+	}
+}
+
+// Overview is the state of the bot shown on the admin dashboard
+type Overview struct {
+	// Users active over the last day, week and month, -1 if activity is
+	// not tracked
+	ActiveDay   int
+	ActiveWeek  int
+	ActiveMonth int
+
+	Operations []OperationStats
+	Panics     int // recovered from over the last hour
+	Recent     []RecentSubmission
+}
+
+// QueueStats is a snapshot of a replica's recording job queue
+type QueueStats struct {
+	Queued  int
+	Running int
+	Workers int
+}
+
+// Messenger is the chat front end the admin dashboard reports on and
+// broadcasts through
+type Messenger interface {
+	QueueStats() QueueStats
+	// Broadcast sends text to every user and returns how many messages were
+	// sent and how many failed
+	Broadcast(ctx context.Context, userIDs []string, text string) (sent, failed int)
+}
+
+// TouchUser records that the user interacted with the bot. Failures are
+// only logged.
+func (s *BotService) TouchUser(ctx context.Context, userID string) {
+	if s.activity == nil {
+		return
+	}
+	if err := s.activity.TouchUser(ctx, userID, s.clock.Now()); err != nil {
+		requestid.Printf(ctx, "Error recording activity of user %s: %v", userID, err)
+	}
+}
+
+// Overview gathers the figures shown on the admin dashboard
+func (s *BotService) Overview(ctx context.Context) (*Overview, error) {
+	now := s.clock.Now()
+	overview := &Overview{
+		ActiveDay:   -1,
+		ActiveWeek:  -1,
+		ActiveMonth: -1,
+		Operations:  s.monitor.Stats(now, monitorWindow),
+		Panics:      s.monitor.Panics(now, monitorWindow),
+		Recent:      s.monitor.RecentSubmissions(),
+	}
+
+	if s.activity != nil {
+		users, err := s.activity.ActiveUsers(ctx, now.AddDate(0, -1, 0))
+		if err != nil {
+			return nil, fmt.Errorf("get active users: %w", err)
+		}
+		overview.ActiveDay, overview.ActiveWeek, overview.ActiveMonth = 0, 0, len(users)
+		for _, user := range users {
+			if now.Sub(user.LastSeen) <= 24*time.Hour {
+				overview.ActiveDay++
+			}
+			if now.Sub(user.LastSeen) <= 7*24*time.Hour {
+				overview.ActiveWeek++
+			}
+		}
+	}
+
+	return overview, nil
+}
+
+// ErrorRates returns the outcomes of conversions and submissions on this
+// instance over the last window, at most an hour
+func (s *BotService) ErrorRates(window time.Duration) []OperationStats {
+	return s.monitor.Stats(s.clock.Now(), window)
+}
+
+// RecordPanic counts a panic a handler recovered from
+func (s *BotService) RecordPanic() {
+	s.monitor.RecordPanic(s.clock.Now())
+}
+
+// Panics returns how many panics handlers recovered from on this instance
+// over the last window, at most an hour
+func (s *BotService) Panics(window time.Duration) int {
+	return s.monitor.Panics(s.clock.Now(), window)
+}
+
+// BroadcastTargets returns the users who interacted with the bot at or after
+// since, to send a broadcast to
+func (s *BotService) BroadcastTargets(ctx context.Context, since time.Time) ([]string, error) {
+	if s.activity == nil {
+		return nil, fmt.Errorf("activity is not tracked")
+	}
+
+	users, err := s.activity.ActiveUsers(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("get active users: %w", err)
+	}
+
+	userIDs := make([]string, len(users))
+	for i, user := range users {
+		userIDs[i] = user.UserID
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,141 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// DeleteUserData permanently removes everything stored about a user: their
+// recordings on the backend and their archived audio, history, goals,
+// position, audit log, session, preferences and profile. It keeps going
+// after a failure so that as much as possible is removed, and reports all
+// errors at the end.
+func (s *BotService) DeleteUserData(ctx context.Context, userID string) error {
+	var errs []error
+
+	recordings, err := s.quranAPI.ListRecordings(ctx, userID, exportRecordingsLimit)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("list recordings: %w", err))
+	}
+	for _, rec := range recordings {
+		if err := s.quranAPI.DeleteRecording(ctx, userID, rec.ID); err != nil {
+			errs = append(errs, fmt.Errorf("delete recording %s: %w", rec.ID, err))
+		}
+	}
+
+	if s.chunks != nil {
+		if err := s.chunks.DeleteChunks(ctx, userID, ""); err != nil {
+			errs = append(errs, fmt.Errorf("delete recording chunks: %w", err))
+		}
+	}
+
+	if s.cache != nil {
+		if err := s.cache.DeleteRecordings(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete cached recordings: %w", err))
+		}
+	}
+
+	if s.audioCache != nil {
+		if err := s.audioCache.DeleteAudio(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete cached audio: %w", err))
+		}
+	}
+
+	if s.archive != nil {
+		if err := s.archive.DeleteUserAudio(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete archived audio: %w", err))
+		}
+	}
+
+	if s.lifecycles != nil {
+		if err := s.lifecycles.DeleteLifecycles(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete lifecycles: %w", err))
+		}
+	}
+
+	if s.goals != nil {
+		if err := s.goals.DeleteGoals(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete goals: %w", err))
+		}
+	}
+
+	if s.positions != nil {
+		if err := s.positions.DeletePosition(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete position: %w", err))
+		}
+	}
+
+	if s.recent != nil {
+		if err := s.recent.DeleteRecentSurahs(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete recent surahs: %w", err))
+		}
+	}
+
+	if s.pins != nil {
+		if err := s.pins.DeletePins(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete pins: %w", err))
+		}
+	}
+
+	if s.tags != nil {
+		if err := s.tags.DeleteTags(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete tags: %w", err))
+		}
+	}
+
+	if s.khatmahs != nil {
+		if err := s.khatmahs.DeleteKhatmah(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete khatmah: %w", err))
+		}
+	}
+
+	if s.wirds != nil {
+		if err := s.wirds.DeleteWird(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete wird: %w", err))
+		}
+	}
+
+	if s.activity != nil {
+		if err := s.activity.RemoveUser(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("remove activity: %w", err))
+		}
+	}
+
+	if s.stats != nil {
+		if err := s.stats.DeleteStats(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete stats: %w", err))
+		}
+	}
+
+	if s.board != nil {
+		if err := s.board.RemoveUser(ctx, userID, s.clock.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("remove from leaderboard: %w", err))
+		}
+	}
+
+	if s.audit != nil {
+		if err := s.audit.DeleteAudit(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("delete audit log: %w", err))
+		}
+	}
+
+	if err := s.fsm.ClearUserSessions(ctx, userID); err != nil {
+		errs = append(errs, fmt.Errorf("clear sessions: %w", err))
+	}
+
+	if err := s.prefs.DeletePreferences(ctx, userID); err != nil {
+		errs = append(errs, fmt.Errorf("delete preferences: %w", err))
+	}
+
+	if s.users != nil {
+		s.names.evict(userID)
+		if err := s.users.DeleteUser(ctx, userID); err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+			errs = append(errs, fmt.Errorf("delete user: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,158 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+var errWordDrillsDisabled = errors.New("word drills are not enabled")
+
+// WithWordDrills lets users drill the words a result got wrong, recording
+// each alone and getting it checked right away, transcribed by the API's
+// auto-detection
+func WithWordDrills() Option {
+	return func(s *BotService) {
+		s.drills = true
+	}
+}
+
+// WordDrillsEnabled reports whether users can drill the words a result got
+// wrong
+func (s *BotService) WordDrillsEnabled() bool {
+	return s.drills
+}
+
+// StartWordDrill starts drilling the words substituted in one of the user's
+// recordings, replacing any drill in progress and pausing the flow the
+// session is in. It fails with
+// domain.ErrNothingToDrill if no word was substituted.
+func (s *BotService) StartWordDrill(ctx context.Context, scope domain.SessionScope, recordingID string) (domain.WordDrill, error) {
+	if !s.drills {
+		return domain.WordDrill{}, errWordDrillsDisabled
+	}
+	recording, err := s.GetRecording(ctx, scope.UserID, recordingID)
+	if err != nil {
+		return domain.WordDrill{}, err
+	}
+	drill, ok := domain.NewWordDrill(*recording)
+	if !ok {
+		return domain.WordDrill{}, domain.ErrNothingToDrill
+	}
+
+	_, err = s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		session.StartWordDrill(drill)
+		return nil
+	})
+	if err != nil {
+		return domain.WordDrill{}, fmt.Errorf("update session: %w", err)
+	}
+	return drill, nil
+}
+
+// WordDrill returns the word drill in progress in the session, nil if none
+func (s *BotService) WordDrill(ctx context.Context, scope domain.SessionScope) (*domain.WordDrill, error) {
+	session, err := s.fsm.GetSession(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	flow, err := session.WordDrill()
+	if err != nil || len(flow.Drill.Words) == 0 {
+		return nil, nil
+	}
+	return &flow.Drill, nil
+}
+
+// StopWordDrill ends the word drill in progress in the session, if any, and
+// resumes the flow it paused
+func (s *BotService) StopWordDrill(ctx context.Context, scope domain.SessionScope) error {
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		if session.Flow == domain.FlowWordDrill {
+			session.Resume()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// SkipDrillWord moves the word drill in progress on to its next word and
+// returns it, ended in the session once done, resuming the flow it paused. It fails with
+// domain.ErrNoWordDrill if none is in progress.
+func (s *BotService) SkipDrillWord(ctx context.Context, scope domain.SessionScope) (domain.WordDrill, error) {
+	return s.updateWordDrill(ctx, scope, nil, func(drill *domain.WordDrill) {
+		drill.Skip()
+	})
+}
+
+// DrillCheck is the outcome of a recording of the word drilled
+type DrillCheck struct {
+	Word   string
+	Heard  string
+	Passed bool
+	Drill  domain.WordDrill // after the attempt
+}
+
+// CheckDrillWord transcribes a recording of the word drilled and checks
+// that the word was heard, moving the drill on to the next word if it was.
+// The drill is ended in the session once done, resuming the flow it paused.
+// It fails with
+// domain.ErrNoWordDrill if none is in progress.
+func (s *BotService) CheckDrillWord(ctx context.Context, scope domain.SessionScope, audioFile io.Reader, meta domain.AudioMetadata) (DrillCheck, error) {
+	drill, err := s.WordDrill(ctx, scope)
+	if err != nil {
+		return DrillCheck{}, err
+	}
+	if drill == nil || drill.Done() {
+		return DrillCheck{}, domain.ErrNoWordDrill
+	}
+
+	heard, err := s.transcribe(ctx, scope.UserID, audioFile, meta)
+	if err != nil {
+		return DrillCheck{}, err
+	}
+
+	check := DrillCheck{Word: drill.Word(), Heard: heard}
+	check.Drill, err = s.updateWordDrill(ctx, scope, drill, func(drill *domain.WordDrill) {
+		check.Passed = drill.Attempt(heard)
+	})
+	if err != nil {
+		return DrillCheck{}, err
+	}
+	return check, nil
+}
+
+// updateWordDrill applies fn to the word drill in progress in the session
+// and returns it, ending the word drill flow once done. When from is set, fn is only applied
+// if the drill is still at the same word, e.g. after a slow transcription.
+func (s *BotService) updateWordDrill(ctx context.Context, scope domain.SessionScope, from *domain.WordDrill, fn func(drill *domain.WordDrill)) (domain.WordDrill, error) {
+	var updated domain.WordDrill
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		flow, err := session.WordDrill()
+		if err != nil || len(flow.Drill.Words) == 0 {
+			return domain.ErrNoWordDrill
+		}
+		updated = flow.Drill
+		if from == nil || (updated.RecordingID == from.RecordingID && updated.Current == from.Current) {
+			fn(&updated)
+		}
+		if updated.Done() {
+			session.Resume()
+		} else {
+			session.SetWordDrill(updated)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrNoWordDrill) {
+			return domain.WordDrill{}, err
+		}
+		return domain.WordDrill{}, fmt.Errorf("update session: %w", err)
+	}
+	return updated, nil
+}
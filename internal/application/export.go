@@ -0,0 +1,170 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// exportRecordingsLimit caps the number of recordings included in an export
+const exportRecordingsLimit = 1000
+
+// UserDataExport is the document produced by ExportUserData. It contains
+// everything the bot stores about a user across all stores.
+type UserDataExport struct {
+	UserID      string              `json:"user_id"`
+	ExportedAt  time.Time           `json:"exported_at"`
+	Session     *domain.Session     `json:"session"`
+	Preferences domain.Preferences  `json:"preferences"`
+	Profile     *profileExport      `json:"profile,omitempty"`
+	Recordings  []recordingExport   `json:"recordings"`
+	Stats       []domain.StatEntry  `json:"stats,omitempty"`
+	Audit       []domain.AuditEntry `json:"audit,omitempty"`
+	Goals       []domain.Goal       `json:"goals,omitempty"`
+	Position    string              `json:"position,omitempty"` // last ayah recorded, e.g. "2:255"
+	Recent      []int               `json:"recent_surahs,omitempty"`
+	Wird        *domain.Wird        `json:"wird,omitempty"`
+	Khatmah     *domain.Khatmah     `json:"khatmah,omitempty"`
+	Pins        []domain.Pin        `json:"pins,omitempty"`
+	Tags        map[string][]string `json:"tags,omitempty"` // by recording ID
+}
+
+type profileExport struct {
+	DisplayName     string     `json:"display_name,omitempty"`
+	Level           string     `json:"level"`
+	RegisteredAt    time.Time  `json:"registered_at"`
+	LastActiveAt    time.Time  `json:"last_active_at"`
+	LastRecordingAt *time.Time `json:"last_recording_at,omitempty"`
+	StreakDays      int        `json:"streak_days"`
+	BestStreak      int        `json:"best_streak"`
+	TotalRecordings int        `json:"total_recordings"`
+}
+
+type recordingExport struct {
+	domain.RecordingSummary
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportUserData assembles a JSON document of all data stored about a user
+func (s *BotService) ExportUserData(ctx context.Context, userID string) ([]byte, error) {
+	export := UserDataExport{
+		UserID:     userID,
+		ExportedAt: s.clock.Now().UTC(),
+	}
+
+	var err error
+	export.Session, err = s.fsm.GetSession(ctx, domain.PrivateScope(userID))
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+
+	export.Preferences, err = s.prefs.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get preferences: %w", err)
+	}
+
+	if s.users != nil {
+		user, err := s.users.GetUser(ctx, userID)
+		switch {
+		case err == nil:
+			learner := user.Learner()
+			export.Profile = &profileExport{
+				DisplayName:     learner.DisplayName,
+				Level:           string(learner.Level),
+				RegisteredAt:    user.RegisteredAt,
+				LastActiveAt:    user.LastActiveAt,
+				StreakDays:      user.StreakDays,
+				BestStreak:      user.BestStreak,
+				TotalRecordings: user.TotalRecordings,
+			}
+			if !user.LastRecordingAt.IsZero() {
+				export.Profile.LastRecordingAt = &user.LastRecordingAt
+			}
+		case err != domain.ErrUserNotFound:
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+	}
+
+	recordings, err := s.quranAPI.ListRecordings(ctx, userID, exportRecordingsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("list recordings: %w", err)
+	}
+	export.Recordings = make([]recordingExport, 0, len(recordings))
+	for _, rec := range recordings {
+		export.Recordings = append(export.Recordings, recordingExport{
+			RecordingSummary: rec.Summary(),
+			UpdatedAt:        rec.UpdatedAt,
+		})
+	}
+
+	if s.stats != nil {
+		export.Stats, err = s.stats.ListStats(ctx, userID, time.Time{})
+		if err != nil {
+			return nil, fmt.Errorf("list stats: %w", err)
+		}
+	}
+
+	if s.audit != nil {
+		export.Audit, err = s.audit.ListAudit(ctx, userID, 0)
+		if err != nil {
+			return nil, fmt.Errorf("list audit entries: %w", err)
+		}
+	}
+
+	if s.goals != nil {
+		export.Goals, err = s.goals.ListGoals(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("list goals: %w", err)
+		}
+	}
+
+	if s.positions != nil {
+		position, err := s.positions.GetPosition(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("get position: %w", err)
+		}
+		if position != nil {
+			export.Position = position.String()
+		}
+	}
+
+	if s.recent != nil {
+		export.Recent, err = s.recent.RecentSurahs(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("get recent surahs: %w", err)
+		}
+	}
+
+	if s.wirds != nil {
+		export.Wird, err = s.wirds.GetWird(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("get wird: %w", err)
+		}
+	}
+
+	if s.khatmahs != nil {
+		export.Khatmah, err = s.khatmahs.GetKhatmah(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("get khatmah: %w", err)
+		}
+	}
+
+	if s.pins != nil {
+		export.Pins, err = s.pins.ListPins(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("list pins: %w", err)
+		}
+	}
+
+	if s.tags != nil {
+		export.Tags, err = s.tags.ListTags(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("list tags: %w", err)
+		}
+	}
+
+	return json.MarshalIndent(export, "", "  ")
+}
@@ -0,0 +1,176 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+const (
+	// maxGoals is the number of goals a user can have at once
+	maxGoals = 5
+
+	// goalNudgeAfter is how far into the user's day progress messages are
+	// sent, so that they arrive around noon rather than at night
+	goalNudgeAfter = 12 * time.Hour
+)
+
+var errGoalsDisabled = errors.New("goals are not kept")
+
+// WithGoals lets users set memorization goals and be sent their progress
+func WithGoals(goals domain.GoalPort) Option {
+	return func(s *BotService) {
+		s.goals = goals
+	}
+}
+
+// SetGoal sets the user a goal to memorize a surah within a number of days,
+// today included, replacing any goal they had for that surah
+func (s *BotService) SetGoal(ctx context.Context, userID string, surahNumber, days int) (domain.Goal, error) {
+	if s.goals == nil {
+		return domain.Goal{}, errGoalsDisabled
+	}
+	if surahNumber < 1 || surahNumber > len(domain.GetAllSurahs()) || days < 1 {
+		return domain.Goal{}, fmt.Errorf("goal for surah %d in %d days: %w", surahNumber, days, domain.ErrInvalidAyah)
+	}
+
+	goals, err := s.goals.ListGoals(ctx, userID)
+	if err != nil {
+		return domain.Goal{}, fmt.Errorf("list goals: %w", err)
+	}
+	replacing := false
+	for _, goal := range goals {
+		replacing = replacing || goal.SurahNumber == surahNumber
+	}
+	if !replacing && len(goals) >= maxGoals {
+		return domain.Goal{}, domain.ErrTooManyGoals
+	}
+
+	today := s.preferences(ctx, userID).DayBoundary().Day(s.clock.Now())
+	goal := domain.NewGoal(userID, surahNumber, today, days)
+	if err := s.goals.SaveGoal(ctx, goal); err != nil {
+		return domain.Goal{}, fmt.Errorf("save goal: %w", err)
+	}
+	return goal, nil
+}
+
+// RemoveGoal removes the user's goal for a surah
+func (s *BotService) RemoveGoal(ctx context.Context, userID string, surahNumber int) error {
+	if s.goals == nil {
+		return domain.ErrGoalNotFound
+	}
+	return s.goals.DeleteGoal(ctx, userID, surahNumber)
+}
+
+// Goals returns how far the user is on each of their goals, soonest
+// deadline first. Without a goal store there are none.
+func (s *BotService) Goals(ctx context.Context, userID string) ([]domain.GoalProgress, error) {
+	if s.goals == nil {
+		return nil, nil
+	}
+	goals, err := s.goals.ListGoals(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list goals: %w", err)
+	}
+	if len(goals) == 0 {
+		return nil, nil
+	}
+	return s.goalProgress(ctx, userID, goals)
+}
+
+// GoalNudges returns the goals of every user a progress message is due for,
+// once the user's day is far enough along. Callers send the message and
+// then call MarkNudged.
+func (s *BotService) GoalNudges(ctx context.Context) ([]domain.GoalProgress, error) {
+	if s.goals == nil {
+		return nil, nil
+	}
+	goals, err := s.goals.AllGoals(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list goals: %w", err)
+	}
+
+	byUser := make(map[string][]domain.Goal)
+	for _, goal := range goals {
+		byUser[goal.UserID] = append(byUser[goal.UserID], goal)
+	}
+
+	now := s.clock.Now()
+	var due []domain.GoalProgress
+	for userID, goals := range byUser {
+		days := s.preferences(ctx, userID).DayBoundary()
+		if now.Sub(days.Start(now)) < goalNudgeAfter {
+			continue
+		}
+		today := days.Day(now)
+
+		var nudge []domain.Goal
+		for _, goal := range goals {
+			if goal.NudgeDue(today) {
+				nudge = append(nudge, goal)
+			}
+		}
+		if len(nudge) == 0 {
+			continue
+		}
+
+		progress, err := s.goalProgress(ctx, userID, nudge)
+		if err != nil {
+			requestid.Printf(ctx, "Error computing goal progress of user %s: %v", userID, err)
+			continue
+		}
+		due = append(due, progress...)
+	}
+	return due, nil
+}
+
+// MarkNudged records that the progress message of a goal was sent. Goals
+// done or past their deadline are removed, their last message sent.
+func (s *BotService) MarkNudged(ctx context.Context, progress domain.GoalProgress) error {
+	if s.goals == nil {
+		return nil
+	}
+	goal := progress.Goal
+	if progress.Done() || progress.Overdue() {
+		err := s.goals.DeleteGoal(ctx, goal.UserID, goal.SurahNumber)
+		if err != nil && !errors.Is(err, domain.ErrGoalNotFound) {
+			return fmt.Errorf("delete goal: %w", err)
+		}
+		return nil
+	}
+
+	goal.NudgedAt = s.preferences(ctx, goal.UserID).DayBoundary().Day(s.clock.Now())
+	if err := s.goals.SaveGoal(ctx, goal); err != nil {
+		return fmt.Errorf("save goal: %w", err)
+	}
+	return nil
+}
+
+// goalProgress computes the progress of a user's goals against what they
+// recited, in their riwayah and with their passing accuracy
+func (s *BotService) goalProgress(ctx context.Context, userID string, goals []domain.Goal) ([]domain.GoalProgress, error) {
+	prefs := s.preferences(ctx, userID)
+	surahs, err := s.Progress(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	bySurah := make(map[int]domain.SurahProgress, len(surahs))
+	for _, p := range surahs {
+		bySurah[p.SurahNumber] = p
+	}
+
+	today := prefs.DayBoundary().Day(s.clock.Now())
+	list := make([]domain.GoalProgress, 0, len(goals))
+	for _, goal := range goals {
+		p, ok := bySurah[goal.SurahNumber]
+		if !ok {
+			p = domain.NewSurahProgress(goal.SurahNumber, prefs.Riwayah)
+		}
+		list = append(list, goal.Progress(p, today, prefs.MinSimilarity))
+	}
+	return list, nil
+}
@@ -0,0 +1,32 @@
+package application
+
+import (
+	"math"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// WithGradingScale grades results on scale instead of the words preset
+func WithGradingScale(scale domain.GradingScale) Option {
+	return func(s *BotService) {
+		s.grades = scale
+	}
+}
+
+// Grade returns the grade of a result with the given word error rate
+func (s *BotService) Grade(wer float64) domain.Grade {
+	return s.grades.Grade(domain.Accuracy(wer))
+}
+
+// FormatGrade formats the grade and accuracy of a result for display. Grade
+// names are translated when the locale has a "grade.<name>" key, and shown
+// as configured otherwise, e.g. letter grades.
+func (s *BotService) FormatGrade(lang domain.Language, wer float64) string {
+	grade := s.Grade(wer)
+	name := s.i18n.Get(lang, "grade."+grade.Name)
+	if name == "grade."+grade.Name {
+		name = grade.Name
+	}
+	accuracy := int(math.Round(domain.Accuracy(wer) * 100))
+	return s.i18n.Get(lang, "recording.grade", name, accuracy)
+}
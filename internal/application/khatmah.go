@@ -0,0 +1,109 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+var errKhatmahsDisabled = errors.New("khatmahs are not kept")
+
+// WithKhatmahs lets users read the whole Quran over a plan of days, e.g.
+// through Ramadan, and follow their progress
+func WithKhatmahs(khatmahs domain.KhatmahPort) Option {
+	return func(s *BotService) {
+		s.khatmahs = khatmahs
+	}
+}
+
+// StartKhatmah starts the user a khatmah over a plan of days from today,
+// replacing any khatmah they were reading
+func (s *BotService) StartKhatmah(ctx context.Context, userID string, days int) (domain.Khatmah, error) {
+	if s.khatmahs == nil {
+		return domain.Khatmah{}, errKhatmahsDisabled
+	}
+	if !domain.ValidKhatmahPlan(days) {
+		return domain.Khatmah{}, fmt.Errorf("unknown khatmah plan of %d days", days)
+	}
+
+	today := s.preferences(ctx, userID).DayBoundary().Day(s.clock.Now())
+	khatmah := domain.NewKhatmah(userID, days, today)
+	if err := s.khatmahs.SaveKhatmah(ctx, khatmah); err != nil {
+		return domain.Khatmah{}, fmt.Errorf("save khatmah: %w", err)
+	}
+	return khatmah, nil
+}
+
+// StopKhatmah removes the user's khatmah
+func (s *BotService) StopKhatmah(ctx context.Context, userID string) error {
+	if s.khatmahs == nil {
+		return nil
+	}
+	if err := s.khatmahs.DeleteKhatmah(ctx, userID); err != nil {
+		return fmt.Errorf("delete khatmah: %w", err)
+	}
+	return nil
+}
+
+// KhatmahProgress returns how far the user's khatmah is today, or nil if
+// they have none
+func (s *BotService) KhatmahProgress(ctx context.Context, userID string) (*domain.KhatmahProgress, error) {
+	if s.khatmahs == nil {
+		return nil, nil
+	}
+	khatmah, err := s.khatmahs.GetKhatmah(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get khatmah: %w", err)
+	}
+	if khatmah == nil {
+		return nil, nil
+	}
+	prefs := s.preferences(ctx, userID)
+	progress := khatmah.Progress(prefs.DayBoundary().Day(s.clock.Now()), prefs.Riwayah)
+	return &progress, nil
+}
+
+// ContinueKhatmah selects the next ayah of the user's khatmah, ready to be
+// recorded, and returns it. It fails with domain.ErrNoKhatmah if the user
+// has none in progress.
+func (s *BotService) ContinueKhatmah(ctx context.Context, scope domain.SessionScope) (domain.Ayah, error) {
+	progress, err := s.KhatmahProgress(ctx, scope.UserID)
+	if err != nil {
+		return domain.Ayah{}, err
+	}
+	if progress == nil || progress.Khatmah.Done() {
+		return domain.Ayah{}, domain.ErrNoKhatmah
+	}
+	next := progress.Khatmah.Next
+	if err := s.RecordAyah(ctx, scope, next); err != nil {
+		return domain.Ayah{}, err
+	}
+	return next, nil
+}
+
+// trackKhatmah moves the user's khatmah on when they recorded its next
+// ayah. Khatmahs are best-effort and never fail the caller.
+func (s *BotService) trackKhatmah(ctx context.Context, userID string, ayah domain.Ayah) {
+	if s.khatmahs == nil {
+		return
+	}
+	khatmah, err := s.khatmahs.GetKhatmah(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting khatmah of user %s: %v", userID, err)
+		return
+	}
+	if khatmah == nil {
+		return
+	}
+
+	prefs := s.preferences(ctx, userID)
+	if !khatmah.Record(ayah, prefs.DayBoundary().Day(s.clock.Now()), prefs.Riwayah) {
+		return
+	}
+	if err := s.khatmahs.SaveKhatmah(ctx, *khatmah); err != nil {
+		requestid.Printf(ctx, "Error saving khatmah of user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,32 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// GetLeaderboard returns the top users of the current period and the
+// requesting user's own position, which is nil if they have no score yet.
+// Without a leaderboard store the board is always empty.
+func (s *BotService) GetLeaderboard(ctx context.Context, userID string, period domain.LeaderboardPeriod, limit int) ([]domain.LeaderboardEntry, *domain.LeaderboardEntry, error) {
+	if s.board == nil {
+		return nil, nil, nil
+	}
+
+	now := s.clock.Now()
+	top, err := s.board.Top(ctx, period, now, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get top: %w", err)
+	}
+
+	me, err := s.board.Rank(ctx, period, now, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get rank: %w", err)
+	}
+
+	s.withDisplayNames(ctx, top)
+
+	return top, me, nil
+}
@@ -0,0 +1,124 @@
+package application
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// displayNameCacheSize bounds the display names remembered to skip storing
+// the names of learners again on every update
+const displayNameCacheSize = 10000
+
+// RegisterLearner creates the learner's profile on their first contact with
+// the bot and keeps their display name current. Names already stored by
+// this process are not stored again. Profile persistence is best-effort and
+// never fails the caller.
+func (s *BotService) RegisterLearner(ctx context.Context, userID, displayName string) {
+	if s.users == nil {
+		return
+	}
+	if name, ok := s.names.get(userID); ok && name == displayName {
+		return
+	}
+
+	if err := s.users.SetDisplayName(ctx, userID, displayName); err != nil {
+		requestid.Printf(ctx, "Error saving user %s: %v", userID, err)
+		return
+	}
+	s.names.put(userID, displayName)
+}
+
+// Learner returns the profile of a learner. It fails with
+// domain.ErrUserNotFound if profiles are not kept or the learner has none.
+func (s *BotService) Learner(ctx context.Context, userID string) (*domain.Learner, error) {
+	if s.users == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	user, err := s.users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	learner := user.Learner()
+	return &learner, nil
+}
+
+// withDisplayNames sets the display name of the learner of each entry
+func (s *BotService) withDisplayNames(ctx context.Context, entries []domain.LeaderboardEntry) {
+	for i, entry := range entries {
+		learner, err := s.Learner(ctx, entry.UserID)
+		if err != nil {
+			if !errors.Is(err, domain.ErrUserNotFound) {
+				requestid.Printf(ctx, "Error getting learner %s: %v", entry.UserID, err)
+			}
+			continue
+		}
+		entries[i].DisplayName = learner.DisplayName
+	}
+}
+
+// displayNames is a bounded LRU of the display name last stored of each
+// learner
+type displayNames struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type displayNameEntry struct {
+	userID string
+	name   string
+}
+
+func newDisplayNames(size int) *displayNames {
+	return &displayNames{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *displayNames) get(userID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[userID]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*displayNameEntry).name, true
+}
+
+func (c *displayNames) put(userID, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[userID]; ok {
+		elem.Value.(*displayNameEntry).name = name
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[userID] = c.order.PushFront(&displayNameEntry{userID: userID, name: name})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*displayNameEntry).userID)
+	}
+}
+
+func (c *displayNames) evict(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[userID]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, userID)
+	}
+}
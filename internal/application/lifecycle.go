@@ -0,0 +1,130 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// resumeWindow is how long after submission a pending recording is still
+// watched again on start; older ones were most likely lost by the API
+const resumeWindow = 24 * time.Hour
+
+// WithRecordingLifecycle tracks each submitted recording until its result is
+// reviewed, so that results are notified once and resumed after a restart
+func WithRecordingLifecycle(lifecycles domain.RecordingLifecyclePort) Option {
+	return func(s *BotService) {
+		s.lifecycles = lifecycles
+	}
+}
+
+// ClaimNotification moves the recording of an event to StageNotified and
+// reports whether the caller should notify the user: false if another
+// replica, or an earlier delivery of the event, already did. Recordings not
+// tracked are always claimed.
+func (s *BotService) ClaimNotification(ctx context.Context, event domain.RecordingEvent) bool {
+	if s.lifecycles == nil {
+		return true
+	}
+	moved, err := s.lifecycles.AdvanceRecording(ctx, event.UserID, event.RecordingID, domain.StageNotified, s.clock.Now())
+	if errors.Is(err, domain.ErrRecordingNotTracked) {
+		return true
+	}
+	if err != nil {
+		// Better notified twice than never
+		requestid.Printf(ctx, "Error claiming notification of recording %s: %v", event.RecordingID, err)
+		return true
+	}
+	return moved
+}
+
+// MarkReviewed records that the user opened the result of a recording
+func (s *BotService) MarkReviewed(ctx context.Context, userID string, recording *domain.Recording) {
+	if recording.Result == nil {
+		return
+	}
+	s.advanceRecording(ctx, userID, recording.ID, domain.StageReviewed)
+}
+
+// ResumeRecordings watches again the recordings submitted within the resume
+// window whose outcome was not delivered, e.g. because the replica watching
+// them restarted
+func (s *BotService) ResumeRecordings(ctx context.Context) {
+	if s.lifecycles == nil || s.poller == nil {
+		return
+	}
+	pending, err := s.lifecycles.PendingRecordings(ctx)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing pending recordings: %v", err)
+		return
+	}
+	var resumed int
+	for _, lifecycle := range pending {
+		if s.clock.Now().Sub(lifecycle.SubmittedAt) > resumeWindow {
+			continue
+		}
+		s.poller.Watch(ctx, lifecycle.UserID, lifecycle.ChatID, lifecycle.RecordingID, nil)
+		resumed++
+	}
+	if resumed > 0 {
+		requestid.Printf(ctx, "Resumed watching %d pending recordings", resumed)
+	}
+}
+
+// trackRecording starts tracking a recording just submitted in a scope
+func (s *BotService) trackRecording(ctx context.Context, scope domain.SessionScope, recording *domain.Recording) {
+	if s.lifecycles == nil {
+		return
+	}
+	now := s.clock.Now()
+	err := s.lifecycles.TrackRecording(ctx, domain.RecordingLifecycle{
+		RecordingID: recording.ID,
+		UserID:      scope.UserID,
+		ChatID:      scope.ChatID,
+		AyahID:      recording.AyahID,
+		Stage:       domain.StageSubmitted,
+		SubmittedAt: now,
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		requestid.Printf(ctx, "Error tracking recording %s: %v", recording.ID, err)
+	}
+}
+
+// advanceRecording moves a recording to a stage. Recordings not tracked are
+// left alone.
+func (s *BotService) advanceRecording(ctx context.Context, userID, recordingID string, to domain.RecordingStage) {
+	if s.lifecycles == nil {
+		return
+	}
+	_, err := s.lifecycles.AdvanceRecording(ctx, userID, recordingID, to, s.clock.Now())
+	if err != nil && !errors.Is(err, domain.ErrRecordingNotTracked) {
+		requestid.Printf(ctx, "Error moving recording %s to %s: %v", recordingID, to, err)
+	}
+}
+
+// withStages sets the tracked stage of each summary
+func (s *BotService) withStages(ctx context.Context, userID string, summaries []domain.RecordingSummary) []domain.RecordingSummary {
+	if s.lifecycles == nil || len(summaries) == 0 {
+		return summaries
+	}
+	lifecycles, err := s.lifecycles.ListLifecycles(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error listing lifecycles of user %s: %v", userID, err)
+		return summaries
+	}
+
+	stages := make(map[string]domain.RecordingStage, len(lifecycles))
+	for _, lifecycle := range lifecycles {
+		stages[lifecycle.RecordingID] = lifecycle.Stage
+	}
+	staged := make([]domain.RecordingSummary, len(summaries))
+	for i, summary := range summaries {
+		summary.Stage = stages[summary.ID]
+		staged[i] = summary
+	}
+	return staged
+}
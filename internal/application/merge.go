@@ -0,0 +1,114 @@
+package application
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// ChunkResult is the analysis of one chunk of a long recording that was
+// split into several submissions
+type ChunkResult struct {
+	Offset   time.Duration // start of the chunk in the whole recording
+	Duration time.Duration // length of the chunk
+	Result   *domain.RecordingResult
+}
+
+// MergeChunkResults stitches the results of the chunks of one recording into
+// the result of the whole recording. Operation timings are shifted to the
+// whole recording. Consecutive chunks may overlap, so that no word is cut in
+// half: words in an overlap are taken from the earlier chunk up to the middle
+// of the overlap and from the later one after it, so each is counted once.
+// The word error rate and transcription are recomputed from the merged
+// operations. Chunks without a result are skipped.
+func MergeChunkResults(chunks []ChunkResult) *domain.RecordingResult {
+	var done []ChunkResult
+	for _, chunk := range chunks {
+		if chunk.Result != nil {
+			done = append(done, chunk)
+		}
+	}
+	if len(done) == 0 {
+		return nil
+	}
+	sort.SliceStable(done, func(i, j int) bool { return done[i].Offset < done[j].Offset })
+
+	merged := &domain.RecordingResult{}
+	for i, chunk := range done {
+		offset := chunk.Offset.Seconds()
+
+		// Keep the operations starting between the cuts with the neighbours
+		from, to := -1.0, -1.0
+		if i > 0 {
+			from = overlapCut(done[i-1], chunk)
+		}
+		if i < len(done)-1 {
+			to = overlapCut(chunk, done[i+1])
+		}
+
+		for _, op := range chunk.Result.Ops {
+			op.TStart += offset
+			op.TEnd += offset
+			if from >= 0 && op.TStart < from {
+				continue
+			}
+			if to >= 0 && op.TStart >= to {
+				continue
+			}
+			merged.Ops = append(merged.Ops, op)
+		}
+	}
+
+	merged.WER = wordErrorRate(merged.Ops)
+	merged.Hypothesis = hypothesis(merged.Ops)
+	return merged
+}
+
+// overlapCut returns the time, in seconds of the whole recording, separating
+// the words taken from two consecutive chunks: the middle of their overlap,
+// or the start of the later chunk if they do not overlap
+func overlapCut(earlier, later ChunkResult) float64 {
+	end := (earlier.Offset + earlier.Duration).Seconds()
+	start := later.Offset.Seconds()
+	if earlier.Duration <= 0 || end <= start {
+		return start
+	}
+	return (start + end) / 2
+}
+
+// wordErrorRate returns (S + D + I) / N, N being the number of reference
+// words (C + S + D)
+func wordErrorRate(ops []domain.Operation) float64 {
+	var errors, reference int
+	for _, op := range ops {
+		switch op.Op {
+		case domain.OpCorrect:
+			reference++
+		case domain.OpSubstitution, domain.OpDeletion:
+			reference++
+			errors++
+		case domain.OpInsertion:
+			errors++
+		}
+	}
+	if reference == 0 {
+		if errors > 0 {
+			return 1
+		}
+		return 0
+	}
+	return float64(errors) / float64(reference)
+}
+
+// hypothesis rebuilds the transcription from the recited words
+func hypothesis(ops []domain.Operation) string {
+	words := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if op.Op != domain.OpDeletion && op.HypAr != "" {
+			words = append(words, op.HypAr)
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,148 @@
+package application
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+func TestOverlapCut(t *testing.T) {
+	chunk := func(offset, duration time.Duration) ChunkResult {
+		return ChunkResult{Offset: offset, Duration: duration}
+	}
+
+	tests := []struct {
+		name           string
+		earlier, later ChunkResult
+		want           float64
+	}{
+		{name: "back to back", earlier: chunk(0, 10*time.Second), later: chunk(10*time.Second, 10*time.Second), want: 10},
+		{name: "gap", earlier: chunk(0, 8*time.Second), later: chunk(10*time.Second, 10*time.Second), want: 10},
+		{name: "overlap", earlier: chunk(0, 12*time.Second), later: chunk(10*time.Second, 10*time.Second), want: 11},
+		{name: "later offsets", earlier: chunk(20*time.Second, 13*time.Second), later: chunk(30*time.Second, 5*time.Second), want: 31.5},
+		{name: "unknown duration", earlier: chunk(0, 0), later: chunk(10*time.Second, 10*time.Second), want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overlapCut(tt.earlier, tt.later); got != tt.want {
+				t.Errorf("overlapCut() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeChunkResults(t *testing.T) {
+	op := func(typ domain.OpType, word string, start float64) domain.Operation {
+		o := domain.Operation{Op: typ, RefAr: word, HypAr: word, TStart: start, TEnd: start + 0.5}
+		switch typ {
+		case domain.OpSubstitution:
+			o.HypAr = word + "x"
+		case domain.OpDeletion:
+			o.HypAr = ""
+		case domain.OpInsertion:
+			o.RefAr = ""
+		}
+		return o
+	}
+	result := func(ops ...domain.Operation) *domain.RecordingResult {
+		return &domain.RecordingResult{WER: -1, Ops: ops, Hypothesis: "stale"}
+	}
+
+	// The first chunk covers 0-12s and the second 10-20s, cut at 11s
+	earlier := ChunkResult{
+		Offset:   0,
+		Duration: 12 * time.Second,
+		Result: result(
+			op(domain.OpCorrect, "a", 1),
+			op(domain.OpSubstitution, "b", 9),
+			op(domain.OpCorrect, "c", 10.5),
+			op(domain.OpCorrect, "d", 11.2),
+		),
+	}
+	later := ChunkResult{
+		Offset:   10 * time.Second,
+		Duration: 10 * time.Second,
+		Result: result(
+			op(domain.OpCorrect, "c", 0.5),
+			op(domain.OpCorrect, "d", 1.2),
+			op(domain.OpDeletion, "e", 3),
+			op(domain.OpInsertion, "f", 5),
+		),
+	}
+
+	tests := []struct {
+		name       string
+		chunks     []ChunkResult
+		wantNil    bool
+		wantStarts []float64
+		wantWER    float64
+		wantHyp    string
+	}{
+		{name: "no chunk", wantNil: true},
+		{name: "no result", chunks: []ChunkResult{{Duration: time.Second}}, wantNil: true},
+		{
+			name:       "overlap counted once",
+			chunks:     []ChunkResult{earlier, later},
+			wantStarts: []float64{1, 9, 10.5, 11.2, 13, 15},
+			wantWER:    3.0 / 5,
+			wantHyp:    "a bx c d f",
+		},
+		{
+			name:       "out of order",
+			chunks:     []ChunkResult{later, earlier},
+			wantStarts: []float64{1, 9, 10.5, 11.2, 13, 15},
+			wantWER:    3.0 / 5,
+			wantHyp:    "a bx c d f",
+		},
+		{
+			name:       "chunk without result skipped",
+			chunks:     []ChunkResult{earlier, {Offset: 10 * time.Second, Duration: 10 * time.Second}},
+			wantStarts: []float64{1, 9, 10.5, 11.2},
+			wantWER:    1.0 / 4,
+			wantHyp:    "a bx c d",
+		},
+		{
+			name:       "only insertions",
+			chunks:     []ChunkResult{{Duration: 5 * time.Second, Result: result(op(domain.OpInsertion, "f", 1))}},
+			wantStarts: []float64{1},
+			wantWER:    1,
+			wantHyp:    "f",
+		},
+		{
+			name:    "no words",
+			chunks:  []ChunkResult{{Duration: 5 * time.Second, Result: result()}},
+			wantWER: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeChunkResults(tt.chunks)
+			if tt.wantNil {
+				if got != nil {
+					t.Fatalf("MergeChunkResults() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("MergeChunkResults() = nil")
+			}
+
+			var starts []float64
+			for _, o := range got.Ops {
+				starts = append(starts, o.TStart)
+			}
+			if !slices.Equal(starts, tt.wantStarts) {
+				t.Errorf("operations start at %v, want %v", starts, tt.wantStarts)
+			}
+			if math.Abs(got.WER-tt.wantWER) > 1e-9 {
+				t.Errorf("WER = %v, want %v", got.WER, tt.wantWER)
+			}
+			if got.Hypothesis != tt.wantHyp {
+				t.Errorf("Hypothesis = %q, want %q", got.Hypothesis, tt.wantHyp)
+			}
+		})
+	}
+}
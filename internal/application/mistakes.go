@@ -0,0 +1,98 @@
+package application
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// pronunciationSimilarity is how alike a recited word must be to the
+// expected one for the substitution to be taken for a pronunciation issue
+// rather than a different word
+const pronunciationSimilarity = 0.6
+
+// MistakeCategory is the kind of mistake a word of a result is
+type MistakeCategory string
+
+// Mistake categories, in the order they are summarized
+const (
+	MistakeSkipped       MistakeCategory = "skipped"       // a word was left out
+	MistakeWrong         MistakeCategory = "wrong"         // another word was recited
+	MistakePronunciation MistakeCategory = "pronunciation" // the word was recited, but sounded off
+	MistakeExtra         MistakeCategory = "extra"         // a word was added
+)
+
+var mistakeCategories = []MistakeCategory{MistakeSkipped, MistakeWrong, MistakePronunciation, MistakeExtra}
+
+// ClassifyMistake returns the category of an operation, or false if it is
+// not a mistake
+func ClassifyMistake(op domain.Operation) (MistakeCategory, bool) {
+	switch op.Op {
+	case domain.OpDeletion:
+		return MistakeSkipped, true
+	case domain.OpInsertion:
+		return MistakeExtra, true
+	case domain.OpSubstitution:
+		if domain.WordSimilarity(cleanWord(op.RefClean, op.RefAr), cleanWord(op.HypClean, op.HypAr)) >= pronunciationSimilarity {
+			return MistakePronunciation, true
+		}
+		return MistakeWrong, true
+	}
+	return "", false
+}
+
+// MistakeSummary counts the mistakes of a result by category
+type MistakeSummary struct {
+	Counts map[MistakeCategory]int
+	Total  int
+}
+
+// SummarizeMistakes classifies the mistakes of a result
+func SummarizeMistakes(ops []domain.Operation) MistakeSummary {
+	summary := MistakeSummary{Counts: make(map[MistakeCategory]int)}
+	for _, op := range ops {
+		if category, ok := ClassifyMistake(op); ok {
+			summary.Counts[category]++
+			summary.Total++
+		}
+	}
+	return summary
+}
+
+// Main returns the category most mistakes fall in, or false if there are
+// none. Ties go to the category summarized first.
+func (m MistakeSummary) Main() (MistakeCategory, bool) {
+	var main MistakeCategory
+	for _, category := range mistakeCategories {
+		if m.Counts[category] > m.Counts[main] {
+			main = category
+		}
+	}
+	return main, main != ""
+}
+
+// FormatMistakes formats the mistakes of a result by category, or an empty
+// string if there are none
+func (s *BotService) FormatMistakes(lang domain.Language, result *domain.RecordingResult) string {
+	summary := SummarizeMistakes(result.Ops)
+	if summary.Total == 0 {
+		return ""
+	}
+
+	var counts []string
+	for _, category := range mistakeCategories {
+		if n := summary.Counts[category]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, s.i18n.Get(lang, "mistakes."+string(category))))
+		}
+	}
+	return s.i18n.Get(lang, "mistakes.summary", strings.Join(counts, ", "))
+}
+
+// cleanWord returns the clean form of a word if the API sent one
+func cleanWord(clean, ar string) string {
+	if clean != "" {
+		return clean
+	}
+	return ar
+}
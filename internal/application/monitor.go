@@ -0,0 +1,173 @@
+package application
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Operations whose outcomes the monitor counts
+const (
+	OpConversion = "conversion"
+	OpSubmission = "submission"
+)
+
+// opPanic counts the panics recovered from
+const opPanic = "panic"
+
+const (
+	// monitorWindow is how far back outcomes are counted
+	monitorWindow = time.Hour
+
+	// recentSubmissionsSize is how many submissions the monitor remembers
+	recentSubmissionsSize = 20
+)
+
+// OperationStats counts the outcomes of an operation over a window
+type OperationStats struct {
+	Operation string
+	Total     int
+	Failed    int
+}
+
+// ErrorRate returns the share of failed operations, 0 if there were none
+func (o OperationStats) ErrorRate() float64 {
+	if o.Total == 0 {
+		return 0
+	}
+	return float64(o.Failed) / float64(o.Total)
+}
+
+// RecentSubmission is a recording submitted through this instance
+type RecentSubmission struct {
+	UserID      string
+	RecordingID string
+	AyahID      string
+	At          time.Time
+}
+
+// outcomeBucket counts the outcomes of one minute
+type outcomeBucket struct {
+	minute int64
+	total  int
+	failed int
+}
+
+// Monitor keeps operational figures for the admin dashboard and alerts: how
+// often conversions and submissions fail, how many panics were recovered
+// from, and the latest submissions. Figures are
+// kept in process, so each replica reports its own and they reset on
+// restart.
+type Monitor struct {
+	mu      sync.Mutex
+	buckets map[string][]outcomeBucket
+	recent  []RecentSubmission
+}
+
+func NewMonitor() *Monitor {
+	return &Monitor{buckets: make(map[string][]outcomeBucket)}
+}
+
+// Record counts the outcome of an operation. Recordings rejected because of
+// what the user sent are not failures of the bot and are counted as
+// successes.
+func (m *Monitor) Record(op string, err error, at time.Time) {
+	failed := err != nil && !isRejection(err)
+	minute := at.Unix() / 60
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := m.buckets[op]
+	if n := len(buckets); n == 0 || buckets[n-1].minute != minute {
+		buckets = append(buckets, outcomeBucket{minute: minute})
+	}
+	last := &buckets[len(buckets)-1]
+	last.total++
+	if failed {
+		last.failed++
+	}
+	m.buckets[op] = trimBuckets(buckets, at, monitorWindow)
+}
+
+// RecordPanic counts a panic recovered from
+func (m *Monitor) RecordPanic(at time.Time) {
+	m.Record(opPanic, nil, at)
+}
+
+// Panics returns how many panics were recovered from over the window before
+// now, at most an hour
+func (m *Monitor) Panics(now time.Time, window time.Duration) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	panics := 0
+	for _, b := range trimBuckets(m.buckets[opPanic], now, window) {
+		panics += b.total
+	}
+	return panics
+}
+
+// AddSubmission remembers a submitted recording, dropping the oldest beyond
+// the latest recentSubmissionsSize
+func (m *Monitor) AddSubmission(sub RecentSubmission) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recent = append(m.recent, sub)
+	if len(m.recent) > recentSubmissionsSize {
+		m.recent = m.recent[len(m.recent)-recentSubmissionsSize:]
+	}
+}
+
+// Stats returns the outcomes of every operation over the window before now,
+// at most an hour
+func (m *Monitor) Stats(now time.Time, window time.Duration) []OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]OperationStats, 0, 2)
+	for _, op := range []string{OpConversion, OpSubmission} {
+		s := OperationStats{Operation: op}
+		for _, b := range trimBuckets(m.buckets[op], now, window) {
+			s.Total += b.total
+			s.Failed += b.failed
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// RecentSubmissions returns the latest submissions, newest first
+func (m *Monitor) RecentSubmissions() []RecentSubmission {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recent := make([]RecentSubmission, len(m.recent))
+	for i, sub := range m.recent {
+		recent[len(m.recent)-1-i] = sub
+	}
+	return recent
+}
+
+// trimBuckets drops the buckets older than the window
+func trimBuckets(buckets []outcomeBucket, now time.Time, window time.Duration) []outcomeBucket {
+	oldest := now.Add(-window).Unix() / 60
+	i := 0
+	for i < len(buckets) && buckets[i].minute <= oldest {
+		i++
+	}
+	return buckets[i:]
+}
+
+// isRejection reports whether err is about what the user sent rather than a
+// failure of the bot
+func isRejection(err error) bool {
+	return errors.Is(err, domain.ErrUnsupportedAudio) ||
+		errors.Is(err, domain.ErrAudioTooShort) ||
+		errors.Is(err, domain.ErrAudioTooLarge) ||
+		errors.Is(err, domain.ErrSilentAudio) ||
+		errors.Is(err, domain.ErrNonSpeechAudio)
+}
@@ -0,0 +1,92 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// maxPins is the number of recordings a user can pin at once
+const maxPins = 20
+
+var errPinsDisabled = errors.New("pins are not kept")
+
+// WithPins lets users pin recordings to keep them at hand
+func WithPins(pins domain.PinPort) Option {
+	return func(s *BotService) {
+		s.pins = pins
+	}
+}
+
+// PinsEnabled reports whether users can pin recordings
+func (s *BotService) PinsEnabled() bool {
+	return s.pins != nil
+}
+
+// Pins returns the user's pinned recordings, latest pinned first. Without
+// a pin store there are none.
+func (s *BotService) Pins(ctx context.Context, userID string) ([]domain.Pin, error) {
+	if s.pins == nil {
+		return nil, nil
+	}
+	pins, err := s.pins.ListPins(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list pins: %w", err)
+	}
+	return pins, nil
+}
+
+// IsPinned reports whether the user pinned a recording
+func (s *BotService) IsPinned(ctx context.Context, userID, recordingID string) (bool, error) {
+	pins, err := s.Pins(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(pins, func(pin domain.Pin) bool {
+		return pin.RecordingID == recordingID
+	}), nil
+}
+
+// PinRecording pins one of the user's recordings. It fails with
+// domain.ErrTooManyPins once the user pinned as many as allowed.
+func (s *BotService) PinRecording(ctx context.Context, userID, recordingID string) error {
+	if s.pins == nil {
+		return errPinsDisabled
+	}
+	pins, err := s.Pins(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, pin := range pins {
+		if pin.RecordingID == recordingID {
+			return nil
+		}
+	}
+	if len(pins) >= maxPins {
+		return domain.ErrTooManyPins
+	}
+
+	recording, err := s.GetRecording(ctx, userID, recordingID)
+	if err != nil {
+		return err
+	}
+	pin := domain.Pin{RecordingID: recording.ID, AyahID: recording.AyahID, PinnedAt: s.clock.Now()}
+	if err := s.pins.PinRecording(ctx, userID, pin); err != nil {
+		return fmt.Errorf("pin recording: %w", err)
+	}
+	return nil
+}
+
+// UnpinRecording unpins one of the user's recordings
+func (s *BotService) UnpinRecording(ctx context.Context, userID, recordingID string) error {
+	if s.pins == nil {
+		return nil
+	}
+	if err := s.pins.UnpinRecording(ctx, userID, recordingID); err != nil {
+		return fmt.Errorf("unpin recording: %w", err)
+	}
+	return nil
+}
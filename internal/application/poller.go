@@ -0,0 +1,183 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+const (
+	pollInterval = 5 * time.Second
+	pollTimeout  = 5 * time.Minute
+
+	// defaultSlotTime is the analysis time of a recording assumed before
+	// any analysis was seen to finish
+	defaultSlotTime = 10 * time.Second
+)
+
+// QueuePosition is where a recording waiting for its analysis is among
+// those watched by this instance, in submission order, and about how long
+// it has left
+type QueuePosition struct {
+	Position  int // from 1
+	Remaining time.Duration
+}
+
+// WatchUpdate is what polling found out about a recording being watched
+type WatchUpdate struct {
+	Queue     QueuePosition     // while the recording is queued
+	Recording *domain.Recording // once its analysis finished
+	TimedOut  bool              // polling gave up before it finished
+}
+
+// watch is a recording being polled
+type watch struct {
+	since time.Time
+	ahead int // recordings watched when it started, itself included
+}
+
+// ResultPoller watches submitted recordings until the analysis finishes and
+// publishes a RecordingEvent on the event bus once the final status is known
+type ResultPoller struct {
+	quranAPI   domain.QuranAPIPort
+	bus        domain.EventBusPort
+	clock      domain.Clock
+	onComplete func(ctx context.Context, userID string, recording *domain.Recording)
+
+	mu      sync.Mutex
+	watches map[string]watch
+	// slotTime estimates how long the analysis of one recording takes,
+	// from how long finished ones waited and how many were ahead of them
+	slotTime time.Duration
+}
+
+// NewResultPoller creates a poller estimating queue times on clock.
+// onComplete, if not nil, is called with the finished recording before the
+// event is published.
+func NewResultPoller(quranAPI domain.QuranAPIPort, bus domain.EventBusPort, clock domain.Clock, onComplete func(ctx context.Context, userID string, recording *domain.Recording)) *ResultPoller {
+	return &ResultPoller{
+		quranAPI:   quranAPI,
+		bus:        bus,
+		clock:      clock,
+		onComplete: onComplete,
+		watches:    make(map[string]watch),
+		slotTime:   defaultSlotTime,
+	}
+}
+
+// Watch starts polling a recording in the background. onUpdate, if not
+// nil, is called with the recording's place in the queue when it starts
+// and after every poll that finds it still queued, then with the finished
+// recording, or once polling timed out. It reports whether the finished
+// recording was shown to the user, which the RecordingEvent published
+// tells. Recordings already watched are left to the poll in progress.
+func (p *ResultPoller) Watch(ctx context.Context, userID string, chatID int64, recordingID string, onUpdate func(ctx context.Context, update WatchUpdate) bool) {
+	p.mu.Lock()
+	if _, ok := p.watches[recordingID]; ok {
+		p.mu.Unlock()
+		return
+	}
+	p.watches[recordingID] = watch{since: p.clock.Now(), ahead: len(p.watches) + 1}
+	p.mu.Unlock()
+
+	go p.poll(ctx, userID, chatID, recordingID, onUpdate)
+}
+
+// Position returns where a watched recording is in the queue, and false if
+// it is not watched
+func (p *ResultPoller) Position(recordingID string) (QueuePosition, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w, ok := p.watches[recordingID]
+	if !ok {
+		return QueuePosition{}, false
+	}
+	position := 1
+	for id, other := range p.watches {
+		if id != recordingID && other.since.Before(w.since) {
+			position++
+		}
+	}
+	return QueuePosition{Position: position, Remaining: max(time.Duration(position)*p.slotTime, pollInterval)}, true
+}
+
+// done stops tracking a recording, learning from how long its analysis
+// took if it finished. Recordings no longer tracked are left alone.
+func (p *ResultPoller) done(recordingID string, finished bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w, ok := p.watches[recordingID]
+	if !ok {
+		return
+	}
+	delete(p.watches, recordingID)
+	if finished {
+		// Moving average, so that the estimate follows the backend's load
+		slot := p.clock.Now().Sub(w.since) / time.Duration(w.ahead)
+		p.slotTime = (3*p.slotTime + slot) / 4
+	}
+}
+
+func (p *ResultPoller) poll(ctx context.Context, userID string, chatID int64, recordingID string, onUpdate func(ctx context.Context, update WatchUpdate) bool) {
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+
+	defer p.done(recordingID, false)
+
+	update := func(ctx context.Context, update WatchUpdate) bool {
+		return onUpdate != nil && onUpdate(ctx, update)
+	}
+	report := func() {
+		if position, ok := p.Position(recordingID); ok {
+			update(ctx, WatchUpdate{Queue: position})
+		}
+	}
+	report()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			requestid.Printf(ctx, "Stopped polling recording %s: %v", recordingID, ctx.Err())
+			update(context.WithoutCancel(ctx), WatchUpdate{TimedOut: true})
+			return
+		case <-ticker.C:
+		}
+
+		recording, err := p.quranAPI.GetRecording(ctx, userID, recordingID)
+		if err != nil {
+			requestid.Printf(ctx, "Error polling recording %s: %v", recordingID, err)
+			continue
+		}
+		if recording.Status == domain.StatusQueued {
+			report()
+			continue
+		}
+		p.done(recordingID, true)
+
+		if p.onComplete != nil {
+			p.onComplete(ctx, userID, recording)
+		}
+		shown := update(ctx, WatchUpdate{Recording: recording})
+
+		event := domain.RecordingEvent{
+			RecordingID: recordingID,
+			UserID:      userID,
+			ChatID:      chatID,
+			AyahID:      recording.AyahID,
+			Status:      recording.Status,
+			Shown:       shown,
+		}
+		if err := p.bus.Publish(ctx, event); err != nil {
+			requestid.Printf(ctx, "Error publishing event for recording %s: %v", recordingID, err)
+		}
+		return
+	}
+}
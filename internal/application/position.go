@@ -0,0 +1,51 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// WithPositions remembers the last ayah each user recorded, so they can
+// continue reading a surah across sessions
+func WithPositions(positions domain.PositionPort) Option {
+	return func(s *BotService) {
+		s.positions = positions
+	}
+}
+
+// ContinueRecording selects the ayah following the one the user last
+// recorded, ready to be recorded, and returns it. It fails with
+// domain.ErrNoPosition if the user recorded none.
+func (s *BotService) ContinueRecording(ctx context.Context, scope domain.SessionScope) (domain.Ayah, error) {
+	if s.positions == nil {
+		return domain.Ayah{}, domain.ErrNoPosition
+	}
+	last, err := s.positions.GetPosition(ctx, scope.UserID)
+	if err != nil {
+		return domain.Ayah{}, fmt.Errorf("get position: %w", err)
+	}
+	if last == nil {
+		return domain.Ayah{}, domain.ErrNoPosition
+	}
+	next := last.Next(s.preferences(ctx, scope.UserID).Riwayah)
+
+	flow := domain.ManualRecordingFlow{SurahNumber: next.SurahNumber, AyahNumber: next.AyahNumber}
+	if err := s.selectAyah(ctx, scope, flow); err != nil {
+		return domain.Ayah{}, err
+	}
+	return next, nil
+}
+
+// savePosition remembers the ayah the user just recorded. Positions are
+// best-effort and never fail the caller.
+func (s *BotService) savePosition(ctx context.Context, userID string, ayah domain.Ayah) {
+	if s.positions == nil {
+		return
+	}
+	if err := s.positions.SetPosition(ctx, userID, ayah); err != nil {
+		requestid.Printf(ctx, "Error saving position of user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,23 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// Progress returns how much of each surah the user has recited, and how
+// well, from their whole history, numbered in their riwayah. It is empty
+// when no history is kept.
+func (s *BotService) Progress(ctx context.Context, userID string) ([]domain.SurahProgress, error) {
+	if s.stats == nil {
+		return nil, nil
+	}
+	stats, err := s.stats.ListStats(ctx, userID, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("list stats: %w", err)
+	}
+	return domain.ProgressFromStats(stats, s.preferences(ctx, userID).Riwayah), nil
+}
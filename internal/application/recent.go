@@ -0,0 +1,41 @@
+package application
+
+import (
+	"context"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// WithRecentSurahs remembers the surahs each user selected last, offered
+// first when they pick a surah
+func WithRecentSurahs(recent domain.RecentSurahPort) Option {
+	return func(s *BotService) {
+		s.recent = recent
+	}
+}
+
+// RecentSurahs returns the surahs the user selected last, latest first.
+// Recent surahs are best-effort: failures return none.
+func (s *BotService) RecentSurahs(ctx context.Context, userID string) []int {
+	if s.recent == nil {
+		return nil
+	}
+	surahs, err := s.recent.RecentSurahs(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting recent surahs of user %s: %v", userID, err)
+		return nil
+	}
+	return surahs
+}
+
+// addRecentSurah remembers that the user selected a surah. Recent surahs are
+// best-effort and never fail the caller.
+func (s *BotService) addRecentSurah(ctx context.Context, userID string, surah int) {
+	if s.recent == nil {
+		return
+	}
+	if err := s.recent.AddRecentSurah(ctx, userID, surah); err != nil {
+		requestid.Printf(ctx, "Error adding recent surah of user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,41 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// RetryRecording selects the ayah of one of the user's recordings again,
+// e.g. one whose analysis failed, ready to be recorded, and returns it. The
+// audio of the user's latest submission is returned too when it was of that
+// ayah and is still cached, so it can be resubmitted without recording
+// again, unless the analysis failed because of the audio itself.
+func (s *BotService) RetryRecording(ctx context.Context, scope domain.SessionScope, recordingID string) (domain.Ayah, *domain.CachedAudio, error) {
+	recording, err := s.GetRecording(ctx, scope.UserID, recordingID)
+	if err != nil {
+		return domain.Ayah{}, nil, err
+	}
+	ayah, err := domain.ParseAyahID(recording.AyahID)
+	if err != nil {
+		return domain.Ayah{}, nil, fmt.Errorf("recording %s: %w", recordingID, err)
+	}
+	if err := s.RecordAyah(ctx, scope, ayah); err != nil {
+		return domain.Ayah{}, nil, err
+	}
+
+	if recording.Status == domain.StatusFailed && !recording.FailureReason().Transient() {
+		return ayah, nil, nil
+	}
+	audio, err := s.LatestAudio(ctx, scope.UserID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting latest audio of user %s: %v", scope.UserID, err)
+		return ayah, nil, nil
+	}
+	if audio == nil || audio.AyahID != ayah.AyahID() {
+		return ayah, nil, nil
+	}
+	return ayah, audio, nil
+}
@@ -1,153 +1,494 @@
 package application
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/metrics"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+const (
+	// recordingsCacheFreshness is how long a cached recording list is served
+	// without asking the API
+	recordingsCacheFreshness = time.Minute
+
+	// recordingsCacheSize is the number of recordings fetched into the cache
+	recordingsCacheSize = 50
+
+	// maxRangeAyahs is the number of ayahs a range recorded in manual mode
+	// can span
+	maxRangeAyahs = 10
 )
 
 // BotService handles the business logic for the bot
 type BotService struct {
-	quranAPI domain.QuranAPIPort
-	fsm      domain.FSMPort
-	i18n     domain.I18nPort
+	quranAPI      domain.QuranAPIPort
+	fsm           domain.FSMPort
+	prefs         domain.PreferencesPort
+	i18n          domain.I18nPort
+	users         domain.UserRepository
+	names         *displayNames // display names stored, see RegisterLearner
+	bus           domain.EventBusPort
+	cache         domain.RecordingCachePort
+	stats         domain.StatsPort
+	board         domain.LeaderboardPort
+	limiter       domain.RateLimiterPort
+	audit         domain.AuditLogPort
+	audioCache    domain.AudioCachePort
+	archive       domain.AudioArchivePort
+	audio         domain.AudioProcessorPort
+	chunks        domain.RecordingChunkPort
+	chunkDuration time.Duration
+	activity      domain.ActivityPort
+	monitor       *Monitor
+	analytics     domain.AnalyticsSinkPort
+	events        chan domain.AnalyticsEvent
+	admins        map[string]bool
+	grades        domain.GradingScale
+	lifecycles    domain.RecordingLifecyclePort
+	goals         domain.GoalPort
+	challenges    domain.ChallengePort
+	positions     domain.PositionPort
+	recent        domain.RecentSurahPort
+	wirds         domain.WirdPort
+	curated       []domain.Ayah
+	khatmahs      domain.KhatmahPort
+	pins          domain.PinPort
+	tags          domain.TagPort
+	texts         domain.AyahTextPort
+	spoken        bool
+	drills        bool
+	clock         domain.Clock
+	hooks         []TransitionHook
+	poller        *ResultPoller
+}
+
+// Option configures optional dependencies of BotService
+type Option func(*BotService)
+
+// WithClock replaces the system clock, e.g. to freeze the time in tests
+func WithClock(clock domain.Clock) Option {
+	return func(s *BotService) {
+		s.clock = clock
+	}
+}
+
+// WithUserRepository enables persistent user profiles
+func WithUserRepository(users domain.UserRepository) Option {
+	return func(s *BotService) {
+		s.users = users
+	}
+}
+
+// WithEventBus enables background result polling and completion events
+func WithEventBus(bus domain.EventBusPort) Option {
+	return func(s *BotService) {
+		s.bus = bus
+	}
+}
+
+// WithRecordingCache keeps a local copy of each user's recording list
+func WithRecordingCache(cache domain.RecordingCachePort) Option {
+	return func(s *BotService) {
+		s.cache = cache
+	}
+}
+
+// WithStatsStore records every completed result in the user's history
+func WithStatsStore(stats domain.StatsPort) Option {
+	return func(s *BotService) {
+		s.stats = stats
+	}
+}
+
+// WithLeaderboard scores completed recordings on weekly and monthly boards
+func WithLeaderboard(board domain.LeaderboardPort) Option {
+	return func(s *BotService) {
+		s.board = board
+	}
+}
+
+// WithRateLimiter enables per-user submission quotas
+func WithRateLimiter(limiter domain.RateLimiterPort) Option {
+	return func(s *BotService) {
+		s.limiter = limiter
+	}
 }
 
-func NewBotService(quranAPI domain.QuranAPIPort, fsm domain.FSMPort, i18n domain.I18nPort) *BotService {
-	return &BotService{
+func NewBotService(quranAPI domain.QuranAPIPort, fsm domain.FSMPort, prefs domain.PreferencesPort, i18n domain.I18nPort, opts ...Option) *BotService {
+	s := &BotService{
 		quranAPI: quranAPI,
 		fsm:      fsm,
+		prefs:    prefs,
 		i18n:     i18n,
+		monitor:  NewMonitor(),
+		clock:    domain.SystemClock{},
+		names:    newDisplayNames(displayNameCacheSize),
+	}
+	s.grades, _ = domain.GradingScalePreset(domain.GradingScaleWords)
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	if s.chunks != nil {
+		s.quranAPI = &chunkedAPI{QuranAPIPort: s.quranAPI, chunks: s.chunks}
+	}
+	if s.bus != nil {
+		s.poller = NewResultPoller(s.quranAPI, s.bus, s.clock, s.completeRecording)
+	}
+
+	return s
 }
 
 // HandleStart handles the /start command
-func (s *BotService) HandleStart(ctx context.Context, userID string, lang domain.Language) error {
-	// Set initial state
-	if err := s.fsm.SetState(ctx, userID, domain.StateSelectSurah); err != nil {
-		return fmt.Errorf("set state: %w", err)
-	}
+func (s *BotService) HandleStart(ctx context.Context, scope domain.SessionScope, lang domain.Language) error {
+	userID := scope.UserID
 
 	// Store user language
-	if err := s.fsm.SetData(ctx, userID, domain.SessionKeyLanguage, string(lang)); err != nil {
-		return fmt.Errorf("set language: %w", err)
+	prefs, err := s.prefs.GetPreferences(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get preferences: %w", err)
 	}
+	if prefs.Language != lang {
+		prefs.Language = lang
+		if err := s.prefs.SetPreferences(ctx, userID, prefs); err != nil {
+			return fmt.Errorf("set language: %w", err)
+		}
+	}
+
+	// Set initial state, always allowed since it restarts the flow
+	err = s.transition(ctx, scope, domain.StateSelectSurah, func(session *domain.Session) error {
+		session.Mode = prefs.DefaultMode
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, userID, domain.AuditEntry{Action: domain.AuditStart, ChatID: scope.ChatID, Detail: string(lang)})
+	s.track(userID, domain.EventModeChosen, map[string]string{"mode": string(prefs.DefaultMode)})
+
+	// Touch the persistent profile so LastActiveAt is kept current
+	s.updateUser(ctx, userID, func(user *domain.User) {})
 
 	return nil
 }
 
-// GetCurrentState returns the current state for a user
-func (s *BotService) GetCurrentState(ctx context.Context, userID string) (domain.State, error) {
-	return s.fsm.GetState(ctx, userID)
+// GetSession returns a whole session in a single read
+func (s *BotService) GetSession(ctx context.Context, scope domain.SessionScope) (*domain.Session, error) {
+	return s.fsm.GetSession(ctx, scope)
+}
+
+// GetCurrentState returns the current state of a session
+func (s *BotService) GetCurrentState(ctx context.Context, scope domain.SessionScope) (domain.State, error) {
+	session, err := s.fsm.GetSession(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	return session.State, nil
+}
+
+// PopExpiredSessions returns up to limit sessions that expired in the middle
+// of a flow, so their users can be told to start over
+func (s *BotService) PopExpiredSessions(ctx context.Context, limit int) ([]domain.SessionScope, error) {
+	scopes, err := s.fsm.PopExpiredSessions(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		s.recordAudit(ctx, scope.UserID, domain.AuditEntry{Action: domain.AuditSessionExpired, ChatID: scope.ChatID})
+		s.track(scope.UserID, domain.EventSessionExpired, nil)
+	}
+	return scopes, nil
 }
 
 // HandleSurahSelection handles when a user selects a Surah
-func (s *BotService) HandleSurahSelection(ctx context.Context, userID string, surahNumber int) error {
+func (s *BotService) HandleSurahSelection(ctx context.Context, scope domain.SessionScope, surahNumber int) error {
 	// Validate surah number
 	surahs := domain.GetAllSurahs()
 	if surahNumber < 1 || surahNumber > len(surahs) {
 		return fmt.Errorf("invalid surah number: %d", surahNumber)
 	}
 
-	// Store selected surah
-	if err := s.fsm.SetData(ctx, userID, domain.SessionKeySurah, strconv.Itoa(surahNumber)); err != nil {
-		return fmt.Errorf("set surah: %w", err)
+	// Store selected surah and move to next state
+	err := s.transition(ctx, scope, domain.StateEnterAyah, func(session *domain.Session) error {
+		flow, err := session.ManualRecording()
+		if err != nil {
+			return err
+		}
+		flow.SurahNumber = surahNumber
+		session.SetManualRecording(flow)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	s.addRecentSurah(ctx, scope.UserID, surahNumber)
+	return nil
+}
 
-	// Move to next state
-	if err := s.fsm.SetState(ctx, userID, domain.StateEnterAyah); err != nil {
-		return fmt.Errorf("set state: %w", err)
+// HandleAyahInput handles when a user enters an Ayah number, or a range of
+// ayahs of the selected surah such as "5-7" to record one after the other
+func (s *BotService) HandleAyahInput(ctx context.Context, scope domain.SessionScope, input string) error {
+	first, last, err := parseAyahInput(input)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	// Validate against the selected surah in the user's riwayah, store the
+	// ayahs and move to the next state
+	riwayah := s.preferences(ctx, scope.UserID).Riwayah
+	return s.transition(ctx, scope, domain.StateWaitRecording, func(session *domain.Session) error {
+		flow, err := session.ManualRecording()
+		if err != nil {
+			return err
+		}
+		ayahs := domain.AyahRange{
+			Start: domain.Ayah{SurahNumber: flow.SurahNumber, AyahNumber: first},
+			End:   domain.Ayah{SurahNumber: flow.SurahNumber, AyahNumber: last},
+		}
+		if err := ayahs.Validate(riwayah); err != nil {
+			return err
+		}
+		if n := ayahs.Len(riwayah); n > maxRangeAyahs {
+			return fmt.Errorf("%w: range %s has %d ayahs, more than %d", domain.ErrInvalidAyah, ayahs, n, maxRangeAyahs)
+		}
+
+		flow.AyahNumber = first
+		if last != first {
+			flow.RangeStart, flow.RangeEnd = first, last
+		}
+		session.SetManualRecording(flow)
+		return nil
+	})
 }
 
-// HandleAyahInput handles when a user enters an Ayah number
-func (s *BotService) HandleAyahInput(ctx context.Context, userID, input string) error {
-	// Parse ayah number
-	ayahNumber, err := strconv.Atoi(input)
-	if err != nil {
-		return fmt.Errorf("invalid ayah number: %s", input)
+// selectAyah puts the session in the manual recording flow of flow and
+// leaves it waiting for the recording of its ayah, in a single session
+// update. Every state may go there, as surah selection, which every state
+// may go back to, leads to it; the steps in between are not taken.
+func (s *BotService) selectAyah(ctx context.Context, scope domain.SessionScope, flow domain.ManualRecordingFlow) error {
+	if err := flow.Range().Validate(s.preferences(ctx, scope.UserID).Riwayah); err != nil {
+		return err
 	}
 
-	// Get selected surah
-	surahStr, err := s.fsm.GetData(ctx, userID, domain.SessionKeySurah)
+	var from domain.State
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		from = session.State
+		session.Transition(domain.StateWaitRecording)
+		session.SetManualRecording(flow)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("get surah: %w", err)
+		return fmt.Errorf("update session: %w", err)
 	}
 
-	surahNumber, err := strconv.Atoi(surahStr)
+	s.runHooks(ctx, scope, from, domain.StateWaitRecording)
+	return nil
+}
+
+// ParseAyahRef parses a reference to an ayah the user typed, e.g. "2:255"
+// or "Al-Baqarah 255", surah names read in their language. It reports false
+// if text is not a reference.
+func (s *BotService) ParseAyahRef(ctx context.Context, userID, text string) (domain.Ayah, bool) {
+	return domain.ParseAyahRef(text, s.preferences(ctx, userID).Language)
+}
+
+// parseAyahInput parses an ayah number, or a range of ayah numbers such as
+// "5-7", into the first and last ayah
+func parseAyahInput(input string) (first, last int, err error) {
+	start, end, isRange := strings.Cut(strings.ReplaceAll(strings.TrimSpace(input), "–", "-"), "-")
+	first, err = strconv.Atoi(strings.TrimSpace(start))
 	if err != nil {
-		return fmt.Errorf("parse surah: %w", err)
+		return 0, 0, fmt.Errorf("%w: %s is not a number", domain.ErrInvalidAyah, input)
 	}
-
-	// Validate ayah number
-	surahs := domain.GetAllSurahs()
-	if surahNumber < 1 || surahNumber > len(surahs) {
-		return fmt.Errorf("invalid surah: %d", surahNumber)
+	if !isRange {
+		return first, first, nil
 	}
-
-	surah := surahs[surahNumber-1]
-	if ayahNumber < 1 || ayahNumber > surah.Ayahs {
-		return fmt.Errorf("invalid ayah number: %d (surah %d has %d ayahs)", ayahNumber, surahNumber, surah.Ayahs)
+	last, err = strconv.Atoi(strings.TrimSpace(end))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %s is not a range", domain.ErrInvalidAyah, input)
 	}
+	return first, last, nil
+}
 
-	// Store ayah number
-	if err := s.fsm.SetData(ctx, userID, domain.SessionKeyAyah, strconv.Itoa(ayahNumber)); err != nil {
-		return fmt.Errorf("set ayah: %w", err)
+// AllowSubmission takes one submission from the user's quota. If the quota is
+// used up it returns false and how long until the next submission is allowed.
+// Limiter failures allow the submission.
+func (s *BotService) AllowSubmission(ctx context.Context, userID string) (bool, time.Duration) {
+	if s.limiter == nil {
+		return true, 0
 	}
-
-	// Move to next state
-	if err := s.fsm.SetState(ctx, userID, domain.StateWaitRecording); err != nil {
-		return fmt.Errorf("set state: %w", err)
+	allowed, retryAfter, err := s.limiter.Allow(ctx, domain.RateActionSubmission, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error checking submission quota of user %s: %v", userID, err)
+		return true, 0
 	}
+	if !allowed {
+		s.recordAudit(ctx, userID, domain.AuditEntry{
+			Action: domain.AuditRateLimited,
+			Detail: fmt.Sprintf("retry after %s", retryAfter.Round(time.Second)),
+		})
+	}
+	return allowed, retryAfter
+}
 
-	return nil
+// Submission is a recording just submitted, and what the flow records next
+type Submission struct {
+	Recording *domain.Recording
+
+	// Range is the range the recording is part of, nil for single ayahs,
+	// and RangeStartedAt when its first ayah was recorded
+	Range          *domain.AyahRange
+	RangeStartedAt time.Time
+
+	// Next is the ayah of the range to record next, nil once every ayah of
+	// the range is recorded
+	Next *domain.Ayah
 }
 
-// HandleRecording handles when a user sends a voice recording
-func (s *BotService) HandleRecording(ctx context.Context, userID string, audioFile io.Reader) (*domain.Recording, error) {
+// HandleRecording handles when a user sends a voice recording of the
+// selected ayah. In a range the next ayah is selected, until the last.
+func (s *BotService) HandleRecording(ctx context.Context, scope domain.SessionScope, audioFile io.Reader, meta domain.AudioMetadata) (*Submission, error) {
+	userID := scope.UserID
+
 	// Get surah and ayah
-	surahStr, err := s.fsm.GetData(ctx, userID, domain.SessionKeySurah)
+	session, err := s.fsm.GetSession(ctx, scope)
 	if err != nil {
-		return nil, fmt.Errorf("get surah: %w", err)
+		return nil, fmt.Errorf("get session: %w", err)
 	}
-
-	ayahStr, err := s.fsm.GetData(ctx, userID, domain.SessionKeyAyah)
-	if err != nil {
-		return nil, fmt.Errorf("get ayah: %w", err)
+	flow, err := session.ManualRecording()
+	if err != nil || session.State != domain.StateWaitRecording || flow.SurahNumber == 0 || flow.AyahNumber == 0 {
+		return nil, s.resetFlow(ctx, scope, session.State, domain.StateWaitRecording)
 	}
 
-	surahNumber, _ := strconv.Atoi(surahStr)
-	ayahNumber, _ := strconv.Atoi(ayahStr)
+	ayahID := domain.FormatAyahID(flow.SurahNumber, flow.AyahNumber)
 
-	ayahID := domain.FormatAyahID(surahNumber, ayahNumber)
+	// Keep the audio before submitting, so it survives a failed submission
+	// and long recordings can be split
+	var data []byte
+	if s.audioCache != nil || s.archive != nil || s.splits(meta) {
+		data, err = io.ReadAll(audioFile)
+		if err != nil {
+			return nil, fmt.Errorf("read audio: %w", err)
+		}
+		s.cacheAudio(ctx, userID, ayahID, data, meta)
+		audioFile = bytes.NewReader(data)
+	}
 
 	// Submit recording to API
-	recording, err := s.quranAPI.SubmitRecording(ctx, userID, ayahID, audioFile)
+	submitted := time.Now()
+	prefs := s.preferences(ctx, userID)
+	recording, err := s.submitRecording(ctx, userID, ayahID, prefs.Riwayah, audioFile, data, meta)
+	metrics.VoiceStageDuration.Since("submit", submitted)
+	s.monitor.Record(OpSubmission, err, s.clock.Now())
 	if err != nil {
 		return nil, fmt.Errorf("submit recording: %w", err)
 	}
+	s.monitor.AddSubmission(RecentSubmission{
+		UserID:      userID,
+		RecordingID: recording.ID,
+		AyahID:      ayahID,
+		At:          s.clock.Now(),
+	})
+	s.trackRecording(ctx, scope, recording)
+	s.recordAudit(ctx, userID, domain.AuditEntry{
+		Action: domain.AuditSubmission,
+		ChatID: scope.ChatID,
+		Detail: fmt.Sprintf("recording %s of ayah %s", recording.ID, ayahID),
+	})
+	s.archiveAudio(ctx, userID, recording.ID, data, meta)
+	s.savePosition(ctx, userID, flow.Ayah())
+	s.trackKhatmah(ctx, userID, flow.Ayah())
+	if flow.ChallengeID != "" {
+		s.answerChallenge(ctx, domain.ChallengeAnswer{ChallengeID: flow.ChallengeID, UserID: userID, RecordingID: recording.ID})
+	}
 
-	// Reset state to allow new recording
-	if err := s.fsm.SetState(ctx, userID, domain.StateSelectSurah); err != nil {
-		return nil, fmt.Errorf("reset state: %w", err)
+	// Move on to the next ayah of a range, or reset state to allow a new
+	// recording
+	submission := &Submission{Recording: recording}
+	next := flow
+	if next.Recorded(s.clock.Now()) {
+		err = s.transition(ctx, scope, domain.StateWaitRecording, func(session *domain.Session) error {
+			session.SetManualRecording(next)
+			return nil
+		})
+		ayah := next.Ayah()
+		submission.Next = &ayah
+	} else {
+		err = s.transition(ctx, scope, domain.StateSelectSurah, nil)
+	}
+	if next.IsRange() {
+		ayahs := next.Range()
+		submission.Range = &ayahs
+		submission.RangeStartedAt = next.RangeStartedAt
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return recording, nil
+	s.updateUser(ctx, userID, func(user *domain.User) {
+		user.RecordRecording(s.clock.Now(), prefs.DayBoundary())
+	})
+	s.invalidateRecordings(ctx, userID)
+
+	return submission, nil
+}
+
+// WatchRecording polls a submitted recording in the background and publishes
+// a RecordingEvent once its analysis is finished. onUpdate, if not nil, is
+// kept up to date with the recording's place in the queue meanwhile, then
+// given the finished recording, reporting whether it showed it to the user.
+func (s *BotService) WatchRecording(ctx context.Context, userID string, chatID int64, recordingID string, onUpdate func(ctx context.Context, update WatchUpdate) bool) {
+	if s.poller == nil {
+		return
+	}
+	s.advanceRecording(ctx, userID, recordingID, domain.StageProcessing)
+	if onUpdate != nil {
+		// Hand the result over as GetRecording does
+		report := onUpdate
+		onUpdate = func(ctx context.Context, update WatchUpdate) bool {
+			if update.Recording != nil && s.preferences(ctx, userID).SeparateBasmala {
+				update.Recording = update.Recording.WithBasmalaSeparated()
+			}
+			return report(ctx, update)
+		}
+	}
+	s.poller.Watch(ctx, userID, chatID, recordingID, onUpdate)
+}
+
+// SubscribeRecordingEvents calls handler for every recording event published
+// by any replica until ctx is done
+func (s *BotService) SubscribeRecordingEvents(ctx context.Context, handler func(ctx context.Context, event domain.RecordingEvent)) error {
+	if s.bus == nil {
+		return nil
+	}
+	return s.bus.Subscribe(ctx, func(ctx context.Context, event domain.RecordingEvent) {
+		s.invalidateRecordings(ctx, event.UserID)
+		handler(ctx, event)
+	})
+}
+
+// Now returns the time on the service's clock, for adapters to format times
+// consistently with it
+func (s *BotService) Now() time.Time {
+	return s.clock.Now()
 }
 
 // GetUserLanguage retrieves the user's preferred language
 func (s *BotService) GetUserLanguage(ctx context.Context, userID string) domain.Language {
-	langStr, err := s.fsm.GetData(ctx, userID, domain.SessionKeyLanguage)
-	if err != nil || langStr == "" {
+	prefs, err := s.prefs.GetPreferences(ctx, userID)
+	if err != nil || prefs.Language == "" {
 		return domain.LangEnglish // default
 	}
-	return domain.Language(langStr)
+	return prefs.Language
 }
 
 // FormatRecordingResult formats the recording result for display
@@ -158,6 +499,17 @@ func (s *BotService) FormatRecordingResult(lang domain.Language, recording *doma
 
 	var sb strings.Builder
 
+	sb.WriteString(s.FormatGrade(lang, recording.Result.WER))
+	sb.WriteString("\n")
+	if mistakes := s.FormatMistakes(lang, recording.Result); mistakes != "" {
+		sb.WriteString(mistakes)
+		sb.WriteString("\n")
+	}
+	if tip := s.FormatCoachingTip(lang, recording.Result); tip != "" {
+		sb.WriteString(tip)
+		sb.WriteString("\n")
+	}
+
 	// Show WER (Word Error Rate)
 	sb.WriteString(fmt.Sprintf("%s: %.2f%%\n\n", s.i18n.Get(lang, "recording.wer"), recording.Result.WER*100))
 
@@ -176,54 +528,229 @@ func (s *BotService) FormatRecordingResult(lang domain.Language, recording *doma
 			emoji = "❌"
 		case domain.OpInsertion:
 			emoji = "➕"
+		case domain.OpBasmala:
+			emoji = "📿"
 		}
 
-		sb.WriteString(fmt.Sprintf("%s %s (%s)\n", emoji, op.RefAr, op.Op))
+		word := op.RefAr
+		if op.Op == domain.OpBasmala {
+			word = op.HypAr
+		}
+		sb.WriteString(fmt.Sprintf("%s %s (%s)\n", emoji, word, op.Op))
 	}
 
 	return sb.String()
 }
 
-// GetSelectedSurah returns the currently selected surah for a user
-func (s *BotService) GetSelectedSurah(ctx context.Context, userID string) (int, error) {
-	surahStr, err := s.fsm.GetData(ctx, userID, domain.SessionKeySurah)
-	if err != nil {
-		return 0, fmt.Errorf("get surah: %w", err)
-	}
-
-	return strconv.Atoi(surahStr)
-}
-
 // GetAllSurahs returns all surahs
 func (s *BotService) GetAllSurahs() []domain.Surah {
 	return domain.GetAllSurahs()
 }
 
-// GetAyahInput gets the accumulated ayah input for a user
-func (s *BotService) GetAyahInput(ctx context.Context, userID string) string {
-	input, err := s.fsm.GetData(ctx, userID, domain.SessionKeyAyahInput)
+// HasSajdah reports whether an ayah, numbered in the user's riwayah, is an
+// ayah of prostration
+func (s *BotService) HasSajdah(ctx context.Context, userID string, ayah domain.Ayah) bool {
+	return ayah.HasSajdah(s.preferences(ctx, userID).Riwayah)
+}
+
+// ValidAyah reports whether an ayah exists in the user's riwayah
+func (s *BotService) ValidAyah(ctx context.Context, userID string, ayah domain.Ayah) bool {
+	return ayah.Validate(s.preferences(ctx, userID).Riwayah) == nil
+}
+
+// AyahCount returns the number of ayahs of a surah in the user's riwayah
+func (s *BotService) AyahCount(ctx context.Context, userID string, surah domain.Surah) int {
+	return surah.AyahCount(s.preferences(ctx, userID).Riwayah)
+}
+
+// ManualRecording returns the context of the manual recording flow of a
+// session. It fails with domain.ErrWrongFlow if the session is in another
+// flow.
+func (s *BotService) ManualRecording(ctx context.Context, scope domain.SessionScope) (domain.ManualRecordingFlow, error) {
+	session, err := s.fsm.GetSession(ctx, scope)
 	if err != nil {
-		return ""
+		return domain.ManualRecordingFlow{}, err
 	}
-	return input
+	return session.ManualRecording()
 }
 
-// SetAyahInput sets the accumulated ayah input for a user
-func (s *BotService) SetAyahInput(ctx context.Context, userID, input string) error {
-	return s.fsm.SetData(ctx, userID, domain.SessionKeyAyahInput, input)
+// UpdateAyahInput atomically replaces the accumulated ayah input with
+// fn(input) and returns the updated manual recording flow
+func (s *BotService) UpdateAyahInput(ctx context.Context, scope domain.SessionScope, fn func(input string) string) (domain.ManualRecordingFlow, error) {
+	var flow domain.ManualRecordingFlow
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		var err error
+		flow, err = session.ManualRecording()
+		if err != nil {
+			return err
+		}
+		flow.AyahInput = fn(flow.AyahInput)
+		session.SetManualRecording(flow)
+		return nil
+	})
+	if err != nil {
+		return domain.ManualRecordingFlow{}, fmt.Errorf("update ayah input: %w", err)
+	}
+	return flow, nil
 }
 
-// ClearAyahInput clears the accumulated ayah input for a user
-func (s *BotService) ClearAyahInput(ctx context.Context, userID string) error {
-	return s.fsm.DeleteData(ctx, userID, domain.SessionKeyAyahInput)
+// SetLastMessageID remembers the last message with an inline keyboard sent in
+// the session's chat and returns the previous one, 0 if there is none
+func (s *BotService) SetLastMessageID(ctx context.Context, scope domain.SessionScope, messageID int) (int, error) {
+	var previous int
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		previous = session.LastMessageID
+		session.LastMessageID = messageID
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("update session: %w", err)
+	}
+	return previous, nil
 }
 
 // GetRecording retrieves a specific recording by ID
 func (s *BotService) GetRecording(ctx context.Context, userID, recordingID string) (*domain.Recording, error) {
-	return s.quranAPI.GetRecording(ctx, userID, recordingID)
+	recording, err := s.quranAPI.GetRecording(ctx, userID, recordingID)
+	if err != nil {
+		return nil, err
+	}
+	if s.preferences(ctx, userID).SeparateBasmala {
+		recording = recording.WithBasmalaSeparated()
+	}
+	s.recordStat(ctx, userID, recording)
+	return recording, nil
 }
 
-// ListRecordings retrieves all recordings for a user
-func (s *BotService) ListRecordings(ctx context.Context, userID string, limit int) ([]*domain.Recording, error) {
-	return s.quranAPI.ListRecordings(ctx, userID, limit)
+// ListRecordings retrieves the summaries of a user's recordings, with the
+// stage of those tracked. A cached list is served while fresh, and as a
+// fallback when the API is unavailable.
+func (s *BotService) ListRecordings(ctx context.Context, userID string, limit int) ([]domain.RecordingSummary, error) {
+	summaries, err := s.listRecordings(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.withStages(ctx, userID, summaries), nil
+}
+
+func (s *BotService) listRecordings(ctx context.Context, userID string, limit int) ([]domain.RecordingSummary, error) {
+	if s.cache == nil {
+		recordings, err := s.quranAPI.ListRecordings(ctx, userID, limit)
+		if err != nil {
+			return nil, err
+		}
+		return summarize(recordings, s.preferences(ctx, userID).SeparateBasmala), nil
+	}
+
+	cached, fetchedAt, err := s.cache.GetRecordings(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error reading cached recordings of user %s: %v", userID, err)
+	}
+	if !fetchedAt.IsZero() && s.clock.Now().Sub(fetchedAt) < recordingsCacheFreshness {
+		return truncate(cached, limit), nil
+	}
+
+	recordings, err := s.quranAPI.ListRecordings(ctx, userID, recordingsCacheSize)
+	if err != nil {
+		if fetchedAt.IsZero() {
+			return nil, err
+		}
+		requestid.Printf(ctx, "Serving stale recordings of user %s: %v", userID, err)
+		return truncate(cached, limit), nil
+	}
+
+	summaries := summarize(recordings, s.preferences(ctx, userID).SeparateBasmala)
+	if err := s.cache.SetRecordings(ctx, userID, summaries, s.clock.Now()); err != nil {
+		requestid.Printf(ctx, "Error caching recordings of user %s: %v", userID, err)
+	}
+	return truncate(summaries, limit), nil
+}
+
+// completeRecording runs once the poller sees a recording finish
+func (s *BotService) completeRecording(ctx context.Context, userID string, recording *domain.Recording) {
+	if s.preferences(ctx, userID).SeparateBasmala {
+		recording = recording.WithBasmalaSeparated()
+	}
+	s.recordStat(ctx, userID, recording)
+	s.trackResult(userID, recording)
+}
+
+// recordStat appends a finished recording to the user's history. Appends are
+// idempotent, so it is safe to call whenever a result is seen.
+func (s *BotService) recordStat(ctx context.Context, userID string, recording *domain.Recording) {
+	stat, ok := recording.Stat()
+	if !ok {
+		return
+	}
+
+	if s.stats != nil {
+		if err := s.stats.AppendStat(ctx, userID, stat); err != nil {
+			requestid.Printf(ctx, "Error recording stat for recording %s: %v", recording.ID, err)
+		}
+	}
+
+	if s.board != nil {
+		points := domain.RecordingPoints(stat)
+		if err := s.board.AddScore(ctx, userID, stat.RecordingID, points, stat.RecordedAt); err != nil {
+			requestid.Printf(ctx, "Error scoring recording %s: %v", recording.ID, err)
+		}
+	}
+}
+
+// invalidateRecordings drops the cached recording list so the next listing
+// refetches it
+func (s *BotService) invalidateRecordings(ctx context.Context, userID string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.DeleteRecordings(ctx, userID); err != nil {
+		requestid.Printf(ctx, "Error invalidating recordings of user %s: %v", userID, err)
+	}
+}
+
+// updateUser applies fn to the user's persistent profile, created if needed,
+// and marks them active. Profile persistence is best-effort and never fails
+// the caller.
+func (s *BotService) updateUser(ctx context.Context, userID string, fn func(user *domain.User)) {
+	if s.users == nil {
+		return
+	}
+
+	now := s.clock.Now()
+	err := s.users.UpdateUser(ctx, userID, now, func(user *domain.User) {
+		user.LastActiveAt = now
+		fn(user)
+	})
+	if err != nil {
+		requestid.Printf(ctx, "Error saving user %s: %v", userID, err)
+	}
+}
+
+// preferences returns the user's preferences, or the defaults if they
+// cannot be read
+func (s *BotService) preferences(ctx context.Context, userID string) domain.Preferences {
+	prefs, err := s.prefs.GetPreferences(ctx, userID)
+	if err != nil {
+		requestid.Printf(ctx, "Error getting preferences of user %s: %v", userID, err)
+		return domain.DefaultPreferences()
+	}
+	return prefs
+}
+
+func summarize(recordings []*domain.Recording, separateBasmala bool) []domain.RecordingSummary {
+	summaries := make([]domain.RecordingSummary, 0, len(recordings))
+	for _, rec := range recordings {
+		if separateBasmala {
+			rec = rec.WithBasmalaSeparated()
+		}
+		summaries = append(summaries, rec.Summary())
+	}
+	return summaries
+}
+
+func truncate(summaries []domain.RecordingSummary, limit int) []domain.RecordingSummary {
+	if limit > 0 && len(summaries) > limit {
+		return summaries[:limit]
+	}
+	return summaries
 }
@@ -0,0 +1,67 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// GetPreferences returns the user's preferences
+func (s *BotService) GetPreferences(ctx context.Context, userID string) (domain.Preferences, error) {
+	prefs, err := s.prefs.GetPreferences(ctx, userID)
+	if err != nil {
+		return prefs, fmt.Errorf("get preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// SetTimezone sets the user's time zone, an IANA name such as
+// "Europe/Moscow". It fails with domain.ErrInvalidTimezone for others.
+func (s *BotService) SetTimezone(ctx context.Context, userID, timezone string) (domain.Preferences, error) {
+	if !domain.ValidTimezone(timezone) {
+		return domain.Preferences{}, fmt.Errorf("%w: %q", domain.ErrInvalidTimezone, timezone)
+	}
+	return s.UpdatePreferences(ctx, userID, func(prefs *domain.Preferences) {
+		prefs.Timezone = timezone
+	})
+}
+
+// SuggestTimezones returns the time zones of a UTC offset now
+func (s *BotService) SuggestTimezones(offset time.Duration) []string {
+	return domain.SuggestTimezones(offset, s.clock.Now())
+}
+
+// LocalTimeOffset returns the UTC offset of a user whose clock reads local,
+// e.g. "14:30". It reports false if local is not a time.
+func (s *BotService) LocalTimeOffset(local string) (time.Duration, bool) {
+	return domain.LocalTimeOffset(local, s.clock.Now())
+}
+
+// UserTime returns t in the user's time zone, e.g. to show when they
+// recorded
+func (s *BotService) UserTime(ctx context.Context, userID string, t time.Time) time.Time {
+	return t.In(s.preferences(ctx, userID).Location())
+}
+
+// UpdatePreferences applies fn to the user's preferences, stores them and
+// returns the result
+func (s *BotService) UpdatePreferences(ctx context.Context, userID string, fn func(prefs *domain.Preferences)) (domain.Preferences, error) {
+	prefs, err := s.prefs.GetPreferences(ctx, userID)
+	if err != nil {
+		return prefs, fmt.Errorf("get preferences: %w", err)
+	}
+
+	previous := prefs
+	fn(&prefs)
+
+	if err := s.prefs.SetPreferences(ctx, userID, prefs); err != nil {
+		return prefs, fmt.Errorf("set preferences: %w", err)
+	}
+	// Cached summaries carry WERs computed with the previous setting
+	if prefs.SeparateBasmala != previous.SeparateBasmala {
+		s.invalidateRecordings(ctx, userID)
+	}
+	return prefs, nil
+}
@@ -0,0 +1,93 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+const (
+	// minSpokenDuration is the duration below which a voice message holds
+	// too little to say an ayah, and is not submitted
+	minSpokenDuration = 500 * time.Millisecond
+	// transcriptionPollInterval and transcriptionTimeout bound the wait for
+	// the transcription of a voice message
+	transcriptionPollInterval = time.Second
+	transcriptionTimeout      = 30 * time.Second
+)
+
+// WithSpokenNavigation lets users say the ayah to go to, e.g. "سورة الكهف
+// آية عشرة", in a short voice message, auto-detected by the API
+func WithSpokenNavigation() Option {
+	return func(s *BotService) {
+		s.spoken = true
+	}
+}
+
+// SpokenNavigationEnabled reports whether users can say the ayah to go to
+func (s *BotService) SpokenNavigationEnabled() bool {
+	return s.spoken
+}
+
+// DetectSpokenAyah transcribes a short voice message and parses what was
+// heard as a reference to an ayah said aloud. It reports false if no ayah
+// of the user's riwayah was said, or the message is too short to hold one.
+func (s *BotService) DetectSpokenAyah(ctx context.Context, userID string, audioFile io.Reader, meta domain.AudioMetadata) (domain.Ayah, bool, error) {
+	if !s.spoken || (meta.Duration > 0 && meta.Duration < minSpokenDuration) {
+		return domain.Ayah{}, false, nil
+	}
+
+	heard, err := s.transcribe(ctx, userID, audioFile, meta)
+	if err != nil {
+		return domain.Ayah{}, false, err
+	}
+	ayah, ok := domain.ParseSpokenAyahRef(heard)
+	if !ok || ayah.Validate(s.preferences(ctx, userID).Riwayah) != nil {
+		return domain.Ayah{}, false, nil
+	}
+	return ayah, true, nil
+}
+
+// transcribe submits a voice message for auto-detection, without an ayah,
+// and returns what the API heard, empty if nothing. The submission is
+// deleted once transcribed, so it does not show among the user's
+// recordings.
+func (s *BotService) transcribe(ctx context.Context, userID string, audioFile io.Reader, meta domain.AudioMetadata) (string, error) {
+	prefs := s.preferences(ctx, userID)
+	recording, err := s.quranAPI.SubmitRecording(ctx, userID, "", prefs.Riwayah, audioFile, meta)
+	s.monitor.Record(OpSubmission, err, s.clock.Now())
+	if err != nil {
+		return "", fmt.Errorf("submit transcription: %w", err)
+	}
+	recordingID := recording.ID
+	defer func() {
+		if err := s.quranAPI.DeleteRecording(context.WithoutCancel(ctx), userID, recordingID); err != nil {
+			requestid.Printf(ctx, "Error deleting transcription %s: %v", recordingID, err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, transcriptionTimeout)
+	defer cancel()
+	ticker := time.NewTicker(transcriptionPollInterval)
+	defer ticker.Stop()
+	for recording.Status == domain.StatusQueued {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("wait for transcription: %w", ctx.Err())
+		case <-ticker.C:
+		}
+		if recording, err = s.quranAPI.GetRecording(ctx, userID, recordingID); err != nil {
+			return "", fmt.Errorf("get transcription: %w", err)
+		}
+	}
+
+	// Failures, e.g. a message too short for the API, heard nothing
+	if recording.Result == nil {
+		return "", nil
+	}
+	return recording.Result.Hypothesis, nil
+}
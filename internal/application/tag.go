@@ -0,0 +1,176 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+var errTagsDisabled = errors.New("tags are not kept")
+
+// WithTags lets users tag their recordings, e.g. "morning" or "with
+// teacher", and filter their recordings by tag
+func WithTags(tags domain.TagPort) Option {
+	return func(s *BotService) {
+		s.tags = tags
+	}
+}
+
+// TagsEnabled reports whether users can tag recordings
+func (s *BotService) TagsEnabled() bool {
+	return s.tags != nil
+}
+
+// UserTags returns every tag the user put on a recording, sorted
+func (s *BotService) UserTags(ctx context.Context, userID string) ([]string, error) {
+	if s.tags == nil {
+		return nil, nil
+	}
+	byRecording, err := s.tags.ListTags(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	var tags []string
+	for _, list := range byRecording {
+		tags = append(tags, list...)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags), nil
+}
+
+// RecordingTags returns the tags of one of the user's recordings, sorted
+func (s *BotService) RecordingTags(ctx context.Context, userID, recordingID string) ([]string, error) {
+	if s.tags == nil {
+		return nil, nil
+	}
+	byRecording, err := s.tags.ListTags(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	return byRecording[recordingID], nil
+}
+
+// TagRecording adds a tag to one of the user's recordings and returns the
+// tag as kept. It fails with domain.ErrInvalidTag if the tag is empty or
+// too long and domain.ErrTooManyTags once the recording has as many tags as
+// allowed.
+func (s *BotService) TagRecording(ctx context.Context, userID, recordingID, tag string) (string, error) {
+	if s.tags == nil {
+		return "", errTagsDisabled
+	}
+	tag, err := domain.NormalizeTag(tag)
+	if err != nil {
+		return "", err
+	}
+	tags, err := s.RecordingTags(ctx, userID, recordingID)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(tags, tag) {
+		return tag, nil
+	}
+	if len(tags) >= domain.MaxTagsPerRecording {
+		return "", domain.ErrTooManyTags
+	}
+
+	// Make sure the recording is the user's
+	if _, err := s.GetRecording(ctx, userID, recordingID); err != nil {
+		return "", err
+	}
+	if err := s.tags.SetTags(ctx, userID, recordingID, append(tags, tag)); err != nil {
+		return "", fmt.Errorf("set tags: %w", err)
+	}
+	return tag, nil
+}
+
+// UntagRecording removes a tag from one of the user's recordings
+func (s *BotService) UntagRecording(ctx context.Context, userID, recordingID, tag string) error {
+	if s.tags == nil {
+		return nil
+	}
+	tags, err := s.RecordingTags(ctx, userID, recordingID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(tags, tag) {
+		return nil
+	}
+	tags = slices.DeleteFunc(slices.Clone(tags), func(t string) bool { return t == tag })
+	if err := s.tags.SetTags(ctx, userID, recordingID, tags); err != nil {
+		return fmt.Errorf("set tags: %w", err)
+	}
+	return nil
+}
+
+// TaggedRecordings returns the summaries of the user's recordings with a
+// tag, latest first
+func (s *BotService) TaggedRecordings(ctx context.Context, userID, tag string) ([]domain.RecordingSummary, error) {
+	if s.tags == nil {
+		return nil, nil
+	}
+	byRecording, err := s.tags.ListTags(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	summaries, err := s.ListRecordings(ctx, userID, recordingsCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	var tagged []domain.RecordingSummary
+	for _, summary := range summaries {
+		if slices.Contains(byRecording[summary.ID], tag) {
+			tagged = append(tagged, summary)
+		}
+	}
+	return tagged, nil
+}
+
+// AwaitTag makes the next text the user sends in the session's chat a new
+// tag of one of their recordings, pausing the flow the session is in
+func (s *BotService) AwaitTag(ctx context.Context, scope domain.SessionScope, recordingID string) error {
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		session.StartTag(recordingID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// TagFromText adds the text the user sent as a tag of the recording they
+// asked to tag, if any, and resumes the flow the tag flow paused. It returns
+// the recording tagged, empty if the user was not typing a tag.
+func (s *BotService) TagFromText(ctx context.Context, scope domain.SessionScope, text string) (string, error) {
+	session, err := s.fsm.GetSession(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	if flow, err := session.Tag(); err != nil || flow.RecordingID == "" {
+		return "", nil
+	}
+
+	var recordingID string
+	_, err = s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		flow, err := session.Tag()
+		if err != nil {
+			return nil
+		}
+		recordingID = flow.RecordingID
+		session.Resume()
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("update session: %w", err)
+	}
+	if recordingID == "" {
+		return "", nil
+	}
+	if _, err := s.TagRecording(ctx, scope.UserID, recordingID, text); err != nil {
+		return recordingID, err
+	}
+	return recordingID, nil
+}
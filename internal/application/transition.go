@@ -0,0 +1,98 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+	"github.com/escalopa/quran-read-bot/internal/requestid"
+)
+
+// TransitionHook is called after a session moved from one state to another,
+// including transitions to the same state
+type TransitionHook func(ctx context.Context, scope domain.SessionScope, from, to domain.State)
+
+// WithTransitionHook registers a hook that runs after every state transition
+func WithTransitionHook(hook TransitionHook) Option {
+	return func(s *BotService) {
+		s.hooks = append(s.hooks, hook)
+	}
+}
+
+// LogTransitions is a TransitionHook that logs every state change
+func LogTransitions(ctx context.Context, scope domain.SessionScope, from, to domain.State) {
+	requestid.Printf(ctx, "Session %s: %s -> %s", scope, from, to)
+}
+
+// transition validates and applies a state change in a single atomic session
+// update. mutate, if not nil, runs first and can set the data the new state
+// needs; an error from it aborts the update without writing anything. A
+// disallowed transition, or a session in another flow than mutate expects,
+// resets the flow instead. Hooks run once the session is saved.
+func (s *BotService) transition(ctx context.Context, scope domain.SessionScope, to domain.State, mutate func(session *domain.Session) error) error {
+	var from domain.State
+	allowed := true
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		from = session.State
+		if !domain.CanTransition(from, to) {
+			allowed = false
+			return errTransitionRejected
+		}
+		if mutate != nil {
+			if err := mutate(session); err != nil {
+				return err
+			}
+		}
+		session.Transition(to)
+		return nil
+	})
+	if !allowed || errors.Is(err, domain.ErrWrongFlow) {
+		return s.resetFlow(ctx, scope, from, to)
+	}
+	if err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+
+	s.runHooks(ctx, scope, from, to)
+	return nil
+}
+
+// resetFlow auto-corrects an inconsistent session by sending the user back to
+// surah selection. It returns an error wrapping ErrInvalidTransition so the
+// caller can tell the user to start over, and ErrSessionExpired too if the
+// session was gone.
+func (s *BotService) resetFlow(ctx context.Context, scope domain.SessionScope, from, to domain.State) error {
+	requestid.Printf(ctx, "Resetting flow of session %s: %s -> %s is not allowed", scope, from, to)
+
+	var current domain.State
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		current = session.State
+		session.Transition(domain.StateSelectSurah)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reset flow: %w", err)
+	}
+	s.runHooks(ctx, scope, current, domain.StateSelectSurah)
+	s.recordAudit(ctx, scope.UserID, domain.AuditEntry{
+		Action: domain.AuditFlowReset,
+		ChatID: scope.ChatID,
+		Detail: fmt.Sprintf("%s -> %s is not allowed", from, to),
+	})
+
+	if from == domain.StateStart {
+		return fmt.Errorf("%w: %w: %s -> %s", domain.ErrSessionExpired, domain.ErrInvalidTransition, from, to)
+	}
+	return fmt.Errorf("%w: %s -> %s", domain.ErrInvalidTransition, from, to)
+}
+
+func (s *BotService) runHooks(ctx context.Context, scope domain.SessionScope, from, to domain.State) {
+	for _, hook := range s.hooks {
+		hook(ctx, scope, from, to)
+	}
+}
+
+// errTransitionRejected aborts a session update whose transition is not
+// allowed
+var errTransitionRejected = errors.New("transition rejected")
@@ -0,0 +1,102 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+var errTypingDisabled = errors.New("no quran text to check typed ayahs")
+
+// WithAyahTexts lets users type ayahs from memory instead of reciting them,
+// checked against the text of the Quran without any audio
+func WithAyahTexts(texts domain.AyahTextPort) Option {
+	return func(s *BotService) {
+		s.texts = texts
+	}
+}
+
+// TypingEnabled reports whether users can type ayahs from memory
+func (s *BotService) TypingEnabled() bool {
+	return s.texts != nil
+}
+
+// SessionMode returns the practice mode of the user's current flow
+func (s *BotService) SessionMode(ctx context.Context, scope domain.SessionScope) domain.Mode {
+	session, err := s.fsm.GetSession(ctx, scope)
+	if err != nil {
+		return ""
+	}
+	return session.Mode
+}
+
+// SetMode switches the practice mode of the user's current flow, e.g. to
+// type the selected ayah instead of reciting it
+func (s *BotService) SetMode(ctx context.Context, scope domain.SessionScope, mode domain.Mode) error {
+	if mode == domain.ModeTyped && s.texts == nil {
+		return errTypingDisabled
+	}
+	_, err := s.fsm.UpdateSession(ctx, scope, func(session *domain.Session) error {
+		session.Mode = mode
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("set mode: %w", err)
+	}
+	s.track(scope.UserID, domain.EventModeChosen, map[string]string{"mode": string(mode)})
+	return nil
+}
+
+// TypedCheck is an ayah typed from memory, as checked against its text
+type TypedCheck struct {
+	Ayah   domain.Ayah
+	Result domain.RecordingResult
+
+	// Next is the ayah of the range to type next, nil for single ayahs and
+	// once every ayah of the range is typed
+	Next *domain.Ayah
+}
+
+// CheckTypedAyah checks the text the user typed from memory against the
+// selected ayah, word by word, like a recitation. In a range the next ayah
+// is selected, until the last. It fails with domain.ErrNoAyahText if the
+// text of the ayah in the user's riwayah is not known.
+func (s *BotService) CheckTypedAyah(ctx context.Context, scope domain.SessionScope, typed string) (*TypedCheck, error) {
+	if s.texts == nil {
+		return nil, errTypingDisabled
+	}
+	session, err := s.fsm.GetSession(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+	flow, err := session.ManualRecording()
+	if err != nil || session.State != domain.StateWaitRecording || flow.SurahNumber == 0 || flow.AyahNumber == 0 {
+		return nil, s.resetFlow(ctx, scope, session.State, domain.StateWaitRecording)
+	}
+
+	ayah := flow.Ayah()
+	reference, err := s.texts.AyahText(ctx, s.preferences(ctx, scope.UserID).Riwayah, ayah)
+	if err != nil {
+		return nil, fmt.Errorf("get text of ayah %s: %w", ayah, err)
+	}
+	check := &TypedCheck{Ayah: ayah, Result: domain.CheckTypedAyah(reference, typed)}
+	s.savePosition(ctx, scope.UserID, ayah)
+
+	next := flow
+	if next.Recorded(s.clock.Now()) {
+		err = s.transition(ctx, scope, domain.StateWaitRecording, func(session *domain.Session) error {
+			session.SetManualRecording(next)
+			return nil
+		})
+		nextAyah := next.Ayah()
+		check.Next = &nextAyah
+	} else {
+		err = s.transition(ctx, scope, domain.StateSelectSurah, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return check, nil
+}
@@ -0,0 +1,23 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// WeakAyahs returns the ayahs the user last recited with a low accuracy,
+// weakest first, to practice them again. It is empty when no history is
+// kept.
+func (s *BotService) WeakAyahs(ctx context.Context, userID string) ([]domain.WeakAyah, error) {
+	if s.stats == nil {
+		return nil, nil
+	}
+	stats, err := s.stats.ListStats(ctx, userID, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("list stats: %w", err)
+	}
+	return domain.WeakAyahs(stats, domain.MaxWeakAyahs), nil
+}
@@ -0,0 +1,138 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/domain"
+)
+
+// wirdSendAfter is how far into the user's day the ayah of their wird is
+// sent, so that it arrives in the morning rather than at night
+const wirdSendAfter = 6 * time.Hour
+
+var errWirdsDisabled = errors.New("wirds are not kept")
+
+// DailyWird is the ayah of the day due to a subscribed user
+type DailyWird struct {
+	Wird domain.Wird
+	Day  time.Time // the user's day, as returned by DayBoundary.Day
+	Ayah domain.Ayah
+}
+
+// WithWirds lets users subscribe to a daily ayah
+func WithWirds(wirds domain.WirdPort) Option {
+	return func(s *BotService) {
+		s.wirds = wirds
+	}
+}
+
+// WithCuratedWird sets the ayahs the curated wird plan goes through. Without
+// any, the plan follows the mushaf like the sequential one.
+func WithCuratedWird(ayahs []domain.Ayah) Option {
+	return func(s *BotService) {
+		s.curated = ayahs
+	}
+}
+
+// Wird returns the user's daily wird subscription, or nil if they have none
+func (s *BotService) Wird(ctx context.Context, userID string) (*domain.Wird, error) {
+	if s.wirds == nil {
+		return nil, nil
+	}
+	wird, err := s.wirds.GetWird(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get wird: %w", err)
+	}
+	return wird, nil
+}
+
+// StartWird subscribes the user to a daily ayah following a plan. A user
+// already subscribed switches plan without starting it over.
+func (s *BotService) StartWird(ctx context.Context, userID string, plan domain.WirdPlan) (domain.Wird, error) {
+	if s.wirds == nil {
+		return domain.Wird{}, errWirdsDisabled
+	}
+	if !domain.ValidWirdPlan(plan) {
+		return domain.Wird{}, fmt.Errorf("unknown wird plan %q", plan)
+	}
+
+	current, err := s.Wird(ctx, userID)
+	if err != nil {
+		return domain.Wird{}, err
+	}
+	wird := domain.Wird{
+		UserID: userID,
+		Start:  s.preferences(ctx, userID).DayBoundary().Day(s.clock.Now()),
+	}
+	if current != nil {
+		wird = *current
+	}
+	wird.Plan = plan
+	if err := s.wirds.SaveWird(ctx, wird); err != nil {
+		return domain.Wird{}, fmt.Errorf("save wird: %w", err)
+	}
+	return wird, nil
+}
+
+// StopWird unsubscribes the user from their daily ayah
+func (s *BotService) StopWird(ctx context.Context, userID string) error {
+	if s.wirds == nil {
+		return nil
+	}
+	if err := s.wirds.DeleteWird(ctx, userID); err != nil {
+		return fmt.Errorf("delete wird: %w", err)
+	}
+	return nil
+}
+
+// WirdsDue returns the ayah of the day of every subscribed user it is due
+// to, once the user's day is far enough along. Callers send the ayah and
+// then call MarkWirdSent.
+func (s *BotService) WirdsDue(ctx context.Context) ([]DailyWird, error) {
+	if s.wirds == nil {
+		return nil, nil
+	}
+	wirds, err := s.wirds.AllWirds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list wirds: %w", err)
+	}
+
+	now := s.clock.Now()
+	var due []DailyWird
+	for _, wird := range wirds {
+		prefs := s.preferences(ctx, wird.UserID)
+		days := prefs.DayBoundary()
+		if now.Sub(days.Start(now)) < wirdSendAfter {
+			continue
+		}
+		today := days.Day(now)
+		if !wird.Due(today) {
+			continue
+		}
+		due = append(due, DailyWird{Wird: wird, Day: today, Ayah: wird.Ayah(today, prefs.Riwayah, s.curated)})
+	}
+	return due, nil
+}
+
+// MarkWirdSent records that the ayah of the day was sent to the user
+func (s *BotService) MarkWirdSent(ctx context.Context, daily DailyWird) error {
+	if s.wirds == nil {
+		return nil
+	}
+	wird := daily.Wird
+	wird.SentAt = daily.Day
+	if err := s.wirds.SaveWird(ctx, wird); err != nil {
+		return fmt.Errorf("save wird: %w", err)
+	}
+	return nil
+}
+
+// RecordAyah selects an ayah, e.g. the one of the user's wird, ready to be
+// recorded
+func (s *BotService) RecordAyah(ctx context.Context, scope domain.SessionScope, ayah domain.Ayah) error {
+	flow := domain.ManualRecordingFlow{SurahNumber: ayah.SurahNumber, AyahNumber: ayah.AyahNumber}
+	return s.selectAyah(ctx, scope, flow)
+}
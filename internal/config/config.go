@@ -2,73 +2,545 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Supported database drivers
+const (
+	DatabaseDriverPostgres = "postgres"
+	DatabaseDriverSQLite   = "sqlite"
+)
+
+// Supported audio decoders
+const (
+	AudioDecoderFFmpeg = "ffmpeg"
+	AudioDecoderNative = "native"
+)
+
+// Supported analytics sinks
+const (
+	AnalyticsSinkFile     = "file"
+	AnalyticsSinkDatabase = "database"
+	AnalyticsSinkHTTP     = "http"
+)
+
+// Supported grading scales
+const (
+	GradingScaleWords   = "words"
+	GradingScaleLetters = "letters"
+)
+
+// Supported FSM drivers
+const (
+	FSMDriverRedis  = "redis"
+	FSMDriverMemory = "memory"
+)
+
 type Config struct {
-	Telegram TelegramConfig `yaml:"telegram"`
-	Redis    RedisConfig    `yaml:"redis"`
-	QuranAPI QuranAPIConfig `yaml:"quran_api"`
-	App      AppConfig      `yaml:"app"`
+	Telegram  TelegramConfig  `yaml:"telegram"`
+	FSM       FSMConfig       `yaml:"fsm"`
+	Redis     RedisConfig     `yaml:"redis"`
+	QuranAPI  QuranAPIConfig  `yaml:"quran_api"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Audio     AudioConfig     `yaml:"audio"`
+	Archive   ArchiveConfig   `yaml:"archive"`
+	App       AppConfig       `yaml:"app"`
+	Health    HealthConfig    `yaml:"health"`
+	Alerts    AlertsConfig    `yaml:"alerts"`
+	Dashboard DashboardConfig `yaml:"dashboard"`
+	Analytics AnalyticsConfig `yaml:"analytics"`
+	Grading   GradingConfig   `yaml:"grading"`
+	Wird      WirdConfig      `yaml:"wird"`
+	QuranText QuranTextConfig `yaml:"quran_text"`
+	Debug     DebugConfig     `yaml:"debug"`
+
+	// RateLimits configures a token bucket per action, e.g. "submission"
+	RateLimits map[string]RateLimitConfig `yaml:"rate_limits"`
 }
 
 type TelegramConfig struct {
 	Token string `yaml:"token"`
+
+	// Bots, when set, replaces Token with several bots served by the
+	// process, e.g. a test and a production bot, or one per mosque
+	Bots []BotConfig `yaml:"bots"`
+
+	Webhook WebhookConfig `yaml:"webhook"`
+}
+
+// WebhookConfig has Telegram deliver updates over HTTP instead of the bot
+// long polling for them, which lets several replicas serve one bot behind a
+// load balancer. It is disabled when URL is empty.
+type WebhookConfig struct {
+	// URL is the public HTTPS address updates are delivered to. With several
+	// bots, each is served at URL/<name>.
+	URL  string `yaml:"url"`
+	Addr string `yaml:"addr"` // the webhook server listens on, defaults to ":8443"
+}
+
+// BotConfig is one of several bots served by one process. Bots share the
+// Quran API client, the database and audio processing, but each has its own
+// update loop and keeps its sessions and other Redis state apart.
+type BotConfig struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	// Namespace prefixes the bot's Redis keys, after redis.namespace, and
+	// defaults to Name
+	Namespace string `yaml:"namespace"`
+
+	// WebhookURL is where Telegram delivers the bot's updates, derived from
+	// telegram.webhook.url, empty to long poll
+	WebhookURL string `yaml:"-"`
+}
+
+// FSMConfig selects the session storage backend
+type FSMConfig struct {
+	Driver string `yaml:"driver"` // "redis" (default) or "memory"
 }
 
 type RedisConfig struct {
-	Addr     string `yaml:"addr"`
-	Password string `yaml:"password"`
-	DB       int    `yaml:"db"`
+	URL      string          `yaml:"url"` // redis:// or rediss:// URI, overrides addr/username/password/db
+	Addr     string          `yaml:"addr"`
+	Username string          `yaml:"username"`
+	Password string          `yaml:"password"`
+	DB       int             `yaml:"db"`
+	TLS      RedisTLSConfig  `yaml:"tls"`
+	Pool     RedisPoolConfig `yaml:"pool"`
+
+	// Namespace prefixes every key, e.g. with the bot's username, so several
+	// bots can share one Redis
+	Namespace string `yaml:"namespace"`
+}
+
+// RedisTLSConfig configures TLS for managed Redis services
+type RedisTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// RedisPoolConfig tunes the connection pool, timeouts and retries. Zero
+// values keep the client defaults.
+type RedisPoolConfig struct {
+	Size            int           `yaml:"size"`
+	MinIdleConns    int           `yaml:"min_idle_conns"`
+	PoolTimeout     time.Duration `yaml:"pool_timeout"`
+	DialTimeout     time.Duration `yaml:"dial_timeout"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	MaxRetries      int           `yaml:"max_retries"` // -1 disables retries
+	MinRetryBackoff time.Duration `yaml:"min_retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
 }
 
 type QuranAPIConfig struct {
-	BaseURL string `yaml:"base_url"`
-	APIKey  string `yaml:"api_key"`
+	BaseURL   string `yaml:"base_url"`
+	APIKey    string `yaml:"api_key"`
+	CacheSize int    `yaml:"cache_size"` // finished recordings kept in memory, 0 for the default
+
+	// AutoDetect lets users say the ayah to go to, e.g. "سورة الكهف آية
+	// عشرة", in a short voice message while picking a surah, and drill the
+	// words a result got wrong one by one. It needs the API to auto-detect
+	// submissions sent without an ayah.
+	AutoDetect bool `yaml:"auto_detect"`
+}
+
+// DatabaseConfig configures the persistent user store. It is optional: when
+// DSN is empty, user profiles are not persisted.
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"` // "postgres" (default) or "sqlite"
+	DSN    string `yaml:"dsn"`    // connection string, or file path for sqlite
+}
+
+// AudioConfig configures voice message processing
+type AudioConfig struct {
+	// MaxConcurrentJobs is the number of workers converting recordings,
+	// which bounds the ffmpeg processes running at once. Defaults to the
+	// number of CPUs.
+	MaxConcurrentJobs int `yaml:"max_concurrent_jobs"`
+	// MaxQueuedJobs bounds the conversions waiting for a free worker before
+	// recordings are turned away, 0 for no limit
+	MaxQueuedJobs int `yaml:"max_queued_jobs"`
+
+	Loudnorm LoudnormConfig `yaml:"loudnorm"`
+
+	// MaxDuration and MaxFileSize (in bytes) reject longer or larger
+	// recordings before they are downloaded, default to 5m and 20 MiB
+	MaxDuration time.Duration `yaml:"max_duration"`
+	MaxFileSize int           `yaml:"max_file_size"`
+
+	Output AudioOutputConfig `yaml:"output"`
+
+	// Decoder converts OGG/Opus recordings: "ffmpeg" (default) or "native"
+	// for the pure-Go decoder, which needs no ffmpeg binary but only
+	// produces WAV at 8, 12, 16, 24 or 48kHz
+	Decoder string `yaml:"decoder"`
+
+	// PassthroughOGG uploads OGG/Opus recordings as they are, without
+	// transcoding, when the analysis API accepts them
+	PassthroughOGG bool `yaml:"passthrough_ogg"`
+
+	// RejectNonSpeech turns away silent recordings and ones that sound like
+	// music or noise before they are submitted, with WAV output only
+	RejectNonSpeech bool `yaml:"reject_non_speech"`
+
+	// ChunkDuration splits longer recordings at pauses in the recitation
+	// and submits each chunk on its own, 0 (the default) to submit
+	// recordings whole. It needs WAV output and at least 10s.
+	ChunkDuration time.Duration `yaml:"chunk_duration"`
+
+	Cache AudioCacheConfig `yaml:"cache"`
+
+	// ReferenceURL is the URL template of reference recitation clips sent
+	// next to results, with {reciter}, {riwayah} and {ayah} (XXXYYY)
+	// placeholders. Defaults to everyayah.com, whose clips are numbered in
+	// Hafs; "-" disables reference clips.
+	ReferenceURL string `yaml:"reference_url"`
+
+	// DenoiseFilter is the ffmpeg filter reducing background noise for users
+	// who enabled "Enhance audio quality" in /settings, defaults to afftdn
+	DenoiseFilter string `yaml:"denoise_filter"`
+}
+
+// AudioOutputConfig is the format recordings are converted to before they
+// are submitted, defaults to 16kHz mono WAV
+type AudioOutputConfig struct {
+	Format     string `yaml:"format"` // "wav", "flac" or "ogg" (Opus)
+	SampleRate int    `yaml:"sample_rate"`
+	Channels   int    `yaml:"channels"`
+}
+
+// AudioCacheConfig configures the on-disk cache of each user's latest
+// submission. It is disabled when Dir is empty.
+type AudioCacheConfig struct {
+	Dir string        `yaml:"dir"`
+	TTL time.Duration `yaml:"ttl"` // defaults to 30m
+}
+
+// ArchiveConfig configures the S3-compatible bucket (AWS S3, MinIO, ...)
+// keeping the audio of every submission. It is disabled when Bucket is empty.
+type ArchiveConfig struct {
+	Endpoint  string `yaml:"endpoint"` // e.g. "https://s3.eu-central-1.amazonaws.com" or "http://minio:9000"
+	Region    string `yaml:"region"`   // defaults to us-east-1
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	Prefix    string `yaml:"prefix"` // key prefix, e.g. "recordings/"
+}
+
+// LoudnormConfig configures the ffmpeg loudnorm pass that evens out the
+// volume of recordings before they are submitted
+type LoudnormConfig struct {
+	Enabled    bool    `yaml:"enabled"`
+	TargetLUFS float64 `yaml:"target_lufs"` // integrated loudness, defaults to -16
+}
+
+// RateLimitConfig allows Requests per Per on average, in bursts of up to
+// Burst requests
+type RateLimitConfig struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst"` // defaults to Requests
+}
+
+// HealthConfig configures the HTTP server answering liveness (/healthz) and
+// readiness (/readyz) probes. It is disabled when Addr is empty.
+type HealthConfig struct {
+	Addr string `yaml:"addr"` // e.g. ":8080"
+}
+
+// AlertsConfig configures the watchdog notifying operators in a Telegram
+// chat of failing dependencies and error rates. It is disabled when ChatID
+// is 0.
+type AlertsConfig struct {
+	ChatID    int64         `yaml:"chat_id"`
+	Interval  time.Duration `yaml:"interval"`   // defaults to 1m
+	Window    time.Duration `yaml:"window"`     // error rates over, defaults to 5m, at most 1h
+	ErrorRate float64       `yaml:"error_rate"` // defaults to 0.2
+	// MinOperations is how many conversions or submissions the window
+	// needs before their error rate alerts, defaults to 5
+	MinOperations int `yaml:"min_operations"`
+}
+
+// DashboardConfig configures the web admin dashboard. It is disabled when
+// Addr is empty and requires a password otherwise.
+type DashboardConfig struct {
+	Addr     string `yaml:"addr"`     // e.g. ":8081"
+	Username string `yaml:"username"` // defaults to "admin"
+	Password string `yaml:"password"`
+}
+
+// AnalyticsConfig configures where usage events are written. It is disabled
+// when Sink is empty.
+type AnalyticsConfig struct {
+	Sink  string `yaml:"sink"`  // "file", "database" (the one of database.dsn) or "http"
+	File  string `yaml:"file"`  // JSON lines file, defaults to data/analytics.jsonl
+	URL   string `yaml:"url"`   // collector receiving batches as JSON arrays
+	Token string `yaml:"token"` // bearer token for the collector, optional
+}
+
+// GradingConfig maps the accuracy of results to the grades shown to users
+type GradingConfig struct {
+	// Scale is a predefined scale: "words" (Excellent, Good, Needs work,
+	// the default) or "letters" (A to F). It is ignored when Grades is set.
+	Scale  string        `yaml:"scale"`
+	Grades []GradeConfig `yaml:"grades"`
+}
+
+// GradeConfig is a grade given from MinAccuracy (between 0 and 1). Names
+// with a "grade.<name>" locale key are translated.
+type GradeConfig struct {
+	Name        string  `yaml:"name"`
+	MinAccuracy float64 `yaml:"min_accuracy"`
+}
+
+// WirdConfig configures the daily ayah users can subscribe to
+type WirdConfig struct {
+	// Curated are the ayahs of the curated plan, e.g. "2:255", sent one a
+	// day in order. Without any, the plan follows the mushaf.
+	Curated []string `yaml:"curated"`
+}
+
+// QuranTextConfig configures the text of the Quran that ayahs typed from
+// memory are checked against. Typing is disabled without any file.
+type QuranTextConfig struct {
+	// Files are the text of each riwayah, e.g. "hafs", in the Tanzil
+	// format: one "surah|ayah|text" line per ayah
+	Files map[string]string `yaml:"files"`
+}
+
+// DebugConfig configures troubleshooting aids that are off by default
+type DebugConfig struct {
+	// Pprof serves net/http/pprof profiles on 127.0.0.1:PprofPort
+	Pprof     bool `yaml:"pprof"`
+	PprofPort int  `yaml:"pprof_port"` // defaults to 6060
 }
 
 type AppConfig struct {
 	LocalesDir      string `yaml:"locales_dir"`
 	DefaultLanguage string `yaml:"default_language"`
+	LogTransitions  bool   `yaml:"log_transitions"` // log every FSM state change
+
+	// AdminIDs are the Telegram user IDs allowed to use /admin
+	AdminIDs []int64 `yaml:"admin_ids"`
 }
 
-// Load loads configuration from a YAML file with environment variable overrides
+// Load loads configuration from a YAML file with environment variable
+// overrides. With an empty filename, it is read from the environment only.
 func Load(filename string) (*Config, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("read config file: %w", err)
-	}
-
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("unmarshal config: %w", err)
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("unmarshal config: %w", err)
+		}
 	}
 
 	// Override with environment variables if present
 	if token := os.Getenv("TELEGRAM_TOKEN"); token != "" {
 		cfg.Telegram.Token = token
 	}
+	if webhookURL := os.Getenv("TELEGRAM_WEBHOOK_URL"); webhookURL != "" {
+		cfg.Telegram.Webhook.URL = webhookURL
+	}
+	if webhookAddr := os.Getenv("TELEGRAM_WEBHOOK_ADDR"); webhookAddr != "" {
+		cfg.Telegram.Webhook.Addr = webhookAddr
+	}
+	if fsmDriver := os.Getenv("FSM_DRIVER"); fsmDriver != "" {
+		cfg.FSM.Driver = fsmDriver
+	}
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		cfg.Redis.URL = redisURL
+	}
 	if redisAddr := os.Getenv("REDIS_ADDR"); redisAddr != "" {
 		cfg.Redis.Addr = redisAddr
 	}
+	if redisUsername := os.Getenv("REDIS_USERNAME"); redisUsername != "" {
+		cfg.Redis.Username = redisUsername
+	}
 	if redisPassword := os.Getenv("REDIS_PASSWORD"); redisPassword != "" {
 		cfg.Redis.Password = redisPassword
 	}
+	if redisNamespace := os.Getenv("REDIS_NAMESPACE"); redisNamespace != "" {
+		cfg.Redis.Namespace = redisNamespace
+	}
 	if apiURL := os.Getenv("QURAN_API_URL"); apiURL != "" {
 		cfg.QuranAPI.BaseURL = apiURL
 	}
 	if apiKey := os.Getenv("QURAN_API_KEY"); apiKey != "" {
 		cfg.QuranAPI.APIKey = apiKey
 	}
+	if dbDriver := os.Getenv("DATABASE_DRIVER"); dbDriver != "" {
+		cfg.Database.Driver = dbDriver
+	}
+	if dsn := os.Getenv("DATABASE_DSN"); dsn != "" {
+		cfg.Database.DSN = dsn
+	}
+	if endpoint := os.Getenv("ARCHIVE_ENDPOINT"); endpoint != "" {
+		cfg.Archive.Endpoint = endpoint
+	}
+	if bucket := os.Getenv("ARCHIVE_BUCKET"); bucket != "" {
+		cfg.Archive.Bucket = bucket
+	}
+	if accessKey := os.Getenv("ARCHIVE_ACCESS_KEY"); accessKey != "" {
+		cfg.Archive.AccessKey = accessKey
+	}
+	if secretKey := os.Getenv("ARCHIVE_SECRET_KEY"); secretKey != "" {
+		cfg.Archive.SecretKey = secretKey
+	}
+	if healthAddr := os.Getenv("HEALTH_ADDR"); healthAddr != "" {
+		cfg.Health.Addr = healthAddr
+	}
+	if alertChatID := os.Getenv("ALERT_CHAT_ID"); alertChatID != "" {
+		id, err := strconv.ParseInt(alertChatID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert chat id %q: %w", alertChatID, err)
+		}
+		cfg.Alerts.ChatID = id
+	}
+	if dashboardAddr := os.Getenv("DASHBOARD_ADDR"); dashboardAddr != "" {
+		cfg.Dashboard.Addr = dashboardAddr
+	}
+	if dashboardUsername := os.Getenv("DASHBOARD_USERNAME"); dashboardUsername != "" {
+		cfg.Dashboard.Username = dashboardUsername
+	}
+	if dashboardPassword := os.Getenv("DASHBOARD_PASSWORD"); dashboardPassword != "" {
+		cfg.Dashboard.Password = dashboardPassword
+	}
+	if analyticsSink := os.Getenv("ANALYTICS_SINK"); analyticsSink != "" {
+		cfg.Analytics.Sink = analyticsSink
+	}
+	if analyticsURL := os.Getenv("ANALYTICS_URL"); analyticsURL != "" {
+		cfg.Analytics.URL = analyticsURL
+	}
+	if analyticsToken := os.Getenv("ANALYTICS_TOKEN"); analyticsToken != "" {
+		cfg.Analytics.Token = analyticsToken
+	}
+	if gradingScale := os.Getenv("GRADING_SCALE"); gradingScale != "" {
+		cfg.Grading.Scale = gradingScale
+	}
+	if adminIDs := os.Getenv("ADMIN_IDS"); adminIDs != "" {
+		cfg.App.AdminIDs = nil
+		for _, field := range strings.Split(adminIDs, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid admin id %q: %w", field, err)
+			}
+			cfg.App.AdminIDs = append(cfg.App.AdminIDs, id)
+		}
+	}
+
+	if err := loadSecrets(&cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.FSM.Driver == "" {
+		cfg.FSM.Driver = FSMDriverRedis
+	}
+	if cfg.Database.Driver == "" {
+		cfg.Database.Driver = DatabaseDriverPostgres
+	}
 
 	// Validate required fields
-	if cfg.Telegram.Token == "" {
-		return nil, fmt.Errorf("telegram token is required")
+	if len(cfg.Telegram.Bots) == 0 {
+		if cfg.Telegram.Token == "" {
+			return nil, fmt.Errorf("telegram token is required")
+		}
+		// A single bot keeps the keys of redis.namespace
+		cfg.Telegram.Bots = []BotConfig{{Name: "default", Token: cfg.Telegram.Token}}
+	} else {
+		names := make(map[string]bool, len(cfg.Telegram.Bots))
+		for i := range cfg.Telegram.Bots {
+			bot := &cfg.Telegram.Bots[i]
+			if bot.Name == "" || bot.Token == "" {
+				return nil, fmt.Errorf("telegram bot %d needs a name and a token", i+1)
+			}
+			if names[bot.Name] {
+				return nil, fmt.Errorf("duplicate telegram bot name: %s", bot.Name)
+			}
+			names[bot.Name] = true
+			if bot.Namespace == "" {
+				bot.Namespace = bot.Name
+			}
+		}
+	}
+	if cfg.Telegram.Webhook.URL != "" {
+		webhookURL, err := url.Parse(cfg.Telegram.Webhook.URL)
+		if err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" {
+			return nil, fmt.Errorf("telegram webhook url must be an https url: %s", cfg.Telegram.Webhook.URL)
+		}
+		if cfg.Telegram.Webhook.Addr == "" {
+			cfg.Telegram.Webhook.Addr = ":8443"
+		}
+		base := strings.TrimSuffix(cfg.Telegram.Webhook.URL, "/")
+		for i := range cfg.Telegram.Bots {
+			cfg.Telegram.Bots[i].WebhookURL = cfg.Telegram.Webhook.URL
+			if len(cfg.Telegram.Bots) > 1 {
+				cfg.Telegram.Bots[i].WebhookURL = base + "/" + url.PathEscape(cfg.Telegram.Bots[i].Name)
+			}
+		}
 	}
-	if cfg.Redis.Addr == "" {
-		return nil, fmt.Errorf("redis address is required")
+	switch cfg.FSM.Driver {
+	case FSMDriverRedis:
+		if cfg.Redis.Addr == "" && cfg.Redis.URL == "" {
+			return nil, fmt.Errorf("redis address or url is required")
+		}
+		if cfg.Redis.Pool.Size < 0 || cfg.Redis.Pool.MinIdleConns < 0 {
+			return nil, fmt.Errorf("redis pool size and min idle conns must not be negative")
+		}
+	case FSMDriverMemory:
+	default:
+		return nil, fmt.Errorf("unknown fsm driver: %s", cfg.FSM.Driver)
+	}
+	switch cfg.Database.Driver {
+	case DatabaseDriverPostgres, DatabaseDriverSQLite:
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", cfg.Database.Driver)
+	}
+	if cfg.Archive.Bucket != "" && cfg.Archive.Endpoint == "" {
+		return nil, fmt.Errorf("archive endpoint is required with an archive bucket")
+	}
+	switch cfg.Analytics.Sink {
+	case "":
+	case AnalyticsSinkFile:
+		if cfg.Analytics.File == "" {
+			cfg.Analytics.File = "data/analytics.jsonl"
+		}
+	case AnalyticsSinkDatabase:
+		if cfg.Database.DSN == "" {
+			return nil, fmt.Errorf("database analytics sink requires a database dsn")
+		}
+	case AnalyticsSinkHTTP:
+		if cfg.Analytics.URL == "" {
+			return nil, fmt.Errorf("http analytics sink requires a url")
+		}
+	default:
+		return nil, fmt.Errorf("unknown analytics sink: %s", cfg.Analytics.Sink)
+	}
+	if cfg.Grading.Scale == "" {
+		cfg.Grading.Scale = GradingScaleWords
+	}
+	if len(cfg.Grading.Grades) == 0 && cfg.Grading.Scale != GradingScaleWords && cfg.Grading.Scale != GradingScaleLetters {
+		return nil, fmt.Errorf("unknown grading scale: %s", cfg.Grading.Scale)
+	}
+	if cfg.Dashboard.Addr != "" && cfg.Dashboard.Password == "" {
+		return nil, fmt.Errorf("dashboard password is required with a dashboard address")
+	}
+	if cfg.Dashboard.Username == "" {
+		cfg.Dashboard.Username = "admin"
 	}
 	if cfg.QuranAPI.BaseURL == "" {
 		return nil, fmt.Errorf("quran API base URL is required")
@@ -81,6 +553,121 @@ func Load(filename string) (*Config, error) {
 	if cfg.App.LocalesDir == "" {
 		cfg.App.LocalesDir = "locales"
 	}
+	if cfg.RateLimits == nil {
+		cfg.RateLimits = make(map[string]RateLimitConfig)
+	}
+	if _, ok := cfg.RateLimits["submission"]; !ok {
+		cfg.RateLimits["submission"] = RateLimitConfig{Requests: 30, Per: time.Hour, Burst: 10}
+	}
+	// Telegram's limits on outgoing messages: about 30 per second overall,
+	// one per second in a private chat and 20 per minute in a group.
+	// Broadcasts leave a third of the overall limit to replies.
+	for action, limit := range map[string]RateLimitConfig{
+		"send":         {Requests: 30, Per: time.Second, Burst: 30},
+		"send_private": {Requests: 1, Per: time.Second, Burst: 5},
+		"send_group":   {Requests: 20, Per: time.Minute, Burst: 5},
+		"broadcast":    {Requests: 20, Per: time.Second, Burst: 20},
+	} {
+		if _, ok := cfg.RateLimits[action]; !ok {
+			cfg.RateLimits[action] = limit
+		}
+	}
+	for action, limit := range cfg.RateLimits {
+		if limit.Requests <= 0 || limit.Per <= 0 {
+			return nil, fmt.Errorf("rate limit %s: requests and per must be positive", action)
+		}
+		if limit.Burst <= 0 {
+			limit.Burst = limit.Requests
+			cfg.RateLimits[action] = limit
+		}
+	}
+	if cfg.Audio.MaxConcurrentJobs <= 0 {
+		cfg.Audio.MaxConcurrentJobs = runtime.NumCPU()
+	}
+	if cfg.Audio.MaxQueuedJobs < 0 {
+		return nil, fmt.Errorf("audio max queued jobs must not be negative")
+	}
+	if cfg.Audio.MaxDuration <= 0 {
+		cfg.Audio.MaxDuration = 5 * time.Minute
+	}
+	if cfg.Audio.MaxFileSize <= 0 {
+		cfg.Audio.MaxFileSize = 20 << 20 // largest file bots can download
+	}
+	if cfg.Audio.Output.Format == "" {
+		cfg.Audio.Output.Format = "wav"
+	}
+	switch cfg.Audio.Output.Format {
+	case "wav", "flac", "ogg":
+	default:
+		return nil, fmt.Errorf("unknown audio output format: %s", cfg.Audio.Output.Format)
+	}
+	if cfg.Audio.Output.SampleRate <= 0 {
+		cfg.Audio.Output.SampleRate = 16000
+	}
+	switch cfg.Audio.Decoder {
+	case "":
+		cfg.Audio.Decoder = AudioDecoderFFmpeg
+	case AudioDecoderFFmpeg:
+	case AudioDecoderNative:
+		if cfg.Audio.Output.Format != "wav" {
+			return nil, fmt.Errorf("native audio decoder only produces wav output")
+		}
+	default:
+		return nil, fmt.Errorf("unknown audio decoder: %s", cfg.Audio.Decoder)
+	}
+	if cfg.Audio.Output.Channels <= 0 {
+		cfg.Audio.Output.Channels = 1
+	}
+	if cfg.Audio.ChunkDuration != 0 {
+		if cfg.Audio.ChunkDuration < 10*time.Second {
+			return nil, fmt.Errorf("audio chunk duration must be at least 10s")
+		}
+		if cfg.Audio.Output.Format != "wav" {
+			return nil, fmt.Errorf("audio chunk duration needs wav output")
+		}
+	}
+	if cfg.Alerts.Interval <= 0 {
+		cfg.Alerts.Interval = time.Minute
+	}
+	if cfg.Alerts.Window <= 0 {
+		cfg.Alerts.Window = 5 * time.Minute
+	}
+	if cfg.Alerts.Window > time.Hour {
+		return nil, fmt.Errorf("alerts window must be at most 1h")
+	}
+	if cfg.Alerts.ErrorRate <= 0 {
+		cfg.Alerts.ErrorRate = 0.2
+	}
+	if cfg.Alerts.MinOperations <= 0 {
+		cfg.Alerts.MinOperations = 5
+	}
+	if cfg.Debug.PprofPort == 0 {
+		cfg.Debug.PprofPort = 6060
+	}
+	if cfg.Debug.PprofPort < 0 || cfg.Debug.PprofPort > 65535 {
+		return nil, fmt.Errorf("invalid pprof port: %d", cfg.Debug.PprofPort)
+	}
+	if cfg.Audio.Cache.TTL <= 0 {
+		cfg.Audio.Cache.TTL = 30 * time.Minute
+	}
+	switch cfg.Audio.ReferenceURL {
+	case "":
+		cfg.Audio.ReferenceURL = "https://everyayah.com/data/{reciter}/{ayah}.mp3"
+	case "-":
+		cfg.Audio.ReferenceURL = ""
+	}
+	if cfg.Audio.DenoiseFilter == "" {
+		cfg.Audio.DenoiseFilter = "afftdn"
+	}
+	if cfg.Audio.Loudnorm.TargetLUFS == 0 {
+		cfg.Audio.Loudnorm.TargetLUFS = -16
+	}
+	if cfg.Audio.Loudnorm.TargetLUFS < -70 || cfg.Audio.Loudnorm.TargetLUFS > -5 {
+		return nil, fmt.Errorf("audio loudnorm target must be between -70 and -5 LUFS")
+	}
+	if cfg.QuranAPI.CacheSize <= 0 {
+		cfg.QuranAPI.CacheSize = 256
+	}
 	if cfg.App.DefaultLanguage == "" {
 		cfg.App.DefaultLanguage = "en"
 	}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/escalopa/quran-read-bot/internal/sigv4"
+)
+
+// secretsTimeout bounds resolving every secret of the configuration
+const secretsTimeout = 30 * time.Second
+
+// SecretResolver fetches a secret from an external store. Secret settings
+// whose value is "<scheme>:<reference>", for a registered scheme, are
+// replaced with what the resolver of the scheme returns for the reference.
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// secretResolvers are the registered resolvers by scheme
+var secretResolvers = map[string]SecretResolver{
+	"file":  fileResolver{},
+	"vault": vaultResolver{},
+	"awssm": awsSecretsResolver{},
+}
+
+// RegisterSecretResolver makes secret settings starting with "<scheme>:"
+// resolve through resolver. It must be called before Load.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolvers[scheme] = resolver
+}
+
+// secretField is a setting holding a secret
+type secretField struct {
+	env   string // environment variable overriding it
+	value *string
+	// reference reports whether the value may reference a secret store.
+	// Connection strings may not, as they have schemes of their own, e.g.
+	// SQLite's "file:".
+	reference bool
+}
+
+func secretFields(cfg *Config) []secretField {
+	return []secretField{
+		{"TELEGRAM_TOKEN", &cfg.Telegram.Token, true},
+		{"QURAN_API_KEY", &cfg.QuranAPI.APIKey, true},
+		{"REDIS_PASSWORD", &cfg.Redis.Password, true},
+		{"REDIS_URL", &cfg.Redis.URL, false},
+		{"DATABASE_DSN", &cfg.Database.DSN, false},
+		{"ARCHIVE_ACCESS_KEY", &cfg.Archive.AccessKey, true},
+		{"ARCHIVE_SECRET_KEY", &cfg.Archive.SecretKey, true},
+		{"DASHBOARD_PASSWORD", &cfg.Dashboard.Password, true},
+		{"ANALYTICS_TOKEN", &cfg.Analytics.Token, true},
+	}
+}
+
+// loadSecrets fills secret settings from <NAME>_FILE environment variables,
+// e.g. Docker secrets mounted under /run/secrets, unless <NAME> itself is
+// set, then resolves the ones referencing a secret store
+func loadSecrets(cfg *Config) error {
+	fields := secretFields(cfg)
+
+	for _, field := range fields {
+		path := os.Getenv(field.env + "_FILE")
+		if path == "" || os.Getenv(field.env) != "" {
+			continue
+		}
+		value, err := readSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", field.env, err)
+		}
+		*field.value = value
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+
+	for _, field := range fields {
+		if !field.reference {
+			continue
+		}
+		if err := resolveSecret(ctx, field.value); err != nil {
+			return fmt.Errorf("resolve secret of %s: %w", field.env, err)
+		}
+	}
+	for i := range cfg.Telegram.Bots {
+		if err := resolveSecret(ctx, &cfg.Telegram.Bots[i].Token); err != nil {
+			return fmt.Errorf("resolve token of bot %s: %w", cfg.Telegram.Bots[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// resolveSecret replaces a value referencing a secret store with the secret
+func resolveSecret(ctx context.Context, value *string) error {
+	scheme, ref, ok := strings.Cut(*value, ":")
+	resolver, registered := secretResolvers[scheme]
+	if !ok || !registered {
+		return nil
+	}
+	secret, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+	*value = secret
+	return nil
+}
+
+// readSecretFile reads a secret from a file, without the trailing newline
+// editors and `echo` leave
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// fileResolver reads "file:<path>" secrets from files
+type fileResolver struct{}
+
+func (fileResolver) Resolve(ctx context.Context, path string) (string, error) {
+	return readSecretFile(path)
+}
+
+// splitSecretKey splits "<name>#<key>" references
+func splitSecretKey(ref string) (name, key string) {
+	name, key, _ = strings.Cut(ref, "#")
+	return name, key
+}
+
+// vaultResolver reads "vault:<path>#<key>" secrets from HashiCorp Vault,
+// e.g. "vault:secret/data/quranbot#telegram_token" for the KV v2 engine
+// mounted at secret/. It connects to VAULT_ADDR with VAULT_TOKEN, and
+// VAULT_NAMESPACE on Vault Enterprise.
+type vaultResolver struct{}
+
+func (vaultResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required for vault secrets")
+	}
+	path, key := splitSecretKey(ref)
+	if key == "" {
+		return "", fmt.Errorf("vault secret %q has no #key", ref)
+	}
+
+	url := strings.TrimSuffix(addr, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := fetchSecretJSON(req, &body); err != nil {
+		return "", fmt.Errorf("read vault secret %s: %w", path, err)
+	}
+
+	// KV v2 nests the secret under data.data, next to its metadata
+	data := body.Data
+	if nested, ok := data["data"]; ok {
+		if _, versioned := data["metadata"]; versioned {
+			if err := json.Unmarshal(nested, &data); err != nil {
+				return "", fmt.Errorf("decode vault secret %s: %w", path, err)
+			}
+		}
+	}
+
+	var value string
+	if err := json.Unmarshal(data[key], &value); err != nil {
+		return "", fmt.Errorf("vault secret %s has no string %q", path, key)
+	}
+	return value, nil
+}
+
+// awsSecretsResolver reads "awssm:<secret-id>" secrets from AWS Secrets
+// Manager, or "awssm:<secret-id>#<key>" for a key of a JSON secret. It signs
+// requests with AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN for AWS_REGION.
+type awsSecretsResolver struct{}
+
+func (awsSecretsResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	creds := sigv4.Credentials{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if creds.AccessKey == "" || creds.SecretKey == "" || region == "" {
+		return "", fmt.Errorf("AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION are required for awssm secrets")
+	}
+
+	secretID, key := splitSecretKey(ref)
+	payload, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", fmt.Errorf("encode request: %w", err)
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	sigv4.Sign(req, payload, creds, region, "secretsmanager", time.Now().UTC())
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := fetchSecretJSON(req, &body); err != nil {
+		return "", fmt.Errorf("get secret %s: %w", secretID, err)
+	}
+	if key == "" {
+		return body.SecretString, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", secretID, err)
+	}
+	var value string
+	if err := json.Unmarshal(fields[key], &value); err != nil {
+		return "", fmt.Errorf("secret %s has no string %q", secretID, key)
+	}
+	return value, nil
+}
+
+// fetchSecretJSON sends req and decodes its JSON response into v
+func fetchSecretJSON(req *http.Request, v any) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
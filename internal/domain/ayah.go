@@ -0,0 +1,223 @@
+package domain
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FormatAyahID formats surah and ayah numbers into XXXYYY format
+func FormatAyahID(surahNumber, ayahNumber int) string {
+	return fmt.Sprintf("%03d%03d", surahNumber, ayahNumber)
+}
+
+// ParseAyahID parses an ayah ID in the XXXYYY format of the API, e.g.
+// "002255", or written as "surah:ayah", e.g. "2:255". The ayah must exist in
+// at least one riwayah; use Validate to check it against the user's.
+func ParseAyahID(id string) (Ayah, error) {
+	id = strings.TrimSpace(id)
+	surah, ayah, ok := strings.Cut(id, ":")
+	if !ok {
+		if len(id) != 6 {
+			return Ayah{}, fmt.Errorf("%w: malformed ID %q", ErrInvalidAyah, id)
+		}
+		surah, ayah = id[:3], id[3:]
+	}
+
+	surahNumber, err := parseNumber(surah)
+	if err != nil {
+		return Ayah{}, fmt.Errorf("%w: malformed surah in %q", ErrInvalidAyah, id)
+	}
+	ayahNumber, err := parseNumber(ayah)
+	if err != nil {
+		return Ayah{}, fmt.Errorf("%w: malformed ayah in %q", ErrInvalidAyah, id)
+	}
+
+	a := Ayah{SurahNumber: surahNumber, AyahNumber: ayahNumber}
+	var firstErr error
+	for _, riwayah := range Riwayat {
+		err := a.Validate(riwayah)
+		if err == nil {
+			return a, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return Ayah{}, firstErr
+}
+
+// parseNumber parses a non-negative decimal number of ASCII digits only,
+// unlike strconv.Atoi which also accepts signs
+func parseNumber(s string) (int, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.Atoi(s)
+}
+
+// sajdahAyahs are the 15 ayahs of prostration, numbered in Hafs
+var sajdahAyahs = []Ayah{
+	{7, 206}, {13, 15}, {16, 50}, {17, 109}, {19, 58}, {22, 18}, {22, 77}, {25, 60},
+	{27, 26}, {32, 15}, {38, 24}, {41, 38}, {53, 62}, {84, 21}, {96, 19},
+}
+
+// HasSajdah reports whether the ayah, numbered in riwayah, is an ayah of
+// prostration. Only the Hafs numbering is known, as the prostrations of
+// Warsh reciters also depend on their school of fiqh.
+func (a Ayah) HasSajdah(riwayah Riwayah) bool {
+	return riwayah == RiwayahHafs && slices.Contains(sajdahAyahs, a)
+}
+
+// Validate checks that the ayah exists in the numbering of riwayah
+func (a Ayah) Validate(riwayah Riwayah) error {
+	if a.SurahNumber < 1 || a.SurahNumber > len(surahs) {
+		return fmt.Errorf("%w: no surah %d", ErrInvalidAyah, a.SurahNumber)
+	}
+	ayahs := surahs[a.SurahNumber-1].AyahCount(riwayah)
+	if a.AyahNumber < 1 || a.AyahNumber > ayahs {
+		return fmt.Errorf("%w: %d (surah %d has %d ayahs in %s)", ErrInvalidAyah, a.AyahNumber, a.SurahNumber, ayahs, riwayah)
+	}
+	return nil
+}
+
+// Next returns the ayah following a in the mushaf, numbered in riwayah.
+// After the last ayah of a surah comes the first of the next one, and after
+// An-Nas the recitation starts over from Al-Fatiha.
+func (a Ayah) Next(riwayah Riwayah) Ayah {
+	if a.SurahNumber < 1 || a.SurahNumber > len(surahs) {
+		return Ayah{SurahNumber: 1, AyahNumber: 1}
+	}
+	if a.AyahNumber < surahs[a.SurahNumber-1].AyahCount(riwayah) {
+		return Ayah{SurahNumber: a.SurahNumber, AyahNumber: a.AyahNumber + 1}
+	}
+	if a.SurahNumber == len(surahs) {
+		return Ayah{SurahNumber: 1, AyahNumber: 1}
+	}
+	return Ayah{SurahNumber: a.SurahNumber + 1, AyahNumber: 1}
+}
+
+// Compare returns -1 if a comes before b in the mushaf, 1 if after and 0
+// if they are the same ayah
+func (a Ayah) Compare(b Ayah) int {
+	if c := cmp.Compare(a.SurahNumber, b.SurahNumber); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.AyahNumber, b.AyahNumber)
+}
+
+// String formats the ayah as "surah:ayah", e.g. "2:255"
+func (a Ayah) String() string {
+	return fmt.Sprintf("%d:%d", a.SurahNumber, a.AyahNumber)
+}
+
+// mushafLen returns the number of ayahs of the mushaf in a riwayah
+func mushafLen(riwayah Riwayah) int {
+	total := 0
+	for _, surah := range surahs {
+		total += surah.AyahCount(riwayah)
+	}
+	return total
+}
+
+// ayahIndex returns the index of a valid ayah in the mushaf in a riwayah,
+// from 0 at Al-Fatiha
+func ayahIndex(a Ayah, riwayah Riwayah) int {
+	index := a.AyahNumber - 1
+	for _, surah := range surahs[:a.SurahNumber-1] {
+		index += surah.AyahCount(riwayah)
+	}
+	return index
+}
+
+// ayahAt returns the ayah at an index of the mushaf in a riwayah, counting
+// from 0 at Al-Fatiha and starting over after An-Nas
+func ayahAt(index int, riwayah Riwayah) Ayah {
+	index %= mushafLen(riwayah)
+	for _, surah := range surahs {
+		count := surah.AyahCount(riwayah)
+		if index < count {
+			return Ayah{SurahNumber: surah.Number, AyahNumber: index + 1}
+		}
+		index -= count
+	}
+	return Ayah{SurahNumber: 1, AyahNumber: 1}
+}
+
+// AyahRange is the ayahs from Start to End, both included, in mushaf order.
+// A range may span several surahs.
+type AyahRange struct {
+	Start Ayah
+	End   Ayah
+}
+
+// SingleAyah returns the range of one ayah
+func SingleAyah(a Ayah) AyahRange {
+	return AyahRange{Start: a, End: a}
+}
+
+// Validate checks that both ends of the range exist in the numbering of
+// riwayah and that Start does not come after End
+func (r AyahRange) Validate(riwayah Riwayah) error {
+	if err := r.Start.Validate(riwayah); err != nil {
+		return err
+	}
+	if err := r.End.Validate(riwayah); err != nil {
+		return err
+	}
+	if r.Start.Compare(r.End) > 0 {
+		return fmt.Errorf("%w: range %s ends before it starts", ErrInvalidAyah, r)
+	}
+	return nil
+}
+
+// Ayahs iterates over the ayahs of a valid range in order, numbered in
+// riwayah
+func (r AyahRange) Ayahs(riwayah Riwayah) iter.Seq[Ayah] {
+	return func(yield func(Ayah) bool) {
+		for a := r.Start; a.Compare(r.End) <= 0 && a.SurahNumber <= len(surahs); {
+			if !yield(a) {
+				return
+			}
+			a.AyahNumber++
+			if a.AyahNumber > surahs[a.SurahNumber-1].AyahCount(riwayah) {
+				a = Ayah{SurahNumber: a.SurahNumber + 1, AyahNumber: 1}
+			}
+		}
+	}
+}
+
+// Len returns the number of ayahs in a valid range, numbered in riwayah
+func (r AyahRange) Len(riwayah Riwayah) int {
+	var n int
+	for range r.Ayahs(riwayah) {
+		n++
+	}
+	return n
+}
+
+// AyahIDs returns the IDs (XXXYYY format) of the ayahs of a valid range, as
+// the API expects them
+func (r AyahRange) AyahIDs(riwayah Riwayah) []string {
+	var ids []string
+	for a := range r.Ayahs(riwayah) {
+		ids = append(ids, a.AyahID())
+	}
+	return ids
+}
+
+// String formats the range as "2:255-257", "2:286-3:2", or "2:255" for a
+// single ayah
+func (r AyahRange) String() string {
+	switch {
+	case r.Start == r.End:
+		return r.Start.String()
+	case r.Start.SurahNumber == r.End.SurahNumber:
+		return fmt.Sprintf("%s-%d", r.Start, r.End.AyahNumber)
+	default:
+		return fmt.Sprintf("%s-%s", r.Start, r.End)
+	}
+}
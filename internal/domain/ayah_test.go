@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAyahID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want Ayah
+		ok   bool
+	}{
+		{name: "API ID", id: "002255", want: Ayah{2, 255}, ok: true},
+		{name: "surah:ayah", id: "2:255", want: Ayah{2, 255}, ok: true},
+		{name: "surah:ayah with leading zeros", id: "002:255", want: Ayah{2, 255}, ok: true},
+		{name: "first ayah", id: "001001", want: Ayah{1, 1}, ok: true},
+		{name: "last ayah", id: "114:6", want: Ayah{114, 6}, ok: true},
+		{name: "last ayah of a surah in one riwayah only", id: "2:286", want: Ayah{2, 286}, ok: true},
+		{name: "surrounding whitespace", id: " 2:255\n", want: Ayah{2, 255}, ok: true},
+
+		{name: "empty", id: ""},
+		{name: "letters", id: "abcdef"},
+		{name: "API ID too short", id: "00225"},
+		{name: "API ID too long", id: "0022550"},
+		{name: "surah 0", id: "0:1"},
+		{name: "surah 0 API ID", id: "000001"},
+		{name: "surah 115", id: "115:1"},
+		{name: "surah 115 API ID", id: "115001"},
+		{name: "ayah 0", id: "1:0"},
+		{name: "ayah 0 API ID", id: "001000"},
+		{name: "ayah past the surah", id: "1:8"},
+		{name: "ayah past the surah API ID", id: "001008"},
+		{name: "ayah past the surah in every riwayah", id: "2:287"},
+		{name: "ayah past the last surah", id: "114:7"},
+		{name: "missing surah", id: ":1"},
+		{name: "missing ayah", id: "1:"},
+		{name: "doubled separator", id: "1::1"},
+		{name: "extra separator", id: "1:1:1"},
+		{name: "plus sign", id: "+1:1"},
+		{name: "minus sign", id: "1:-1"},
+		{name: "signed API ID", id: "+01001"},
+		{name: "whitespace around the separator", id: "1 : 1"},
+		{name: "inner whitespace", id: "001 01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAyahID(tt.id)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidAyah) {
+					t.Fatalf("ParseAyahID(%q) = %v, %v, want ErrInvalidAyah", tt.id, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAyahID(%q) failed: %v", tt.id, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAyahID(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
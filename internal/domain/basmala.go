@@ -0,0 +1,63 @@
+package domain
+
+// basmala is "بسم الله الرحمن الرحيم", as normalized by arabicKey
+var basmala = []string{arabicKey("بسم"), arabicKey("الله"), arabicKey("الرحمن"), arabicKey("الرحيم")}
+
+// minBasmalaWords is how many words of the basmala an insertion must start
+// with to be taken for it rather than for words added by mistake
+const minBasmalaWords = 2
+
+// WithBasmalaSeparated returns the recording with the basmala recited before
+// the ayah labelled OpBasmala instead of counted as inserted words, and its
+// WER without them. The recording itself is returned when it does not start
+// with the basmala, so cached recordings are never modified.
+func (r *Recording) WithBasmalaSeparated() *Recording {
+	if r.Result == nil {
+		return r
+	}
+	n := basmalaWords(r.Result.Ops)
+	if n == 0 {
+		return r
+	}
+
+	result := *r.Result
+	result.Ops = append([]Operation(nil), r.Result.Ops...)
+	for i := range n {
+		result.Ops[i].Op = OpBasmala
+	}
+
+	var reference int
+	for _, op := range result.Ops {
+		if op.Op == OpCorrect || op.Op == OpSubstitution || op.Op == OpDeletion {
+			reference++
+		}
+	}
+	if reference > 0 {
+		result.WER = max(0, result.WER-float64(n)/float64(reference))
+	}
+
+	recording := *r
+	recording.Result = &result
+	return &recording
+}
+
+// basmalaWords returns how many of the leading insertions of ops are the
+// words of the basmala in order, tolerating a letter misheard in each, or 0
+// if fewer than minBasmalaWords are
+func basmalaWords(ops []Operation) int {
+	var n int
+	for n < len(ops) && n < len(basmala) && ops[n].Op == OpInsertion {
+		word := ops[n].HypAr
+		if word == "" {
+			word = ops[n].HypClean
+		}
+		if editDistance([]rune(arabicKey(word)), []rune(basmala[n])) > 1 {
+			break
+		}
+		n++
+	}
+	if n < minBasmalaWords {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrChallengeNotFound is returned for challenges that do not exist or
+// expired
+var ErrChallengeNotFound = errors.New("challenge not found")
+
+// ErrOwnChallenge is returned when users try to take their own challenge
+var ErrOwnChallenge = errors.New("own challenge")
+
+// Challenge is an invitation to recite an ayah better than someone did.
+// Friends take it through a link holding its ID.
+type Challenge struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`      // who challenged
+	RecordingID string    `json:"recording_id"` // the challenger's attempt
+	AyahID      string    `json:"ayah_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ChallengeAnswer is a friend's attempt at a challenge
+type ChallengeAnswer struct {
+	ChallengeID string `json:"challenge_id"`
+	UserID      string `json:"user_id"`
+	RecordingID string `json:"recording_id"`
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// Clock tells the time to everything that depends on it, e.g. streaks,
+// expiries and "X ago" texts, so that it can be frozen or moved in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock of the machine
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ClockFunc adapts a function to a Clock, e.g. to freeze the time with
+// func() time.Time { return frozen }
+type ClockFunc func() time.Time
+
+// Now returns f()
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
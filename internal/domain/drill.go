@@ -0,0 +1,90 @@
+package domain
+
+import "errors"
+
+var (
+	// ErrNothingToDrill is returned when drilling a recording without a
+	// substituted word
+	ErrNothingToDrill = errors.New("no mistaken word to drill")
+
+	// ErrNoWordDrill is returned when a user acts on a word drill without
+	// one in progress
+	ErrNoWordDrill = errors.New("no word drill in progress")
+)
+
+// WordDrill is a drill of the words a recording got wrong: each word is
+// recorded alone, one after the other, until it is heard right or skipped
+type WordDrill struct {
+	RecordingID string   `json:"recording_id"`
+	AyahID      string   `json:"ayah_id"`
+	Words       []string `json:"words"`             // reference words, in the ayah's order
+	Current     int      `json:"current,omitempty"` // index of the word to record
+	Passed      int      `json:"passed,omitempty"`  // words heard right
+}
+
+// NewWordDrill returns the drill of the words substituted in a recording,
+// each once. It reports false if no word was.
+func NewWordDrill(recording Recording) (WordDrill, bool) {
+	if recording.Result == nil {
+		return WordDrill{}, false
+	}
+	drill := WordDrill{RecordingID: recording.ID, AyahID: recording.AyahID}
+	seen := make(map[string]bool)
+	for _, op := range recording.Result.Ops {
+		if op.Op != OpSubstitution || op.RefAr == "" {
+			continue
+		}
+		if clean := arabicWord(op.RefAr); !seen[clean] {
+			seen[clean] = true
+			drill.Words = append(drill.Words, op.RefAr)
+		}
+	}
+	return drill, len(drill.Words) > 0
+}
+
+// Word returns the word to record, empty once the drill is done
+func (d WordDrill) Word() string {
+	if d.Done() {
+		return ""
+	}
+	return d.Words[d.Current]
+}
+
+// Done reports whether every word was heard right or skipped
+func (d WordDrill) Done() bool {
+	return d.Current >= len(d.Words)
+}
+
+// Attempt checks what was heard of a recording of the word to record,
+// moving on to the next word if the word was heard right. It reports
+// whether it was.
+func (d *WordDrill) Attempt(heard string) bool {
+	if d.Done() || !WordHeard(d.Word(), heard) {
+		return false
+	}
+	d.Current++
+	d.Passed++
+	return true
+}
+
+// Skip moves on to the next word without it being heard right
+func (d *WordDrill) Skip() {
+	if !d.Done() {
+		d.Current++
+	}
+}
+
+// WordHeard reports whether a word is among the words heard, spelled the
+// same or alike enough, as words typed from memory are
+func WordHeard(word, heard string) bool {
+	ref := textWords(word)
+	if len(ref) == 0 {
+		return false
+	}
+	for _, w := range textWords(heard) {
+		if ref[0].matches(w) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,44 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Surah represents a chapter in the Quran
 type Surah struct {
-	Number int
-	Name   string
-	Ayahs  int
+	Number     int
+	Name       string // transliterated, e.g. "Al-Baqarah"
+	Ayahs      int    // in the numbering of Hafs, see AyahCount
+	ArabicName string
+	Revelation Revelation
+	// Juz and Pages are the juz and the pages of the Madani mushaf the
+	// surah spans. Consecutive surahs may share a juz or a page.
+	Juz   Range
+	Pages Range
+}
+
+// Revelation is where a surah was revealed
+type Revelation string
+
+const (
+	RevelationMeccan  Revelation = "meccan"
+	RevelationMedinan Revelation = "medinan"
+)
+
+// Range is an inclusive range of juz or page numbers
+type Range struct {
+	First int
+	Last  int
+}
+
+// Contains reports whether n is in the range
+func (r Range) Contains(n int) bool {
+	return r.First <= n && n <= r.Last
 }
 
 // Ayah represents a verse in the Quran
@@ -27,10 +59,110 @@ type Recording struct {
 	AyahID    string
 	Status    RecordingStatus
 	Result    *RecordingResult
+	Error     string // why the analysis failed, as told by the API
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// Summary returns the lightweight view of a recording used in lists
+func (r *Recording) Summary() RecordingSummary {
+	summary := RecordingSummary{
+		ID:        r.ID,
+		AyahID:    r.AyahID,
+		Status:    r.Status,
+		CreatedAt: r.CreatedAt,
+	}
+	if r.Result != nil {
+		wer := r.Result.WER
+		summary.WER = &wer
+	}
+	return summary
+}
+
+// RecordingSummary is the metadata of a recording needed to render lists
+type RecordingSummary struct {
+	ID        string          `json:"id"`
+	AyahID    string          `json:"ayah_id"`
+	Status    RecordingStatus `json:"status"`
+	WER       *float64        `json:"wer,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Stage     RecordingStage  `json:"-"` // tracked by the bot, empty if not tracked
+}
+
+// Stat returns the history entry for a finished recording. ok is false if
+// the recording has no result yet.
+func (r *Recording) Stat() (stat StatEntry, ok bool) {
+	if r.Status != StatusDone || r.Result == nil {
+		return StatEntry{}, false
+	}
+
+	recordedAt := r.UpdatedAt
+	if recordedAt.IsZero() {
+		recordedAt = r.CreatedAt
+	}
+	return StatEntry{
+		RecordingID: r.ID,
+		AyahID:      r.AyahID,
+		Accuracy:    Accuracy(r.Result.WER),
+		WER:         r.Result.WER,
+		RecordedAt:  recordedAt,
+	}, true
+}
+
+// AudioSource is a recording received by a chat adapter, to be prepared for
+// analysis
+type AudioSource struct {
+	URL      string        // where the raw audio is downloaded from
+	MimeType string        // as reported by the sender, used when the content does not tell
+	Duration time.Duration // as reported by the sender, 0 if unknown
+}
+
+// AudioProcessOptions are the user's choices for preparing their recordings
+type AudioProcessOptions struct {
+	Enhance bool // reduce background noise
+}
+
+// AudioChunk is a part of a long recording, cut at a pause so it can be
+// submitted on its own
+type AudioChunk struct {
+	Data     []byte
+	Offset   time.Duration // start of the chunk in the whole recording
+	Duration time.Duration
+}
+
+// RecordingChunk is a chunk of a long recording submitted as a recording of
+// its own
+type RecordingChunk struct {
+	RecordingID string        `json:"recording_id"`
+	Offset      time.Duration `json:"offset"` // start of the chunk in the whole recording
+	Duration    time.Duration `json:"duration"`
+}
+
+// AudioMetadata describes a submitted recording. The API uses it to
+// schedule analyses; zero values are unknown and not sent.
+type AudioMetadata struct {
+	Duration       time.Duration `json:"duration,omitempty"`
+	SampleRate     int           `json:"sample_rate,omitempty"`     // of the submitted audio, in Hz
+	Format         string        `json:"format,omitempty"`          // of the submitted audio, e.g. "wav"
+	OriginalFormat string        `json:"original_format,omitempty"` // format the user sent, e.g. "ogg" or "mp3"
+}
+
+// CachedAudio is the converted audio of a user's latest submission, kept for
+// a short time so it can be resubmitted without recording again
+type CachedAudio struct {
+	AyahID   string        `json:"ayah_id"`
+	Data     []byte        `json:"data"`
+	Meta     AudioMetadata `json:"meta"`
+	StoredAt time.Time     `json:"stored_at"`
+}
+
+// ArchivedAudio is the converted audio of a submitted recording, kept in
+// long-term storage so it can be played back
+type ArchivedAudio struct {
+	Data []byte
+	Meta AudioMetadata
+}
+
 type RecordingStatus string
 
 const (
@@ -39,6 +171,62 @@ const (
 	StatusFailed RecordingStatus = "failed"
 )
 
+// RecordingEvent announces that a recording reached a final status
+type RecordingEvent struct {
+	RecordingID string          `json:"recording_id"`
+	UserID      string          `json:"user_id"`
+	ChatID      int64           `json:"chat_id"`
+	AyahID      string          `json:"ayah_id"`
+	Status      RecordingStatus `json:"status"`
+	// Shown is set when the result was already shown to the user in the
+	// message telling the recording was submitted
+	Shown bool `json:"shown,omitempty"`
+}
+
+// AuditAction names what an AuditEntry records
+type AuditAction string
+
+const (
+	AuditTransition     AuditAction = "transition"
+	AuditStart          AuditAction = "start"
+	AuditSubmission     AuditAction = "submission"
+	AuditFlowReset      AuditAction = "flow_reset"
+	AuditRateLimited    AuditAction = "rate_limited"
+	AuditSessionExpired AuditAction = "session_expired"
+)
+
+// AuditEntry is one line of a user's audit log, kept so that reports of the
+// bot getting stuck can be traced back to what actually happened
+type AuditEntry struct {
+	At     time.Time   `json:"at"`
+	Action AuditAction `json:"action"`
+	ChatID int64       `json:"chat_id,omitempty"` // group chat of the session, 0 for private
+	From   State       `json:"from,omitempty"`    // transitions only
+	To     State       `json:"to,omitempty"`      // transitions only
+	Detail string      `json:"detail,omitempty"`
+}
+
+// StatEntry is one completed recording in a user's history
+type StatEntry struct {
+	RecordingID string    `json:"recording_id"`
+	AyahID      string    `json:"ayah_id"`
+	Accuracy    float64   `json:"accuracy"`
+	WER         float64   `json:"wer"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// Accuracy converts a word error rate into a score between 0 and 1
+func Accuracy(wer float64) float64 {
+	switch {
+	case wer <= 0:
+		return 1
+	case wer >= 1:
+		return 0
+	default:
+		return 1 - wer
+	}
+}
+
 // RecordingResult represents the analysis result of a recording
 type RecordingResult struct {
 	WER        float64
@@ -64,8 +252,26 @@ const (
 	OpSubstitution OpType = "S" // Substitution (wrong word)
 	OpDeletion     OpType = "D" // Deletion (missing word)
 	OpInsertion    OpType = "I" // Insertion (extra word)
+	OpBasmala      OpType = "B" // Basmala recited before the ayah, not a mistake
+)
+
+// IsMistake reports whether the operation counts against the recitation
+func (t OpType) IsMistake() bool {
+	return t == OpSubstitution || t == OpDeletion || t == OpInsertion
+}
+
+// Riwayah is the transmission of the Quran a user recites, which decides
+// how ayahs are counted and numbered
+type Riwayah string
+
+const (
+	RiwayahHafs  Riwayah = "hafs"  // Hafs from Asim, Kufan count of 6236 ayahs
+	RiwayahWarsh Riwayah = "warsh" // Warsh from Nafi, Madani count of 6214 ayahs
 )
 
+// Riwayat lists the supported riwayat
+var Riwayat = []Riwayah{RiwayahHafs, RiwayahWarsh}
+
 // Language represents supported languages
 type Language string
 
@@ -74,3 +280,263 @@ const (
 	LangArabic  Language = "ar"
 	LangRussian Language = "ru"
 )
+
+// User represents the long-lived profile of a bot user, kept separately
+// from the short-lived FSM session
+type User struct {
+	ID              string
+	DisplayName     string       // as on the chat platform, kept current
+	Level           LearnerLevel // LevelBeginner when empty
+	Preferences     Preferences
+	RegisteredAt    time.Time
+	LastActiveAt    time.Time
+	LastRecordingAt time.Time
+	StreakDays      int
+	BestStreak      int
+	TotalRecordings int
+}
+
+// RecordRecording updates the user's aggregates and daily streak for a new
+// recording submitted at the given time, on the user's days
+func (u *User) RecordRecording(at time.Time, days DayBoundary) {
+	streak := u.Streak()
+	streak.Record(at, days)
+
+	u.StreakDays = streak.Days
+	u.BestStreak = streak.Best
+	u.TotalRecordings++
+	u.LastRecordingAt = streak.Last
+}
+
+// Streak returns the user's daily streak
+func (u *User) Streak() Streak {
+	return Streak{Days: u.StreakDays, Best: u.BestStreak, Last: u.LastRecordingAt}
+}
+
+// Mode represents how a user submits recordings
+type Mode string
+
+const (
+	ModeManual Mode = "manual" // User picks the surah and ayah before reciting
+	ModeTyped  Mode = "typed"  // User types the selected ayah from memory
+)
+
+// MaxRecentSurahs is the number of surahs a user selected last that are
+// offered first when picking a surah
+const MaxRecentSurahs = 5
+
+// Preferences holds per-user settings that outlive the FSM session
+type Preferences struct {
+	Language      Language `json:"language"`
+	MinSimilarity float64  `json:"min_similarity"` // Minimum accuracy (0..1) considered a pass
+	DefaultMode   Mode     `json:"default_mode"`
+	Reciter       string   `json:"reciter"` // Reference reciter identifier
+	Notifications bool     `json:"notifications"`
+	EnhanceAudio  bool     `json:"enhance_audio"` // Reduce background noise before submission
+	Riwayah       Riwayah  `json:"riwayah"`       // Decides ayah numbering
+	// Timezone is the IANA name of the user's time zone, e.g.
+	// "Europe/Moscow", deciding when their days start; UTC when empty
+	Timezone string `json:"timezone"`
+	// DayStartHour is the local hour days start at, e.g. 4 so that the night
+	// until Fajr counts with the day before; 0 for midnight
+	DayStartHour int `json:"day_start_hour"`
+	// SeparateBasmala labels a basmala recited before the ayah instead of
+	// counting its words as insertions
+	SeparateBasmala bool `json:"separate_basmala"`
+}
+
+// Location returns the user's time zone. Unknown time zones count as UTC.
+func (p Preferences) Location() *time.Location {
+	location, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// DayBoundary returns when the user's days start
+func (p Preferences) DayBoundary() DayBoundary {
+	return DayBoundary{Location: p.Location(), Offset: time.Duration(p.DayStartHour) * time.Hour}
+}
+
+// DefaultPreferences returns the preferences of a user who never changed them
+func DefaultPreferences() Preferences {
+	return Preferences{
+		Language:        LangEnglish,
+		MinSimilarity:   0.8,
+		DefaultMode:     ModeManual,
+		Reciter:         "Husary_128kbps",
+		Notifications:   true,
+		Riwayah:         RiwayahHafs,
+		SeparateBasmala: true,
+	}
+}
+
+// LeaderboardPeriod is the time window a leaderboard ranks users over
+type LeaderboardPeriod string
+
+const (
+	LeaderboardWeekly  LeaderboardPeriod = "weekly"
+	LeaderboardMonthly LeaderboardPeriod = "monthly"
+)
+
+// PeriodID identifies the period containing at, e.g. "2024-W07" for weekly
+// or "2024-02" for monthly boards. Periods are computed in UTC.
+func (p LeaderboardPeriod) PeriodID(at time.Time) string {
+	at = at.UTC()
+	if p == LeaderboardWeekly {
+		year, week := at.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return at.Format("2006-01")
+}
+
+// ActiveUser is a user and when they last interacted with the bot
+type ActiveUser struct {
+	UserID   string
+	LastSeen time.Time
+}
+
+// AnalyticsEventName names what an AnalyticsEvent records
+type AnalyticsEventName string
+
+const (
+	EventCommand        AnalyticsEventName = "command"         // command: the command used
+	EventModeChosen     AnalyticsEventName = "mode_chosen"     // mode: the practice mode of a new flow
+	EventFunnelStep     AnalyticsEventName = "funnel_step"     // from, to: the states of a transition
+	EventSessionExpired AnalyticsEventName = "session_expired" // the flow was abandoned mid-way
+	EventResult         AnalyticsEventName = "result"          // recording_id, accuracy: the accuracy bucket
+)
+
+// AnalyticsEvent is a usage event for product analytics. Events carry a
+// pseudonymous user ID rather than the Telegram one; it is stable, so a
+// user's events can be put back in order to find where flows are abandoned.
+type AnalyticsEvent struct {
+	Name       AnalyticsEventName `json:"name"`
+	UserID     string             `json:"user_id"`
+	At         time.Time          `json:"at"`
+	Properties map[string]string  `json:"properties,omitempty"`
+}
+
+// LeaderboardEntry is a user's position on a leaderboard
+type LeaderboardEntry struct {
+	UserID      string
+	DisplayName string // of the learner, empty if unknown
+	Rank        int    // 1-based
+	Score       float64
+}
+
+// RecordingPoints returns the leaderboard points earned by a completed
+// recording: its accuracy as a percentage
+func RecordingPoints(stat StatEntry) float64 {
+	return math.Round(stat.Accuracy * 100)
+}
+
+// RateAction names an action whose frequency is limited
+type RateAction string
+
+const (
+	RateActionSubmission  RateAction = "submission"   // recordings submitted by a user
+	RateActionBroadcast   RateAction = "broadcast"    // messages sent by a broadcast
+	RateActionSend        RateAction = "send"         // messages sent to Telegram
+	RateActionPrivateSend RateAction = "send_private" // messages sent to a private chat
+	RateActionGroupSend   RateAction = "send_group"   // messages sent to a group
+)
+
+// RateLimit is a token bucket allowing Requests per Per on average, with
+// bursts of up to Burst requests
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// TokensPerSecond returns the bucket refill rate
+func (l RateLimit) TokensPerSecond() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// SessionScope identifies a session. A user has one session in their private
+// chat with the bot and an independent one in every group they use it in.
+type SessionScope struct {
+	UserID string
+	ChatID int64 // group chat ID, 0 for the private chat
+}
+
+// PrivateScope returns the scope of the user's private chat session
+func PrivateScope(userID string) SessionScope {
+	return SessionScope{UserID: userID}
+}
+
+// IsGroup reports whether the scope is a group chat
+func (s SessionScope) IsGroup() bool {
+	return s.ChatID != 0
+}
+
+// Key encodes the scope as "<user>" for the private chat and
+// "<user>:<chat>" for groups, so private sessions keep their historical keys
+func (s SessionScope) Key() string {
+	if !s.IsGroup() {
+		return s.UserID
+	}
+	return s.UserID + ":" + strconv.FormatInt(s.ChatID, 10)
+}
+
+func (s SessionScope) String() string {
+	return s.Key()
+}
+
+// ParseSessionScope decodes a scope encoded by Key
+func ParseSessionScope(key string) (SessionScope, error) {
+	userID, chat, ok := strings.Cut(key, ":")
+	if !ok {
+		return PrivateScope(key), nil
+	}
+	chatID, err := strconv.ParseInt(chat, 10, 64)
+	if err != nil {
+		return SessionScope{}, fmt.Errorf("invalid session scope %q: %w", key, err)
+	}
+	return SessionScope{UserID: userID, ChatID: chatID}, nil
+}
+
+// Session is a user's short-lived FSM session
+type Session struct {
+	State         State           `json:"state"`
+	Flow          Flow            `json:"flow,omitempty"`            // empty until a flow starts
+	Context       json.RawMessage `json:"context,omitempty"`         // context of Flow, see ManualRecording
+	Mode          Mode            `json:"mode,omitempty"`            // practice mode of the current flow
+	LastMessageID int             `json:"last_message_id,omitempty"` // last message with an inline keyboard
+}
+
+// NewSession returns an empty session at the start of the flow
+func NewSession() *Session {
+	return &Session{State: StateStart}
+}
+
+// Transition moves the session to state to and drops data that belongs to
+// later steps of the flow, resuming the flow paused by an interrupting one.
+// Surah selection starts the manual recording flow over.
+func (s *Session) Transition(to State) {
+	s.State = to
+	s.Resume()
+	if to == StateSelectSurah {
+		s.SetManualRecording(ManualRecordingFlow{})
+		return
+	}
+
+	flow, err := s.ManualRecording()
+	if err != nil {
+		return
+	}
+	switch to {
+	case StateEnterAyah:
+		flow.AyahNumber = 0
+		flow.AyahInput = ""
+		flow.RangeStart, flow.RangeEnd = 0, 0
+		flow.RangeStartedAt = time.Time{}
+		flow.ChallengeID = ""
+	case StateWaitRecording:
+		flow.AyahInput = ""
+	}
+	s.SetManualRecording(flow)
+}
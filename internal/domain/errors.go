@@ -0,0 +1,51 @@
+package domain
+
+import "errors"
+
+// ErrUserNotFound is returned by UserRepository when no profile exists
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUnsupportedAudio is returned for recordings in a format that cannot be
+// converted
+var ErrUnsupportedAudio = errors.New("unsupported audio format")
+
+// ErrAudioTooLarge is returned for recordings over the size limit
+var ErrAudioTooLarge = errors.New("audio file too large")
+
+// ErrAudioTooShort is returned for recordings too short to hold an ayah
+var ErrAudioTooShort = errors.New("audio too short")
+
+// ErrSilentAudio is returned for recordings with nothing audible in them
+var ErrSilentAudio = errors.New("silent audio")
+
+// ErrNonSpeechAudio is returned for recordings that sound like music or
+// noise rather than a recitation
+var ErrNonSpeechAudio = errors.New("audio does not contain speech")
+
+// ErrInvalidTransition is returned when an action is not allowed in the
+// user's current state
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// ErrSessionExpired is returned, along with ErrInvalidTransition, when an
+// action needs a session that expired
+var ErrSessionExpired = errors.New("session expired")
+
+// ErrQuotaExceeded is returned when the Quran API refuses a request because
+// the bot's quota is used up
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrBackendUnavailable is returned when the Quran API cannot be reached or
+// fails on its side
+var ErrBackendUnavailable = errors.New("backend unavailable")
+
+// ErrNoPosition is returned when a user has no recorded ayah to continue
+// from
+var ErrNoPosition = errors.New("no ayah recorded yet")
+
+// ErrNotComparable is returned when recordings are compared that are not of
+// the same ayah or not analyzed yet
+var ErrNotComparable = errors.New("recordings cannot be compared")
+
+// ErrInvalidAyah is returned for ayahs and ayah ranges that are not in the
+// Quran, or not in the riwayah they are numbered in
+var ErrInvalidAyah = errors.New("invalid ayah")
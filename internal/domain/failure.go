@@ -0,0 +1,51 @@
+package domain
+
+import "strings"
+
+// FailureReason is why the analysis of a recording failed, in terms the
+// user can act on
+type FailureReason string
+
+const (
+	FailureNoSpeech FailureReason = "no_speech" // nothing was recited, e.g. silence
+	FailureTooShort FailureReason = "too_short"
+	FailureTooLong  FailureReason = "too_long"
+	FailureBadAudio FailureReason = "bad_audio" // the audio could not be decoded
+	FailureMismatch FailureReason = "mismatch"  // the recitation is not of the ayah
+	FailureTimeout  FailureReason = "timeout"
+	FailureUnknown  FailureReason = "unknown"
+)
+
+// failureKeywords maps words of the errors the API reports to the reason
+// they mean, checked in order
+var failureKeywords = []struct {
+	keywords []string
+	reason   FailureReason
+}{
+	{[]string{"silence", "silent", "no speech", "empty", "no voice"}, FailureNoSpeech},
+	{[]string{"too short"}, FailureTooShort},
+	{[]string{"too long"}, FailureTooLong},
+	{[]string{"decode", "format", "corrupt", "codec", "invalid audio"}, FailureBadAudio},
+	{[]string{"mismatch", "align", "does not match", "wrong ayah"}, FailureMismatch},
+	{[]string{"timeout", "timed out", "deadline"}, FailureTimeout},
+}
+
+// FailureReason returns why the analysis of a failed recording failed, from
+// the error told by the API, FailureUnknown if it does not say
+func (r *Recording) FailureReason() FailureReason {
+	message := strings.ToLower(r.Error)
+	for _, entry := range failureKeywords {
+		for _, keyword := range entry.keywords {
+			if strings.Contains(message, keyword) {
+				return entry.reason
+			}
+		}
+	}
+	return FailureUnknown
+}
+
+// Transient reports whether the analysis may succeed on the same audio,
+// the failure not being due to the recording itself
+func (r FailureReason) Transient() bool {
+	return r == FailureTimeout || r == FailureUnknown
+}
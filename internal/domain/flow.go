@@ -0,0 +1,249 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWrongFlow is returned when a handler reads or changes the context of a
+// flow the session is not in
+var ErrWrongFlow = errors.New("session is in another flow")
+
+// Flow is what a session is being used for. Each flow keeps its own context
+// in the session, so handlers only ever see the data of their flow.
+type Flow string
+
+const (
+	// FlowManualRecording picks a surah, then an ayah, then records it
+	FlowManualRecording Flow = "manual"
+	// FlowAutoDetect records first and lets the ayah be detected
+	FlowAutoDetect Flow = "auto_detect"
+	// FlowSearch looks a surah up by name
+	FlowSearch Flow = "search"
+	// FlowTag types a new tag of a recording. It pauses the flow it
+	// interrupts, resumed once the tag is typed.
+	FlowTag Flow = "tag"
+	// FlowWordDrill records the words a recording got wrong one by one. It
+	// pauses the flow it interrupts, resumed once the drill ends.
+	FlowWordDrill Flow = "word_drill"
+)
+
+// PausedFlow is a flow put aside by a short flow interrupting it, such as
+// FlowTag or FlowWordDrill, with its context
+type PausedFlow struct {
+	Flow    Flow            `json:"flow,omitempty"`
+	Context json.RawMessage `json:"context,omitempty"`
+}
+
+// interrupting reports whether a flow pauses the flow it interrupts
+func (f Flow) interrupting() bool {
+	return f == FlowTag || f == FlowWordDrill
+}
+
+// ManualRecordingFlow is the context of FlowManualRecording. A range of
+// ayahs is recorded one ayah after the other, the selected ayah moving
+// through the range.
+type ManualRecordingFlow struct {
+	SurahNumber int    `json:"surah,omitempty"`      // 0 when no surah is selected
+	AyahNumber  int    `json:"ayah,omitempty"`       // 0 when no ayah is selected
+	AyahInput   string `json:"ayah_input,omitempty"` // digits entered so far on the ayah keyboard
+
+	// RangeStart and RangeEnd are the first and last ayah of the range
+	// recorded, 0 when a single ayah is
+	RangeStart int `json:"range_start,omitempty"`
+	RangeEnd   int `json:"range_end,omitempty"`
+	// RangeStartedAt is when the first ayah of the range was recorded
+	RangeStartedAt time.Time `json:"range_started_at,omitzero"`
+
+	// ChallengeID is the challenge taken by recording the ayah, if any
+	ChallengeID string `json:"challenge,omitempty"`
+}
+
+// Ayah returns the selected ayah
+func (f ManualRecordingFlow) Ayah() Ayah {
+	return Ayah{SurahNumber: f.SurahNumber, AyahNumber: f.AyahNumber}
+}
+
+// IsRange reports whether a range of ayahs is recorded
+func (f ManualRecordingFlow) IsRange() bool {
+	return f.RangeEnd != 0
+}
+
+// Range returns the ayahs recorded: the range, or the selected ayah alone
+func (f ManualRecordingFlow) Range() AyahRange {
+	if !f.IsRange() {
+		return SingleAyah(f.Ayah())
+	}
+	return AyahRange{
+		Start: Ayah{SurahNumber: f.SurahNumber, AyahNumber: f.RangeStart},
+		End:   Ayah{SurahNumber: f.SurahNumber, AyahNumber: f.RangeEnd},
+	}
+}
+
+// Recorded moves the selected ayah to the next of the range once it was
+// recorded at a time. It reports false when no ayah is left to record.
+func (f *ManualRecordingFlow) Recorded(at time.Time) bool {
+	if !f.IsRange() || f.AyahNumber >= f.RangeEnd {
+		return false
+	}
+	if f.AyahNumber == f.RangeStart {
+		f.RangeStartedAt = at
+	}
+	f.AyahNumber++
+	return true
+}
+
+// AutoDetectFlow is the context of FlowAutoDetect
+type AutoDetectFlow struct {
+	SurahNumber int `json:"surah,omitempty"` // surah the recitation is expected in, 0 for any
+}
+
+// SearchFlow is the context of FlowSearch
+type SearchFlow struct {
+	Query string `json:"query,omitempty"`
+	Page  int    `json:"page,omitempty"` // page of the matches shown
+}
+
+// TagFlow is the context of FlowTag
+type TagFlow struct {
+	RecordingID string     `json:"recording"` // recording the tag is added to
+	Paused      PausedFlow `json:"paused,omitzero"`
+}
+
+// WordDrillFlow is the context of FlowWordDrill
+type WordDrillFlow struct {
+	Drill  WordDrill  `json:"drill"`
+	Paused PausedFlow `json:"paused,omitzero"`
+}
+
+// ManualRecording returns the context of the manual recording flow, also
+// while paused. It fails with ErrWrongFlow if the session is in another
+// flow.
+func (s *Session) ManualRecording() (ManualRecordingFlow, error) {
+	return flowContext[ManualRecordingFlow](s, FlowManualRecording)
+}
+
+// SetManualRecording puts the session in the manual recording flow, ending
+// any flow interrupting it
+func (s *Session) SetManualRecording(flow ManualRecordingFlow) {
+	s.setFlow(FlowManualRecording, flow)
+}
+
+// AutoDetect returns the context of the auto-detection flow. It fails with
+// ErrWrongFlow if the session is in another flow.
+func (s *Session) AutoDetect() (AutoDetectFlow, error) {
+	return flowContext[AutoDetectFlow](s, FlowAutoDetect)
+}
+
+// SetAutoDetect puts the session in the auto-detection flow
+func (s *Session) SetAutoDetect(flow AutoDetectFlow) {
+	s.setFlow(FlowAutoDetect, flow)
+}
+
+// Search returns the context of the search flow. It fails with
+// ErrWrongFlow if the session is in another flow.
+func (s *Session) Search() (SearchFlow, error) {
+	return flowContext[SearchFlow](s, FlowSearch)
+}
+
+// SetSearch puts the session in the search flow
+func (s *Session) SetSearch(flow SearchFlow) {
+	s.setFlow(FlowSearch, flow)
+}
+
+// Tag returns the context of the tag flow. It fails with ErrWrongFlow if
+// the session is in another flow.
+func (s *Session) Tag() (TagFlow, error) {
+	return flowContext[TagFlow](s, FlowTag)
+}
+
+// StartTag puts the session in the tag flow for a recording, pausing the
+// flow it was in
+func (s *Session) StartTag(recordingID string) {
+	s.setFlow(FlowTag, TagFlow{RecordingID: recordingID, Paused: s.pause()})
+}
+
+// WordDrill returns the context of the word drill flow. It fails with
+// ErrWrongFlow if the session is in another flow.
+func (s *Session) WordDrill() (WordDrillFlow, error) {
+	return flowContext[WordDrillFlow](s, FlowWordDrill)
+}
+
+// StartWordDrill puts the session in the word drill flow, pausing the flow
+// it was in
+func (s *Session) StartWordDrill(drill WordDrill) {
+	s.setFlow(FlowWordDrill, WordDrillFlow{Drill: drill, Paused: s.pause()})
+}
+
+// SetWordDrill replaces the drill of the word drill flow the session is in
+func (s *Session) SetWordDrill(drill WordDrill) {
+	s.setFlow(FlowWordDrill, WordDrillFlow{Drill: drill, Paused: s.pause()})
+}
+
+// Resume ends a flow interrupting another, such as the tag flow, and puts
+// the session back in the flow it paused. Other flows are left alone.
+func (s *Session) Resume() {
+	paused, ok := s.paused()
+	if !ok {
+		return
+	}
+	s.Flow = paused.Flow
+	s.Context = paused.Context
+}
+
+// pause returns the flow the session is in, to be resumed by a flow
+// interrupting it. An interrupting flow is not paused itself but replaced,
+// so the flow it paused is resumed instead.
+func (s *Session) pause() PausedFlow {
+	if paused, ok := s.paused(); ok {
+		return paused
+	}
+	return PausedFlow{Flow: s.Flow, Context: s.Context}
+}
+
+// paused returns the flow paused by the interrupting flow the session is
+// in. It reports false if the session is in no interrupting flow.
+func (s *Session) paused() (PausedFlow, bool) {
+	if !s.Flow.interrupting() {
+		return PausedFlow{}, false
+	}
+	// The contexts of interrupting flows all keep the flow they paused
+	var context struct {
+		Paused PausedFlow `json:"paused"`
+	}
+	_ = json.Unmarshal(s.Context, &context)
+	return context.Paused, true
+}
+
+// flowContext decodes the context of a flow. Sessions in no flow yet, e.g.
+// new ones, have the empty context of every flow, and the context of a
+// flow paused by an interrupting one can still be read.
+func flowContext[T any](s *Session, flow Flow) (T, error) {
+	var context T
+	current := PausedFlow{Flow: s.Flow, Context: s.Context}
+	if paused, ok := s.paused(); ok && flow != s.Flow {
+		current = paused
+	}
+	if current.Flow == "" {
+		return context, nil
+	}
+	if current.Flow != flow {
+		return context, fmt.Errorf("%w: %s, not %s", ErrWrongFlow, s.Flow, flow)
+	}
+	if len(current.Context) == 0 {
+		return context, nil
+	}
+	if err := json.Unmarshal(current.Context, &context); err != nil {
+		return context, fmt.Errorf("decode %s flow: %w", flow, err)
+	}
+	return context, nil
+}
+
+func (s *Session) setFlow(flow Flow, context any) {
+	// The flow contexts are plain structs, which always encode
+	raw, _ := json.Marshal(context)
+	s.Flow = flow
+	s.Context = raw
+}
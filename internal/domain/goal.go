@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrGoalNotFound is returned by GoalPort for goals that were not set
+var ErrGoalNotFound = errors.New("goal not found")
+
+// ErrTooManyGoals is returned when a user sets more goals than allowed at
+// once
+var ErrTooManyGoals = errors.New("too many goals")
+
+// goalNudgeDays is how many days pass between the progress messages of a
+// goal
+const goalNudgeDays = 3
+
+// Goal is a surah a user set out to memorize by a deadline, e.g. Surah
+// Al-Mulk by June 1. Days are the user's, at midnight UTC as returned by
+// DayBoundary.Day.
+type Goal struct {
+	UserID      string    `json:"user_id"`
+	SurahNumber int       `json:"surah_number"`
+	Start       time.Time `json:"start"`     // day the goal was set
+	Deadline    time.Time `json:"deadline"`  // last day of the goal
+	NudgedAt    time.Time `json:"nudged_at"` // day of the last progress message, zero if none
+}
+
+// NewGoal returns a goal set on a day to memorize a surah within a number of
+// days, that day included
+func NewGoal(userID string, surahNumber int, today time.Time, days int) Goal {
+	return Goal{
+		UserID:      userID,
+		SurahNumber: surahNumber,
+		Start:       today,
+		Deadline:    today.AddDate(0, 0, max(days, 1)-1),
+	}
+}
+
+// NudgeDue reports whether a progress message is due on a day: every few
+// days after the goal was set, on its last day, and once after it
+func (g Goal) NudgeDue(today time.Time) bool {
+	last := g.NudgedAt
+	if last.IsZero() {
+		last = g.Start
+	}
+	if !today.After(last) {
+		return false
+	}
+	return today.Equal(g.Deadline) || today.After(g.Deadline) ||
+		!today.Before(last.AddDate(0, 0, goalNudgeDays))
+}
+
+// Progress returns how far the goal is on a day given the user's progress
+// in the surah. Ayahs count as memorized once recited with minAccuracy.
+func (g Goal) Progress(p SurahProgress, today time.Time, minAccuracy float64) GoalProgress {
+	progress := GoalProgress{
+		Goal:      g,
+		Ayahs:     p.Ayahs,
+		DaysLeft:  max(daysBetween(today, g.Deadline)+1, 0),
+		DaysTotal: daysBetween(g.Start, g.Deadline) + 1,
+	}
+	for ayah := 1; ayah <= p.Ayahs; ayah++ {
+		if p.HasRecited(ayah) && p.BestAccuracy(ayah) >= minAccuracy {
+			progress.Memorized++
+		}
+	}
+	return progress
+}
+
+// GoalProgress is how far a goal is on a given day
+type GoalProgress struct {
+	Goal      Goal
+	Memorized int // ayahs recited with the passing accuracy
+	Ayahs     int
+	DaysLeft  int // today included, 0 once the deadline passed
+	DaysTotal int
+}
+
+// Done reports whether every ayah of the surah is memorized
+func (p GoalProgress) Done() bool {
+	return p.Ayahs > 0 && p.Memorized >= p.Ayahs
+}
+
+// Overdue reports whether the deadline passed before the goal was done
+func (p GoalProgress) Overdue() bool {
+	return !p.Done() && p.DaysLeft == 0
+}
+
+// Completion returns the share of the surah memorized, from 0 to 1
+func (p GoalProgress) Completion() float64 {
+	if p.Ayahs == 0 {
+		return 0
+	}
+	return float64(p.Memorized) / float64(p.Ayahs)
+}
+
+// AyahsPerDay returns how many ayahs are left to memorize each remaining
+// day to meet the deadline, rounded up
+func (p GoalProgress) AyahsPerDay() int {
+	left := max(p.Ayahs-p.Memorized, 0)
+	if p.DaysLeft == 0 {
+		return left
+	}
+	return (left + p.DaysLeft - 1) / p.DaysLeft
+}
+
+// OnTrack reports whether at least as much is memorized as the days gone by
+// call for, at an even pace from the day the goal was set
+func (p GoalProgress) OnTrack() bool {
+	if p.DaysTotal <= 0 {
+		return p.Done()
+	}
+	elapsed := min(p.DaysTotal-p.DaysLeft, p.DaysTotal)
+	return p.Memorized*p.DaysTotal >= p.Ayahs*elapsed
+}
+
+// daysBetween returns the number of days from one day to another, both at
+// midnight UTC
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
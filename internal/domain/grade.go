@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Grade is a named band of accuracy shown with results, e.g. "Excellent"
+// from 90%
+type Grade struct {
+	Name        string
+	MinAccuracy float64 // between 0 and 1
+}
+
+// GradingScale maps accuracies to grades. Grades are ordered from the best,
+// and the last one takes every accuracy below the others.
+type GradingScale []Grade
+
+// Grading scales deployments choose from, by name
+const (
+	GradingScaleWords   = "words"   // Excellent, Good, Needs work
+	GradingScaleLetters = "letters" // A to F
+)
+
+// GradingScalePreset returns a predefined scale, or false if name is not
+// one of the GradingScale constants
+func GradingScalePreset(name string) (GradingScale, bool) {
+	switch name {
+	case GradingScaleWords:
+		return GradingScale{
+			{Name: "excellent", MinAccuracy: 0.9},
+			{Name: "good", MinAccuracy: 0.75},
+			{Name: "needs_work", MinAccuracy: 0},
+		}, true
+	case GradingScaleLetters:
+		return GradingScale{
+			{Name: "A", MinAccuracy: 0.9},
+			{Name: "B", MinAccuracy: 0.8},
+			{Name: "C", MinAccuracy: 0.7},
+			{Name: "D", MinAccuracy: 0.6},
+			{Name: "F", MinAccuracy: 0},
+		}, true
+	}
+	return nil, false
+}
+
+// NewGradingScale orders grades from the best and checks that they have
+// distinct names and thresholds between 0 and 1
+func NewGradingScale(grades []Grade) (GradingScale, error) {
+	if len(grades) == 0 {
+		return nil, fmt.Errorf("grading scale has no grades")
+	}
+	scale := slices.Clone(GradingScale(grades))
+	slices.SortStableFunc(scale, func(a, b Grade) int {
+		switch {
+		case a.MinAccuracy > b.MinAccuracy:
+			return -1
+		case a.MinAccuracy < b.MinAccuracy:
+			return 1
+		}
+		return 0
+	})
+
+	for i, grade := range scale {
+		if grade.Name == "" {
+			return nil, fmt.Errorf("grade %d has no name", i+1)
+		}
+		if grade.MinAccuracy < 0 || grade.MinAccuracy > 1 {
+			return nil, fmt.Errorf("grade %s: min accuracy %v is not between 0 and 1", grade.Name, grade.MinAccuracy)
+		}
+		if i > 0 && grade.MinAccuracy == scale[i-1].MinAccuracy {
+			return nil, fmt.Errorf("grades %s and %s have the same min accuracy", scale[i-1].Name, grade.Name)
+		}
+		if slices.ContainsFunc(scale[:i], func(g Grade) bool { return g.Name == grade.Name }) {
+			return nil, fmt.Errorf("grade %s is defined twice", grade.Name)
+		}
+	}
+	return scale, nil
+}
+
+// Grade returns the grade of an accuracy between 0 and 1
+func (s GradingScale) Grade(accuracy float64) Grade {
+	for _, grade := range s {
+		if accuracy >= grade.MinAccuracy {
+			return grade
+		}
+	}
+	return s[len(s)-1]
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// ErrNoKhatmah is returned when a user acts on a khatmah without one in
+// progress
+var ErrNoKhatmah = errors.New("no khatmah in progress")
+
+// KhatmahPlans are the lengths in days offered for a khatmah, e.g. a juz a
+// day through Ramadan or half a juz a day over two months. Each splits
+// every juz evenly.
+var KhatmahPlans = []int{30, 60}
+
+// ValidKhatmahPlan reports whether days is a plan offered
+func ValidKhatmahPlan(days int) bool {
+	return slices.Contains(KhatmahPlans, days)
+}
+
+// Khatmah is a user's reading of the whole Quran over a number of days, a
+// portion a day, in mushaf order. Days are the user's, at midnight UTC as
+// returned by DayBoundary.Day.
+type Khatmah struct {
+	UserID string    `json:"user_id"`
+	Days   int       `json:"days"`
+	Start  time.Time `json:"start"` // first day of the plan
+	Next   Ayah      `json:"next"`  // ayah to record next
+	// Completed is the day each portion was finished, in order
+	Completed []time.Time `json:"completed,omitempty"`
+}
+
+// NewKhatmah returns a khatmah starting on a day from Al-Fatiha
+func NewKhatmah(userID string, days int, today time.Time) Khatmah {
+	return Khatmah{UserID: userID, Days: days, Start: today, Next: Ayah{SurahNumber: 1, AyahNumber: 1}}
+}
+
+// Done reports whether every portion was finished
+func (k Khatmah) Done() bool {
+	return len(k.Completed) >= k.Days
+}
+
+// Record moves the khatmah on once an ayah was recorded on a day. Only the
+// next ayah counts, so that the Quran is read in order. It reports whether
+// the khatmah moved on.
+func (k *Khatmah) Record(ayah Ayah, today time.Time, riwayah Riwayah) bool {
+	if k.Done() || ayah != k.Next {
+		return false
+	}
+	portions := KhatmahPortions(k.Days, riwayah)
+	if ayah == portions[len(k.Completed)].End {
+		k.Completed = append(k.Completed, today)
+	}
+	k.Next = ayah.Next(riwayah)
+	return true
+}
+
+// Progress returns how far the khatmah is on a day
+func (k Khatmah) Progress(today time.Time, riwayah Riwayah) KhatmahProgress {
+	portions := KhatmahPortions(k.Days, riwayah)
+	day := min(max(daysBetween(k.Start, today)+1, 1), k.Days)
+	progress := KhatmahProgress{
+		Khatmah: k,
+		Day:     day,
+		Today:   portions[day-1],
+		Read:    ayahIndex(k.Next, riwayah),
+		Total:   mushafLen(riwayah),
+	}
+	if k.Done() {
+		progress.Read = progress.Total
+	}
+	return progress
+}
+
+// KhatmahProgress is how far a khatmah is on a given day
+type KhatmahProgress struct {
+	Khatmah Khatmah
+	Day     int       // of the plan, from 1
+	Today   AyahRange // portion of the day
+	Read    int       // ayahs recorded
+	Total   int
+}
+
+// Behind returns how many portions of the days before this one are left,
+// negative when the user is ahead of the plan
+func (p KhatmahProgress) Behind() int {
+	return p.Day - 1 - len(p.Khatmah.Completed)
+}
+
+// KhatmahPortions splits the mushaf in a riwayah into the portions of a
+// plan of days, a multiple of 30: every juz is split into days/30 portions
+// of about as many ayahs.
+func KhatmahPortions(days int, riwayah Riwayah) []AyahRange {
+	perJuz := max(days/len(juzStarts), 1)
+	total := mushafLen(riwayah)
+
+	portions := make([]AyahRange, 0, perJuz*len(juzStarts))
+	for i, start := range juzStarts {
+		first, end := ayahIndex(start, riwayah), total
+		if i+1 < len(juzStarts) {
+			end = ayahIndex(juzStarts[i+1], riwayah)
+		}
+		for part := range perJuz {
+			from := first + (end-first)*part/perJuz
+			to := first + (end-first)*(part+1)/perJuz - 1
+			portions = append(portions, AyahRange{Start: ayahAt(from, riwayah), End: ayahAt(to, riwayah)})
+		}
+	}
+	return portions
+}
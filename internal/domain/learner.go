@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// LearnerLevel is how far along a learner is in their recitation
+type LearnerLevel string
+
+const (
+	LevelBeginner     LearnerLevel = "beginner"
+	LevelIntermediate LearnerLevel = "intermediate"
+	LevelAdvanced     LearnerLevel = "advanced"
+)
+
+// Learner is the profile of someone reciting with the bot, as shown on
+// leaderboards and to teachers rather than their chat ID
+type Learner struct {
+	ID          string
+	DisplayName string // from the chat platform, may be empty
+	Language    Language
+	Level       LearnerLevel
+	JoinedAt    time.Time
+	Settings    Preferences
+}
+
+// Learner returns the profile of the user
+func (u *User) Learner() Learner {
+	level := u.Level
+	if level == "" {
+		level = LevelBeginner
+	}
+	return Learner{
+		ID:          u.ID,
+		DisplayName: u.DisplayName,
+		Language:    u.Preferences.Language,
+		Level:       level,
+		JoinedAt:    u.RegisteredAt,
+		Settings:    u.Preferences,
+	}
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRecordingNotTracked is returned by RecordingLifecyclePort for
+// recordings whose lifecycle is not tracked, e.g. submitted before tracking
+// was enabled
+var ErrRecordingNotTracked = errors.New("recording not tracked")
+
+// RecordingStage is where a submitted recording is in its lifecycle, as
+// tracked by the bot rather than the API
+type RecordingStage string
+
+const (
+	StageSubmitted  RecordingStage = "submitted"  // accepted by the API
+	StageProcessing RecordingStage = "processing" // watched until its analysis finishes
+	StageNotified   RecordingStage = "notified"   // the user was told the outcome
+	StageReviewed   RecordingStage = "reviewed"   // the user opened the result
+)
+
+// stageTransitions lists the stages each stage may move to. Stages only move
+// forward, and may be skipped, e.g. when the user opens a result before the
+// notification is sent.
+var stageTransitions = map[RecordingStage][]RecordingStage{
+	StageSubmitted:  {StageProcessing, StageNotified, StageReviewed},
+	StageProcessing: {StageNotified, StageReviewed},
+	StageNotified:   {StageReviewed},
+}
+
+// CanAdvance reports whether a recording may move from one stage to another
+func CanAdvance(from, to RecordingStage) bool {
+	for _, stage := range stageTransitions[from] {
+		if stage == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Pending reports whether the outcome of a recording in this stage is yet to
+// be delivered to the user
+func (s RecordingStage) Pending() bool {
+	return s == StageSubmitted || s == StageProcessing
+}
+
+// RecordingLifecycle tracks a submitted recording until the user reviewed
+// its result, so that the poller, the notifier and the UI agree on what is
+// pending
+type RecordingLifecycle struct {
+	RecordingID string         `json:"recording_id"`
+	UserID      string         `json:"user_id"`
+	ChatID      int64          `json:"chat_id"` // where the outcome is delivered
+	AyahID      string         `json:"ayah_id"`
+	Stage       RecordingStage `json:"stage"`
+	SubmittedAt time.Time      `json:"submitted_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// Advance moves the recording to a stage at the given time and reports
+// whether it moved. It stays put if the stage is not ahead of its own.
+func (l *RecordingLifecycle) Advance(to RecordingStage, at time.Time) bool {
+	if !CanAdvance(l.Stage, to) {
+		return false
+	}
+	l.Stage = to
+	l.UpdatedAt = at
+	return true
+}
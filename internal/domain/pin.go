@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTooManyPins is returned when a user pins more recordings than allowed
+var ErrTooManyPins = errors.New("too many pinned recordings")
+
+// Pin is a recording a user pinned to keep it at hand, e.g. their best
+// attempt at an ayah
+type Pin struct {
+	RecordingID string    `json:"recording_id"`
+	AyahID      string    `json:"ayah_id"` // XXXYYY format, numbered in the riwayah recorded in
+	PinnedAt    time.Time `json:"pinned_at"`
+}
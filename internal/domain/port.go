@@ -3,39 +3,415 @@ package domain
 import (
 	"context"
 	"io"
+	"time"
 )
 
 // QuranAPIPort defines the interface for interacting with the Quran reading API
 type QuranAPIPort interface {
-	// SubmitRecording submits a voice recording for analysis
-	SubmitRecording(ctx context.Context, learnerID, ayahID string, audioFile io.Reader) (*Recording, error)
+	// SubmitRecording submits a voice recording of an ayah, numbered in
+	// riwayah, for analysis. An empty ayahID has the ayah auto-detected.
+	SubmitRecording(ctx context.Context, learnerID, ayahID string, riwayah Riwayah, audioFile io.Reader, meta AudioMetadata) (*Recording, error)
 
 	// GetRecording retrieves a recording by ID
 	GetRecording(ctx context.Context, learnerID, recordingID string) (*Recording, error)
 
 	// ListRecordings lists all recordings for a learner
 	ListRecordings(ctx context.Context, learnerID string, limit int) ([]*Recording, error)
+
+	// DeleteRecording permanently deletes a recording and its analysis
+	DeleteRecording(ctx context.Context, learnerID, recordingID string) error
+}
+
+// AyahTextPort defines the interface for the text of the Quran
+type AyahTextPort interface {
+	// AyahText returns the text of an ayah, numbered in riwayah, or
+	// ErrNoAyahText if the riwayah has no text
+	AyahText(ctx context.Context, riwayah Riwayah, ayah Ayah) (string, error)
+}
+
+// RecordingCachePort defines the interface for caching a user's recording list
+type RecordingCachePort interface {
+	// GetRecordings returns the cached summaries and when they were fetched.
+	// A zero time means nothing is cached.
+	GetRecordings(ctx context.Context, userID string) ([]RecordingSummary, time.Time, error)
+
+	// SetRecordings caches the summaries fetched at the given time
+	SetRecordings(ctx context.Context, userID string, recordings []RecordingSummary, fetchedAt time.Time) error
+
+	// DeleteRecordings invalidates the cached summaries
+	DeleteRecordings(ctx context.Context, userID string) error
+}
+
+// AudioProcessorPort defines the interface for preparing recordings for
+// analysis, independently of the chat platform they come from
+type AudioProcessorPort interface {
+	// PrepareAudio downloads a recording and converts it to the format
+	// submitted for analysis. It fails with ErrUnsupportedAudio,
+	// ErrAudioTooLarge, ErrSilentAudio or ErrNonSpeechAudio for recordings
+	// it cannot take or that cannot hold a recitation.
+	PrepareAudio(ctx context.Context, src AudioSource, opts AudioProcessOptions) ([]byte, AudioMetadata, error)
+
+	// SplitAudio cuts a prepared recording into chunks of at most
+	// maxDuration at pauses in the recitation
+	SplitAudio(ctx context.Context, data []byte, meta AudioMetadata, maxDuration time.Duration) ([]AudioChunk, error)
+
+	// EncodeVoice converts audio in the given format to OGG/Opus, for
+	// playback as a voice message
+	EncodeVoice(ctx context.Context, data []byte, format string) ([]byte, error)
+}
+
+// AudioCachePort defines the interface for keeping the audio of each user's
+// latest submission for a short time
+type AudioCachePort interface {
+	// PutAudio stores the audio, replacing the user's previous one
+	PutAudio(ctx context.Context, userID string, audio CachedAudio) error
+
+	// GetAudio returns the user's audio, or nil if there is none or it expired
+	GetAudio(ctx context.Context, userID string) (*CachedAudio, error)
+
+	// DeleteAudio deletes the user's audio
+	DeleteAudio(ctx context.Context, userID string) error
+}
+
+// AudioArchivePort defines the interface for keeping the audio of every
+// submitted recording
+type AudioArchivePort interface {
+	// PutRecordingAudio stores the audio of a recording
+	PutRecordingAudio(ctx context.Context, userID, recordingID string, audio ArchivedAudio) error
+
+	// GetRecordingAudio returns the audio of a recording, or nil if it was
+	// not archived
+	GetRecordingAudio(ctx context.Context, userID, recordingID string) (*ArchivedAudio, error)
+
+	// DeleteUserAudio deletes the audio of all the user's recordings
+	DeleteUserAudio(ctx context.Context, userID string) error
+}
+
+// StatsPort defines the interface for a user's history of completed recordings
+type StatsPort interface {
+	// AppendStat adds an entry to the user's history. Appending a recording
+	// that is already recorded is a no-op.
+	AppendStat(ctx context.Context, userID string, stat StatEntry) error
+
+	// ListStats returns the entries recorded at or after since, oldest first
+	ListStats(ctx context.Context, userID string, since time.Time) ([]StatEntry, error)
+
+	// DeleteStats deletes the user's whole history
+	DeleteStats(ctx context.Context, userID string) error
+}
+
+// RecordingLifecyclePort defines the interface for tracking the lifecycle of
+// submitted recordings
+type RecordingLifecyclePort interface {
+	// TrackRecording starts tracking a recording, replacing any lifecycle it
+	// had
+	TrackRecording(ctx context.Context, lifecycle RecordingLifecycle) error
+
+	// AdvanceRecording atomically moves a recording to a stage and reports
+	// whether it moved, so that of concurrent callers only one does. It
+	// fails with ErrRecordingNotTracked for recordings not tracked.
+	AdvanceRecording(ctx context.Context, userID, recordingID string, to RecordingStage, at time.Time) (bool, error)
+
+	// ListLifecycles returns the user's tracked recordings, newest first
+	ListLifecycles(ctx context.Context, userID string) ([]RecordingLifecycle, error)
+
+	// PendingRecordings returns the recordings of every user whose outcome
+	// is yet to be delivered, oldest first
+	PendingRecordings(ctx context.Context) ([]RecordingLifecycle, error)
+
+	// DeleteLifecycles stops tracking all of a user's recordings
+	DeleteLifecycles(ctx context.Context, userID string) error
+}
+
+// GoalPort defines the interface for storing users' memorization goals
+type GoalPort interface {
+	// SaveGoal adds a goal, or replaces the user's goal for the same surah
+	SaveGoal(ctx context.Context, goal Goal) error
+
+	// ListGoals returns the user's goals, soonest deadline first
+	ListGoals(ctx context.Context, userID string) ([]Goal, error)
+
+	// AllGoals returns the goals of every user
+	AllGoals(ctx context.Context) ([]Goal, error)
+
+	// DeleteGoal removes the user's goal for a surah. It fails with
+	// ErrGoalNotFound if there is none.
+	DeleteGoal(ctx context.Context, userID string, surahNumber int) error
+
+	// DeleteGoals removes all of a user's goals
+	DeleteGoals(ctx context.Context, userID string) error
+}
+
+// PositionPort defines the interface for remembering the last ayah each user
+// recorded, so they can continue reciting from there
+type PositionPort interface {
+	// SetPosition records the ayah the user last recorded
+	SetPosition(ctx context.Context, userID string, ayah Ayah) error
+
+	// GetPosition returns the ayah the user last recorded, or nil if they
+	// recorded none
+	GetPosition(ctx context.Context, userID string) (*Ayah, error)
+
+	// DeletePosition forgets the user's position
+	DeletePosition(ctx context.Context, userID string) error
+}
+
+// RecentSurahPort defines the interface for remembering the surahs each
+// user selected last, to offer them first
+type RecentSurahPort interface {
+	// AddRecentSurah records that the user selected a surah, keeping the
+	// MaxRecentSurahs selected last
+	AddRecentSurah(ctx context.Context, userID string, surah int) error
+
+	// RecentSurahs returns the surahs the user selected last, latest first
+	RecentSurahs(ctx context.Context, userID string) ([]int, error)
+
+	// DeleteRecentSurahs forgets the surahs the user selected
+	DeleteRecentSurahs(ctx context.Context, userID string) error
+}
+
+// WirdPort defines the interface for storing users' daily wird
+// subscriptions
+type WirdPort interface {
+	// SaveWird adds or replaces the user's subscription
+	SaveWird(ctx context.Context, wird Wird) error
+
+	// GetWird returns the user's subscription, or nil if they have none
+	GetWird(ctx context.Context, userID string) (*Wird, error)
+
+	// AllWirds returns the subscriptions of every user
+	AllWirds(ctx context.Context) ([]Wird, error)
+
+	// DeleteWird removes the user's subscription
+	DeleteWird(ctx context.Context, userID string) error
+}
+
+// KhatmahPort defines the interface for storing the khatmah each user is
+// reading
+type KhatmahPort interface {
+	// SaveKhatmah adds or replaces the user's khatmah
+	SaveKhatmah(ctx context.Context, khatmah Khatmah) error
+
+	// GetKhatmah returns the user's khatmah, or nil if they have none
+	GetKhatmah(ctx context.Context, userID string) (*Khatmah, error)
+
+	// DeleteKhatmah removes the user's khatmah
+	DeleteKhatmah(ctx context.Context, userID string) error
+}
+
+// PinPort defines the interface for storing the recordings each user
+// pinned
+type PinPort interface {
+	// PinRecording pins a recording, or updates its pin
+	PinRecording(ctx context.Context, userID string, pin Pin) error
+
+	// UnpinRecording unpins a recording, doing nothing if it was not pinned
+	UnpinRecording(ctx context.Context, userID, recordingID string) error
+
+	// ListPins returns the user's pinned recordings, latest pinned first
+	ListPins(ctx context.Context, userID string) ([]Pin, error)
+
+	// DeletePins unpins all of the user's recordings
+	DeletePins(ctx context.Context, userID string) error
+}
+
+// TagPort defines the interface for storing the tags users put on their
+// recordings
+type TagPort interface {
+	// SetTags replaces the tags of a recording, removing them all when tags
+	// is empty
+	SetTags(ctx context.Context, userID, recordingID string, tags []string) error
+
+	// ListTags returns the tags of the user's tagged recordings by recording
+	// ID, each sorted
+	ListTags(ctx context.Context, userID string) (map[string][]string, error)
+
+	// DeleteTags removes the tags of all of the user's recordings
+	DeleteTags(ctx context.Context, userID string) error
+}
+
+// RecordingChunkPort defines the interface for remembering the chunks long
+// recordings were split into, each submitted as a recording of its own.
+// The recording of the first chunk stands for the whole recording.
+type RecordingChunkPort interface {
+	// SetChunks stores the chunks of a recording, keyed by the recording of
+	// its first chunk
+	SetChunks(ctx context.Context, userID, recordingID string, chunks []RecordingChunk) error
+
+	// ListChunks returns the chunks of the user's split recordings by the
+	// recording of their first chunk
+	ListChunks(ctx context.Context, userID string) (map[string][]RecordingChunk, error)
+
+	// DeleteChunks forgets the chunks of one of the user's recordings, or
+	// of all of them when recordingID is empty
+	DeleteChunks(ctx context.Context, userID, recordingID string) error
+}
+
+// ChallengePort defines the interface for storing challenges between
+// friends and the attempts at them. Both expire after the given ttl, which
+// also bounds how long they outlive a user deleting their data.
+type ChallengePort interface {
+	// SaveChallenge stores a challenge
+	SaveChallenge(ctx context.Context, challenge Challenge, ttl time.Duration) error
+
+	// GetChallenge returns a challenge. It fails with ErrChallengeNotFound
+	// if there is none or it expired.
+	GetChallenge(ctx context.Context, id string) (*Challenge, error)
+
+	// SaveAnswer stores a friend's attempt at a challenge
+	SaveAnswer(ctx context.Context, answer ChallengeAnswer, ttl time.Duration) error
+
+	// GetAnswer returns the challenge attempt a recording is, or nil if it
+	// is none
+	GetAnswer(ctx context.Context, recordingID string) (*ChallengeAnswer, error)
+}
+
+// LeaderboardPort defines the interface for weekly and monthly leaderboards
+type LeaderboardPort interface {
+	// AddScore adds the points of a completed recording to the boards of the
+	// periods containing at. Adding the same recording twice is a no-op.
+	AddScore(ctx context.Context, userID, recordingID string, points float64, at time.Time) error
+
+	// Top returns the highest ranked users of the period containing at
+	Top(ctx context.Context, period LeaderboardPeriod, at time.Time, limit int) ([]LeaderboardEntry, error)
+
+	// Rank returns the user's position in the period containing at, or nil if
+	// the user has no score in that period
+	Rank(ctx context.Context, period LeaderboardPeriod, at time.Time, userID string) (*LeaderboardEntry, error)
+
+	// RemoveUser removes the user from the current boards
+	RemoveUser(ctx context.Context, userID string, at time.Time) error
+}
+
+// RateLimiterPort defines the interface for per-action rate limiting
+type RateLimiterPort interface {
+	// Allow takes a token from the bucket of action for key. If the bucket is
+	// empty it returns false and how long to wait for the next token.
+	// Actions without a configured limit are always allowed.
+	Allow(ctx context.Context, action RateAction, key string) (bool, time.Duration, error)
 }
 
 // FSMPort defines the interface for finite state machine storage
 type FSMPort interface {
-	// SetState sets the current state for a user
-	SetState(ctx context.Context, userID string, state State) error
+	// GetSession reads a session. A missing or expired session is returned
+	// as a new session in StateStart.
+	GetSession(ctx context.Context, scope SessionScope) (*Session, error)
+
+	// SaveSession stores a whole session and refreshes its expiry
+	SaveSession(ctx context.Context, scope SessionScope, session *Session) error
+
+	// UpdateSession atomically reads the session, applies fn and writes all
+	// fields back together. If fn returns an error nothing is written and the
+	// error is returned as is.
+	UpdateSession(ctx context.Context, scope SessionScope, fn func(session *Session) error) (*Session, error)
+
+	// ClearSession deletes a session
+	ClearSession(ctx context.Context, scope SessionScope) error
+
+	// ClearUserSessions deletes all of a user's sessions, in every chat
+	ClearUserSessions(ctx context.Context, userID string) error
 
-	// GetState gets the current state for a user
-	GetState(ctx context.Context, userID string) (State, error)
+	// PopExpiredSessions returns up to limit sessions that expired while in
+	// the middle of a flow. Each one is returned once, even when several
+	// replicas poll concurrently.
+	PopExpiredSessions(ctx context.Context, limit int) ([]SessionScope, error)
+}
+
+// UserRepository defines the interface for persistent user profile storage
+type UserRepository interface {
+	// GetUser retrieves a user profile, returning ErrUserNotFound if none exists
+	GetUser(ctx context.Context, userID string) (*User, error)
+
+	// UpdateUser applies fn to a user profile, or to a new one registered at
+	// now if none exists, and saves its activity and aggregates, locking the
+	// profile meanwhile. Preferences, language and learner details are kept
+	// by their own calls and left as they are.
+	UpdateUser(ctx context.Context, userID string, now time.Time, fn func(user *User)) error
+
+	// SetDisplayName stores the display name of a learner, creating their
+	// learner details at LevelBeginner if needed
+	SetDisplayName(ctx context.Context, userID, displayName string) error
+
+	// DeleteUser deletes a user profile
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// AuditLogPort keeps a capped, append-only log of each user's state
+// transitions and notable actions
+type AuditLogPort interface {
+	// AppendAudit adds an entry, dropping the oldest ones beyond the cap
+	AppendAudit(ctx context.Context, userID string, entry AuditEntry) error
 
-	// DeleteState deletes the state for a user
-	DeleteState(ctx context.Context, userID string) error
+	// ListAudit returns up to limit entries, newest first
+	ListAudit(ctx context.Context, userID string, limit int) ([]AuditEntry, error)
 
-	// SetData sets temporary data for a user's current session
-	SetData(ctx context.Context, userID, key, value string) error
+	// DeleteAudit deletes the user's whole log
+	DeleteAudit(ctx context.Context, userID string) error
+}
+
+// ActivityPort keeps track of when each user last interacted with the bot,
+// for the admin dashboard and broadcasts
+type ActivityPort interface {
+	// TouchUser records that the user interacted with the bot at the given
+	// time
+	TouchUser(ctx context.Context, userID string, at time.Time) error
 
-	// GetData gets temporary data for a user's current session
-	GetData(ctx context.Context, userID, key string) (string, error)
+	// ActiveUsers returns the users who interacted at or after since, most
+	// recent first
+	ActiveUsers(ctx context.Context, since time.Time) ([]ActiveUser, error)
+
+	// RemoveUser forgets the user
+	RemoveUser(ctx context.Context, userID string) error
+}
 
-	// DeleteData deletes temporary data for a user
-	DeleteData(ctx context.Context, userID, key string) error
+// AnalyticsSinkPort stores usage events for product analytics
+type AnalyticsSinkPort interface {
+	// WriteEvents stores a batch of events
+	WriteEvents(ctx context.Context, events []AnalyticsEvent) error
+}
+
+// PreferencesPort defines the interface for per-user settings storage
+type PreferencesPort interface {
+	// GetPreferences retrieves a user's preferences, returning
+	// DefaultPreferences if none were stored
+	GetPreferences(ctx context.Context, userID string) (Preferences, error)
+
+	// SetPreferences stores a user's preferences
+	SetPreferences(ctx context.Context, userID string, prefs Preferences) error
+
+	// DeletePreferences deletes a user's stored preferences
+	DeletePreferences(ctx context.Context, userID string) error
+}
+
+// LockPort defines the interface for locks shared between bot replicas
+type LockPort interface {
+	// Acquire takes the lock for key if it is free and reports whether it
+	// succeeded. The lock expires after ttl unless released earlier.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Release releases a lock previously taken by this process
+	Release(ctx context.Context, key string) error
+
+	// Extend makes a lock taken by this process expire after ttl from now,
+	// and reports whether it was still held
+	Extend(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// UpdateLogPort defines the interface for remembering the Telegram updates
+// already handled by any replica, to skip those delivered again
+type UpdateLogPort interface {
+	// MarkHandled records an update and reports whether it was not handled
+	// within the last ttl
+	MarkHandled(ctx context.Context, updateID int, ttl time.Duration) (bool, error)
+}
+
+// EventBusPort defines the interface for broadcasting events to all replicas
+type EventBusPort interface {
+	// Publish broadcasts a recording event
+	Publish(ctx context.Context, event RecordingEvent) error
+
+	// Subscribe calls handler for every published event until ctx is done
+	Subscribe(ctx context.Context, handler func(ctx context.Context, event RecordingEvent)) error
 }
 
 // I18nPort defines the interface for internationalization
@@ -66,11 +442,3 @@ const (
 	StateWaitRecording State = "wait_recording"
 	StateProcessing    State = "processing"
 )
-
-// SessionData keys
-const (
-	SessionKeySurah     = "surah"
-	SessionKeyAyah      = "ayah"
-	SessionKeyAyahInput = "ayah_input" // Accumulated digit input for ayah number
-	SessionKeyLanguage  = "language"
-)
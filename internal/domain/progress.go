@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// SurahProgress is how much of a surah a user has recited, and how well:
+// a bitmap of the ayahs recited and the best accuracy of each
+type SurahProgress struct {
+	SurahNumber int       `json:"surah_number"`
+	Ayahs       int       `json:"ayahs"`    // in the numbering progress was recorded in
+	Recited     []uint64  `json:"recited"`  // bit n-1 is set once ayah n was recited
+	Accuracy    []float64 `json:"accuracy"` // best accuracy of ayah n at n-1
+}
+
+// NewSurahProgress returns the progress of a surah nothing was recited of,
+// numbered in riwayah
+func NewSurahProgress(surahNumber int, riwayah Riwayah) SurahProgress {
+	ayahs := 0
+	if surahNumber >= 1 && surahNumber <= len(surahs) {
+		ayahs = surahs[surahNumber-1].AyahCount(riwayah)
+	}
+	return SurahProgress{
+		SurahNumber: surahNumber,
+		Ayahs:       ayahs,
+		Recited:     make([]uint64, (ayahs+63)/64),
+		Accuracy:    make([]float64, ayahs),
+	}
+}
+
+// Record marks an ayah recited with an accuracy, keeping the best accuracy
+// of the ayah. It reports false for ayahs not in the surah.
+func (p *SurahProgress) Record(ayah int, accuracy float64) bool {
+	if ayah < 1 || ayah > p.Ayahs {
+		return false
+	}
+	p.Recited[(ayah-1)/64] |= 1 << ((ayah - 1) % 64)
+	p.Accuracy[ayah-1] = max(p.Accuracy[ayah-1], accuracy)
+	return true
+}
+
+// HasRecited reports whether an ayah was recited
+func (p SurahProgress) HasRecited(ayah int) bool {
+	if ayah < 1 || ayah > p.Ayahs {
+		return false
+	}
+	return p.Recited[(ayah-1)/64]&(1<<((ayah-1)%64)) != 0
+}
+
+// BestAccuracy returns the best accuracy an ayah was recited with, 0 if it
+// was not
+func (p SurahProgress) BestAccuracy(ayah int) float64 {
+	if ayah < 1 || ayah > p.Ayahs {
+		return 0
+	}
+	return p.Accuracy[ayah-1]
+}
+
+// RecitedCount returns how many ayahs were recited
+func (p SurahProgress) RecitedCount() int {
+	var n int
+	for _, word := range p.Recited {
+		n += bits.OnesCount64(word)
+	}
+	return n
+}
+
+// Completion returns the share of the surah's ayahs recited, from 0 to 1
+func (p SurahProgress) Completion() float64 {
+	if p.Ayahs == 0 {
+		return 0
+	}
+	return float64(p.RecitedCount()) / float64(p.Ayahs)
+}
+
+// Complete reports whether every ayah was recited
+func (p SurahProgress) Complete() bool {
+	return p.Ayahs > 0 && p.RecitedCount() == p.Ayahs
+}
+
+// Missing returns the ayahs not recited yet, in order
+func (p SurahProgress) Missing() []int {
+	var missing []int
+	for ayah := 1; ayah <= p.Ayahs; ayah++ {
+		if !p.HasRecited(ayah) {
+			missing = append(missing, ayah)
+		}
+	}
+	return missing
+}
+
+// AverageAccuracy returns the mean best accuracy of the recited ayahs, 0 if
+// none was
+func (p SurahProgress) AverageAccuracy() float64 {
+	var sum float64
+	var n int
+	for ayah := 1; ayah <= p.Ayahs; ayah++ {
+		if p.HasRecited(ayah) {
+			sum += p.Accuracy[ayah-1]
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// ProgressFromStats builds the progress of every surah with a recording in
+// a history, ordered by surah, numbered in riwayah. Entries with malformed
+// ayah IDs are skipped.
+func ProgressFromStats(stats []StatEntry, riwayah Riwayah) []SurahProgress {
+	bySurah := make(map[int]*SurahProgress)
+	for _, stat := range stats {
+		ayah, err := ParseAyahID(stat.AyahID)
+		if err != nil {
+			continue
+		}
+		progress, ok := bySurah[ayah.SurahNumber]
+		if !ok {
+			p := NewSurahProgress(ayah.SurahNumber, riwayah)
+			progress = &p
+			bySurah[ayah.SurahNumber] = progress
+		}
+		progress.Record(ayah.AyahNumber, stat.Accuracy)
+	}
+
+	list := make([]SurahProgress, 0, len(bySurah))
+	for _, progress := range bySurah {
+		list = append(list, *progress)
+	}
+	slices.SortFunc(list, func(a, b SurahProgress) int { return a.SurahNumber - b.SurahNumber })
+	return list
+}
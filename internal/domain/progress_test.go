@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"testing"
+)
+
+// checkProgress fails unless exactly the ayahs in best were recited, each
+// with its best accuracy
+func checkProgress(t *testing.T, p SurahProgress, best map[int]float64) {
+	t.Helper()
+	if got := p.RecitedCount(); got != len(best) {
+		t.Errorf("surah %d: RecitedCount() = %d, want %d", p.SurahNumber, got, len(best))
+	}
+	for ayah := 1; ayah <= p.Ayahs; ayah++ {
+		want, recited := best[ayah]
+		if got := p.HasRecited(ayah); got != recited {
+			t.Errorf("surah %d: HasRecited(%d) = %v, want %v", p.SurahNumber, ayah, got, recited)
+		}
+		if got := p.BestAccuracy(ayah); got != want {
+			t.Errorf("surah %d: BestAccuracy(%d) = %v, want %v", p.SurahNumber, ayah, got, want)
+		}
+	}
+}
+
+func TestSurahProgressRecord(t *testing.T) {
+	type record struct {
+		ayah     int
+		accuracy float64
+	}
+	tests := []struct {
+		name     string
+		surah    int
+		riwayah  Riwayah
+		records  []record
+		want     map[int]float64 // best accuracy by ayah recited
+		rejected int
+	}{
+		{name: "nothing recited", surah: 1, riwayah: RiwayahHafs},
+		{name: "one ayah", surah: 1, riwayah: RiwayahHafs, records: []record{{3, 0.8}}, want: map[int]float64{3: 0.8}},
+		{
+			name:    "best accuracy kept",
+			surah:   1,
+			riwayah: RiwayahHafs,
+			records: []record{{3, 0.9}, {3, 0.5}, {3, 0.95}},
+			want:    map[int]float64{3: 0.95},
+		},
+		{name: "recited without accuracy", surah: 1, riwayah: RiwayahHafs, records: []record{{2, 0}}, want: map[int]float64{2: 0}},
+		{name: "first and last ayahs", surah: 1, riwayah: RiwayahHafs, records: []record{{1, 1}, {7, 0.6}}, want: map[int]float64{1: 1, 7: 0.6}},
+		{name: "outside the surah", surah: 1, riwayah: RiwayahHafs, records: []record{{0, 1}, {8, 1}, {-1, 1}}, rejected: 3},
+		{
+			name:    "past the first bitmap word",
+			surah:   2,
+			riwayah: RiwayahHafs,
+			records: []record{{64, 0.7}, {65, 0.6}, {128, 0.5}, {286, 1}},
+			want:    map[int]float64{64: 0.7, 65: 0.6, 128: 0.5, 286: 1},
+		},
+		{
+			name:     "numbered in the riwayah",
+			surah:    2,
+			riwayah:  RiwayahWarsh,
+			records:  []record{{285, 0.9}, {286, 0.9}},
+			want:     map[int]float64{285: 0.9},
+			rejected: 1,
+		},
+		{name: "unknown surah", surah: 115, riwayah: RiwayahHafs, records: []record{{1, 1}}, rejected: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewSurahProgress(tt.surah, tt.riwayah)
+			var rejected int
+			for _, r := range tt.records {
+				if !p.Record(r.ayah, r.accuracy) {
+					rejected++
+				}
+			}
+			if rejected != tt.rejected {
+				t.Errorf("Record() refused %d ayahs, want %d", rejected, tt.rejected)
+			}
+			checkProgress(t, p, tt.want)
+		})
+	}
+}
+
+func TestSurahProgressSummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		recited    map[int]float64
+		completion float64
+		complete   bool
+		missing    []int
+		average    float64
+	}{
+		{name: "nothing recited", completion: 0, missing: []int{1, 2, 3, 4, 5, 6, 7}},
+		{name: "some ayahs", recited: map[int]float64{1: 1, 3: 0.5}, completion: 2.0 / 7, missing: []int{2, 4, 5, 6, 7}, average: 0.75},
+		{
+			name:       "whole surah",
+			recited:    map[int]float64{1: 1, 2: 1, 3: 1, 4: 1, 5: 1, 6: 1, 7: 0.3},
+			completion: 1,
+			complete:   true,
+			average:    6.3 / 7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewSurahProgress(1, RiwayahHafs)
+			for _, ayah := range slices.Sorted(maps.Keys(tt.recited)) {
+				p.Record(ayah, tt.recited[ayah])
+			}
+			if got := p.Completion(); got != tt.completion {
+				t.Errorf("Completion() = %v, want %v", got, tt.completion)
+			}
+			if got := p.Complete(); got != tt.complete {
+				t.Errorf("Complete() = %v, want %v", got, tt.complete)
+			}
+			if got := p.Missing(); !slices.Equal(got, tt.missing) {
+				t.Errorf("Missing() = %v, want %v", got, tt.missing)
+			}
+			if got := p.AverageAccuracy(); math.Abs(got-tt.average) > 1e-9 {
+				t.Errorf("AverageAccuracy() = %v, want %v", got, tt.average)
+			}
+		})
+	}
+}
+
+func TestProgressFromStats(t *testing.T) {
+	type surah struct {
+		number int
+		best   map[int]float64
+	}
+	tests := []struct {
+		name    string
+		stats   []StatEntry
+		riwayah Riwayah
+		want    []surah
+	}{
+		{name: "no history", riwayah: RiwayahHafs},
+		{
+			name: "ordered by surah, best accuracy kept",
+			stats: []StatEntry{
+				{AyahID: "002001", Accuracy: 0.5},
+				{AyahID: "001002", Accuracy: 0.9},
+				{AyahID: "001002", Accuracy: 0.7},
+				{AyahID: "002001", Accuracy: 0.8},
+				{AyahID: "114006", Accuracy: 1},
+			},
+			riwayah: RiwayahHafs,
+			want: []surah{
+				{1, map[int]float64{2: 0.9}},
+				{2, map[int]float64{1: 0.8}},
+				{114, map[int]float64{6: 1}},
+			},
+		},
+		{
+			name:    "malformed ayah IDs skipped",
+			stats:   []StatEntry{{AyahID: ""}, {AyahID: "abc"}, {AyahID: "001008"}, {AyahID: "001001", Accuracy: 1}},
+			riwayah: RiwayahHafs,
+			want:    []surah{{1, map[int]float64{1: 1}}},
+		},
+		{
+			name:    "ayah outside the riwayah's numbering",
+			stats:   []StatEntry{{AyahID: "002286", Accuracy: 1}, {AyahID: "002285", Accuracy: 0.6}},
+			riwayah: RiwayahWarsh,
+			want:    []surah{{2, map[int]float64{285: 0.6}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ProgressFromStats(tt.stats, tt.riwayah)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ProgressFromStats() returned %d surahs, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].SurahNumber != want.number {
+					t.Fatalf("surah %d is %d, want %d", i, got[i].SurahNumber, want.number)
+				}
+				checkProgress(t, got[i], want.best)
+			}
+		})
+	}
+}
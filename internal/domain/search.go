@@ -0,0 +1,309 @@
+package domain
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// minSurahMatchScore is the least score of the candidates FindSurah returns
+const minSurahMatchScore = 0.6
+
+// SurahMatch is a surah found by FindSurah, with how well its name matched
+// the query, from 0 to 1
+type SurahMatch struct {
+	Surah Surah
+	Score float64
+}
+
+// transliterations map letters of the scripts users type surah names in to
+// Latin, by the language they are read in
+var transliterations = map[Language]map[rune]string{
+	LangRussian: {
+		'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+		'ж': "j", 'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m",
+		'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+		'ф': "f", 'х': "h", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "sh", 'ъ': "",
+		'ы': "i", 'ь': "", 'э': "e", 'ю': "iu", 'я': "ia",
+	},
+}
+
+// latinSounds collapse the spellings of the same sound in transliterated
+// names, applied in order, e.g. "Baqarah", "Bakara" and "Baqara" all become
+// "bakara"
+var latinSounds = strings.NewReplacer(
+	"kh", "h", "dh", "z", "th", "s", "ph", "f", "ck", "k", "q", "k", "c", "k",
+	"x", "ks", "aa", "a", "ee", "i", "oo", "u", "ou", "u",
+	"e", "i", "o", "u", "y", "i", "w", "u",
+)
+
+// latinArticles are the forms of the Arabic article starting transliterated
+// names, e.g. "Al-" or "Ash-"
+var latinArticles = []string{"adh", "ash", "ath", "aal", "al", "an", "ar", "as", "at", "ad", "az", "el", "ul"}
+
+// FindSurah returns the surahs whose names match query, best first. Names
+// match in Arabic, with or without diacritics, and in any transliteration
+// close enough to the usual one, e.g. "bakara", "baqarah" and "البقرة" all
+// find Al-Baqarah. Queries in other scripts are transliterated, preferring
+// the letters of lang. A surah number matches that surah.
+func FindSurah(query string, lang Language) []SurahMatch {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+	if number, err := strconv.Atoi(toWesternDigits(query)); err == nil {
+		if number < 1 || number > len(surahs) {
+			return nil
+		}
+		return []SurahMatch{{Surah: surahs[number-1], Score: 1}}
+	}
+
+	var keys []string
+	if isArabic(query) {
+		keys = []string{arabicKey(query)}
+	} else {
+		keys = latinKeys(transliterate(query, lang))
+	}
+
+	var matches []SurahMatch
+	for _, surah := range surahs {
+		names := append(latinKeys(surah.Name), arabicKey(surah.ArabicName))
+		var best float64
+		for _, key := range keys {
+			for _, name := range names {
+				best = max(best, nameScore(key, name))
+			}
+		}
+		if best >= minSurahMatchScore {
+			matches = append(matches, SurahMatch{Surah: surah, Score: best})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b SurahMatch) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	return matches
+}
+
+// refWords are the words users may put around the surah name and ayah
+// number of an ayah reference, e.g. "surah" or "آية"
+var refWords = []string{"surah", "sura", "surat", "سورة", "سوره", "сура", "ayah", "aya", "ayat", "آية", "اية", "аят"}
+
+// ParseAyahRef parses a reference to an ayah, as a surah and ayah number,
+// e.g. "2:255", "2 255" or "2.255", or as a surah name and ayah number,
+// e.g. "Al-Baqarah 255" or "سورة البقرة آية 255", the name matched as by
+// FindSurah. It reports false if text is not a reference. The ayah is not
+// validated.
+func ParseAyahRef(text string, lang Language) (Ayah, bool) {
+	text = strings.TrimSpace(toWesternDigits(text))
+	for _, sep := range []string{":", ".", " "} {
+		surah, ayah, ok := strings.Cut(text, sep)
+		if !ok {
+			continue
+		}
+		s, errS := strconv.Atoi(strings.TrimSpace(surah))
+		a, errA := strconv.Atoi(strings.TrimSpace(ayah))
+		if errS == nil && errA == nil {
+			return Ayah{SurahNumber: s, AyahNumber: a}, s > 0 && a > 0
+		}
+	}
+
+	fields := strings.Fields(strings.ReplaceAll(text, ":", " "))
+	if len(fields) < 2 {
+		return Ayah{}, false
+	}
+	number, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || number < 1 {
+		return Ayah{}, false
+	}
+	name := slices.DeleteFunc(fields[:len(fields)-1], func(field string) bool {
+		return slices.Contains(refWords, strings.ToLower(field))
+	})
+	matches := FindSurah(strings.Join(name, " "), lang)
+	if len(name) == 0 || len(matches) == 0 {
+		return Ayah{}, false
+	}
+	return Ayah{SurahNumber: matches[0].Surah.Number, AyahNumber: number}, true
+}
+
+// nameScore rates how well a normalized query matches a normalized name:
+// 1 when equal, 0.9 when the name starts with the query, and by edit
+// distance otherwise
+func nameScore(query, name string) float64 {
+	if query == "" || name == "" {
+		return 0
+	}
+	if query == name {
+		return 1
+	}
+	q, n := []rune(query), []rune(name)
+	if len(q) >= 3 && strings.HasPrefix(name, query) {
+		return 0.9
+	}
+	return 1 - float64(editDistance(q, n))/float64(max(len(q), len(n)))
+}
+
+// WordSimilarity rates how alike two Arabic words are, from 0 to 1, ignoring
+// diacritics and the spellings of the same letter
+func WordSimilarity(a, b string) float64 {
+	x, y := []rune(arabicWord(a)), []rune(arabicWord(b))
+	if len(x) == 0 || len(y) == 0 {
+		return 0
+	}
+	return 1 - float64(editDistance(x, y))/float64(max(len(x), len(y)))
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// transliterate spells the letters of query that are not Latin in Latin,
+// using the table of lang first
+func transliterate(query string, lang Language) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(query) {
+		if latin, ok := transliterations[lang][r]; ok {
+			b.WriteString(latin)
+			continue
+		}
+		found := false
+		for _, table := range transliterations {
+			if latin, ok := table[r]; ok {
+				b.WriteString(latin)
+				found = true
+				break
+			}
+		}
+		if !found {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// latinKeys normalizes a Latin name for comparison, with and without the
+// article it may start with
+func latinKeys(name string) []string {
+	name = strings.ToLower(name)
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !('a' <= r && r <= 'z')
+	})
+	if len(words) == 0 {
+		return nil
+	}
+
+	full := strings.Join(words, "")
+	keys := []string{latinKey(full)}
+	if len(words) > 1 && slices.Contains(latinArticles, words[0]) {
+		keys = append(keys, latinKey(strings.Join(words[1:], "")))
+	} else {
+		// The article may be written without a separator, e.g. "albaqara"
+		for _, article := range latinArticles {
+			if rest, ok := strings.CutPrefix(full, article); ok && len(rest) >= 3 {
+				keys = append(keys, latinKey(rest))
+			}
+		}
+	}
+	return keys
+}
+
+// latinKey collapses the spellings of the same sounds, doubled letters and
+// a final "h"
+func latinKey(name string) string {
+	name = latinSounds.Replace(name)
+	var b strings.Builder
+	var last rune
+	for _, r := range name {
+		if r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	key := b.String()
+	if len(key) > 2 {
+		key = strings.TrimSuffix(key, "h")
+	}
+	return key
+}
+
+// arabicKey normalizes an Arabic name for comparison: without diacritics,
+// tatweel, spaces or the article, and with one form of alef, ta marbuta
+// and ya
+func arabicKey(name string) string {
+	key := arabicWord(name)
+	if rest, ok := strings.CutPrefix(key, "ال"); ok && len([]rune(rest)) >= 2 {
+		key = rest
+	}
+	return key
+}
+
+// arabicWord normalizes Arabic text for comparison: without diacritics,
+// tatweel or spaces, and with one form of alef, ta marbuta and ya
+func arabicWord(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'ً' && r <= 'ْ', r == 'ٰ', r == 'ـ':
+			// diacritics and tatweel
+		case r >= 'ۖ' && r <= 'ۭ':
+			// Quranic annotation marks and small letters of the mushaf
+		case r == 'أ', r == 'إ', r == 'آ', r == 'ٱ':
+			b.WriteRune('ا')
+		case r == 'ة':
+			b.WriteRune('ه')
+		case r == 'ى', r == 'ئ':
+			b.WriteRune('ي')
+		case r == 'ؤ':
+			b.WriteRune('و')
+		case unicode.Is(unicode.Arabic, r) && unicode.IsLetter(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isArabic reports whether s contains Arabic letters
+func isArabic(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Arabic, r) && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// toWesternDigits replaces Arabic-Indic and Persian digits with 0-9
+func toWesternDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '٠' && r <= '٩':
+			return '0' + r - '٠'
+		case r >= '۰' && r <= '۹':
+			return '0' + r - '۰'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// spokenNumbers are the values of the Arabic number words, as normalized by
+// arabicWord (e.g. "مئتين" is "ميتين"), up to the 286 ayahs of the longest
+// surah
+var spokenNumbers = map[string]int{
+	"واحد": 1, "واحده": 1, "احد": 1, "اثنان": 2, "اثنين": 2, "اثنتان": 2, "اثنتين": 2, "اثنا": 2, "اثني": 2,
+	"ثلاث": 3, "ثلاثه": 3, "اربع": 4, "اربعه": 4, "خمس": 5, "خمسه": 5, "ست": 6, "سته": 6,
+	"سبع": 7, "سبعه": 7, "ثمان": 8, "ثماني": 8, "ثمانيه": 8, "تسع": 9, "تسعه": 9, "عشر": 10, "عشره": 10,
+	"عشرون": 20, "عشرين": 20, "ثلاثون": 30, "ثلاثين": 30, "اربعون": 40, "اربعين": 40,
+	"خمسون": 50, "خمسين": 50, "ستون": 60, "ستين": 60, "سبعون": 70, "سبعين": 70,
+	"ثمانون": 80, "ثمانين": 80, "تسعون": 90, "تسعين": 90,
+	"ميه": 100, "مايه": 100, "ميتان": 200, "ميتين": 200, "مايتان": 200, "مايتين": 200,
+	// Ordinals, e.g. "الآية الأولى"
+	"الاولي": 1, "الثانيه": 2, "الثالثه": 3, "الرابعه": 4, "الخامسه": 5,
+	"السادسه": 6, "السابعه": 7, "الثامنه": 8, "التاسعه": 9, "العاشره": 10,
+}
+
+// spokenSurahWords and spokenAyahWords introduce the surah and the ayah of a
+// spoken reference, as normalized by arabicWord
+var (
+	spokenSurahWords = []string{"سوره", "السوره", "سورت"}
+	spokenAyahWords  = []string{"ايه", "الايه", "ايت", "رقم"}
+)
+
+// ParseSpokenAyahRef parses a reference to an ayah said aloud, as
+// transcribed, e.g. "سورة الكهف آية عشرة": a surah name or number, then the
+// ayah number, numbers said in Arabic words, e.g. "خمس وعشرون", or digits.
+// It reports false if text is not a reference. The ayah is not validated.
+func ParseSpokenAyahRef(text string) (Ayah, bool) {
+	var words []string
+	for _, field := range strings.Fields(toWesternDigits(text)) {
+		if _, err := strconv.Atoi(field); err == nil {
+			words = append(words, field)
+		} else if word := arabicWord(field); word != "" {
+			words = append(words, word)
+		}
+	}
+
+	// Split at the word introducing the ayah, or else before the number
+	// the reference ends with
+	surah, ayah := words, []string(nil)
+	if i := slices.IndexFunc(words, func(word string) bool { return slices.Contains(spokenAyahWords, word) }); i >= 0 {
+		surah, ayah = words[:i], words[i+1:]
+	} else {
+		i := len(words)
+		for i > 0 {
+			if _, ok := spokenNumber(words[i-1:]); !ok {
+				break
+			}
+			i--
+		}
+		surah, ayah = words[:i], words[i:]
+	}
+	surah = slices.DeleteFunc(slices.Clone(surah), func(word string) bool { return slices.Contains(spokenSurahWords, word) })
+
+	ayahNumber, ok := spokenNumber(ayah)
+	if !ok || len(surah) == 0 {
+		return Ayah{}, false
+	}
+	if surahNumber, ok := spokenNumber(surah); ok {
+		return Ayah{SurahNumber: surahNumber, AyahNumber: ayahNumber}, true
+	}
+	matches := FindSurah(strings.Join(surah, " "), LangArabic)
+	if len(matches) == 0 {
+		return Ayah{}, false
+	}
+	return Ayah{SurahNumber: matches[0].Surah.Number, AyahNumber: ayahNumber}, true
+}
+
+// spokenNumber adds up the number said in words, e.g. "ثلاثه عشر" or
+// "خمس وعشرون", or written in digits. It reports false if a word is not a
+// number.
+func spokenNumber(words []string) (int, bool) {
+	if len(words) == 0 {
+		return 0, false
+	}
+	total := 0
+	for _, word := range words {
+		if n, err := strconv.Atoi(word); err == nil {
+			total += n
+			continue
+		}
+		n, ok := spokenNumbers[word]
+		if !ok {
+			// "و" joins the parts of a number, e.g. "وعشرون"
+			if rest, joined := strings.CutPrefix(word, "و"); joined {
+				n, ok = spokenNumbers[rest]
+			}
+		}
+		if !ok {
+			return 0, false
+		}
+		total += n
+	}
+	return total, total > 0
+}
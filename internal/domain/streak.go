@@ -0,0 +1,73 @@
+package domain
+
+import "time"
+
+// DayBoundary decides which day a moment falls on for a user: days start at
+// their local midnight, or Offset after it, e.g. 4h so that the night until
+// Fajr counts with the day before
+type DayBoundary struct {
+	Location *time.Location // UTC when nil
+	Offset   time.Duration
+}
+
+// Day returns the user's date of t, at midnight UTC so that days compare
+// and add up the same in every time zone
+func (d DayBoundary) Day(t time.Time) time.Time {
+	y, m, day := d.local(t).Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Start returns the moment the user's day of t started, e.g. to count the
+// recordings of today against a daily goal
+func (d DayBoundary) Start(t time.Time) time.Time {
+	y, m, day := d.local(t).Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, d.location()).Add(d.Offset)
+}
+
+// local returns t on the user's clock, shifted back by Offset so that days
+// start at midnight
+func (d DayBoundary) local(t time.Time) time.Time {
+	return t.In(d.location()).Add(-d.Offset)
+}
+
+func (d DayBoundary) location() *time.Location {
+	if d.Location == nil {
+		return time.UTC
+	}
+	return d.Location
+}
+
+// Streak counts the consecutive days a user recorded on
+type Streak struct {
+	Days int
+	Best int
+	Last time.Time // latest recording
+}
+
+// Record counts a recording made at a time, on the days of a boundary
+func (s *Streak) Record(at time.Time, days DayBoundary) {
+	switch {
+	case s.Last.IsZero():
+		s.Days = 1
+	case !days.Day(at).After(days.Day(s.Last)):
+		// Already counted that day
+	case days.Day(at).Equal(days.Day(s.Last).AddDate(0, 0, 1)):
+		s.Days++
+	default:
+		s.Days = 1
+	}
+
+	s.Best = max(s.Best, s.Days)
+	if at.After(s.Last) {
+		s.Last = at
+	}
+}
+
+// Current returns the streak as of now: Days while the user recorded today
+// or yesterday, and 0 once a whole day went by without a recording
+func (s Streak) Current(now time.Time, days DayBoundary) int {
+	if s.Last.IsZero() || days.Day(now).After(days.Day(s.Last).AddDate(0, 0, 1)) {
+		return 0
+	}
+	return s.Days
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrInvalidTag is returned for tags that are empty or too long
+	ErrInvalidTag = errors.New("invalid tag")
+
+	// ErrTooManyTags is returned when a recording is given more tags than
+	// allowed
+	ErrTooManyTags = errors.New("too many tags")
+)
+
+// Tag limits
+const (
+	MaxTagLength        = 24 // in characters
+	MaxTagsPerRecording = 5
+)
+
+// NormalizeTag turns what a user typed into a tag, e.g. " #With  Teacher"
+// into "with teacher", failing with ErrInvalidTag if nothing or too much is
+// left
+func NormalizeTag(raw string) (string, error) {
+	tag := strings.TrimPrefix(strings.TrimSpace(raw), "#")
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrInvalidTimezone is returned for time zones that are not IANA names
+var ErrInvalidTimezone = errors.New("invalid time zone")
+
+// maxTimezoneSuggestions is the number of time zones suggested at most for
+// an offset
+const maxTimezoneSuggestions = 6
+
+// timezones are the zones suggested for a UTC offset, roughly west to east,
+// several for the offsets most users are at
+var timezones = []string{
+	"Pacific/Honolulu", "America/Anchorage", "America/Los_Angeles", "America/Denver",
+	"America/Chicago", "America/New_York", "America/Toronto", "America/Sao_Paulo",
+	"Atlantic/Azores", "Europe/London", "Africa/Casablanca", "Europe/Paris",
+	"Europe/Berlin", "Africa/Algiers", "Africa/Lagos", "Africa/Cairo",
+	"Europe/Istanbul", "Europe/Moscow", "Asia/Riyadh", "Asia/Baghdad",
+	"Africa/Nairobi", "Asia/Tehran", "Asia/Dubai", "Asia/Baku",
+	"Asia/Kabul", "Asia/Karachi", "Asia/Tashkent", "Asia/Kolkata",
+	"Asia/Dhaka", "Asia/Almaty", "Asia/Jakarta", "Asia/Kuala_Lumpur",
+	"Asia/Shanghai", "Asia/Tokyo", "Australia/Sydney", "Pacific/Auckland",
+}
+
+// ValidTimezone reports whether name is an IANA time zone, e.g.
+// "Europe/Moscow"
+func ValidTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// SuggestTimezones returns the time zones at a UTC offset at a moment,
+// falling back to the fixed "Etc/GMT" zone of whole hours
+func SuggestTimezones(offset time.Duration, at time.Time) []string {
+	var list []string
+	for _, name := range timezones {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			continue
+		}
+		if _, seconds := at.In(location).Zone(); time.Duration(seconds)*time.Second == offset {
+			list = append(list, name)
+		}
+		if len(list) == maxTimezoneSuggestions {
+			break
+		}
+	}
+	if len(list) == 0 && offset%time.Hour == 0 {
+		// Etc zones are named with the sign inverted, e.g. Etc/GMT-3 for
+		// UTC+3
+		hours := int(offset / time.Hour)
+		name := fmt.Sprintf("Etc/GMT%+d", -hours)
+		if hours == 0 {
+			name = "Etc/GMT"
+		}
+		if ValidTimezone(name) {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// LongitudeOffset returns the UTC offset of the solar time at a longitude,
+// to the hour, e.g. 3h in Moscow
+func LongitudeOffset(longitude float64) time.Duration {
+	return time.Duration(math.Round(longitude/15)) * time.Hour
+}
+
+// LocalTimeOffset returns the UTC offset of someone whose clock reads local,
+// e.g. "14:30", at now, to the quarter hour. It reports false if local is
+// not a time.
+func LocalTimeOffset(local string, now time.Time) (time.Duration, bool) {
+	clock, err := time.Parse("15:04", local)
+	if err != nil {
+		return 0, false
+	}
+	now = now.UTC()
+	offset := time.Duration(clock.Hour()-now.Hour())*time.Hour + time.Duration(clock.Minute()-now.Minute())*time.Minute
+	offset = offset.Round(15 * time.Minute)
+	// Offsets range from UTC-12 to UTC+14
+	switch {
+	case offset < -12*time.Hour:
+		offset += 24 * time.Hour
+	case offset > 14*time.Hour:
+		offset -= 24 * time.Hour
+	}
+	return offset, true
+}
@@ -0,0 +1,36 @@
+package domain
+
+// transitions lists the states each state may move to. Surah selection is
+// reachable from everywhere since it is where every flow restarts.
+var transitions = map[State][]State{
+	StateStart:         {StateSelectSurah, StateEnterAyah},
+	StateSelectSurah:   {StateSelectSurah, StateEnterAyah},
+	StateEnterAyah:     {StateSelectSurah, StateEnterAyah, StateWaitRecording},
+	StateWaitRecording: {StateSelectSurah, StateEnterAyah, StateWaitRecording, StateProcessing},
+	StateProcessing:    {StateSelectSurah, StateWaitRecording},
+}
+
+// InFlow reports whether a session in this state is in the middle of a
+// recitation, so that losing it interrupts the user
+func (s State) InFlow() bool {
+	switch s {
+	case StateEnterAyah, StateWaitRecording, StateProcessing:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether a session may move from one state to another.
+// Unknown states, e.g. from an older release, may only restart the flow.
+func CanTransition(from, to State) bool {
+	allowed, ok := transitions[from]
+	if !ok {
+		return to == StateSelectSurah
+	}
+	for _, state := range allowed {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
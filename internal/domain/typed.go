@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoAyahText is returned when the text of an ayah is not known, e.g. for a
+// riwayah without a text configured
+var ErrNoAyahText = errors.New("no ayah text")
+
+// typedMatchSimilarity is how alike a typed word must be to the word of the
+// ayah to count as correct, which forgives a typo in a long word
+const typedMatchSimilarity = 0.75
+
+// CheckTypedAyah aligns the words typed from memory with the words of the
+// reference text of the ayah, and grades them like a recitation: each word
+// is correct, substituted, missing or extra. Diacritics, the spellings of a
+// letter and the marks of the mushaf are ignored.
+func CheckTypedAyah(reference, typed string) RecordingResult {
+	ref, hyp := textWords(reference), textWords(typed)
+
+	// cost[i][j] is the edit distance between the first i words of the
+	// reference and the first j words typed
+	cost := make([][]int, len(ref)+1)
+	for i := range cost {
+		cost[i] = make([]int, len(hyp)+1)
+		cost[i][0] = i
+	}
+	for j := range cost[0] {
+		cost[0][j] = j
+	}
+	for i := 1; i <= len(ref); i++ {
+		for j := 1; j <= len(hyp); j++ {
+			sub := 1
+			if ref[i-1].matches(hyp[j-1]) {
+				sub = 0
+			}
+			cost[i][j] = min(cost[i-1][j]+1, cost[i][j-1]+1, cost[i-1][j-1]+sub)
+		}
+	}
+
+	// Walk back from the end, preferring substitutions so that a wrong word
+	// is shown in place rather than as a missing and an extra one
+	var ops []Operation
+	mistakes := 0
+	for i, j := len(ref), len(hyp); i > 0 || j > 0; {
+		switch {
+		case i > 0 && j > 0 && ref[i-1].matches(hyp[j-1]) && cost[i][j] == cost[i-1][j-1]:
+			ops = append(ops, ref[i-1].op(OpCorrect, hyp[j-1]))
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && cost[i][j] == cost[i-1][j-1]+1:
+			ops = append(ops, ref[i-1].op(OpSubstitution, hyp[j-1]))
+			i, j = i-1, j-1
+			mistakes++
+		case i > 0 && cost[i][j] == cost[i-1][j]+1:
+			ops = append(ops, ref[i-1].op(OpDeletion, textWord{}))
+			i--
+			mistakes++
+		default:
+			ops = append(ops, textWord{}.op(OpInsertion, hyp[j-1]))
+			j--
+			mistakes++
+		}
+	}
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+
+	result := RecordingResult{Ops: ops, Hypothesis: strings.TrimSpace(typed)}
+	if len(ref) > 0 {
+		result.WER = float64(mistakes) / float64(len(ref))
+	}
+	return result
+}
+
+// textWord is a word of a text as written and normalized for comparison
+type textWord struct {
+	ar, clean string
+}
+
+// textWords splits text into words, dropping the marks of the mushaf that
+// stand alone, e.g. the signs of pause
+func textWords(text string) []textWord {
+	var words []textWord
+	for _, field := range strings.Fields(text) {
+		if clean := arabicWord(field); clean != "" {
+			words = append(words, textWord{ar: field, clean: clean})
+		}
+	}
+	return words
+}
+
+func (w textWord) matches(typed textWord) bool {
+	return w.clean == typed.clean || WordSimilarity(w.ar, typed.ar) >= typedMatchSimilarity
+}
+
+// op returns the operation of the reference word w against the typed word
+func (w textWord) op(t OpType, typed textWord) Operation {
+	return Operation{RefAr: w.ar, RefClean: w.clean, HypAr: typed.ar, HypClean: typed.clean, Op: t}
+}
@@ -1,128 +1,213 @@
 package domain
 
-import "fmt"
+import "slices"
 
-// FormatAyahID formats surah and ayah numbers into XXXYYY format
-func FormatAyahID(surahNumber, ayahNumber int) string {
-	return fmt.Sprintf("%03d%03d", surahNumber, ayahNumber)
+// surahData lists the surahs in order, with their pages in the Madani
+// mushaf of 604 pages
+var surahData = [114]struct {
+	name       string
+	arabicName string
+	ayahs      int
+	revelation Revelation
+	pages      Range
+}{
+	{"Al-Fatihah", "الفاتحة", 7, RevelationMeccan, Range{1, 1}},
+	{"Al-Baqarah", "البقرة", 286, RevelationMedinan, Range{2, 49}},
+	{"Aal-E-Imran", "آل عمران", 200, RevelationMedinan, Range{50, 76}},
+	{"An-Nisa", "النساء", 176, RevelationMedinan, Range{77, 106}},
+	{"Al-Ma'idah", "المائدة", 120, RevelationMedinan, Range{106, 127}},
+	{"Al-An'am", "الأنعام", 165, RevelationMeccan, Range{128, 150}},
+	{"Al-A'raf", "الأعراف", 206, RevelationMeccan, Range{151, 176}},
+	{"Al-Anfal", "الأنفال", 75, RevelationMedinan, Range{177, 186}},
+	{"At-Tawbah", "التوبة", 129, RevelationMedinan, Range{187, 207}},
+	{"Yunus", "يونس", 109, RevelationMeccan, Range{208, 221}},
+	{"Hud", "هود", 123, RevelationMeccan, Range{221, 235}},
+	{"Yusuf", "يوسف", 111, RevelationMeccan, Range{235, 248}},
+	{"Ar-Ra'd", "الرعد", 43, RevelationMedinan, Range{249, 255}},
+	{"Ibrahim", "إبراهيم", 52, RevelationMeccan, Range{255, 261}},
+	{"Al-Hijr", "الحجر", 99, RevelationMeccan, Range{262, 267}},
+	{"An-Nahl", "النحل", 128, RevelationMeccan, Range{267, 281}},
+	{"Al-Isra", "الإسراء", 111, RevelationMeccan, Range{282, 293}},
+	{"Al-Kahf", "الكهف", 110, RevelationMeccan, Range{293, 304}},
+	{"Maryam", "مريم", 98, RevelationMeccan, Range{305, 312}},
+	{"Ta-Ha", "طه", 135, RevelationMeccan, Range{312, 321}},
+	{"Al-Anbiya", "الأنبياء", 112, RevelationMeccan, Range{322, 331}},
+	{"Al-Hajj", "الحج", 78, RevelationMedinan, Range{332, 341}},
+	{"Al-Mu'minun", "المؤمنون", 118, RevelationMeccan, Range{342, 349}},
+	{"An-Nur", "النور", 64, RevelationMedinan, Range{350, 359}},
+	{"Al-Furqan", "الفرقان", 77, RevelationMeccan, Range{359, 366}},
+	{"Ash-Shu'ara", "الشعراء", 227, RevelationMeccan, Range{367, 376}},
+	{"An-Naml", "النمل", 93, RevelationMeccan, Range{377, 385}},
+	{"Al-Qasas", "القصص", 88, RevelationMeccan, Range{385, 396}},
+	{"Al-Ankabut", "العنكبوت", 69, RevelationMeccan, Range{396, 404}},
+	{"Ar-Rum", "الروم", 60, RevelationMeccan, Range{404, 410}},
+	{"Luqman", "لقمان", 34, RevelationMeccan, Range{411, 414}},
+	{"As-Sajdah", "السجدة", 30, RevelationMeccan, Range{415, 417}},
+	{"Al-Ahzab", "الأحزاب", 73, RevelationMedinan, Range{418, 427}},
+	{"Saba", "سبأ", 54, RevelationMeccan, Range{428, 434}},
+	{"Fatir", "فاطر", 45, RevelationMeccan, Range{434, 440}},
+	{"Ya-Sin", "يس", 83, RevelationMeccan, Range{440, 445}},
+	{"As-Saffat", "الصافات", 182, RevelationMeccan, Range{446, 452}},
+	{"Sad", "ص", 88, RevelationMeccan, Range{453, 458}},
+	{"Az-Zumar", "الزمر", 75, RevelationMeccan, Range{458, 467}},
+	{"Ghafir", "غافر", 85, RevelationMeccan, Range{467, 476}},
+	{"Fussilat", "فصلت", 54, RevelationMeccan, Range{477, 482}},
+	{"Ash-Shura", "الشورى", 53, RevelationMeccan, Range{483, 489}},
+	{"Az-Zukhruf", "الزخرف", 89, RevelationMeccan, Range{489, 495}},
+	{"Ad-Dukhan", "الدخان", 59, RevelationMeccan, Range{496, 498}},
+	{"Al-Jathiyah", "الجاثية", 37, RevelationMeccan, Range{499, 502}},
+	{"Al-Ahqaf", "الأحقاف", 35, RevelationMeccan, Range{502, 506}},
+	{"Muhammad", "محمد", 38, RevelationMedinan, Range{507, 510}},
+	{"Al-Fath", "الفتح", 29, RevelationMedinan, Range{511, 515}},
+	{"Al-Hujurat", "الحجرات", 18, RevelationMedinan, Range{515, 517}},
+	{"Qaf", "ق", 45, RevelationMeccan, Range{518, 520}},
+	{"Adh-Dhariyat", "الذاريات", 60, RevelationMeccan, Range{520, 523}},
+	{"At-Tur", "الطور", 49, RevelationMeccan, Range{523, 525}},
+	{"An-Najm", "النجم", 62, RevelationMeccan, Range{526, 528}},
+	{"Al-Qamar", "القمر", 55, RevelationMeccan, Range{528, 531}},
+	{"Ar-Rahman", "الرحمن", 78, RevelationMedinan, Range{531, 534}},
+	{"Al-Waqi'ah", "الواقعة", 96, RevelationMeccan, Range{534, 537}},
+	{"Al-Hadid", "الحديد", 29, RevelationMedinan, Range{537, 541}},
+	{"Al-Mujadila", "المجادلة", 22, RevelationMedinan, Range{542, 545}},
+	{"Al-Hashr", "الحشر", 24, RevelationMedinan, Range{545, 548}},
+	{"Al-Mumtahanah", "الممتحنة", 13, RevelationMedinan, Range{549, 551}},
+	{"As-Saf", "الصف", 14, RevelationMedinan, Range{551, 552}},
+	{"Al-Jumu'ah", "الجمعة", 11, RevelationMedinan, Range{553, 554}},
+	{"Al-Munafiqun", "المنافقون", 11, RevelationMedinan, Range{554, 555}},
+	{"At-Taghabun", "التغابن", 18, RevelationMedinan, Range{556, 557}},
+	{"At-Talaq", "الطلاق", 12, RevelationMedinan, Range{558, 559}},
+	{"At-Tahrim", "التحريم", 12, RevelationMedinan, Range{560, 561}},
+	{"Al-Mulk", "الملك", 30, RevelationMeccan, Range{562, 564}},
+	{"Al-Qalam", "القلم", 52, RevelationMeccan, Range{564, 566}},
+	{"Al-Haqqah", "الحاقة", 52, RevelationMeccan, Range{566, 568}},
+	{"Al-Ma'arij", "المعارج", 44, RevelationMeccan, Range{568, 570}},
+	{"Nuh", "نوح", 28, RevelationMeccan, Range{570, 571}},
+	{"Al-Jinn", "الجن", 28, RevelationMeccan, Range{572, 573}},
+	{"Al-Muzzammil", "المزمل", 20, RevelationMeccan, Range{574, 575}},
+	{"Al-Muddaththir", "المدثر", 56, RevelationMeccan, Range{575, 577}},
+	{"Al-Qiyamah", "القيامة", 40, RevelationMeccan, Range{577, 578}},
+	{"Al-Insan", "الإنسان", 31, RevelationMedinan, Range{578, 580}},
+	{"Al-Mursalat", "المرسلات", 50, RevelationMeccan, Range{580, 581}},
+	{"An-Naba", "النبأ", 40, RevelationMeccan, Range{582, 583}},
+	{"An-Nazi'at", "النازعات", 46, RevelationMeccan, Range{583, 584}},
+	{"Abasa", "عبس", 42, RevelationMeccan, Range{585, 585}},
+	{"At-Takwir", "التكوير", 29, RevelationMeccan, Range{586, 586}},
+	{"Al-Infitar", "الانفطار", 19, RevelationMeccan, Range{587, 587}},
+	{"Al-Mutaffifin", "المطففين", 36, RevelationMeccan, Range{587, 589}},
+	{"Al-Inshiqaq", "الانشقاق", 25, RevelationMeccan, Range{589, 589}},
+	{"Al-Buruj", "البروج", 22, RevelationMeccan, Range{590, 590}},
+	{"At-Tariq", "الطارق", 17, RevelationMeccan, Range{591, 591}},
+	{"Al-A'la", "الأعلى", 19, RevelationMeccan, Range{591, 592}},
+	{"Al-Ghashiyah", "الغاشية", 26, RevelationMeccan, Range{592, 592}},
+	{"Al-Fajr", "الفجر", 30, RevelationMeccan, Range{593, 594}},
+	{"Al-Balad", "البلد", 20, RevelationMeccan, Range{594, 594}},
+	{"Ash-Shams", "الشمس", 15, RevelationMeccan, Range{595, 595}},
+	{"Al-Layl", "الليل", 21, RevelationMeccan, Range{595, 596}},
+	{"Ad-Duha", "الضحى", 11, RevelationMeccan, Range{596, 596}},
+	{"Ash-Sharh", "الشرح", 8, RevelationMeccan, Range{596, 596}},
+	{"At-Tin", "التين", 8, RevelationMeccan, Range{597, 597}},
+	{"Al-Alaq", "العلق", 19, RevelationMeccan, Range{597, 597}},
+	{"Al-Qadr", "القدر", 5, RevelationMeccan, Range{598, 598}},
+	{"Al-Bayyinah", "البينة", 8, RevelationMedinan, Range{598, 599}},
+	{"Az-Zalzalah", "الزلزلة", 8, RevelationMedinan, Range{599, 599}},
+	{"Al-Adiyat", "العاديات", 11, RevelationMeccan, Range{599, 600}},
+	{"Al-Qari'ah", "القارعة", 11, RevelationMeccan, Range{600, 600}},
+	{"At-Takathur", "التكاثر", 8, RevelationMeccan, Range{600, 600}},
+	{"Al-Asr", "العصر", 3, RevelationMeccan, Range{601, 601}},
+	{"Al-Humazah", "الهمزة", 9, RevelationMeccan, Range{601, 601}},
+	{"Al-Fil", "الفيل", 5, RevelationMeccan, Range{601, 601}},
+	{"Quraysh", "قريش", 4, RevelationMeccan, Range{602, 602}},
+	{"Al-Ma'un", "الماعون", 7, RevelationMeccan, Range{602, 602}},
+	{"Al-Kawthar", "الكوثر", 3, RevelationMeccan, Range{602, 602}},
+	{"Al-Kafirun", "الكافرون", 6, RevelationMeccan, Range{603, 603}},
+	{"An-Nasr", "النصر", 3, RevelationMedinan, Range{603, 603}},
+	{"Al-Masad", "المسد", 5, RevelationMeccan, Range{603, 603}},
+	{"Al-Ikhlas", "الإخلاص", 4, RevelationMeccan, Range{604, 604}},
+	{"Al-Falaq", "الفلق", 5, RevelationMeccan, Range{604, 604}},
+	{"An-Nas", "الناس", 6, RevelationMeccan, Range{604, 604}},
 }
 
+// warshAyahs are the ayah counts of the surahs in the Madani count of
+// Warsh, by surah number - 1
+var warshAyahs = [114]int{
+	7, 285, 200, 175, 122, 167, 206, 76, 130, 109, 122, 111, 44, 54, 99,
+	128, 110, 105, 99, 134, 111, 76, 119, 62, 77, 227, 95, 88, 69, 59,
+	33, 30, 73, 54, 46, 82, 182, 86, 72, 84, 53, 50, 89, 56, 36,
+	34, 40, 29, 18, 45, 60, 47, 61, 55, 77, 99, 28, 21, 24, 13,
+	14, 11, 11, 18, 11, 12, 30, 52, 52, 44, 30, 28, 18, 55, 39,
+	31, 50, 40, 45, 41, 29, 19, 36, 25, 22, 17, 19, 26, 32, 20,
+	16, 21, 11, 8, 8, 20, 5, 8, 9, 11, 10, 8, 3, 9, 5,
+	4, 6, 3, 6, 3, 5, 4, 5, 6,
+}
+
+// AyahCount returns the number of ayahs of the surah in a riwayah
+func (s Surah) AyahCount(riwayah Riwayah) int {
+	if riwayah == RiwayahWarsh {
+		return warshAyahs[s.Number-1]
+	}
+	return s.Ayahs
+}
+
+// juzStarts are the first ayah of each of the 30 juz
+var juzStarts = [30]Ayah{
+	{1, 1}, {2, 142}, {2, 253}, {3, 93}, {4, 24}, {4, 148}, {5, 82}, {6, 111}, {7, 88}, {8, 41},
+	{9, 93}, {11, 6}, {12, 53}, {15, 1}, {17, 1}, {18, 75}, {21, 1}, {23, 1}, {25, 21}, {27, 56},
+	{29, 46}, {33, 31}, {36, 28}, {39, 32}, {41, 47}, {46, 1}, {51, 31}, {58, 1}, {67, 1}, {78, 1},
+}
+
+// surahs is the metadata of every surah, indexed by number - 1
+var surahs = func() []Surah {
+	list := make([]Surah, len(surahData))
+	for i, d := range surahData {
+		number := i + 1
+		list[i] = Surah{
+			Number:     number,
+			Name:       d.name,
+			Ayahs:      d.ayahs,
+			ArabicName: d.arabicName,
+			Revelation: d.revelation,
+			Juz:        Range{JuzOf(number, 1), JuzOf(number, d.ayahs)},
+			Pages:      d.pages,
+		}
+	}
+	return list
+}()
+
 // GetAllSurahs returns a list of all 114 Surahs in the Quran
 func GetAllSurahs() []Surah {
-	return []Surah{
-		{1, "Al-Fatihah", 7},
-		{2, "Al-Baqarah", 286},
-		{3, "Aal-E-Imran", 200},
-		{4, "An-Nisa", 176},
-		{5, "Al-Ma'idah", 120},
-		{6, "Al-An'am", 165},
-		{7, "Al-A'raf", 206},
-		{8, "Al-Anfal", 75},
-		{9, "At-Tawbah", 129},
-		{10, "Yunus", 109},
-		{11, "Hud", 123},
-		{12, "Yusuf", 111},
-		{13, "Ar-Ra'd", 43},
-		{14, "Ibrahim", 52},
-		{15, "Al-Hijr", 99},
-		{16, "An-Nahl", 128},
-		{17, "Al-Isra", 111},
-		{18, "Al-Kahf", 110},
-		{19, "Maryam", 98},
-		{20, "Ta-Ha", 135},
-		{21, "Al-Anbiya", 112},
-		{22, "Al-Hajj", 78},
-		{23, "Al-Mu'minun", 118},
-		{24, "An-Nur", 64},
-		{25, "Al-Furqan", 77},
-		{26, "Ash-Shu'ara", 227},
-		{27, "An-Naml", 93},
-		{28, "Al-Qasas", 88},
-		{29, "Al-Ankabut", 69},
-		{30, "Ar-Rum", 60},
-		{31, "Luqman", 34},
-		{32, "As-Sajdah", 30},
-		{33, "Al-Ahzab", 73},
-		{34, "Saba", 54},
-		{35, "Fatir", 45},
-		{36, "Ya-Sin", 83},
-		{37, "As-Saffat", 182},
-		{38, "Sad", 88},
-		{39, "Az-Zumar", 75},
-		{40, "Ghafir", 85},
-		{41, "Fussilat", 54},
-		{42, "Ash-Shura", 53},
-		{43, "Az-Zukhruf", 89},
-		{44, "Ad-Dukhan", 59},
-		{45, "Al-Jathiyah", 37},
-		{46, "Al-Ahqaf", 35},
-		{47, "Muhammad", 38},
-		{48, "Al-Fath", 29},
-		{49, "Al-Hujurat", 18},
-		{50, "Qaf", 45},
-		{51, "Adh-Dhariyat", 60},
-		{52, "At-Tur", 49},
-		{53, "An-Najm", 62},
-		{54, "Al-Qamar", 55},
-		{55, "Ar-Rahman", 78},
-		{56, "Al-Waqi'ah", 96},
-		{57, "Al-Hadid", 29},
-		{58, "Al-Mujadila", 22},
-		{59, "Al-Hashr", 24},
-		{60, "Al-Mumtahanah", 13},
-		{61, "As-Saf", 14},
-		{62, "Al-Jumu'ah", 11},
-		{63, "Al-Munafiqun", 11},
-		{64, "At-Taghabun", 18},
-		{65, "At-Talaq", 12},
-		{66, "At-Tahrim", 12},
-		{67, "Al-Mulk", 30},
-		{68, "Al-Qalam", 52},
-		{69, "Al-Haqqah", 52},
-		{70, "Al-Ma'arij", 44},
-		{71, "Nuh", 28},
-		{72, "Al-Jinn", 28},
-		{73, "Al-Muzzammil", 20},
-		{74, "Al-Muddaththir", 56},
-		{75, "Al-Qiyamah", 40},
-		{76, "Al-Insan", 31},
-		{77, "Al-Mursalat", 50},
-		{78, "An-Naba", 40},
-		{79, "An-Nazi'at", 46},
-		{80, "Abasa", 42},
-		{81, "At-Takwir", 29},
-		{82, "Al-Infitar", 19},
-		{83, "Al-Mutaffifin", 36},
-		{84, "Al-Inshiqaq", 25},
-		{85, "Al-Buruj", 22},
-		{86, "At-Tariq", 17},
-		{87, "Al-A'la", 19},
-		{88, "Al-Ghashiyah", 26},
-		{89, "Al-Fajr", 30},
-		{90, "Al-Balad", 20},
-		{91, "Ash-Shams", 15},
-		{92, "Al-Layl", 21},
-		{93, "Ad-Duha", 11},
-		{94, "Ash-Sharh", 8},
-		{95, "At-Tin", 8},
-		{96, "Al-Alaq", 19},
-		{97, "Al-Qadr", 5},
-		{98, "Al-Bayyinah", 8},
-		{99, "Az-Zalzalah", 8},
-		{100, "Al-Adiyat", 11},
-		{101, "Al-Qari'ah", 11},
-		{102, "At-Takathur", 8},
-		{103, "Al-Asr", 3},
-		{104, "Al-Humazah", 9},
-		{105, "Al-Fil", 5},
-		{106, "Quraysh", 4},
-		{107, "Al-Ma'un", 7},
-		{108, "Al-Kawthar", 3},
-		{109, "Al-Kafirun", 6},
-		{110, "An-Nasr", 3},
-		{111, "Al-Masad", 5},
-		{112, "Al-Ikhlas", 4},
-		{113, "Al-Falaq", 5},
-		{114, "An-Nas", 6},
+	return slices.Clone(surahs)
+}
+
+// JuzOf returns the juz an ayah is in
+func JuzOf(surahNumber, ayahNumber int) int {
+	juz := 1
+	for i, start := range juzStarts {
+		if start.SurahNumber < surahNumber || (start.SurahNumber == surahNumber && start.AyahNumber <= ayahNumber) {
+			juz = i + 1
+		}
+	}
+	return juz
+}
+
+// SurahByPage returns the surah a page of the Madani mushaf starts in, or
+// false if there is no such page
+func SurahByPage(page int) (Surah, bool) {
+	for _, surah := range surahs {
+		if surah.Pages.Contains(page) {
+			return surah, true
+		}
+	}
+	return Surah{}, false
+}
+
+// SurahsInJuz returns the surahs with ayahs in a juz, in order, or none if
+// there is no such juz
+func SurahsInJuz(juz int) []Surah {
+	var list []Surah
+	for _, surah := range surahs {
+		if surah.Juz.Contains(juz) {
+			list = append(list, surah)
+		}
 	}
+	return list
 }
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+)
+
+const (
+	// WeakAyahAccuracy is the accuracy under which the last attempt at an
+	// ayah makes it weak
+	WeakAyahAccuracy = 0.9
+
+	// MaxWeakAyahs is the number of weak ayahs listed at most
+	MaxWeakAyahs = 10
+)
+
+// WeakAyah is an ayah the user last recited with a low accuracy
+type WeakAyah struct {
+	Ayah     Ayah
+	Accuracy float64 // of the last attempt
+	Attempts int
+}
+
+// WeakAyahs returns the ayahs of a history, oldest first, whose last attempt
+// is under WeakAyahAccuracy, weakest first, at most limit of them. Ties go to
+// the ayahs attempted most, then in mushaf order. Entries with malformed
+// ayah IDs are skipped.
+func WeakAyahs(stats []StatEntry, limit int) []WeakAyah {
+	byAyah := make(map[Ayah]*WeakAyah)
+	for _, stat := range stats {
+		ayah, err := ParseAyahID(stat.AyahID)
+		if err != nil {
+			continue
+		}
+		weak, ok := byAyah[ayah]
+		if !ok {
+			weak = &WeakAyah{Ayah: ayah}
+			byAyah[ayah] = weak
+		}
+		weak.Accuracy = stat.Accuracy
+		weak.Attempts++
+	}
+
+	var list []WeakAyah
+	for _, weak := range byAyah {
+		if weak.Accuracy < WeakAyahAccuracy {
+			list = append(list, *weak)
+		}
+	}
+	slices.SortFunc(list, func(a, b WeakAyah) int {
+		return cmp.Or(
+			cmp.Compare(a.Accuracy, b.Accuracy),
+			b.Attempts-a.Attempts,
+			a.Ayah.Compare(b.Ayah),
+		)
+	})
+	return list[:min(len(list), limit)]
+}
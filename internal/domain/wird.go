@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// WirdPlan is the reading plan the ayah of a daily wird follows
+type WirdPlan string
+
+const (
+	// WirdSequential goes through the mushaf an ayah a day, from Al-Fatiha
+	WirdSequential WirdPlan = "sequential"
+	// WirdJuzMonth sends the first ayah of the juz numbered after the day of
+	// the month, so a month goes through the 30 juz
+	WirdJuzMonth WirdPlan = "juz_month"
+	// WirdCurated goes through a list of ayahs chosen by the operator
+	WirdCurated WirdPlan = "curated"
+)
+
+// WirdPlans are the plans offered to users, in order
+var WirdPlans = []WirdPlan{WirdSequential, WirdJuzMonth, WirdCurated}
+
+// ValidWirdPlan reports whether plan is a known plan
+func ValidWirdPlan(plan WirdPlan) bool {
+	return slices.Contains(WirdPlans, plan)
+}
+
+// Wird is a user's subscription to a daily ayah. Days are the user's, at
+// midnight UTC as returned by DayBoundary.Day.
+type Wird struct {
+	UserID string    `json:"user_id"`
+	Plan   WirdPlan  `json:"plan"`
+	Start  time.Time `json:"start"`   // day the user subscribed
+	SentAt time.Time `json:"sent_at"` // day of the last ayah sent, zero if none
+}
+
+// Due reports whether the ayah of a day is still to be sent
+func (w Wird) Due(today time.Time) bool {
+	return today.After(w.SentAt)
+}
+
+// Ayah returns the ayah of a day in a riwayah. The curated plan goes
+// through curated, skipping ayahs the riwayah does not number, and falls
+// back to the sequential plan when none is left.
+func (w Wird) Ayah(today time.Time, riwayah Riwayah, curated []Ayah) Ayah {
+	day := max(daysBetween(w.Start, today), 0)
+	switch w.Plan {
+	case WirdJuzMonth:
+		return juzStarts[min(today.Day(), len(juzStarts))-1]
+	case WirdCurated:
+		valid := slices.DeleteFunc(slices.Clone(curated), func(a Ayah) bool {
+			return a.Validate(riwayah) != nil
+		})
+		if len(valid) > 0 {
+			return valid[day%len(valid)]
+		}
+	}
+	return ayahAt(day, riwayah)
+}
@@ -0,0 +1,159 @@
+// Package metrics keeps latency histograms of the bot and exports them in
+// the Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Latencies of the bot's features
+var (
+	CommandDuration = NewHistogram(
+		"quranbot_command_duration_seconds",
+		"Time to handle a command.",
+		"command",
+	)
+	CallbackDuration = NewHistogram(
+		"quranbot_callback_duration_seconds",
+		"Time to handle a button press, by callback data prefix.",
+		"callback",
+	)
+	VoiceStageDuration = NewHistogram(
+		"quranbot_voice_stage_duration_seconds",
+		"Time spent in each stage of a voice message: queue, download, convert and submit.",
+		"stage",
+	)
+)
+
+// buckets are the upper bounds, in seconds, of the histogram buckets, from
+// instant replies up to slow conversions
+var buckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Histogram
+)
+
+// Histogram counts durations in buckets, separately for every value of a
+// label
+type Histogram struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the histogram of one label value
+type series struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a histogram exported by Handler
+func NewHistogram(name, help, label string) *Histogram {
+	h := &Histogram{
+		name:   name,
+		help:   help,
+		label:  label,
+		series: make(map[string]*series),
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, h)
+	return h
+}
+
+// Observe records a duration for a label value
+func (h *Histogram) Observe(value string, d time.Duration) {
+	seconds := d.Seconds()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[value]
+	if !ok {
+		s = &series{counts: make([]uint64, len(buckets))}
+		h.series[value] = s
+	}
+	if i, _ := slices.BinarySearch(buckets, seconds); i < len(buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += seconds
+}
+
+// Since records the time elapsed since start for a label value
+func (h *Histogram) Since(value string, start time.Time) {
+	h.Observe(value, time.Since(start))
+}
+
+// write writes the histogram in the Prometheus text format, label values in
+// order
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+
+	values := make([]string, 0, len(h.series))
+	for value := range h.series {
+		values = append(values, value)
+	}
+	slices.Sort(values)
+
+	for _, value := range values {
+		s := h.series[value]
+		label := fmt.Sprintf("%s=%q", h.label, escapeLabel(value))
+
+		var cumulative uint64
+		for i, bound := range buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", h.name, label, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, label, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, label, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, label, s.count)
+	}
+}
+
+// escapeLabel drops the characters %q would escape differently than the
+// Prometheus format
+func escapeLabel(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// Write writes every histogram in the Prometheus text format
+func Write(w io.Writer) {
+	registryMu.Lock()
+	histograms := slices.Clone(registry)
+	registryMu.Unlock()
+
+	for _, h := range histograms {
+		h.write(w)
+	}
+}
+
+// Handler serves every histogram in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
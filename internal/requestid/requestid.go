@@ -0,0 +1,44 @@
+// Package requestid tags everything done for one Telegram update with a
+// short random ID: log lines, Quran API requests and the error messages
+// users see, so a complaint quoting the ID can be traced through the logs.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// Header carries the request ID on outgoing HTTP requests
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// New returns a random 8 character ID, short enough for users to quote
+func New() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// With returns a copy of ctx carrying id
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// From returns the ID carried by ctx, empty if none
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Printf logs like log.Printf, prefixed with the ID carried by ctx if any
+func Printf(ctx context.Context, format string, args ...any) {
+	if id := From(ctx); id != "" {
+		log.Output(2, fmt.Sprintf("[req %s] "+format, append([]any{id}, args...)...))
+		return
+	}
+	log.Output(2, fmt.Sprintf(format, args...))
+}